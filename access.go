@@ -0,0 +1,102 @@
+package swarmgo
+
+import "context"
+
+// Principal identifies the user a run acts on behalf of
+type Principal struct {
+	ID          string                 // Identifier of the user or service
+	Roles       []string               // Roles granted to the principal
+	Permissions []string               // Permissions granted to the principal
+	Claims      map[string]interface{} // Additional claims, e.g. from an identity token
+}
+
+// HasPermission reports whether the principal was granted the permission
+func (p *Principal) HasPermission(permission string) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasRole reports whether the principal was granted the role
+func (p *Principal) HasRole(role string) bool {
+	if p == nil {
+		return false
+	}
+	for _, granted := range p.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying the principal for runs started with it
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal carried by the context, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	principal, ok := ctx.Value(principalKey{}).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// WithPermissions returns a copy of the function that may only be used by principals
+// holding all of the given permissions
+func (af AgentFunction[I]) WithPermissions(permissions ...string) AgentFunction[I] {
+	af.Permissions = append(append([]string{}, af.Permissions...), permissions...)
+	return af
+}
+
+// WithRoles returns a copy of the function that may only be used by principals holding
+// one of the given roles
+func (af AgentFunction[I]) WithRoles(roles ...string) AgentFunction[I] {
+	af.Roles = append(append([]string{}, af.Roles...), roles...)
+	return af
+}
+
+// authorized reports whether the principal may use the function: it must hold all of the
+// function's permissions and, if the function requires roles, one of them. Functions without
+// requirements are available to everyone, including runs without a principal.
+func authorized[I any](principal *Principal, af *AgentFunction[I]) bool {
+	for _, permission := range af.Permissions {
+		if !principal.HasPermission(permission) {
+			return false
+		}
+	}
+	if len(af.Roles) == 0 {
+		return true
+	}
+	for _, role := range af.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// availableFunctions returns the functions the principal carried by ctx may use and whose
+// feature flags are enabled for the run
+func (a *Agent) availableFunctions(ctx context.Context) []AgentFunction[map[string]interface{}] {
+	principal, _ := PrincipalFromContext(ctx)
+	return a.functionsFor(ctx, principal)
+}
+
+// functionsFor returns the functions the principal may use whose feature flags are enabled
+func (a *Agent) functionsFor(ctx context.Context, principal *Principal) []AgentFunction[map[string]interface{}] {
+	var functions []AgentFunction[map[string]interface{}]
+	for _, af := range a.allFunctions() {
+		if authorized(principal, &af) && flagEnabled(ctx, af.Flag) {
+			functions = append(functions, af)
+		}
+	}
+	return functions
+}
@@ -1,8 +1,11 @@
 package swarmgo
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sync"
 
 	"github.com/invopop/jsonschema"
 	"github.com/prathyushnallamothu/swarmgo/llm"
@@ -10,25 +13,55 @@ import (
 
 // Agent represents an entity with specific attributes and behaviors.
 type Agent struct {
-	Name              string                                               // The name of the agent.
-	Model             string                                               // The model identifier.
-	Provider          llm.LLMProvider                                      // The LLM provider to use.
-	Config            *ClientConfig                                        // Provider-specific configuration.
-	Instructions      string                                               // Static instructions for the agent.
-	InstructionsFunc  func(contextVariables map[string]interface{}) string // Function to generate dynamic instructions based on context.
-	Functions         []AgentFunction[map[string]interface{}]              // A list of functions the agent can perform.
-	Memory            *MemoryStore                                         // Memory store for the agent.
-	ParallelToolCalls bool                                                 // Whether to allow parallel tool calls.
+	Name               string                                               // The name of the agent.
+	Model              string                                               // The model identifier.
+	Provider           llm.LLMProvider                                      // The LLM provider to use.
+	Config             *ClientConfig                                        // Provider-specific configuration.
+	Instructions       string                                               // Static instructions for the agent.
+	InstructionsFunc   func(contextVariables map[string]interface{}) string // Function to generate dynamic instructions based on context.
+	Functions          []AgentFunction[map[string]interface{}]              // A list of functions the agent can perform.
+	Memory             *MemoryStore                                         // Memory store for the agent.
+	ParallelToolCalls  bool                                                 // Whether to allow parallel tool calls.
+	KeepRaw            bool                                                 // Whether to retain raw provider responses on messages, streamed or not. Gemini does not support it.
+	RepairToolHistory  bool                                                 // Whether to repair mismatched tool messages instead of failing.
+	Skills             []*Skill                                             // Skills composed into the agent's tools and instructions.
+	Policy             *DataPolicy                                          // Data policy enforced in addition to the swarm's policy.
+	OutputFilters      []OutputFilter                                       // Filters that can block generated content.
+	OutputFilterWindow int                                                  // Bytes of streamed output held back for filtering.
+	InjectionGuard     *InjectionGuard                                      // Scans tool results for prompt injections.
+	ToolPredictors     []ToolPredictor                                      // Predict tool calls to execute speculatively.
+	ToolFilter         ToolFilter                                           // Decides which tools are offered on each request.
+	ArgumentTemplates  []string                                             // Context variables usable as {{.key}} in tool arguments.
+	HiddenContext      []string                                             // Context variables never shown to the model.
+	ModelAliases       map[string]string                                    // Model aliases resolved before requests are sent.
+	AllowedModels      []string                                             // Models the agent may use; empty allows all.
+	BlockedModels      []string                                             // Models the agent may never use.
+	PromptVariants     []PromptVariant                                      // Instruction variants served to a share of runs.
+	ToolSelector       *ToolSelector                                        // Picks the tools most relevant to each request.
+	ToolChoice         *llm.ToolChoice                                      // Tool choice of the agent's first turn in a run.
+	HostedTools        []llm.HostedTool                                     // Provider-hosted tools; the agent runs on the swarm's Responses backend.
+	RequestHeaders     map[string]string                                    // Extra HTTP headers sent with the agent's provider requests.
+	RequestQuery       map[string]string                                    // Extra query parameters sent with the agent's provider requests.
 }
 
 type AgentFunctionExecutor[I any] func(args I, contextVariables map[string]interface{}) Result
 
+// contextExecutor implements functions that need the context of the run calling them
+type contextExecutor func(ctx context.Context, args map[string]interface{}, contextVariables map[string]interface{}) Result
+
 // AgentFunction represents a function that can be performed by an agent
 type AgentFunction[I any] struct {
-	Name        string                   // The name of the function.
-	Description string                   // Description of what the function does.
-	params      map[string]interface{}   // The parameters of the function.
-	executor    AgentFunctionExecutor[I] // The actual function implementation.
+	Name            string                   // The name of the function.
+	Description     string                   // Description of what the function does.
+	Permissions     []string                 // Permissions required to use the function.
+	Roles           []string                 // Roles one of which is required to use the function.
+	Flag            string                   // Feature flag that must be enabled to use the function.
+	Compensation    Compensation             // Undoes the function's side effects when a run fails.
+	NeedsApproval   bool                     // Whether each call must be approved before it runs.
+	params          map[string]interface{}   // The parameters of the function.
+	executor        AgentFunctionExecutor[I] // The actual function implementation.
+	contextExecutor contextExecutor          // Used instead of executor by functions that need the run's context.
+	definition      *llm.Function            // Definition sent to providers, built once.
 }
 
 // FunctionToDefinition converts an AgentFunction to a llm.Function
@@ -40,8 +73,21 @@ func FunctionToDefinition[I any](af AgentFunction[I]) llm.Function {
 	}
 }
 
-// NewAgentFunction creates a new agent function
-func NewAgentFunction[I any](name, description string, executor AgentFunctionExecutor[I]) (AgentFunction[map[string]interface{}], error) {
+// schemaCache holds the generated parameter schemas by argument type. Cached schemas are
+// shared between functions and must not be modified.
+var schemaCache sync.Map // map[reflect.Type]map[string]interface{}
+
+// parameterSchema returns the JSON schema of the argument type I, generating it on first use
+func parameterSchema[I any]() (map[string]interface{}, error) {
+	typ := reflect.TypeOf((*I)(nil)).Elem()
+	if cached, ok := schemaCache.Load(typ); ok {
+		return cached.(map[string]interface{}), nil
+	}
+
+	if err := checkSchemaTags(typ); err != nil {
+		return nil, err
+	}
+
 	var zero I
 	reflector := jsonschema.Reflector{
 		RequiredFromJSONSchemaTags: true,
@@ -53,12 +99,12 @@ func NewAgentFunction[I any](name, description string, executor AgentFunctionExe
 	// Pretty print the JSON schema
 	schemaBytes, err := json.MarshalIndent(schema, "", "  ")
 	if err != nil {
-		return AgentFunction[map[string]interface{}]{}, fmt.Errorf("Error generating schema: %v", err)
+		return nil, fmt.Errorf("Error generating schema: %v", err)
 	}
 
 	var schemaMap map[string]interface{}
 	if err := json.Unmarshal(schemaBytes, &schemaMap); err != nil {
-		return AgentFunction[map[string]interface{}]{}, fmt.Errorf("Error unmarshaling schema: %v", err)
+		return nil, fmt.Errorf("Error unmarshaling schema: %v", err)
 	}
 
 	params := make(map[string]interface{})
@@ -71,10 +117,26 @@ func NewAgentFunction[I any](name, description string, executor AgentFunctionExe
 		params[k] = schemaMap[k]
 	}
 
+	cached, _ := schemaCache.LoadOrStore(typ, params)
+	return cached.(map[string]interface{}), nil
+}
+
+// NewAgentFunction creates a new agent function
+func NewAgentFunction[I any](name, description string, executor AgentFunctionExecutor[I]) (AgentFunction[map[string]interface{}], error) {
+	params, err := parameterSchema[I]()
+	if err != nil {
+		return AgentFunction[map[string]interface{}]{}, err
+	}
+
 	return AgentFunction[map[string]interface{}]{
 		Name:        name,
 		Description: description,
 		params:      params,
+		definition: &llm.Function{
+			Name:        name,
+			Description: description,
+			Parameters:  params,
+		},
 		executor: func(args map[string]interface{}, contextVariables map[string]interface{}) Result {
 			argsBytes, err := json.Marshal(args)
 			if err != nil {
@@ -96,6 +158,45 @@ func NewAgentFunction[I any](name, description string, executor AgentFunctionExe
 	}, nil
 }
 
+// toolDefinition returns the function's definition, reusing the one built at construction
+// unless the name or description were changed since
+func (af *AgentFunction[I]) toolDefinition() *llm.Function {
+	if d := af.definition; d != nil && d.Name == af.Name && d.Description == af.Description {
+		return d
+	}
+	def := FunctionToDefinition(*af)
+	return &def
+}
+
+// ToolFilter reports whether the named tool should be offered to the model on a request
+type ToolFilter func(ctx context.Context, name string, contextVariables map[string]interface{}) bool
+
+// WithToolFilter only offers the model the tools the filter passes
+func (a *Agent) WithToolFilter(filter ToolFilter) *Agent {
+	a.ToolFilter = filter
+	return a
+}
+
+// toolDefinitions returns the tools available to the caller in ctx that pass the agent's
+// filter, as sent to providers
+func (a *Agent) toolDefinitions(ctx context.Context, contextVariables map[string]interface{}) []llm.Tool {
+	functions := a.availableFunctions(ctx)
+	if len(functions) == 0 {
+		return nil
+	}
+	tools := make([]llm.Tool, 0, len(functions))
+	for i := range functions {
+		if a.ToolFilter != nil && !a.ToolFilter(ctx, functions[i].Name, contextVariables) {
+			continue
+		}
+		tools = append(tools, llm.Tool{Type: "function", Function: functions[i].toolDefinition()})
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+	return tools
+}
+
 // NewAgent creates a new agent with initialized memory store
 func NewAgent(
 	name,
@@ -137,3 +238,15 @@ func (a *Agent) WithParallelToolCalls(enabled bool) *Agent {
 	a.ParallelToolCalls = enabled
 	return a
 }
+
+// WithKeepRaw enables or disables retaining raw provider responses on returned messages
+func (a *Agent) WithKeepRaw(enabled bool) *Agent {
+	a.KeepRaw = enabled
+	return a
+}
+
+// WithRepairToolHistory enables or disables repairing mismatched tool messages in the history
+func (a *Agent) WithRepairToolHistory(enabled bool) *Agent {
+	a.RepairToolHistory = enabled
+	return a
+}
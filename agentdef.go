@@ -0,0 +1,213 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// AgentDefinitionVersion is the version of the agent definitions written by MarshalAgent
+const AgentDefinitionVersion = 1
+
+// ErrUnsupportedAgentVersion is returned for agent definitions of a version this package
+// cannot read
+var ErrUnsupportedAgentVersion = errors.New("unsupported agent definition version")
+
+// AgentDefinition is the serializable part of an agent, e.g. to store agents in a database and
+// edit them in an admin UI. Tools and skills are referenced by name and looked up in a
+// ToolRegistry when the agent is rebuilt. Behavior only code can supply, such as dynamic
+// instructions, output filters, tool filters, predictors, selectors and injection guards, is
+// not part of the definition and must be set again on the rebuilt agent.
+type AgentDefinition struct {
+	Version            int                 `json:"version"`
+	Name               string              `json:"name"`
+	Model              string              `json:"model"`
+	Provider           llm.LLMProvider     `json:"provider,omitempty"`
+	Instructions       string              `json:"instructions,omitempty"`
+	Tools              []string            `json:"tools,omitempty"`
+	Skills             []string            `json:"skills,omitempty"`
+	ParallelToolCalls  bool                `json:"parallel_tool_calls,omitempty"`
+	KeepRaw            bool                `json:"keep_raw,omitempty"`
+	RepairToolHistory  bool                `json:"repair_tool_history,omitempty"`
+	OutputFilterWindow int                 `json:"output_filter_window,omitempty"`
+	ArgumentTemplates  []string            `json:"argument_templates,omitempty"`
+	HiddenContext      []string            `json:"hidden_context,omitempty"`
+	ModelAliases       map[string]string   `json:"model_aliases,omitempty"`
+	AllowedModels      []string            `json:"allowed_models,omitempty"`
+	BlockedModels      []string            `json:"blocked_models,omitempty"`
+	PromptVariants     []PromptVariantSpec `json:"prompt_variants,omitempty"`
+	ToolChoice         *llm.ToolChoice     `json:"tool_choice,omitempty"`
+	HostedTools        []llm.HostedTool    `json:"hosted_tools,omitempty"`
+	Policy             *DataPolicy         `json:"policy,omitempty"`
+	RequestHeaders     map[string]string   `json:"request_headers,omitempty"`
+	RequestQuery       map[string]string   `json:"request_query,omitempty"`
+}
+
+// PromptVariantSpec is the serializable part of a prompt variant
+type PromptVariantSpec struct {
+	Name         string  `json:"name"`
+	Instructions string  `json:"instructions"`
+	Weight       float64 `json:"weight,omitempty"`
+}
+
+// ToolRegistry maps the tool and skill names of agent definitions to their implementations.
+// It is safe for concurrent use.
+type ToolRegistry struct {
+	mu     sync.RWMutex
+	tools  map[string]AgentFunction[map[string]interface{}]
+	skills map[string]*Skill
+}
+
+// NewToolRegistry creates a registry holding the tools
+func NewToolRegistry(tools ...AgentFunction[map[string]interface{}]) *ToolRegistry {
+	r := &ToolRegistry{
+		tools:  make(map[string]AgentFunction[map[string]interface{}]),
+		skills: make(map[string]*Skill),
+	}
+	return r.Register(tools...)
+}
+
+// Register adds tools to the registry, replacing those with the same names
+func (r *ToolRegistry) Register(tools ...AgentFunction[map[string]interface{}]) *ToolRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, tool := range tools {
+		r.tools[tool.Name] = tool
+	}
+	return r
+}
+
+// RegisterSkills adds skills to the registry, replacing those with the same names
+func (r *ToolRegistry) RegisterSkills(skills ...*Skill) *ToolRegistry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, skill := range skills {
+		r.skills[skill.Name] = skill
+	}
+	return r
+}
+
+// Tools returns the names of the registered tools in sorted order, e.g. to offer them in an
+// admin UI
+func (r *ToolRegistry) Tools() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Definition returns the serializable definition of the agent
+func (a *Agent) Definition() AgentDefinition {
+	def := AgentDefinition{
+		Version:            AgentDefinitionVersion,
+		Name:               a.Name,
+		Model:              a.Model,
+		Provider:           a.Provider,
+		Instructions:       a.Instructions,
+		ParallelToolCalls:  a.ParallelToolCalls,
+		KeepRaw:            a.KeepRaw,
+		RepairToolHistory:  a.RepairToolHistory,
+		OutputFilterWindow: a.OutputFilterWindow,
+		ArgumentTemplates:  a.ArgumentTemplates,
+		HiddenContext:      a.HiddenContext,
+		ModelAliases:       a.ModelAliases,
+		AllowedModels:      a.AllowedModels,
+		BlockedModels:      a.BlockedModels,
+		ToolChoice:         a.ToolChoice,
+		HostedTools:        a.HostedTools,
+		Policy:             a.Policy,
+		RequestHeaders:     a.RequestHeaders,
+		RequestQuery:       a.RequestQuery,
+	}
+	for _, function := range a.Functions {
+		def.Tools = append(def.Tools, function.Name)
+	}
+	for _, skill := range a.Skills {
+		def.Skills = append(def.Skills, skill.Name)
+	}
+	for _, variant := range a.PromptVariants {
+		def.PromptVariants = append(def.PromptVariants, PromptVariantSpec{Name: variant.Name, Instructions: variant.Instructions, Weight: variant.Weight})
+	}
+	return def
+}
+
+// Agent rebuilds the agent the definition describes, looking its tools and skills up in the
+// registry. Names missing from the registry are reported together.
+func (def AgentDefinition) Agent(registry *ToolRegistry) (*Agent, error) {
+	if def.Version < 1 || def.Version > AgentDefinitionVersion {
+		return nil, fmt.Errorf("%w %d, expected 1 to %d", ErrUnsupportedAgentVersion, def.Version, AgentDefinitionVersion)
+	}
+
+	agent := NewAgent(def.Name, def.Model, def.Provider)
+	agent.Instructions = def.Instructions
+	agent.ParallelToolCalls = def.ParallelToolCalls
+	agent.KeepRaw = def.KeepRaw
+	agent.RepairToolHistory = def.RepairToolHistory
+	agent.OutputFilterWindow = def.OutputFilterWindow
+	agent.ArgumentTemplates = def.ArgumentTemplates
+	agent.HiddenContext = def.HiddenContext
+	agent.ModelAliases = def.ModelAliases
+	agent.AllowedModels = def.AllowedModels
+	agent.BlockedModels = def.BlockedModels
+	agent.ToolChoice = def.ToolChoice
+	agent.HostedTools = def.HostedTools
+	agent.Policy = def.Policy
+	agent.RequestHeaders = def.RequestHeaders
+	agent.RequestQuery = def.RequestQuery
+	for _, variant := range def.PromptVariants {
+		agent.PromptVariants = append(agent.PromptVariants, PromptVariant{Name: variant.Name, Instructions: variant.Instructions, Weight: variant.Weight})
+	}
+
+	if len(def.Tools) == 0 && len(def.Skills) == 0 {
+		return agent, nil
+	}
+	if registry == nil {
+		return nil, fmt.Errorf("agent %s references tools but no registry was given", def.Name)
+	}
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+	var errs []error
+	for _, name := range def.Tools {
+		tool, ok := registry.tools[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%w %q", ErrUnknownTool, name))
+			continue
+		}
+		agent.Functions = append(agent.Functions, tool)
+	}
+	for _, name := range def.Skills {
+		skill, ok := registry.skills[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("unknown skill %q", name))
+			continue
+		}
+		agent.Skills = append(agent.Skills, skill)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to rebuild agent %s: %w", def.Name, errors.Join(errs...))
+	}
+	return agent, nil
+}
+
+// MarshalAgent encodes the agent's definition as a versioned JSON document
+func MarshalAgent(agent *Agent) ([]byte, error) {
+	return json.MarshalIndent(agent.Definition(), "", "  ")
+}
+
+// UnmarshalAgent rebuilds an agent from a JSON document written by MarshalAgent, looking its
+// tools and skills up in the registry
+func UnmarshalAgent(data []byte, registry *ToolRegistry) (*Agent, error) {
+	var def AgentDefinition
+	if err := json.Unmarshal(data, &def); err != nil {
+		return nil, fmt.Errorf("failed to decode agent definition: %w", err)
+	}
+	return def.Agent(registry)
+}
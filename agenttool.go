@@ -0,0 +1,84 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// AgentToolOptions configures an agent wrapped as a tool with AgentAsTool
+type AgentToolOptions struct {
+	Swarm       *Swarm // Swarm the sub-runs are executed with; required
+	Name        string // Tool name, "ask_<agent name>" if empty
+	Description string // Tool description, derived from the agent's name if empty
+
+	MaxTurns      int    // Turn budget of each sub-run, DefaultMaxTurns if not positive
+	ModelOverride string // Model the sub-runs use instead of the agent's model
+	// BudgetShare is the fraction of the calling run's remaining token budget each sub-run
+	// may use, DefaultBudgetShare if zero
+	BudgetShare float64
+	// ContextKeys lists the caller's context variables copied into the sub-run. Sub-runs
+	// start with no context variables otherwise, and their changes are never copied back.
+	ContextKeys []string
+}
+
+// agentToolArgs are the arguments of an agent wrapped as a tool
+type agentToolArgs struct {
+	Input string `json:"input" jsonschema:"required,description=The task or question for the agent\\, with all the context it needs"`
+}
+
+var toolNameInvalidChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// AgentAsTool wraps an agent as a function another agent can call to delegate a task without
+// handing off the conversation. Each call runs the agent in an isolated sub-run that starts
+// from the task alone, and returns only the agent's final answer.
+func AgentAsTool(agent *Agent, opts AgentToolOptions) AgentFunction[map[string]interface{}] {
+	name := opts.Name
+	if name == "" {
+		name = "ask_" + strings.Trim(toolNameInvalidChars.ReplaceAllString(strings.ToLower(agent.Name), "_"), "_")
+	}
+	description := opts.Description
+	if description == "" {
+		description = fmt.Sprintf("Delegate a task to the %s agent and get its answer", agent.Name)
+	}
+
+	// The schema comes from the typed arguments; the run's context is needed to execute
+	fn, _ := NewAgentFunction(name, description, func(args agentToolArgs, contextVariables map[string]interface{}) Result {
+		return Result{Success: false, Error: fmt.Errorf("%s must be called from a run", name)}
+	})
+	fn.contextExecutor = func(ctx context.Context, args map[string]interface{}, contextVariables map[string]interface{}) Result {
+		if opts.Swarm == nil {
+			return Result{Success: false, Error: fmt.Errorf("%s has no swarm to run %s with", name, agent.Name)}
+		}
+		input, _ := args["input"].(string)
+		if strings.TrimSpace(input) == "" {
+			return Result{Success: false, Error: fmt.Errorf("input is required")}
+		}
+
+		subContext := make(map[string]interface{}, len(opts.ContextKeys))
+		for _, key := range opts.ContextKeys {
+			if value, ok := contextVariables[key]; ok {
+				subContext[key] = value
+			}
+		}
+
+		response, err := opts.Swarm.RunWithOptions(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: input}}, RunOptions{
+			ContextVariables: subContext,
+			ModelOverride:    opts.ModelOverride,
+			MaxTurns:         opts.MaxTurns,
+			BudgetShare:      opts.BudgetShare,
+		})
+		if err != nil {
+			return Result{Success: false, Error: fmt.Errorf("%s failed: %w", agent.Name, err)}
+		}
+		answer := lastAssistantContent(response.Messages)
+		if answer == "" {
+			return Result{Success: false, Error: fmt.Errorf("%s did not answer", agent.Name)}
+		}
+		return Result{Success: true, Data: answer}
+	}
+	return fn
+}
@@ -0,0 +1,275 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrAnalyticsQueueFull is returned by Submit when the analyzer's queue has no room left
+var ErrAnalyticsQueueFull = errors.New("analytics queue is full")
+
+// ErrAnalyzerClosed is returned by Submit once the analyzer has been closed
+var ErrAnalyzerClosed = errors.New("analyzer is closed")
+
+// DefaultAnalyticsQueueSize is the number of sessions an analyzer queues before Submit fails
+const DefaultAnalyticsQueueSize = 256
+
+// analyticsInstructions tells the analysis model what to tag
+const analyticsInstructions = `You analyze completed customer conversations for product analytics. For the conversation given, report:
+- topics: the subjects the user raised, as short lowercase phrases%s
+- sentiment: the user's overall sentiment
+- resolution: whether the user's request was resolved, left unresolved, or abandoned by the user
+- escalate: whether a human should follow up, e.g. for complaints, legal or safety issues, or repeated failures, with the reason
+- summary: one sentence describing the conversation`
+
+// Sentiment values of a SessionAnalysis
+const (
+	SentimentPositive = "positive"
+	SentimentNeutral  = "neutral"
+	SentimentNegative = "negative"
+)
+
+// Resolution values of a SessionAnalysis
+const (
+	ResolutionResolved   = "resolved"
+	ResolutionUnresolved = "unresolved"
+	ResolutionAbandoned  = "abandoned"
+)
+
+// SessionAnalysis is the tags a ConversationAnalyzer assigned to a session
+type SessionAnalysis struct {
+	SessionID        string    `json:"session_id"`
+	AgentName        string    `json:"agent_name,omitempty"`
+	Topics           []string  `json:"topics"`
+	Sentiment        string    `json:"sentiment"`  // SentimentPositive, SentimentNeutral or SentimentNegative
+	Resolution       string    `json:"resolution"` // ResolutionResolved, ResolutionUnresolved or ResolutionAbandoned
+	Escalate         bool      `json:"escalate"`
+	EscalationReason string    `json:"escalation_reason,omitempty"`
+	Summary          string    `json:"summary"`
+	MessageCount     int       `json:"message_count"`
+	AnalyzedAt       time.Time `json:"analyzed_at"`
+	Usage            llm.Usage `json:"usage"`
+}
+
+// AnalyticsSink receives session analyses, e.g. to load them into a warehouse
+type AnalyticsSink interface {
+	WriteAnalysis(ctx context.Context, analysis SessionAnalysis) error
+}
+
+// AnalyticsSinkFunc adapts a function to the AnalyticsSink interface
+type AnalyticsSinkFunc func(ctx context.Context, analysis SessionAnalysis) error
+
+// WriteAnalysis calls f(ctx, analysis)
+func (f AnalyticsSinkFunc) WriteAnalysis(ctx context.Context, analysis SessionAnalysis) error {
+	return f(ctx, analysis)
+}
+
+// ConversationAnalyzer tags completed sessions with topics, sentiment, resolution status and
+// escalation flags in the background, with a model that is typically a cheap one, and writes
+// the analyses to a sink. Close it, flushing queued sessions, before shutting down its swarm.
+type ConversationAnalyzer struct {
+	Model     string
+	Sink      AnalyticsSink
+	Topics    []string                          // Topics to choose from, free-form if empty
+	Workers   int                               // Sessions analyzed at once, 1 if not positive
+	QueueSize int                               // Sessions queued before Submit fails, DefaultAnalyticsQueueSize if not positive
+	OnError   func(sessionID string, err error) // Called when a session cannot be analyzed or written, logged if nil
+	swarm     *Swarm
+	start     sync.Once
+	mu        sync.Mutex
+	closed    bool
+	queue     chan SessionSnapshot
+	wg        sync.WaitGroup
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+// NewConversationAnalyzer creates an analyzer tagging sessions with the model and writing the
+// analyses to the sink
+func NewConversationAnalyzer(sw *Swarm, model string, sink AnalyticsSink) *ConversationAnalyzer {
+	return &ConversationAnalyzer{Model: model, Sink: sink, swarm: sw}
+}
+
+// WithTopics restricts the topics sessions are tagged with to a taxonomy
+func (a *ConversationAnalyzer) WithTopics(topics ...string) *ConversationAnalyzer {
+	a.Topics = topics
+	return a
+}
+
+// WithWorkers sets how many sessions are analyzed at once
+func (a *ConversationAnalyzer) WithWorkers(n int) *ConversationAnalyzer {
+	a.Workers = n
+	return a
+}
+
+// WithErrorHandler sets the function called when a session cannot be analyzed or written
+func (a *ConversationAnalyzer) WithErrorHandler(onError func(sessionID string, err error)) *ConversationAnalyzer {
+	a.OnError = onError
+	return a
+}
+
+// Submit queues a completed session for analysis without waiting for it
+func (a *ConversationAnalyzer) Submit(snapshot SessionSnapshot) error {
+	a.start.Do(a.run)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return ErrAnalyzerClosed
+	}
+	select {
+	case a.queue <- snapshot:
+		return nil
+	default:
+		return ErrAnalyticsQueueFull
+	}
+}
+
+// SubmitSession queues the current state of a session for analysis
+func (a *ConversationAnalyzer) SubmitSession(session *Session) error {
+	return a.Submit(session.Snapshot())
+}
+
+// Close stops accepting sessions and waits for the queued ones to be analyzed. If ctx expires
+// first, the analyses in flight are cancelled and the remaining sessions dropped.
+func (a *ConversationAnalyzer) Close(ctx context.Context) error {
+	a.start.Do(a.run)
+
+	a.mu.Lock()
+	if !a.closed {
+		a.closed = true
+		close(a.queue)
+	}
+	a.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		a.wg.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		a.cancel()
+		<-drained
+		return fmt.Errorf("analytics queue not drained: %w", ctx.Err())
+	}
+}
+
+// run starts the workers
+func (a *ConversationAnalyzer) run() {
+	size := a.QueueSize
+	if size <= 0 {
+		size = DefaultAnalyticsQueueSize
+	}
+	workers := max(a.Workers, 1)
+	a.queue = make(chan SessionSnapshot, size)
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+
+	a.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer a.wg.Done()
+			for snapshot := range a.queue {
+				if a.ctx.Err() != nil {
+					continue
+				}
+				if err := a.process(a.ctx, snapshot); err != nil {
+					if a.OnError != nil {
+						a.OnError(snapshot.ID, err)
+					} else {
+						log.Println(err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// process analyzes a session and writes the analysis to the sink
+func (a *ConversationAnalyzer) process(ctx context.Context, snapshot SessionSnapshot) error {
+	analysis, err := a.Analyze(ctx, snapshot)
+	if err != nil {
+		return err
+	}
+	if a.Sink == nil {
+		return nil
+	}
+	if err := a.Sink.WriteAnalysis(ctx, analysis); err != nil {
+		return fmt.Errorf("failed to write analysis of session %s: %w", snapshot.ID, err)
+	}
+	return nil
+}
+
+// Analyze tags a session synchronously, without writing the analysis to the sink
+func (a *ConversationAnalyzer) Analyze(ctx context.Context, snapshot SessionSnapshot) (SessionAnalysis, error) {
+	var transcript strings.Builder
+	for _, msg := range snapshot.Messages {
+		if (msg.Role == llm.RoleUser || msg.Role == llm.RoleAssistant) && msg.Content != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n\n", msg.Role, msg.Content)
+		}
+	}
+	if transcript.Len() == 0 {
+		return SessionAnalysis{SessionID: snapshot.ID}, fmt.Errorf("session %s has no conversation to analyze", snapshot.ID)
+	}
+
+	topics := map[string]interface{}{"type": "string"}
+	taxonomy := ""
+	if len(a.Topics) > 0 {
+		names := make([]interface{}, len(a.Topics))
+		for i, topic := range a.Topics {
+			names[i] = topic
+		}
+		topics["enum"] = names
+		taxonomy = ", chosen from: " + strings.Join(a.Topics, ", ")
+	}
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"topics":            map[string]interface{}{"type": "array", "items": topics},
+			"sentiment":         map[string]interface{}{"type": "string", "enum": []interface{}{SentimentPositive, SentimentNeutral, SentimentNegative}},
+			"resolution":        map[string]interface{}{"type": "string", "enum": []interface{}{ResolutionResolved, ResolutionUnresolved, ResolutionAbandoned}},
+			"escalate":          map[string]interface{}{"type": "boolean"},
+			"escalation_reason": map[string]interface{}{"type": "string"},
+			"summary":           map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"topics", "sentiment", "resolution", "escalate", "escalation_reason", "summary"},
+		"additionalProperties": false,
+	}
+
+	var analysis SessionAnalysis
+	agent := NewAgent("ConversationAnalyzer", a.Model, a.swarm.provider).WithInstructions(fmt.Sprintf(analyticsInstructions, taxonomy))
+	usage, err := a.swarm.runStructured(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: transcript.String()}}, schema, &analysis, func() error {
+		analysis.Sentiment = strings.ToLower(analysis.Sentiment)
+		analysis.Resolution = strings.ToLower(analysis.Resolution)
+		switch analysis.Sentiment {
+		case SentimentPositive, SentimentNeutral, SentimentNegative:
+		default:
+			return fmt.Errorf("unknown sentiment %q", analysis.Sentiment)
+		}
+		switch analysis.Resolution {
+		case ResolutionResolved, ResolutionUnresolved, ResolutionAbandoned:
+		default:
+			return fmt.Errorf("unknown resolution %q", analysis.Resolution)
+		}
+		return nil
+	}, DefaultStructuredRetries)
+	// Decoding replaces the whole value, so the session fields are filled in afterwards
+	analysis.SessionID = snapshot.ID
+	analysis.AgentName = snapshot.AgentName
+	analysis.MessageCount = len(snapshot.Messages)
+	analysis.AnalyzedAt = time.Now()
+	analysis.Usage = usage
+	if err != nil {
+		return analysis, fmt.Errorf("failed to analyze session %s: %w", snapshot.ID, err)
+	}
+	return analysis, nil
+}
@@ -0,0 +1,39 @@
+package swarmgo
+
+import (
+	"context"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ToolApprover decides whether a call to a tool needing approval may run, e.g. by asking the
+// user. It blocks until the call is approved or denied; an error stops the run.
+type ToolApprover func(ctx context.Context, toolCall llm.ToolCall) (bool, error)
+
+type toolApproverKey struct{}
+
+// WithToolApprover returns a context whose runs ask the approver before calling tools needing
+// approval
+func WithToolApprover(ctx context.Context, approver ToolApprover) context.Context {
+	return context.WithValue(ctx, toolApproverKey{}, approver)
+}
+
+// WithApproval returns a copy of the function whose calls only run once approved by the
+// ToolApprover of the run's context. Runs without an approver deny them.
+func (af AgentFunction[I]) WithApproval() AgentFunction[I] {
+	af.NeedsApproval = true
+	return af
+}
+
+// approved reports whether the tool call may run, asking the approver carried by ctx if the
+// function needs approval
+func approved[I any](ctx context.Context, af *AgentFunction[I], toolCall llm.ToolCall) (bool, error) {
+	if !af.NeedsApproval {
+		return true, nil
+	}
+	approver, ok := ctx.Value(toolApproverKey{}).(ToolApprover)
+	if !ok || approver == nil {
+		return false, nil
+	}
+	return approver(ctx, toolCall)
+}
@@ -0,0 +1,116 @@
+package swarmgo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches {{.key}} placeholders in tool arguments
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// WithArgumentTemplates lets the model refer to the named context variables in tool arguments
+// as {{.key}} placeholders, which are replaced with their values before the tool runs. The
+// model is told the placeholders exist but never sees the values.
+func (a *Agent) WithArgumentTemplates(keys ...string) *Agent {
+	a.ArgumentTemplates = append(a.ArgumentTemplates, keys...)
+	return a
+}
+
+// argumentTemplateNotice tells the model which placeholders it may use
+func (a *Agent) argumentTemplateNotice(messages Messages) string {
+	if len(a.ArgumentTemplates) == 0 {
+		return ""
+	}
+	placeholders := make([]string, len(a.ArgumentTemplates))
+	for i, key := range a.ArgumentTemplates {
+		placeholders[i] = "{{." + key + "}}"
+	}
+	sort.Strings(placeholders)
+	return messages.format(MessageArgumentTemplates, strings.Join(placeholders, ", "))
+}
+
+// expandArguments replaces placeholders in the string values of args with context variables.
+// A value that is exactly one placeholder takes the variable's value and type. Placeholders for
+// keys the agent does not allow, or that have no value, are an error.
+func (a *Agent) expandArguments(args map[string]interface{}, contextVariables map[string]interface{}) (map[string]interface{}, error) {
+	if len(a.ArgumentTemplates) == 0 {
+		return args, nil
+	}
+	expanded, err := a.expandValue(args, contextVariables)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.(map[string]interface{}), nil
+}
+
+// expandValue expands placeholders in a decoded JSON value
+func (a *Agent) expandValue(value interface{}, contextVariables map[string]interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return a.expandString(v, contextVariables)
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			var err error
+			if expanded[key], err = a.expandValue(item, contextVariables); err != nil {
+				return nil, err
+			}
+		}
+		return expanded, nil
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if expanded[i], err = a.expandValue(item, contextVariables); err != nil {
+				return nil, err
+			}
+		}
+		return expanded, nil
+	default:
+		return value, nil
+	}
+}
+
+// expandString expands the placeholders in a single string value
+func (a *Agent) expandString(s string, contextVariables map[string]interface{}) (interface{}, error) {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+
+	lookup := func(key string) (interface{}, error) {
+		allowed := false
+		for _, k := range a.ArgumentTemplates {
+			if k == key {
+				allowed = true
+				break
+			}
+		}
+		value, ok := contextVariables[key]
+		if !allowed || !ok {
+			return nil, fmt.Errorf("unknown placeholder {{.%s}}", key)
+		}
+		return value, nil
+	}
+
+	// A lone placeholder keeps the variable's type
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return lookup(s[matches[0][2]:matches[0][3]])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		value, err := lookup(s[m[2]:m[3]])
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[last:m[0]])
+		fmt.Fprintf(&b, "%v", value)
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
@@ -0,0 +1,264 @@
+package swarmgo
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// AuditEventType represents the kinds of audit records emitted by a Swarm
+type AuditEventType string
+
+const (
+	AuditRunStart        AuditEventType = "run_start"
+	AuditProviderRequest AuditEventType = "provider_request"
+	AuditToolCall        AuditEventType = "tool_call"
+	AuditRunEnd          AuditEventType = "run_end"
+)
+
+// AuditRecord describes a single auditable action
+type AuditRecord struct {
+	Time         time.Time       `json:"time"`
+	Type         AuditEventType  `json:"type"`
+	RunID        string          `json:"run_id"`
+	PrincipalID  string          `json:"principal_id,omitempty"`  // Who the run acted for
+	Agent        string          `json:"agent,omitempty"`         // Which agent ran
+	Provider     llm.LLMProvider `json:"provider,omitempty"`      // Where data was sent
+	Model        string          `json:"model,omitempty"`         // Which model received it
+	MessageCount int             `json:"message_count,omitempty"` // Number of messages sent to the provider
+	RequestHash  string          `json:"request_hash,omitempty"`  // Hash of the full provider request
+	ContextKeys  []string        `json:"context_keys,omitempty"`  // Context variables available to the agent
+	Tool         string          `json:"tool,omitempty"`          // Which tool executed
+	ToolCallID   string          `json:"tool_call_id,omitempty"`
+	Arguments    string          `json:"arguments,omitempty"`  // Tool arguments as JSON
+	Prefetched   bool            `json:"prefetched,omitempty"` // The tool ran before the model requested it
+	Error        string          `json:"error,omitempty"`
+}
+
+// AuditSink persists audit records. Implementations must only ever append.
+type AuditSink interface {
+	WriteAudit(ctx context.Context, record AuditRecord) error
+}
+
+// WithAuditSink sets the sink receiving audit records for every run. Runs fail if a record
+// about to be acted on cannot be written.
+func (s *Swarm) WithAuditSink(sink AuditSink) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditSink = sink
+	return s
+}
+
+type runIDKey struct{}
+
+// runIDFromContext returns the ID of the run the context belongs to
+func runIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(runIDKey{}).(string)
+	return id
+}
+
+// audit fills in the run and principal of a record and writes it to the configured sink
+func (s *Swarm) audit(ctx context.Context, record AuditRecord) error {
+	s.mu.Lock()
+	sink := s.auditSink
+	s.mu.Unlock()
+	if sink == nil {
+		return nil
+	}
+
+	record.Time = time.Now()
+	record.RunID = runIDFromContext(ctx)
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		record.PrincipalID = principal.ID
+	}
+	if record.Provider == "" {
+		record.Provider = s.provider
+	}
+
+	if err := sink.WriteAudit(ctx, record); err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// auditRequest records the data about to be sent to the provider
+func (s *Swarm) auditRequest(ctx context.Context, agent *Agent, req llm.ChatCompletionRequest) error {
+	return s.audit(ctx, AuditRecord{
+		Type:         AuditProviderRequest,
+		Agent:        agent.Name,
+		Model:        req.Model,
+		MessageCount: len(req.Messages),
+		RequestHash:  hashRequest(req),
+	})
+}
+
+// auditRunEnd writes the final record of a run. The run has already happened, so failures are only logged.
+func (s *Swarm) auditRunEnd(ctx context.Context, agent *Agent, runErr error) {
+	record := AuditRecord{Type: AuditRunEnd, Agent: agent.Name}
+	if runErr != nil {
+		record.Error = runErr.Error()
+	}
+	// The run context may already be cancelled; the record must still be written
+	if err := s.audit(context.WithoutCancel(ctx), record); err != nil {
+		log.Println(err)
+	}
+}
+
+// contextKeys returns the sorted keys of the context variables
+func contextKeys(contextVariables map[string]interface{}) []string {
+	keys := make([]string, 0, len(contextVariables))
+	for k := range contextVariables {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// FileAuditSink appends audit records as JSON lines to a file. With a cipher, each line
+// holds a base64-encoded encrypted record instead.
+type FileAuditSink struct {
+	file   *os.File
+	cipher Cipher
+	mutex  sync.Mutex
+}
+
+// NewFileAuditSink opens (or creates) the file in append-only mode
+func NewFileAuditSink(path string) (*FileAuditSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &FileAuditSink{file: file}, nil
+}
+
+// WithCipher encrypts records written by the sink
+func (f *FileAuditSink) WithCipher(c Cipher) *FileAuditSink {
+	f.cipher = c
+	return f
+}
+
+// WriteAudit appends a record and syncs it to disk
+func (f *FileAuditSink) WriteAudit(ctx context.Context, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if f.cipher != nil {
+		encrypted, err := f.cipher.Encrypt(data, []byte("audit"))
+		if err != nil {
+			return err
+		}
+		data = []byte(base64.StdEncoding.EncodeToString(encrypted))
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, err := f.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return f.file.Sync()
+}
+
+// Close closes the underlying file
+func (f *FileAuditSink) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLAuditSink inserts audit records into a SQL table. Queries use "?" placeholders
+// (SQLite, MySQL); the table is created if it does not exist.
+type SQLAuditSink struct {
+	db    *sql.DB
+	table string
+}
+
+// NewSQLAuditSink creates the audit table if needed and returns a sink writing to it
+func NewSQLAuditSink(db *sql.DB, table string) (*SQLAuditSink, error) {
+	if !sqlIdentifier.MatchString(table) {
+		return nil, fmt.Errorf("invalid audit table name: %q", table)
+	}
+
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS ` + table + ` (
+		time TIMESTAMP NOT NULL,
+		type TEXT NOT NULL,
+		run_id TEXT,
+		principal_id TEXT,
+		agent TEXT,
+		provider TEXT,
+		model TEXT,
+		message_count INTEGER,
+		request_hash TEXT,
+		context_keys TEXT,
+		tool TEXT,
+		tool_call_id TEXT,
+		arguments TEXT,
+		error TEXT
+	)`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+	return &SQLAuditSink{db: db, table: table}, nil
+}
+
+// WriteAudit inserts a record
+func (s *SQLAuditSink) WriteAudit(ctx context.Context, record AuditRecord) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO `+s.table+` (
+		time, type, run_id, principal_id, agent, provider, model, message_count,
+		request_hash, context_keys, tool, tool_call_id, arguments, error
+	) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		record.Time, string(record.Type), record.RunID, record.PrincipalID, record.Agent,
+		string(record.Provider), record.Model, record.MessageCount, record.RequestHash,
+		strings.Join(record.ContextKeys, ","), record.Tool, record.ToolCallID, record.Arguments, record.Error,
+	)
+	return err
+}
+
+// AuditFilter selects audit records to purge. Records must match every field that is set.
+type AuditFilter struct {
+	Before      time.Time // Records written before this time
+	PrincipalID string    // Records of runs acting for this principal
+}
+
+// AuditPurger is implemented by audit sinks whose records can be deleted, e.g. to enforce a
+// retention period or erase a user's data
+type AuditPurger interface {
+	PurgeAudit(ctx context.Context, filter AuditFilter) (int, error)
+}
+
+// PurgeAudit deletes the records matching the filter and returns how many were deleted. An
+// empty filter is rejected rather than deleting every record.
+func (s *SQLAuditSink) PurgeAudit(ctx context.Context, filter AuditFilter) (int, error) {
+	var conditions []string
+	var args []interface{}
+	if !filter.Before.IsZero() {
+		conditions = append(conditions, "time < ?")
+		args = append(args, filter.Before)
+	}
+	if filter.PrincipalID != "" {
+		conditions = append(conditions, "principal_id = ?")
+		args = append(args, filter.PrincipalID)
+	}
+	if len(conditions) == 0 {
+		return 0, fmt.Errorf("audit filter is empty")
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM `+s.table+` WHERE `+strings.Join(conditions, " AND "), args...)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	return int(deleted), err
+}
@@ -0,0 +1,192 @@
+package swarmgo
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// Baseline allocations per operation of the hot-path benchmarks, measured with go1.23 on
+// linux/amd64. Allocation counts are stable across machines, unlike timings, so they are what
+// the regression gate checks. Run the gate with:
+//
+//	SWARMGO_BENCH_GATE=1 go test -run TestBenchmarkBaselines .
+//
+// and compare timings between branches with:
+//
+//	go test -run '^$' -bench . -benchmem -count 10 ./... > new.txt && benchstat old.txt new.txt
+//
+// The gate allows 20% headroom over each baseline. If a change intentionally adds
+// allocations, update the baseline in the same commit.
+var benchmarkBaselines = map[string]struct {
+	bench  func(b *testing.B)
+	allocs int64 // Allocations per op at the time the baseline was recorded
+}{
+	"Run":              {BenchmarkRun, 50},
+	"RunWithTools":     {BenchmarkRunWithTools, 108},
+	"HandleToolCall":   {BenchmarkHandleToolCall, 18},
+	"ParameterSchema":  {BenchmarkParameterSchema, 104},
+	"NewAgentFunction": {BenchmarkNewAgentFunction, 2},
+	"ToolDefinitions":  {BenchmarkToolDefinitions, 6},
+	"StreamMessages":   {BenchmarkStreamMessages, 131},
+	"HistoryAppend":    {BenchmarkHistoryAppend, 12},
+}
+
+// TestBenchmarkBaselines fails when a benchmark allocates noticeably more than its baseline
+func TestBenchmarkBaselines(t *testing.T) {
+	if os.Getenv("SWARMGO_BENCH_GATE") == "" {
+		t.Skip("set SWARMGO_BENCH_GATE=1 to check benchmark baselines")
+	}
+	for name, baseline := range benchmarkBaselines {
+		result := testing.Benchmark(baseline.bench)
+		t.Logf("%s: %s %s", name, result.String(), result.MemString())
+		if limit := baseline.allocs + baseline.allocs/5 + 1; result.AllocsPerOp() > limit {
+			t.Errorf("%s allocates %d times per op, baseline is %d", name, result.AllocsPerOp(), baseline.allocs)
+		}
+	}
+}
+
+// benchLLM is a minimal LLM that replays canned responses without mock bookkeeping,
+// so benchmarks measure the library rather than the test double
+type benchLLM struct {
+	responses []llm.ChatCompletionResponse
+	chunks    []llm.ChatCompletionResponse
+	next      int
+}
+
+func (l *benchLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	resp := l.responses[l.next%len(l.responses)]
+	l.next++
+	return resp, nil
+}
+
+func (l *benchLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	return &fakeStream{ctx: ctx, chunks: l.chunks}, nil
+}
+
+// nopStreamHandler discards stream events
+type nopStreamHandler struct{}
+
+func (nopStreamHandler) OnStart()                {}
+func (nopStreamHandler) OnToken(string)          {}
+func (nopStreamHandler) OnToolCall(llm.ToolCall) {}
+func (nopStreamHandler) OnComplete(llm.Message)  {}
+func (nopStreamHandler) OnError(error)           {}
+
+type benchArgs struct {
+	OrderID string `json:"order_id" jsonschema:"required"`
+	Verbose bool   `json:"verbose"`
+}
+
+func benchAgent(b *testing.B) *Agent {
+	lookup, err := NewAgentFunction("lookup_order", "Look up an order", func(args benchArgs, cv map[string]interface{}) Result {
+		return Result{Success: true, Data: "shipped"}
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return NewAgent("BenchAgent", "gpt-4", llm.OpenAI).WithInstructions("You help with orders.").WithFunctions(lookup)
+}
+
+func assistantReply(content string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}}}
+}
+
+func BenchmarkRun(b *testing.B) {
+	sw := &Swarm{client: &benchLLM{responses: []llm.ChatCompletionResponse{assistantReply("Hello!")}}}
+	agent := benchAgent(b)
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.Memory = nil
+		if _, err := sw.RunWithOptions(context.Background(), agent, messages, RunOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRunWithTools(b *testing.B) {
+	toolCall := llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{
+		Role: llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{
+			Name: "lookup_order", Arguments: `{"order_id":"A1"}`,
+		}}},
+	}}}}
+	sw := &Swarm{client: &benchLLM{responses: []llm.ChatCompletionResponse{toolCall, assistantReply("It shipped.")}}}
+	agent := benchAgent(b)
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "Where is order A1?"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.Memory = nil
+		if _, err := sw.RunWithOptions(context.Background(), agent, messages, RunOptions{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHandleToolCall(b *testing.B) {
+	sw := &Swarm{}
+	agent := benchAgent(b)
+	toolCall := llm.ToolCall{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{
+		Name: "lookup_order", Arguments: `{"order_id":"A1"}`,
+	}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sw.handleToolCall(context.Background(), &toolCall, agent, nil, nil, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkParameterSchema measures schema generation without the per-type cache
+func BenchmarkParameterSchema(b *testing.B) {
+	typ := reflect.TypeOf(benchArgs{})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		schemaCache.Delete(typ)
+		if _, err := parameterSchema[benchArgs](); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkToolDefinitions(b *testing.B) {
+	agent := benchAgent(b)
+	for i := 1; i < 10; i++ {
+		agent.WithFunctions(agent.Functions[0])
+	}
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		agent.toolDefinitions(ctx, nil)
+	}
+}
+
+func BenchmarkStreamMessages(b *testing.B) {
+	chunks := make([]llm.ChatCompletionResponse, 100)
+	for i := range chunks {
+		chunks[i] = tokenChunk("tok ")
+	}
+	sw := &Swarm{client: &benchLLM{chunks: chunks}}
+	agent := benchAgent(b)
+	messages := []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sw.streamMessages(context.Background(), agent, messages, nil, "", nopStreamHandler{}, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
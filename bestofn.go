@@ -0,0 +1,193 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// Candidate is one sampled completion of a best-of-N turn
+type Candidate struct {
+	Message      llm.Message
+	FinishReason string
+	Usage        llm.Usage // Of the sample and, with a JudgeScorer, its judge request
+	Score        float64
+	Selected     bool  // Whether the candidate was chosen as the turn's message
+	Err          error // Sampling or scoring error; failed candidates are never selected
+}
+
+// Scorer rates a candidate reply to the conversation; higher scores are better
+type Scorer interface {
+	Score(ctx context.Context, history []llm.Message, candidate llm.Message) (float64, error)
+}
+
+// ScorerFunc adapts a function to the Scorer interface
+type ScorerFunc func(ctx context.Context, history []llm.Message, candidate llm.Message) (float64, error)
+
+// Score calls f
+func (f ScorerFunc) Score(ctx context.Context, history []llm.Message, candidate llm.Message) (float64, error) {
+	return f(ctx, history, candidate)
+}
+
+// judgePrompt asks a judge model to rate a candidate reply
+const judgePrompt = `Rate how well the candidate reply answers the conversation below on a scale from 0 to 10%s.
+Reply with only the number.
+
+Conversation:
+%s
+Candidate reply:
+%s`
+
+// scorePattern finds the first number in a judge's reply
+var scorePattern = regexp.MustCompile(`-?\d+(\.\d+)?`)
+
+// JudgeScorer scores candidates by asking a judge model to rate them
+type JudgeScorer struct {
+	Client   llm.LLM
+	Model    string
+	Criteria string // Optional description of what makes a good reply
+}
+
+// NewJudgeScorer creates a scorer that rates candidates with the model
+func NewJudgeScorer(client llm.LLM, model string) *JudgeScorer {
+	return &JudgeScorer{Client: client, Model: model}
+}
+
+// Score asks the judge model for a rating
+func (j *JudgeScorer) Score(ctx context.Context, history []llm.Message, candidate llm.Message) (float64, error) {
+	score, _, err := j.score(ctx, history, candidate, nil)
+	return score, err
+}
+
+// score asks the judge model for a rating and returns it with the usage of the judge request.
+// If audit is set, it is called with the request before it is sent.
+func (j *JudgeScorer) score(ctx context.Context, history []llm.Message, candidate llm.Message, audit func(llm.ChatCompletionRequest) error) (float64, llm.Usage, error) {
+	var conversation strings.Builder
+	for _, msg := range history {
+		if msg.Role == llm.RoleSystem || msg.Content == "" {
+			continue
+		}
+		fmt.Fprintf(&conversation, "%s: %s\n", msg.Role, msg.Content)
+	}
+	reply := candidate.Content
+	for _, toolCall := range candidate.ToolCalls {
+		reply += fmt.Sprintf("\n[calls %s with %s]", toolCall.Function.Name, toolCall.Function.Arguments)
+	}
+	criteria := ""
+	if j.Criteria != "" {
+		criteria = ", judging by: " + j.Criteria
+	}
+
+	req := llm.ChatCompletionRequest{
+		Model:    j.Model,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: fmt.Sprintf(judgePrompt, criteria, conversation.String(), reply)}},
+	}
+	if audit != nil {
+		if err := audit(req); err != nil {
+			return 0, llm.Usage{}, err
+		}
+	}
+	resp, err := j.Client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return 0, llm.Usage{}, fmt.Errorf("judge request failed: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return 0, resp.Usage, errors.New("no choices in judge response")
+	}
+	match := scorePattern.FindString(resp.Choices[0].Message.Content)
+	if match == "" {
+		return 0, resp.Usage, fmt.Errorf("judge returned no score: %q", resp.Choices[0].Message.Content)
+	}
+	score, err := strconv.ParseFloat(match, 64)
+	return score, resp.Usage, err
+}
+
+// bestOfN samples opts.BestOfN completions in parallel and returns the request and a response
+// holding the highest scoring one, along with every candidate. Usage covers all samples and
+// their judge requests.
+func (s *Swarm) bestOfN(ctx context.Context, agent *Agent, history []llm.Message, contextVariables map[string]interface{}, opts RunOptions) (llm.ChatCompletionRequest, llm.ChatCompletionResponse, []Candidate, error) {
+	candidates := make([]Candidate, opts.BestOfN)
+	requests := make([]llm.ChatCompletionRequest, opts.BestOfN)
+
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, resp, err := s.getChatCompletion(ctx, agent, history, contextVariables, opts.ModelOverride, opts.Stream, opts.Debug)
+			requests[i] = req
+			if err == nil && len(resp.Choices) == 0 {
+				err = fmt.Errorf("no choices in response")
+			}
+			if err != nil {
+				candidates[i].Err = err
+				return
+			}
+			candidates[i].Message = resp.Choices[0].Message
+			candidates[i].FinishReason = resp.Choices[0].FinishReason
+			candidates[i].Usage = resp.Usage
+
+			scorer := opts.Scorer
+			if scorer == nil {
+				client, err := s.clientFor(agent)
+				if err != nil {
+					candidates[i].Err = err
+					return
+				}
+				scorer = NewJudgeScorer(client, req.Model)
+			}
+			judge, ok := scorer.(*JudgeScorer)
+			if !ok {
+				candidates[i].Score, candidates[i].Err = scorer.Score(providerContext(ctx, agent), history, candidates[i].Message)
+				return
+			}
+			// The judge's request is audited and its usage counted like the candidate's own
+			audit := func(req llm.ChatCompletionRequest) error { return s.auditRequest(ctx, agent, req) }
+			score, usage, err := judge.score(providerContext(ctx, agent), history, candidates[i].Message, audit)
+			candidates[i].Score, candidates[i].Err = score, err
+			candidates[i].Usage = addUsage(candidates[i].Usage, usage)
+		}(i)
+	}
+	wg.Wait()
+
+	var usage llm.Usage
+	for _, c := range candidates {
+		usage = addUsage(usage, c.Usage)
+	}
+	best, err := selectCandidate(candidates)
+	if err != nil {
+		return requests[0], llm.ChatCompletionResponse{}, candidates, err
+	}
+
+	return requests[best], llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: candidates[best].Message, FinishReason: candidates[best].FinishReason}},
+		Usage:   usage,
+	}, candidates, nil
+}
+
+// selectCandidate marks the highest scoring candidate that did not fail as selected and
+// returns its index
+func selectCandidate(candidates []Candidate) (int, error) {
+	best := -1
+	var errs []error
+	for i, c := range candidates {
+		if c.Err != nil {
+			errs = append(errs, fmt.Errorf("candidate %d: %w", i, c.Err))
+			continue
+		}
+		if best < 0 || c.Score > candidates[best].Score {
+			best = i
+		}
+	}
+	if best < 0 {
+		return -1, fmt.Errorf("all %d candidates failed: %w", len(candidates), errors.Join(errs...))
+	}
+	candidates[best].Selected = true
+	return best, nil
+}
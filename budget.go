@@ -0,0 +1,113 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrMaxDepthExceeded is returned when runs started from within runs, such as agents called
+// as tools, nest deeper than the swarm allows
+var ErrMaxDepthExceeded = errors.New("maximum run depth exceeded")
+
+// ErrBudgetExceeded is returned when a run uses more tokens than its budget
+var ErrBudgetExceeded = errors.New("token budget exceeded")
+
+// DefaultMaxRunDepth is the nesting depth allowed when the swarm does not set one. A run
+// started directly has depth 1.
+const DefaultMaxRunDepth = 5
+
+// DefaultBudgetShare is the fraction of its parent's remaining token budget a nested run may
+// use when it does not set one
+const DefaultBudgetShare = 0.5
+
+// WithMaxRunDepth limits how deeply runs may be nested, so agents delegating to agents cannot
+// recurse without bound
+func (s *Swarm) WithMaxRunDepth(depth int) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxRunDepth = depth
+	return s
+}
+
+// runBudget tracks the tokens used by a run. Tokens are charged to the run and every run
+// enclosing it, so nested runs spend their parents' budgets too.
+type runBudget struct {
+	parent *runBudget
+	limit  int64 // Zero for no limit
+	used   atomic.Int64
+}
+
+// remaining returns the tokens left before this run or an enclosing one exceeds its budget,
+// and whether any of them has a budget
+func (b *runBudget) remaining() (int64, bool) {
+	var left int64
+	limited := false
+	for ; b != nil; b = b.parent {
+		if b.limit <= 0 {
+			continue
+		}
+		if r := b.limit - b.used.Load(); !limited || r < left {
+			left = r
+		}
+		limited = true
+	}
+	return left, limited
+}
+
+// charge records tokens used by the run and reports whether a budget was exceeded
+func (b *runBudget) charge(tokens int) error {
+	for run := b; run != nil; run = run.parent {
+		run.used.Add(int64(tokens))
+	}
+	if left, limited := b.remaining(); limited && left < 0 {
+		return fmt.Errorf("%w: %d tokens over", ErrBudgetExceeded, -left)
+	}
+	return nil
+}
+
+// runScope is the position of a run among the runs enclosing it
+type runScope struct {
+	depth  int
+	budget *runBudget
+}
+
+type runScopeKey struct{}
+
+// enterRun checks the nesting depth of a new run and derives its token budget from its own
+// limit and the remaining budget of the enclosing run, if any
+func (s *Swarm) enterRun(ctx context.Context, maxTokens int, share float64) (context.Context, *runBudget, error) {
+	s.mu.Lock()
+	maxDepth := s.maxRunDepth
+	s.mu.Unlock()
+	if maxDepth <= 0 {
+		maxDepth = DefaultMaxRunDepth
+	}
+
+	parent, _ := ctx.Value(runScopeKey{}).(*runScope)
+	scope := &runScope{depth: 1, budget: &runBudget{limit: int64(maxTokens)}}
+	if parent != nil {
+		scope.depth = parent.depth + 1
+		scope.budget.parent = parent.budget
+	}
+	if scope.depth > maxDepth {
+		return ctx, nil, fmt.Errorf("%w: depth %d, limit %d", ErrMaxDepthExceeded, scope.depth, maxDepth)
+	}
+
+	if parent != nil {
+		if left, limited := parent.budget.remaining(); limited {
+			if share <= 0 {
+				share = DefaultBudgetShare
+			}
+			inherited := int64(float64(left) * min(share, 1))
+			if inherited <= 0 {
+				return ctx, nil, fmt.Errorf("%w: no budget left for a nested run", ErrBudgetExceeded)
+			}
+			if scope.budget.limit <= 0 || inherited < scope.budget.limit {
+				scope.budget.limit = inherited
+			}
+		}
+	}
+	return context.WithValue(ctx, runScopeKey{}, scope), scope.budget, nil
+}
@@ -0,0 +1,94 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ChoicePolicy selects which of the choices returned for a turn the run continues with
+type ChoicePolicy int
+
+const (
+	// ChoiceFirst continues with the first choice
+	ChoiceFirst ChoicePolicy = iota
+	// ChoiceScored continues with the highest scoring choice, rated by RunOptions.Scorer or a
+	// JudgeScorer using the run's model if nil
+	ChoiceScored
+)
+
+// requestParams are per-run parameters applied to every request of the run
+type requestParams struct {
+	n       int          // Number of choices to request, the provider's default if not positive
+	stop    []string     // Sequences that end generation
+	grammar *llm.Grammar // Constrains decoding, if set
+}
+
+type requestParamsKey struct{}
+
+// withRequestParams returns a context whose requests are sent with the parameters
+func withRequestParams(ctx context.Context, params requestParams) context.Context {
+	return context.WithValue(ctx, requestParamsKey{}, params)
+}
+
+// applyRequestParams sets the parameters of the run the context belongs to on the request
+func applyRequestParams(ctx context.Context, req *llm.ChatCompletionRequest) {
+	applyToolChoice(ctx, req)
+	applyServiceTier(ctx, req)
+	params, ok := ctx.Value(requestParamsKey{}).(requestParams)
+	if !ok {
+		return
+	}
+	if params.n > 1 {
+		req.N = params.n
+	}
+	if len(params.stop) > 0 {
+		req.Stop = params.stop
+	}
+	if params.grammar != nil {
+		req.Grammar = params.grammar
+	}
+}
+
+// multiChoice requests opts.Choices completions in a single request and returns the request
+// and a response holding the choice selected by opts.ChoicePolicy, along with every choice
+func (s *Swarm) multiChoice(ctx context.Context, agent *Agent, history []llm.Message, contextVariables map[string]interface{}, opts RunOptions) (llm.ChatCompletionRequest, llm.ChatCompletionResponse, []Candidate, error) {
+	ctx = withRequestParams(ctx, requestParams{n: opts.Choices, stop: opts.Stop, grammar: opts.Grammar})
+	req, resp, err := s.getChatCompletion(ctx, agent, history, contextVariables, opts.ModelOverride, opts.Stream, opts.Debug)
+	if err != nil {
+		return req, resp, nil, err
+	}
+	if len(resp.Choices) == 0 {
+		return req, resp, nil, errors.New("no choices in response")
+	}
+
+	candidates := make([]Candidate, len(resp.Choices))
+	for i, choice := range resp.Choices {
+		candidates[i] = Candidate{Message: choice.Message, FinishReason: choice.FinishReason}
+	}
+
+	if opts.ChoicePolicy == ChoiceScored && len(candidates) > 1 {
+		scorer := opts.Scorer
+		if scorer == nil {
+			scorer = NewJudgeScorer(s.client, req.Model)
+		}
+		var wg sync.WaitGroup
+		for i := range candidates {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				candidates[i].Score, candidates[i].Err = scorer.Score(ctx, history, candidates[i].Message)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	best, err := selectCandidate(candidates)
+	if err != nil {
+		return req, llm.ChatCompletionResponse{}, candidates, err
+	}
+	resp.Choices = []llm.Choice{{Message: candidates[best].Message, FinishReason: candidates[best].FinishReason}}
+	return req, resp, candidates, nil
+}
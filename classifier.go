@@ -0,0 +1,151 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// classifierInstructions tells the classifier's model how to answer
+const classifierInstructions = `Classify the user's input into exactly one of the labels below. Give your confidence in the label as a number from 0 to 1, and a short reason.
+
+Labels:
+%s`
+
+// Label is a class a Classifier can assign
+type Label struct {
+	Name        string
+	Description string // What inputs with the label are about
+}
+
+// Classification is the label a Classifier assigned to an input
+type Classification struct {
+	Label      string    `json:"label"`
+	Confidence float64   `json:"confidence"` // From 0 to 1
+	Reason     string    `json:"reason"`
+	Usage      llm.Usage `json:"-"`
+}
+
+// Classifier assigns inputs one of a set of labels with structured output, e.g. to detect
+// intents. It is safe for concurrent use once configured.
+type Classifier struct {
+	Labels       []Label
+	Model        string
+	Instructions string // Additional guidance, such as how to treat ambiguous inputs
+	Retries      int    // Corrections of invalid replies, DefaultStructuredRetries if zero
+	swarm        *Swarm
+}
+
+// NewClassifier creates a classifier assigning the labels with the model
+func NewClassifier(sw *Swarm, model string, labels ...Label) *Classifier {
+	return &Classifier{Labels: labels, Model: model, swarm: sw}
+}
+
+// WithInstructions adds guidance for the classifier
+func (c *Classifier) WithInstructions(instructions string) *Classifier {
+	c.Instructions = instructions
+	return c
+}
+
+// Classify assigns the input one of the classifier's labels
+func (c *Classifier) Classify(ctx context.Context, input string) (Classification, error) {
+	if len(c.Labels) == 0 {
+		return Classification{}, fmt.Errorf("classifier has no labels")
+	}
+	names := make([]interface{}, len(c.Labels))
+	var list strings.Builder
+	for i, label := range c.Labels {
+		names[i] = label.Name
+		fmt.Fprintf(&list, "- %s: %s\n", label.Name, label.Description)
+	}
+	instructions := fmt.Sprintf(classifierInstructions, list.String())
+	if c.Instructions != "" {
+		instructions += "\n" + c.Instructions
+	}
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"label":      map[string]interface{}{"type": "string", "enum": names},
+			"confidence": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+			"reason":     map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"label", "confidence", "reason"},
+		"additionalProperties": false,
+	}
+
+	retries := c.Retries
+	if retries == 0 {
+		retries = DefaultStructuredRetries
+	}
+	agent := NewAgent("Classifier", c.Model, c.swarm.provider).WithInstructions(instructions)
+	var result Classification
+	usage, err := c.swarm.runStructured(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: input}}, schema, &result, func() error {
+		for _, label := range c.Labels {
+			if strings.EqualFold(label.Name, result.Label) {
+				result.Label = label.Name
+				result.Confidence = min(max(result.Confidence, 0), 1)
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown label %q", result.Label)
+	}, retries)
+	result.Usage = usage
+	if err != nil {
+		return result, fmt.Errorf("failed to classify input: %w", err)
+	}
+	return result, nil
+}
+
+// RouterAgent hands each request to the agent for its intent, as classified by a Classifier
+type RouterAgent struct {
+	Classifier    *Classifier
+	Routes        map[string]*Agent // Agents by label
+	Fallback      *Agent            // Agent for unrouted labels and low-confidence classifications
+	MinConfidence float64           // Classifications below this confidence go to Fallback
+}
+
+// NewRouterAgent creates a router handing requests to the agents by label
+func NewRouterAgent(classifier *Classifier, routes map[string]*Agent) *RouterAgent {
+	return &RouterAgent{Classifier: classifier, Routes: routes}
+}
+
+// WithFallback sets the agent for requests that cannot be routed confidently
+func (r *RouterAgent) WithFallback(agent *Agent, minConfidence float64) *RouterAgent {
+	r.Fallback = agent
+	r.MinConfidence = minConfidence
+	return r
+}
+
+// Route classifies the input and returns the agent it is routed to
+func (r *RouterAgent) Route(ctx context.Context, input string) (*Agent, Classification, error) {
+	classification, err := r.Classifier.Classify(ctx, input)
+	if err != nil {
+		return nil, classification, err
+	}
+	agent, ok := r.Routes[classification.Label]
+	if !ok || agent == nil || classification.Confidence < r.MinConfidence {
+		agent = r.Fallback
+	}
+	if agent == nil {
+		return nil, classification, fmt.Errorf("no agent for label %q with confidence %.2f", classification.Label, classification.Confidence)
+	}
+	return agent, classification, nil
+}
+
+// Run routes the latest user message and runs the conversation with the agent it is routed to
+func (r *RouterAgent) Run(ctx context.Context, messages []llm.Message, opts RunOptions) (Response, error) {
+	var input string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == llm.RoleUser {
+			input = messages[i].Content
+			break
+		}
+	}
+	agent, _, err := r.Route(ctx, input)
+	if err != nil {
+		return Response{}, err
+	}
+	return r.Classifier.swarm.RunWithOptions(ctx, agent, messages, opts)
+}
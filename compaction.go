@@ -0,0 +1,208 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// CompactHistoryToolName is the name of the tool the model calls to compact the conversation
+const CompactHistoryToolName = "compact_history"
+
+// DefaultCompactionPrompt instructs the model writing the summary of a compacted conversation
+const DefaultCompactionPrompt = `Summarize the conversation below so that it can be continued from the summary alone. Keep the user's goals, decisions made, facts and tool results that are still needed, and any open tasks. Be concise.`
+
+// DefaultCompactionKeepRecent is the number of recent messages compaction keeps verbatim
+const DefaultCompactionKeepRecent = 4
+
+// compactionInstructions explains the compact_history tool to the model
+const compactionInstructions = `When the conversation gets long, call compact_history to replace its older messages with a summary. Only do so at a point where the details of earlier messages are no longer needed.`
+
+// CompactionOptions configures how a conversation is compacted into a summary
+type CompactionOptions struct {
+	Model      string // Model writing the summary, the agent's model if empty
+	Prompt     string // Instructions for the summary, DefaultCompactionPrompt if empty
+	KeepRecent int    // Recent messages kept verbatim, DefaultCompactionKeepRecent if not positive
+}
+
+// Compaction describes the compaction of a run's conversation, which is its input messages
+// followed by Response.Messages
+type Compaction struct {
+	Summary  string // Summary of the compacted messages
+	Replaced int    // Number of leading messages of the conversation the summary replaces
+}
+
+// SummaryMessage returns the message the summary is sent to the model as
+func (c *Compaction) SummaryMessage() llm.Message {
+	return llm.Message{Role: llm.RoleUser, Content: "Summary of the earlier conversation:\n\n" + c.Summary}
+}
+
+// Apply returns the conversation with the compacted messages replaced by the summary
+func (c *Compaction) Apply(messages []llm.Message) []llm.Message {
+	replaced := min(c.Replaced, len(messages))
+	compacted := make([]llm.Message, 0, len(messages)-replaced+1)
+	compacted = append(compacted, c.SummaryMessage())
+	return append(compacted, messages[replaced:]...)
+}
+
+// compactionRequest is the result of the compact_history tool, which the run acts on once
+// the tool calls of the turn are answered
+type compactionRequest struct {
+	opts  CompactionOptions
+	focus string
+}
+
+// String is the tool result the model sees
+func (r compactionRequest) String() string {
+	return "The earlier conversation will be replaced by a summary."
+}
+
+// CompactionSkill returns a skill exposing the compact_history tool, with which the model
+// compacts the conversation into a summary when it notices it getting long. Runs send the
+// summary in place of the compacted messages from the next turn on, and report it on
+// Response.Compaction; sessions apply it to their history. Streamed runs do not compact.
+func CompactionSkill(opts CompactionOptions) *Skill {
+	compact, _ := NewAgentFunction(CompactHistoryToolName, "Replace the older messages of the conversation with a summary", func(args struct {
+		Focus string `json:"focus" jsonschema:"description=What the summary should preserve in particular"`
+	}, contextVariables map[string]interface{}) Result {
+		return Result{Success: true, Data: compactionRequest{opts: opts, focus: args.Focus}}
+	})
+	return NewSkill("History compaction", compactionInstructions, compact).
+		WithDescription("Lets the model compact a long conversation into a summary")
+}
+
+// WithHistoryCompaction lets the agent compact the conversation with the compact_history tool
+func (a *Agent) WithHistoryCompaction(opts CompactionOptions) *Agent {
+	return a.WithSkills(CompactionSkill(opts))
+}
+
+// compactionRequested returns the compaction requested by one of the tool results, if any
+func compactionRequested(results []ToolResult) (compactionRequest, bool) {
+	for _, result := range results {
+		if request, ok := result.Result.Data.(compactionRequest); ok {
+			return request, true
+		}
+	}
+	return compactionRequest{}, false
+}
+
+// compactedView returns the messages requests are built from: the history, with the messages
+// replaced by the compaction's summary if there is one
+func compactedView(history History, compaction *Compaction) []llm.Message {
+	if compaction == nil {
+		return history.Messages()
+	}
+	return append([]llm.Message{compaction.SummaryMessage()}, history.Since(compaction.Replaced)...)
+}
+
+// compactionSplit returns the index of the first message kept verbatim, keeping tool results
+// with the call they answer
+func compactionSplit(messages []llm.Message, keep int) int {
+	split := max(len(messages)-keep, 0)
+	for split > 0 && (messages[split].Role == llm.RoleTool || messages[split].Role == llm.RoleFunction) {
+		split--
+	}
+	return split
+}
+
+// compactHistory summarizes all but the recent messages of the history, as seen through the
+// previous compaction, and returns the new compaction. It returns the previous one if there
+// is too little to compact.
+func (s *Swarm) compactHistory(ctx context.Context, agent *Agent, history History, previous *Compaction, request compactionRequest) (*Compaction, llm.Usage, error) {
+	view := compactedView(history, previous)
+	keep := request.opts.KeepRecent
+	if keep <= 0 {
+		keep = DefaultCompactionKeepRecent
+	}
+	split := compactionSplit(view, keep)
+	offset := 0
+	if previous != nil {
+		// The view starts with the previous summary in place of the messages it replaced
+		offset = previous.Replaced - 1
+	}
+	if split <= 1 {
+		return previous, llm.Usage{}, nil
+	}
+
+	summary, usage, err := s.summarize(ctx, agent, view[:split], request)
+	if err != nil {
+		return nil, usage, fmt.Errorf("failed to compact history: %w", err)
+	}
+	return &Compaction{Summary: summary, Replaced: offset + split}, usage, nil
+}
+
+// summarize asks the model to summarize the messages
+func (s *Swarm) summarize(ctx context.Context, agent *Agent, messages []llm.Message, request compactionRequest) (string, llm.Usage, error) {
+	model, err := agent.resolveModel(request.opts.Model)
+	if err != nil {
+		return "", llm.Usage{}, err
+	}
+	if _, err := s.enforcePolicy(agent, model, nil); err != nil {
+		return "", llm.Usage{}, err
+	}
+
+	prompt := request.opts.Prompt
+	if prompt == "" {
+		prompt = DefaultCompactionPrompt
+	}
+	if request.focus != "" {
+		prompt += "\nPreserve in particular: " + request.focus
+	}
+	req := llm.ChatCompletionRequest{
+		Model: model,
+		Messages: []llm.Message{
+			{Role: llm.RoleSystem, Content: prompt},
+			{Role: llm.RoleUser, Content: renderTranscript(messages)},
+		},
+	}
+	if err := s.auditRequest(ctx, agent, req); err != nil {
+		return "", llm.Usage{}, err
+	}
+	client, err := s.clientFor(agent)
+	if err != nil {
+		return "", llm.Usage{}, err
+	}
+	resp, err := client.CreateChatCompletion(providerContext(ctx, agent), req)
+	if err != nil {
+		return "", llm.Usage{}, err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return "", resp.Usage, fmt.Errorf("empty summary")
+	}
+	return resp.Choices[0].Message.Content, resp.Usage, nil
+}
+
+// renderTranscript writes messages as plain text, so they can be summarized without sending
+// tool calls that the summary request does not declare
+func renderTranscript(messages []llm.Message) string {
+	var b strings.Builder
+	for _, msg := range messages {
+		switch {
+		case msg.Role == llm.RoleTool || msg.Role == llm.RoleFunction:
+			fmt.Fprintf(&b, "[result of %s]: %s\n", msg.Name, msg.Content)
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+			}
+			for _, call := range msg.ToolCalls {
+				fmt.Fprintf(&b, "[%s called %s(%s)]\n", msg.Role, call.Function.Name, call.Function.Arguments)
+			}
+		default:
+			fmt.Fprintf(&b, "%s: %s\n", msg.Role, msg.Content)
+		}
+	}
+	return b.String()
+}
+
+// applyCompaction replaces the compacted messages of the history with the summary. It must be
+// called with the lock held.
+func (s *Session) applyCompaction(compaction *Compaction) {
+	if len(s.addedAt) == len(s.Messages) {
+		replaced := min(compaction.Replaced, len(s.addedAt))
+		s.addedAt = append([]time.Time{time.Now()}, s.addedAt[replaced:]...)
+	}
+	s.Messages = compaction.Apply(s.Messages)
+}
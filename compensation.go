@@ -0,0 +1,109 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Compensation undoes the side effects of a successful tool call, such as cancelling an
+// order the tool placed. It receives the arguments the tool ran with and its result.
+type Compensation func(ctx context.Context, args map[string]interface{}, result Result) error
+
+// WithCompensation returns a copy of the function that registers the compensation each
+// time it succeeds, so a failed run or workflow can undo the call
+func (af AgentFunction[I]) WithCompensation(compensation Compensation) AgentFunction[I] {
+	af.Compensation = compensation
+	return af
+}
+
+// compensationStep is a successful tool call that can be undone
+type compensationStep struct {
+	toolName     string
+	toolCallID   string
+	args         map[string]interface{}
+	result       Result
+	compensation Compensation
+}
+
+// Saga collects the compensations of the tool calls made by the runs it is attached to, so
+// their side effects can be undone together. It is safe for concurrent use.
+type Saga struct {
+	mu    sync.Mutex
+	steps []compensationStep
+}
+
+// NewSaga creates an empty saga
+func NewSaga() *Saga {
+	return &Saga{}
+}
+
+type sagaKey struct{}
+
+// WithSaga returns a context whose runs record their compensations in the saga
+func WithSaga(ctx context.Context, saga *Saga) context.Context {
+	return context.WithValue(ctx, sagaKey{}, saga)
+}
+
+// sagaFromContext returns the saga attached to the context, if any
+func sagaFromContext(ctx context.Context) *Saga {
+	saga, _ := ctx.Value(sagaKey{}).(*Saga)
+	return saga
+}
+
+// Len returns the number of compensations waiting to run
+func (s *Saga) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.steps)
+}
+
+// Compensate runs the recorded compensations in reverse order and clears them. Every
+// compensation runs even if an earlier one fails; the failures are returned joined.
+func (s *Saga) Compensate(ctx context.Context) error {
+	s.mu.Lock()
+	steps := s.steps
+	s.steps = nil
+	s.mu.Unlock()
+
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		step := steps[i]
+		if err := step.compensation(ctx, step.args, step.result); err != nil {
+			errs = append(errs, fmt.Errorf("failed to compensate %s (%s): %w", step.toolName, step.toolCallID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// recordCompensation adds the compensation of a successful tool call to the saga of the
+// context, if there is one
+func recordCompensation(ctx context.Context, fn *AgentFunction[map[string]interface{}], toolCallID string, args map[string]interface{}, result Result) {
+	saga := sagaFromContext(ctx)
+	if saga == nil || fn.Compensation == nil || !result.Success || result.Error != nil {
+		return
+	}
+	saga.mu.Lock()
+	defer saga.mu.Unlock()
+	saga.steps = append(saga.steps, compensationStep{
+		toolName:     fn.Name,
+		toolCallID:   toolCallID,
+		args:         args,
+		result:       result,
+		compensation: fn.Compensation,
+	})
+}
+
+// compensateRun undoes the run's tool calls after it failed. The compensations run even if
+// the run's context was cancelled.
+func compensateRun(ctx context.Context, runErr error) error {
+	saga := sagaFromContext(ctx)
+	if saga == nil {
+		return runErr
+	}
+	if err := saga.Compensate(context.WithoutCancel(ctx)); err != nil {
+		return errors.Join(runErr, err)
+	}
+	return runErr
+}
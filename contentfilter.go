@@ -0,0 +1,146 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ContentFilteredError is returned when the provider's content filter blocks a response, or the
+// model declines to answer for policy reasons
+type ContentFilteredError struct {
+	Agent      string   // Agent whose request was filtered
+	Model      string   // Model the request was sent to
+	Categories []string // Categories reported by the provider's filter, if any
+	Refusal    string   // Explanation given by a model that refused, if any
+}
+
+// Error implements the error interface
+func (e *ContentFilteredError) Error() string {
+	if e.Refusal != "" {
+		return fmt.Sprintf("%s refused to answer for %s: %s", e.Model, e.Agent, e.Refusal)
+	}
+	if len(e.Categories) > 0 {
+		return fmt.Sprintf("response of %s for %s blocked by content filter: %s", e.Model, e.Agent, strings.Join(e.Categories, ", "))
+	}
+	return fmt.Sprintf("response of %s for %s blocked by content filter", e.Model, e.Agent)
+}
+
+// ContentFilterFallback configures how a run recovers from a response blocked by the provider's
+// content filter. The fallbacks are tried in field order; a run without any that succeeds fails
+// with a *ContentFilteredError.
+type ContentFilterFallback struct {
+	// Sanitize rewrites the request messages, e.g. to redact what tripped the filter, and the
+	// request is sent again with them
+	Sanitize func(messages []llm.Message, filtered *ContentFilteredError) []llm.Message
+	Client   llm.LLM         // Alternate provider the request is sent to
+	Provider llm.LLMProvider // Provider of Client, checked against data policies
+	Endpoint string          // API host of Client, checked against data policies, empty for the provider's default
+	Model    string          // Model requested from Client, the request's model if empty
+	Response string          // Canned answer used in place of the blocked response
+}
+
+// WithContentFilterFallback sets how runs recover from responses blocked by the provider's
+// content filter. Streamed responses are not recovered, as their output was already delivered.
+func (s *Swarm) WithContentFilterFallback(fallback ContentFilterFallback) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.contentFilter = fallback
+	return s
+}
+
+// contentFilterError returns the error describing a filtered choice, or nil if it was not filtered
+func contentFilterError(agent, model string, choice llm.Choice) *ContentFilteredError {
+	if choice.FinishReason != llm.FinishReasonContentFilter && choice.Message.Refusal == "" {
+		return nil
+	}
+	return &ContentFilteredError{
+		Agent:      agent,
+		Model:      model,
+		Categories: choice.Message.Filtered,
+		Refusal:    choice.Message.Refusal,
+	}
+}
+
+// filteredResponse returns the error describing a filtered response, or nil if it was not filtered
+func filteredResponse(agent, model string, resp llm.ChatCompletionResponse) *ContentFilteredError {
+	if len(resp.Choices) == 0 {
+		return nil
+	}
+	return contentFilterError(agent, model, resp.Choices[0])
+}
+
+// recoverFiltered applies the content filter fallbacks to a response the client returned. It
+// returns the response unchanged if it was not filtered, and the usage of every attempt is
+// included. If no fallback succeeds, the errors of the retries are returned with the filter's.
+func (s *Swarm) recoverFiltered(ctx context.Context, client llm.LLM, agent *Agent, req llm.ChatCompletionRequest, resp llm.ChatCompletionResponse) (llm.ChatCompletionResponse, error) {
+	filtered := filteredResponse(agent.Name, req.Model, resp)
+	if filtered == nil {
+		return resp, nil
+	}
+
+	s.mu.Lock()
+	fallback := s.contentFilter
+	s.mu.Unlock()
+
+	usage := resp.Usage
+	var errs []error
+	retry := func(client llm.LLM, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, bool) {
+		if err := s.auditRequest(ctx, agent, req); err != nil {
+			errs = append(errs, err)
+			return llm.ChatCompletionResponse{}, false
+		}
+		retried, err := client.CreateChatCompletion(providerContext(ctx, agent), req)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("retry with %s failed: %w", req.Model, err))
+			return llm.ChatCompletionResponse{}, false
+		}
+		usage = addUsage(usage, retried.Usage)
+		retried.Usage = usage
+		return retried, len(retried.Choices) > 0 && filteredResponse(agent.Name, req.Model, retried) == nil
+	}
+
+	if fallback.Sanitize != nil {
+		sanitized := req
+		sanitized.Messages = fallback.Sanitize(append([]llm.Message(nil), req.Messages...), filtered)
+		if retried, ok := retry(client, sanitized); ok {
+			return retried, nil
+		}
+	}
+	if fallback.Client != nil {
+		alternate := req
+		requested := fallback.Model
+		if requested == "" {
+			requested = req.Model
+		}
+		model, err := agent.resolveModel(requested)
+		if err == nil {
+			_, err = s.enforcePolicyAt(agent, fallback.Provider, fallback.Endpoint, model, nil)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			alternate.Model = model
+			if retried, ok := retry(fallback.Client, alternate); ok {
+				return retried, nil
+			}
+		}
+	}
+	if fallback.Response != "" {
+		return llm.ChatCompletionResponse{
+			ID: resp.ID,
+			Choices: []llm.Choice{{
+				Message:      llm.Message{Role: llm.RoleAssistant, Content: fallback.Response},
+				FinishReason: "stop",
+			}},
+			Usage: usage,
+		}, nil
+	}
+	if len(errs) > 0 {
+		return resp, errors.Join(append([]error{filtered}, errs...)...)
+	}
+	return resp, filtered
+}
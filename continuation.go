@@ -0,0 +1,79 @@
+package swarmgo
+
+import (
+	"context"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// FinishReasonLength is the finish reason of responses cut off at the output token limit
+const FinishReasonLength = "length"
+
+// Text a continuation repeats from the end of the response it continues is removed if it is
+// between minContinuationOverlap and maxContinuationOverlap bytes long. Shorter overlaps are
+// too likely to be a coincidence.
+const (
+	minContinuationOverlap = 8
+	maxContinuationOverlap = 200
+)
+
+// continueTruncated requests the rest of a response cut off at the output token limit, up to
+// max times, and joins the pieces into the response's message. The usage of the continuation
+// requests is added to the response's.
+func (s *Swarm) continueTruncated(
+	ctx context.Context,
+	agent *Agent,
+	conversation []llm.Message,
+	contextVariables map[string]interface{},
+	modelOverride string,
+	stream bool,
+	debug bool,
+	resp llm.ChatCompletionResponse,
+	max int,
+) (llm.ChatCompletionResponse, int, error) {
+	choice := resp.Choices[0]
+	prompt := messagesFromContext(ctx).format(MessageContinueTruncated)
+	continued := 0
+	for continued < max && choice.FinishReason == FinishReasonLength && len(choice.Message.ToolCalls) == 0 {
+		history := make([]llm.Message, 0, len(conversation)+2)
+		history = append(history, conversation...)
+		history = append(history, choice.Message, llm.Message{Role: llm.RoleUser, Content: prompt})
+		_, next, err := s.getChatCompletion(ctx, agent, history, contextVariables, modelOverride, stream, debug)
+		if err != nil {
+			return resp, continued, err
+		}
+		resp.Usage = addUsage(resp.Usage, next.Usage)
+		if len(next.Choices) == 0 {
+			break
+		}
+		continued++
+		choice.Message.Content = joinContinuation(choice.Message.Content, next.Choices[0].Message.Content)
+		choice.Message.ToolCalls = next.Choices[0].Message.ToolCalls
+		choice.FinishReason = next.Choices[0].FinishReason
+	}
+	resp.Choices[0] = choice
+	return resp, continued, nil
+}
+
+// joinContinuation appends the continuation of a truncated response to it, dropping a code
+// fence the continuation reopened and text it repeated from the end of the response
+func joinContinuation(text, continuation string) string {
+	// A response cut off inside a code block leaves an odd number of fences open
+	if strings.Count(text, "```")%2 == 1 {
+		trimmed := strings.TrimLeft(continuation, " \t\r\n")
+		if strings.HasPrefix(trimmed, "```") {
+			if _, rest, ok := strings.Cut(trimmed, "\n"); ok {
+				continuation = rest
+			}
+		}
+	}
+
+	longest := min(len(text), len(continuation), maxContinuationOverlap)
+	for n := longest; n >= minContinuationOverlap; n-- {
+		if strings.HasSuffix(text, continuation[:n]) {
+			return text + continuation[n:]
+		}
+	}
+	return text + continuation
+}
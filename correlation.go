@@ -0,0 +1,136 @@
+package swarmgo
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// crockford is the alphabet of ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator returns a new unique identifier for a run, turn or session
+type IDGenerator func() string
+
+// NewULID returns a ULID: 26 characters that sort by creation time, made of a millisecond
+// timestamp followed by 80 random bits. It is the default ID generator.
+func NewULID() string {
+	var id [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(id[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(ms))
+	if _, err := rand.Read(id[6:]); err != nil {
+		binary.BigEndian.PutUint64(id[8:], uint64(time.Now().UnixNano()))
+	}
+
+	// 128 bits in 26 characters of 5 bits, the first holding the top 3 bits
+	var out [26]byte
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
+
+// generateID returns an identifier from the default generator, for IDs made without a swarm
+func generateID() string {
+	return NewULID()
+}
+
+// WithIDGenerator sets how the swarm generates the IDs of runs, turns and sessions, e.g. to
+// use the IDs of an existing tracing system. ULIDs are generated by default.
+func (s *Swarm) WithIDGenerator(gen IDGenerator) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idGenerator = gen
+	return s
+}
+
+// newID returns an identifier from the swarm's generator. The swarm's lock must not be held.
+func (s *Swarm) newID() string {
+	if s == nil {
+		return generateID()
+	}
+	s.mu.Lock()
+	gen := s.idGenerator
+	s.mu.Unlock()
+	if gen == nil {
+		return generateID()
+	}
+	return gen()
+}
+
+// Correlation identifies what a context belongs to, to tie logs, audit records, metrics and
+// provider requests of the same run together
+type Correlation struct {
+	RunID      string // Run the context belongs to
+	TurnID     string // Model turn of the run, if any
+	ToolCallID string // Tool call being executed, if any
+}
+
+type turnIDKey struct{}
+
+type toolCallIDKey struct{}
+
+// CorrelationFromContext returns the correlation IDs of the context. Tools can use it to tag
+// their own logs and downstream requests.
+func CorrelationFromContext(ctx context.Context) Correlation {
+	turnID, _ := ctx.Value(turnIDKey{}).(string)
+	toolCallID, _ := ctx.Value(toolCallIDKey{}).(string)
+	return Correlation{RunID: runIDFromContext(ctx), TurnID: turnID, ToolCallID: toolCallID}
+}
+
+// String joins the IDs that are set with slashes, e.g. for log lines and request headers
+func (c Correlation) String() string {
+	var parts []string
+	for _, id := range []string{c.RunID, c.TurnID, c.ToolCallID} {
+		if id != "" {
+			parts = append(parts, id)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// withTurnID returns a context belonging to the turn
+func withTurnID(ctx context.Context, turnID string) context.Context {
+	return context.WithValue(ctx, turnIDKey{}, turnID)
+}
+
+// withToolCallID returns a context belonging to the tool call
+func withToolCallID(ctx context.Context, toolCallID string) context.Context {
+	return context.WithValue(ctx, toolCallIDKey{}, toolCallID)
+}
+
+// withCorrelationHeader returns a context whose provider requests carry its correlation IDs
+// in the llm.CorrelationHeader header
+func withCorrelationHeader(ctx context.Context) context.Context {
+	if id := CorrelationFromContext(ctx).String(); id != "" {
+		return llm.WithHeaders(ctx, http.Header{llm.CorrelationHeader: {id}})
+	}
+	return ctx
+}
+
+// requestUser returns the ID of the principal the run acts for, sent as the user of provider
+// requests so providers can attribute them, e.g. in OpenAI's user field
+func requestUser(ctx context.Context) string {
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return principal.ID
+	}
+	return ""
+}
+
+// logPrefix tags debug log lines with the correlation IDs of the context
+func logPrefix(ctx context.Context) string {
+	if id := CorrelationFromContext(ctx).String(); id != "" {
+		return fmt.Sprintf("[%s] ", id)
+	}
+	return ""
+}
@@ -0,0 +1,45 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrCredentialNotFound is returned when a credential provider has no value for a name
+var ErrCredentialNotFound = errors.New("credential not found")
+
+// CredentialProvider supplies secrets such as API keys and encryption keys by name
+type CredentialProvider interface {
+	GetCredential(ctx context.Context, name string) (string, error)
+}
+
+// EnvCredentialProvider reads credentials from environment variables. The name is
+// upper-cased and prefixed with Prefix, e.g. "session_key" becomes "SWARMGO_SESSION_KEY".
+type EnvCredentialProvider struct {
+	Prefix string
+}
+
+// GetCredential returns the value of the environment variable for the name
+func (p EnvCredentialProvider) GetCredential(ctx context.Context, name string) (string, error) {
+	key := p.Prefix + strings.ToUpper(name)
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return "", fmt.Errorf("%w: %s", ErrCredentialNotFound, key)
+	}
+	return value, nil
+}
+
+// StaticCredentials is a CredentialProvider backed by a fixed map, useful for tests
+type StaticCredentials map[string]string
+
+// GetCredential returns the credential stored under the name
+func (c StaticCredentials) GetCredential(ctx context.Context, name string) (string, error) {
+	value, ok := c[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrCredentialNotFound, name)
+	}
+	return value, nil
+}
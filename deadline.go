@@ -0,0 +1,45 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrRunTimeout is returned when a run does not finish within RunOptions.Timeout
+var ErrRunTimeout = errors.New("run timed out")
+
+type serviceTierKey struct{}
+
+// withRunTimeout returns a context that expires after the run's timeout, with its cancel
+// function. Provider requests and tool calls of the run inherit the deadline.
+func withRunTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// runTimeoutError reports a run that failed because its timeout expired
+func runTimeoutError(ctx context.Context, timeout time.Duration, err error) error {
+	if err == nil || timeout <= 0 || !errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, ErrRunTimeout) {
+		return err
+	}
+	return fmt.Errorf("%w after %v: %w", ErrRunTimeout, timeout, err)
+}
+
+// withServiceTier returns a context whose requests, including those of nested runs, are
+// served at the tier
+func withServiceTier(ctx context.Context, tier llm.ServiceTier) context.Context {
+	return context.WithValue(ctx, serviceTierKey{}, tier)
+}
+
+// applyServiceTier sets the service tier of the run the context belongs to on the request
+func applyServiceTier(ctx context.Context, req *llm.ChatCompletionRequest) {
+	if tier, ok := ctx.Value(serviceTierKey{}).(llm.ServiceTier); ok && tier != "" {
+		req.ServiceTier = tier
+	}
+}
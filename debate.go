@@ -0,0 +1,148 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultDebateRounds is the number of rounds a debate runs when none is configured
+const DefaultDebateRounds = 2
+
+// DebateArgument is one debater's answer in one round
+type DebateArgument struct {
+	Round   int
+	Agent   string
+	Content string
+}
+
+// DebateResult holds the full debate transcript and the judge's final answer
+type DebateResult struct {
+	Arguments     []DebateArgument // All arguments, ordered by round then debater
+	Final         string           // The judge's final answer
+	JudgeResponse Response
+}
+
+// Debate has several agents argue answers to a question over multiple rounds, each seeing the
+// others' previous arguments, after which a judge agent selects or synthesizes the final answer
+type Debate struct {
+	swarm    *Swarm
+	Debaters []*Agent
+	Judge    *Agent
+	Rounds   int
+	MaxTurns int // Maximum turns per agent run
+	Debug    bool
+}
+
+// NewDebate creates a debate between the debaters, decided by the judge
+func NewDebate(swarm *Swarm, judge *Agent, debaters ...*Agent) *Debate {
+	return &Debate{
+		swarm:    swarm,
+		Debaters: debaters,
+		Judge:    judge,
+		Rounds:   DefaultDebateRounds,
+	}
+}
+
+// WithRounds sets the number of debate rounds
+func (d *Debate) WithRounds(rounds int) *Debate {
+	d.Rounds = rounds
+	return d
+}
+
+// Run holds the debate on the question and returns the judge's verdict
+func (d *Debate) Run(ctx context.Context, question string) (DebateResult, error) {
+	if len(d.Debaters) == 0 {
+		return DebateResult{}, errors.New("debate needs at least one debater")
+	}
+	if d.Judge == nil {
+		return DebateResult{}, errors.New("debate needs a judge")
+	}
+	rounds := d.Rounds
+	if rounds <= 0 {
+		rounds = DefaultDebateRounds
+	}
+
+	var result DebateResult
+	var previous []DebateArgument
+	for round := 1; round <= rounds; round++ {
+		arguments, err := d.runRound(ctx, question, round, previous)
+		if err != nil {
+			return result, err
+		}
+		result.Arguments = append(result.Arguments, arguments...)
+		previous = arguments
+	}
+
+	prompt := fmt.Sprintf("Question: %s\n\nThe following debate took place:\n\n%s\nWeigh the arguments and give the single best final answer to the question.",
+		question, formatArguments(result.Arguments))
+	response, err := d.swarm.RunWithOptions(ctx, d.Judge, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, RunOptions{
+		MaxTurns: d.MaxTurns,
+		Debug:    d.Debug,
+	})
+	if err != nil {
+		return result, fmt.Errorf("judge %s failed: %w", d.Judge.Name, err)
+	}
+	result.JudgeResponse = response
+	result.Final = lastAssistantContent(response.Messages)
+	return result, nil
+}
+
+// runRound asks every debater for an argument concurrently
+func (d *Debate) runRound(ctx context.Context, question string, round int, previous []DebateArgument) ([]DebateArgument, error) {
+	arguments := make([]DebateArgument, len(d.Debaters))
+	errs := make([]error, len(d.Debaters))
+
+	var wg sync.WaitGroup
+	for i, debater := range d.Debaters {
+		wg.Add(1)
+		go func(i int, debater *Agent) {
+			defer wg.Done()
+
+			prompt := "Question: " + question + "\n\nGive your best answer with a short justification."
+			if len(previous) > 0 {
+				prompt = fmt.Sprintf("Question: %s\n\nThese were the answers in the previous round:\n\n%s\nCritique the other answers, then give your improved answer.",
+					question, formatArguments(previous))
+			}
+
+			response, err := d.swarm.RunWithOptions(ctx, debater, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, RunOptions{
+				MaxTurns: d.MaxTurns,
+				Debug:    d.Debug,
+			})
+			if err != nil {
+				errs[i] = fmt.Errorf("debater %s failed in round %d: %w", debater.Name, round, err)
+				return
+			}
+			arguments[i] = DebateArgument{Round: round, Agent: debater.Name, Content: lastAssistantContent(response.Messages)}
+		}(i, debater)
+	}
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return arguments, nil
+}
+
+// formatArguments renders arguments as a transcript for prompts
+func formatArguments(arguments []DebateArgument) string {
+	var b strings.Builder
+	for _, argument := range arguments {
+		fmt.Fprintf(&b, "[Round %d] %s:\n%s\n\n", argument.Round, argument.Agent, argument.Content)
+	}
+	return b.String()
+}
+
+// lastAssistantContent returns the content of the last assistant message with text
+func lastAssistantContent(messages []llm.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == llm.RoleAssistant && messages[i].Content != "" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package swarmgo
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestDebate tests that debaters see each other's arguments and the judge sees the transcript
+func TestDebate(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	reply := func(content string) llm.ChatCompletionResponse {
+		return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}}}
+	}
+	from := func(instructions string) interface{} {
+		return mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+			return len(req.Messages) > 0 && req.Messages[0].Content == instructions
+		})
+	}
+	mockClient.On("CreateChatCompletion", mock.Anything, from("You are optimistic.")).Return(reply("Yes"), nil)
+	mockClient.On("CreateChatCompletion", mock.Anything, from("You are skeptical.")).Return(reply("No"), nil)
+	mockClient.On("CreateChatCompletion", mock.Anything, from("You are the judge.")).Return(reply("Maybe"), nil)
+
+	pro := NewAgent("Pro", "gpt-4", llm.OpenAI).WithInstructions("You are optimistic.")
+	con := NewAgent("Con", "gpt-4", llm.OpenAI).WithInstructions("You are skeptical.")
+	judge := NewAgent("Judge", "gpt-4", llm.OpenAI).WithInstructions("You are the judge.")
+
+	result, err := NewDebate(sw, judge, pro, con).WithRounds(2).Run(context.Background(), "Will it rain?")
+	assert.NoError(t, err)
+	assert.Equal(t, "Maybe", result.Final)
+	assert.Len(t, result.Arguments, 4)
+	assert.Equal(t, DebateArgument{Round: 2, Agent: "Con", Content: "No"}, result.Arguments[3])
+
+	for _, call := range mockClient.Calls {
+		req := call.Arguments.Get(1).(llm.ChatCompletionRequest)
+		prompt := req.Messages[len(req.Messages)-1].Content
+		if req.Messages[0].Content == "You are the judge." {
+			assert.Contains(t, prompt, "[Round 2] Pro:\nYes")
+		} else if strings.Contains(prompt, "previous round") {
+			assert.Contains(t, prompt, "[Round 1] Con:\nNo")
+		}
+	}
+	mockClient.AssertNumberOfCalls(t, "CreateChatCompletion", 5)
+}
@@ -0,0 +1,231 @@
+// Package debugserver serves an admin and debug dashboard for a swarm: its registered agents
+// and tools, active runs with their status, recent transcripts, agent memories and metrics.
+// It exposes conversation contents, so serve it on an internal address only.
+package debugserver
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo"
+)
+
+// DefaultMemoryLimit is the number of recent memories listed per agent unless ?limit= is given
+const DefaultMemoryLimit = 50
+
+// Server serves the debug dashboard of a swarm
+type Server struct {
+	swarm   *swarmgo.Swarm
+	monitor *swarmgo.RunMonitor
+	agents  map[string]*swarmgo.Agent
+	tools   *swarmgo.ToolRegistry
+	mutex   sync.Mutex
+}
+
+// NewServer creates a debug server for the swarm and attaches a run monitor keeping the given
+// number of recent runs to it
+func NewServer(swarm *swarmgo.Swarm, recentRuns int) *Server {
+	monitor := swarmgo.NewRunMonitor(recentRuns)
+	swarm.WithRunMonitor(monitor)
+	return &Server{
+		swarm:   swarm,
+		monitor: monitor,
+		agents:  make(map[string]*swarmgo.Agent),
+	}
+}
+
+// Monitor returns the run monitor attached to the swarm
+func (s *Server) Monitor() *swarmgo.RunMonitor {
+	return s.monitor
+}
+
+// RegisterAgent lists agents on the dashboard
+func (s *Server) RegisterAgent(agents ...*swarmgo.Agent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, agent := range agents {
+		s.agents[agent.Name] = agent
+	}
+}
+
+// RegisterTools lists the tools of a registry on the dashboard
+func (s *Server) RegisterTools(registry *swarmgo.ToolRegistry) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tools = registry
+}
+
+// Handler returns an http.Handler serving the dashboard at / and its data as JSON at
+// /agents, /agents/{name}/memory, /tools, /runs, /runs/{id} and /metrics
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("GET /agents", s.handleAgents)
+	mux.HandleFunc("GET /agents/{name}/memory", s.handleMemory)
+	mux.HandleFunc("GET /tools", s.handleTools)
+	mux.HandleFunc("GET /runs", s.handleRuns)
+	mux.HandleFunc("GET /runs/{id}", s.handleRun)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	return mux
+}
+
+// handleAgents lists the registered agents as definitions, plus their skills' tools
+func (s *Server) handleAgents(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	agents := make([]AgentInfo, 0, len(s.agents))
+	for _, agent := range s.agents {
+		info := AgentInfo{AgentDefinition: agent.Definition()}
+		if err := agent.Validate(); err != nil {
+			info.ValidationError = err.Error()
+		}
+		agents = append(agents, info)
+	}
+	s.mutex.Unlock()
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Name < agents[j].Name })
+	writeJSON(w, agents)
+}
+
+// handleMemory lists the most recent memories of an agent
+func (s *Server) handleMemory(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	agent, ok := s.agents[r.PathValue("name")]
+	s.mutex.Unlock()
+	if !ok {
+		http.Error(w, "unknown agent: "+r.PathValue("name"), http.StatusNotFound)
+		return
+	}
+	limit := DefaultMemoryLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			http.Error(w, "invalid limit: "+value, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	memories := []swarmgo.Memory{}
+	if agent.Memory != nil {
+		memories = append(memories, agent.Memory.GetRecentMemories(limit)...)
+	}
+	writeJSON(w, memories)
+}
+
+// handleTools lists the registered tools
+func (s *Server) handleTools(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	registry := s.tools
+	s.mutex.Unlock()
+	tools := []string{}
+	if registry != nil {
+		tools = registry.Tools()
+	}
+	writeJSON(w, tools)
+}
+
+// handleRuns lists the active runs and the recently finished ones, without transcripts
+func (s *Server) handleRuns(w http.ResponseWriter, r *http.Request) {
+	recent := s.monitor.Recent()
+	for i := range recent {
+		recent[i].Transcript = nil
+	}
+	writeJSON(w, RunsInfo{Active: s.monitor.Active(), Recent: recent})
+}
+
+// handleRun returns a run with its transcript
+func (s *Server) handleRun(w http.ResponseWriter, r *http.Request) {
+	run, ok := s.monitor.Run(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "unknown run: "+r.PathValue("id"), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, run)
+}
+
+// handleMetrics returns the totals over the observed runs
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.monitor.Metrics())
+}
+
+// handleIndex renders the dashboard
+func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	names := make([]string, 0, len(s.agents))
+	for name := range s.agents {
+		names = append(names, name)
+	}
+	s.mutex.Unlock()
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboard.Execute(w, dashboardData{
+		Agents:  names,
+		Active:  s.monitor.Active(),
+		Recent:  s.monitor.Recent(),
+		Metrics: s.monitor.Metrics(),
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeJSON writes v as an indented JSON response
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// dashboardData is rendered by the dashboard template
+type dashboardData struct {
+	Agents  []string
+	Active  []swarmgo.RunInfo
+	Recent  []swarmgo.RunInfo
+	Metrics swarmgo.RunMetrics
+}
+
+// dashboard is the HTML page served at /
+var dashboard = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>swarmgo debug</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+td, th { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }
+.failed { color: #b00; }
+</style>
+</head>
+<body>
+<h1>swarmgo debug</h1>
+<p>Runs: {{.Metrics.Started}} started, {{.Metrics.Completed}} completed, {{.Metrics.Failed}} failed, {{.Metrics.Active}} active.
+Turns: {{.Metrics.Turns}}. Tokens: {{.Metrics.Usage.TotalTokens}}. Provider time: {{.Metrics.Provider}}. Tool time: {{.Metrics.Tools}}.
+<a href="/metrics">JSON</a></p>
+
+<h2>Agents</h2>
+<ul>{{range .Agents}}<li>{{.}} (<a href="/agents/{{.}}/memory">memory</a>)</li>{{else}}<li>None registered</li>{{end}}</ul>
+<p><a href="/agents">Definitions</a> · <a href="/tools">Tools</a></p>
+
+<h2>Active runs</h2>
+<table>
+<tr><th>Run</th><th>Agent</th><th>Status</th><th>Turns</th><th>Tokens</th><th>Started</th></tr>
+{{range .Active}}<tr><td><a href="/runs/{{.ID}}">{{.ID}}</a></td><td>{{.ActiveAgent}}</td><td>{{.Status}}</td><td>{{.Turns}}</td><td>{{.Usage.TotalTokens}}</td><td>{{.StartedAt.Format "15:04:05"}}</td></tr>
+{{else}}<tr><td colspan="6">None</td></tr>{{end}}
+</table>
+
+<h2>Recent runs</h2>
+<table>
+<tr><th>Run</th><th>Agent</th><th>Status</th><th>Turns</th><th>Tokens</th><th>Ended</th><th>Error</th></tr>
+{{range .Recent}}<tr{{if .Error}} class="failed"{{end}}><td><a href="/runs/{{.ID}}">{{.ID}}</a></td><td>{{.ActiveAgent}}</td><td>{{.Status}}</td><td>{{.Turns}}</td><td>{{.Usage.TotalTokens}}</td><td>{{.EndedAt.Format "15:04:05"}}</td><td>{{.Error}}</td></tr>
+{{else}}<tr><td colspan="7">None</td></tr>{{end}}
+</table>
+</body>
+</html>
+`))
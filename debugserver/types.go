@@ -0,0 +1,17 @@
+package debugserver
+
+import (
+	"github.com/prathyushnallamothu/swarmgo"
+)
+
+// AgentInfo describes a registered agent
+type AgentInfo struct {
+	swarmgo.AgentDefinition
+	ValidationError string `json:"validation_error,omitempty"` // Problems reported by Agent.Validate
+}
+
+// RunsInfo lists the runs observed by the swarm's monitor
+type RunsInfo struct {
+	Active []swarmgo.RunInfo `json:"active"`
+	Recent []swarmgo.RunInfo `json:"recent"`
+}
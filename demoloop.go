@@ -58,7 +58,7 @@ func RunDemoLoop(client *Swarm, agent *Agent) {
 					fmt.Printf("\033[94m%s\033[0m: %s\n", response.Agent.Name, msg.Content)
 					lastAssistantMessage = msg
 				}
-			case llm.RoleFunction:
+			case llm.RoleFunction, llm.RoleTool:
 				fmt.Printf("\033[92m%s function Result\033[0m: %s\n", msg.Name, msg.Content)
 				functionMessages = append(functionMessages, msg)
 			}
@@ -0,0 +1,227 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultDocumentSections is the maximum number of sections a DocumentWriter outlines when none is configured
+const DefaultDocumentSections = 8
+
+// DefaultSectionWords is the target length of each section when none is configured
+const DefaultSectionWords = 400
+
+// documentContextChars is how much of the previous section is shown when writing the next one
+const documentContextChars = 1500
+
+// DocumentEventType is the kind of progress a DocumentWriter reports
+type DocumentEventType string
+
+const (
+	DocumentOutlined       DocumentEventType = "outlined"        // The outline was written
+	DocumentSectionStarted DocumentEventType = "section_started" // A section is being written
+	DocumentToken          DocumentEventType = "token"           // A token of the section being written
+	DocumentSectionDone    DocumentEventType = "section_done"    // A section was written
+)
+
+// DocumentEvent is a progress update of a DocumentWriter
+type DocumentEvent struct {
+	Type    DocumentEventType
+	Section int    // Index of the section, for section events
+	Total   int    // Number of sections in the outline
+	Title   string // Title of the section, or of the document for DocumentOutlined
+	Token   string // Streamed text, for DocumentToken
+	Text    string // Text of the section, for DocumentSectionDone
+}
+
+// DocumentSection is an entry of a document's outline
+type DocumentSection struct {
+	Title string `json:"title"`
+	Brief string `json:"brief"` // What the section covers
+}
+
+// Document is a document written by a DocumentWriter
+type Document struct {
+	Title    string
+	Outline  []DocumentSection
+	Sections []string // Text of each section, without its heading
+	Text     string   // The stitched document in Markdown
+}
+
+// DocumentWriter writes documents longer than a model can produce in one response, such as
+// reports: it outlines the document, writes each section in its own request with the outline
+// and the end of the previous section as context, and stitches the sections together. Sections
+// are streamed, so progress can be shown while the document is written.
+type DocumentWriter struct {
+	Model        string
+	Instructions string                    // Guidance on style, audience and format
+	MaxSections  int                       // Maximum number of sections, DefaultDocumentSections if not positive
+	SectionWords int                       // Target length of each section, DefaultSectionWords if not positive
+	OnEvent      func(event DocumentEvent) // Receives progress updates, if set
+	swarm        *Swarm
+}
+
+// NewDocumentWriter creates a document writer using the model
+func NewDocumentWriter(sw *Swarm, model string) *DocumentWriter {
+	return &DocumentWriter{Model: model, swarm: sw}
+}
+
+// WithInstructions sets guidance on the style, audience and format of documents
+func (w *DocumentWriter) WithInstructions(instructions string) *DocumentWriter {
+	w.Instructions = instructions
+	return w
+}
+
+// WithSections sets the maximum number of sections and the target length of each
+func (w *DocumentWriter) WithSections(maxSections, sectionWords int) *DocumentWriter {
+	w.MaxSections = maxSections
+	w.SectionWords = sectionWords
+	return w
+}
+
+// WithProgress sets the function receiving progress updates
+func (w *DocumentWriter) WithProgress(onEvent func(event DocumentEvent)) *DocumentWriter {
+	w.OnEvent = onEvent
+	return w
+}
+
+// Write writes a document for the task
+func (w *DocumentWriter) Write(ctx context.Context, task string) (Document, error) {
+	maxSections := w.MaxSections
+	if maxSections <= 0 {
+		maxSections = DefaultDocumentSections
+	}
+	sectionWords := w.SectionWords
+	if sectionWords <= 0 {
+		sectionWords = DefaultSectionWords
+	}
+
+	doc, err := w.outline(ctx, task, maxSections)
+	if err != nil {
+		return doc, err
+	}
+	w.emit(DocumentEvent{Type: DocumentOutlined, Total: len(doc.Outline), Title: doc.Title})
+
+	var outline strings.Builder
+	for i, section := range doc.Outline {
+		fmt.Fprintf(&outline, "%d. %s: %s\n", i+1, section.Title, section.Brief)
+	}
+	writer := NewAgent("DocumentWriter", w.Model, w.swarm.provider).WithInstructions(w.instructions())
+	for i, section := range doc.Outline {
+		w.emit(DocumentEvent{Type: DocumentSectionStarted, Section: i, Total: len(doc.Outline), Title: section.Title})
+
+		var prompt strings.Builder
+		fmt.Fprintf(&prompt, "You are writing the document %q for this task:\n%s\n\nOutline:\n%s\n", doc.Title, task, outline.String())
+		if i > 0 {
+			previous := doc.Sections[i-1]
+			if len(previous) > documentContextChars {
+				previous = "..." + previous[len(previous)-documentContextChars:]
+			}
+			fmt.Fprintf(&prompt, "The previous section, %q, ended with:\n%s\n\n", doc.Outline[i-1].Title, previous)
+		}
+		fmt.Fprintf(&prompt, "Write section %d, %q, covering: %s\nWrite about %d words. Write only the body of this section, without its heading, and do not repeat earlier sections or anticipate later ones.", i+1, section.Title, section.Brief, sectionWords)
+
+		handler := &documentStreamHandler{writer: w, section: i, total: len(doc.Outline), title: section.Title}
+		messages, err := w.swarm.streamMessages(ctx, writer, []llm.Message{{Role: llm.RoleUser, Content: prompt.String()}}, nil, "", handler, false)
+		if err != nil {
+			return doc, fmt.Errorf("failed to write section %d of %d: %w", i+1, len(doc.Outline), err)
+		}
+		text := stripHeading(strings.TrimSpace(lastAssistantContent(messages)), section.Title)
+		doc.Sections = append(doc.Sections, text)
+		w.emit(DocumentEvent{Type: DocumentSectionDone, Section: i, Total: len(doc.Outline), Title: section.Title, Text: text})
+	}
+
+	var stitched strings.Builder
+	fmt.Fprintf(&stitched, "# %s\n", doc.Title)
+	for i, section := range doc.Outline {
+		fmt.Fprintf(&stitched, "\n## %s\n\n%s\n", section.Title, doc.Sections[i])
+	}
+	doc.Text = stitched.String()
+	return doc, nil
+}
+
+// outline asks the model for the document's title and sections
+func (w *DocumentWriter) outline(ctx context.Context, task string, maxSections int) (Document, error) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title": map[string]interface{}{"type": "string"},
+			"sections": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"title": map[string]interface{}{"type": "string"},
+						"brief": map[string]interface{}{"type": "string"},
+					},
+					"required":             []string{"title", "brief"},
+					"additionalProperties": false,
+				},
+			},
+		},
+		"required":             []string{"title", "sections"},
+		"additionalProperties": false,
+	}
+
+	var outline struct {
+		Title    string            `json:"title"`
+		Sections []DocumentSection `json:"sections"`
+	}
+	planner := NewAgent("DocumentOutliner", w.Model, w.swarm.provider).WithInstructions(w.instructions())
+	prompt := fmt.Sprintf("Outline a document for this task in at most %d sections. Give the document a title, and each section a title and a brief of what it covers.\n\nTask: %s", maxSections, task)
+	_, err := w.swarm.runStructured(ctx, planner, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, schema, &outline, func() error {
+		if len(outline.Sections) == 0 {
+			return fmt.Errorf("the outline has no sections")
+		}
+		if len(outline.Sections) > maxSections {
+			return fmt.Errorf("the outline has %d sections, at most %d are allowed", len(outline.Sections), maxSections)
+		}
+		return nil
+	}, DefaultStructuredRetries)
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to outline document: %w", err)
+	}
+	return Document{Title: outline.Title, Outline: outline.Sections}, nil
+}
+
+// instructions returns the system prompt of the writer's agents
+func (w *DocumentWriter) instructions() string {
+	instructions := "You are an expert writer producing long, well-structured documents in Markdown."
+	if w.Instructions != "" {
+		instructions += "\n" + w.Instructions
+	}
+	return instructions
+}
+
+// emit reports a progress update
+func (w *DocumentWriter) emit(event DocumentEvent) {
+	if w.OnEvent != nil {
+		w.OnEvent(event)
+	}
+}
+
+// stripHeading removes a leading Markdown heading repeating the section's title
+func stripHeading(text, title string) string {
+	first, rest, _ := strings.Cut(text, "\n")
+	if strings.HasPrefix(first, "#") && strings.EqualFold(strings.TrimSpace(strings.TrimLeft(first, "#")), title) {
+		return strings.TrimSpace(rest)
+	}
+	return text
+}
+
+// documentStreamHandler forwards the tokens of a section to the writer's progress updates
+type documentStreamHandler struct {
+	DefaultStreamHandler
+	writer  *DocumentWriter
+	section int
+	total   int
+	title   string
+}
+
+// OnToken reports a streamed token of the section
+func (h *documentStreamHandler) OnToken(token string) {
+	h.writer.emit(DocumentEvent{Type: DocumentToken, Section: h.section, Total: h.total, Title: h.title, Token: token})
+}
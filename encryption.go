@@ -0,0 +1,73 @@
+package swarmgo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// Cipher encrypts data before it is written to storage and decrypts it when read back. The
+// associated data, such as the ID of a stored session, is authenticated but not encrypted: a
+// ciphertext only decrypts with the associated data it was sealed with, so one record cannot
+// be swapped for another.
+type Cipher interface {
+	Encrypt(plaintext, associatedData []byte) ([]byte, error)
+	Decrypt(ciphertext, associatedData []byte) ([]byte, error)
+}
+
+// AESGCMCipher encrypts with AES-GCM, prefixing each ciphertext with its random nonce
+type AESGCMCipher struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMCipher creates a cipher from a 16, 24 or 32 byte key
+func NewAESGCMCipher(key []byte) (*AESGCMCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMCipher{aead: aead}, nil
+}
+
+// NewAESGCMCipherFromCredential creates a cipher from a base64-encoded key supplied by a credential provider
+func NewAESGCMCipherFromCredential(ctx context.Context, provider CredentialProvider, name string) (*AESGCMCipher, error) {
+	encoded, err := provider.GetCredential(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key %s is not valid base64: %w", name, err)
+	}
+	return NewAESGCMCipher(key)
+}
+
+// Encrypt seals the plaintext, binding it to the associated data
+func (c *AESGCMCipher) Encrypt(plaintext, associatedData []byte) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, plaintext, associatedData), nil
+}
+
+// Decrypt opens a ciphertext produced by Encrypt with the same associated data
+func (c *AESGCMCipher) Decrypt(ciphertext, associatedData []byte) ([]byte, error) {
+	size := c.aead.NonceSize()
+	if len(ciphertext) < size {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := c.aead.Open(nil, ciphertext[:size], ciphertext[size:], associatedData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
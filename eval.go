@@ -0,0 +1,107 @@
+package swarmgo
+
+import (
+	"context"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// EvalCase is a single scenario an agent is evaluated against
+type EvalCase struct {
+	Name             string
+	Messages         []llm.Message
+	ContextVariables map[string]interface{}
+	// Environment, if set, backs the agent's tools for this case and is reset before it runs
+	Environment *SimulatedEnvironment
+	// Check inspects the outcome and returns an error describing why the case failed
+	Check func(response Response, env *SimulatedEnvironment) error
+}
+
+// EvalResult is the outcome of one EvalCase
+type EvalResult struct {
+	Name     string
+	Passed   bool
+	Err      error // Run error or the error returned by Check
+	Response Response
+	Duration time.Duration
+}
+
+// EvalReport summarizes an evaluation
+type EvalReport struct {
+	Results []EvalResult
+	Passed  int
+	Failed  int
+	// Variants breaks the results down by the prompt variant served to the evaluated agent.
+	// Cases served no variant are not included.
+	Variants map[string]VariantMetrics
+}
+
+// VariantMetrics summarizes the eval cases served one prompt variant
+type VariantMetrics struct {
+	Runs     int
+	Passed   int
+	Failed   int
+	Duration time.Duration // Total duration of the cases
+	Usage    llm.Usage     // Token usage summed over the cases
+}
+
+// PassRate returns the fraction of the variant's cases that passed
+func (m VariantMetrics) PassRate() float64 {
+	if m.Runs == 0 {
+		return 0
+	}
+	return float64(m.Passed) / float64(m.Runs)
+}
+
+// Evaluate runs every case against the agent and reports which passed. Cases run in order
+// with the given options; each case's messages and context variables replace those in opts.
+func (s *Swarm) Evaluate(ctx context.Context, agent *Agent, cases []EvalCase, opts RunOptions) EvalReport {
+	var report EvalReport
+	for _, c := range cases {
+		caseAgent := agent
+		if c.Environment != nil {
+			c.Environment.Reset()
+			caseAgent = c.Environment.Apply(agent)
+		}
+
+		caseOpts := opts
+		caseOpts.ContextVariables = make(map[string]interface{}, len(c.ContextVariables))
+		for k, v := range c.ContextVariables {
+			caseOpts.ContextVariables[k] = v
+		}
+
+		start := time.Now()
+		response, err := s.RunWithOptions(ctx, caseAgent, c.Messages, caseOpts)
+		result := EvalResult{Name: c.Name, Response: response, Duration: time.Since(start)}
+		if err == nil && c.Check != nil {
+			err = c.Check(response, c.Environment)
+		}
+		result.Err = err
+		result.Passed = err == nil
+
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+
+		if variant, ok := response.PromptVariants[caseAgent.Name]; ok {
+			if report.Variants == nil {
+				report.Variants = make(map[string]VariantMetrics)
+			}
+			metrics := report.Variants[variant]
+			metrics.Runs++
+			if result.Passed {
+				metrics.Passed++
+			} else {
+				metrics.Failed++
+			}
+			metrics.Duration += result.Duration
+			metrics.Usage = addUsage(metrics.Usage, response.Usage)
+			report.Variants[variant] = metrics
+		}
+	}
+	return report
+}
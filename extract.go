@@ -0,0 +1,152 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// extractInstructions tells the extraction model what to do
+const extractInstructions = `Extract the information described by the JSON schema from the user's text. Only use what the text states; leave out fields the text does not mention.`
+
+// ExtractOptions configures an extraction
+type ExtractOptions[T any] struct {
+	Instructions string // Additional guidance, such as how to normalize values
+	ChunkSize    int    // Size in characters above which text is extracted in chunks, DefaultChunkSize if zero
+	ChunkOverlap int    // Characters repeated between consecutive chunks
+	Concurrency  int    // Maximum number of chunks extracted at once, unlimited if not positive
+	Retries      int    // Corrections of invalid replies per chunk, DefaultStructuredRetries if zero
+	// Merge combines the values extracted from the chunks of a long text, in text order.
+	// MergeExtracted if nil.
+	Merge func(parts []T) T
+}
+
+// Extract pulls a value of type T, typically a struct whose fields describe the entities to
+// find, out of unstructured text with structured output. Long texts are extracted in chunks
+// whose values are merged with MergeExtracted.
+func Extract[T any](ctx context.Context, sw *Swarm, model, text string) (T, error) {
+	return ExtractWithOptions(ctx, sw, model, text, ExtractOptions[T]{})
+}
+
+// ExtractWithOptions pulls a value of type T out of unstructured text as configured by opts
+func ExtractWithOptions[T any](ctx context.Context, sw *Swarm, model, text string, opts ExtractOptions[T]) (T, error) {
+	var zero T
+	schema, err := parameterSchema[T]()
+	if err != nil {
+		return zero, err
+	}
+	chunks := ChunkText(text, opts.ChunkSize, opts.ChunkOverlap)
+	if len(chunks) == 0 {
+		return zero, errors.New("no text to extract from")
+	}
+
+	instructions := extractInstructions
+	if opts.Instructions != "" {
+		instructions += "\n" + opts.Instructions
+	}
+	retries := opts.Retries
+	if retries == 0 {
+		retries = DefaultStructuredRetries
+	}
+
+	parts := make([]T, len(chunks))
+	errs := make([]error, len(chunks))
+	limit := opts.Concurrency
+	if limit <= 0 {
+		limit = len(chunks)
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if len(chunks) > 1 {
+				chunk = fmt.Sprintf("[Part %d of %d of a longer text]\n\n%s", i+1, len(chunks), chunk)
+			}
+			agent := NewAgent("Extractor", model, sw.provider).WithInstructions(instructions)
+			_, errs[i] = sw.runStructured(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: chunk}}, schema, &parts[i], nil, retries)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return zero, fmt.Errorf("failed to extract from chunk %d of %d: %w", i+1, len(chunks), err)
+		}
+	}
+	if len(parts) == 1 {
+		return parts[0], nil
+	}
+	merge := opts.Merge
+	if merge == nil {
+		merge = MergeExtracted[T]
+	}
+	return merge(parts), nil
+}
+
+// MergeExtracted combines values extracted from the chunks of a text: the first non-zero value
+// of every field is kept, structs and maps are merged field by field and key by key, and slices
+// are concatenated without duplicates
+func MergeExtracted[T any](parts []T) T {
+	var merged T
+	target := reflect.ValueOf(&merged).Elem()
+	for _, part := range parts {
+		mergeValue(target, reflect.ValueOf(part))
+	}
+	return merged
+}
+
+// mergeValue merges src into dst, which must be settable
+func mergeValue(dst, src reflect.Value) {
+	if !src.IsValid() || src.IsZero() {
+		return
+	}
+	if dst.IsZero() {
+		dst.Set(src)
+		return
+	}
+	switch dst.Kind() {
+	case reflect.Struct:
+		for i := 0; i < dst.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				mergeValue(dst.Field(i), src.Field(i))
+			}
+		}
+	case reflect.Pointer:
+		mergeValue(dst.Elem(), src.Elem())
+	case reflect.Map:
+		for _, key := range src.MapKeys() {
+			existing := dst.MapIndex(key)
+			if !existing.IsValid() {
+				dst.SetMapIndex(key, src.MapIndex(key))
+				continue
+			}
+			value := reflect.New(existing.Type()).Elem()
+			value.Set(existing)
+			mergeValue(value, src.MapIndex(key))
+			dst.SetMapIndex(key, value)
+		}
+	case reflect.Slice:
+		for i := 0; i < src.Len(); i++ {
+			item := src.Index(i)
+			duplicate := false
+			for j := 0; j < dst.Len(); j++ {
+				if reflect.DeepEqual(dst.Index(j).Interface(), item.Interface()) {
+					duplicate = true
+					break
+				}
+			}
+			if !duplicate {
+				dst.Set(reflect.Append(dst, item))
+			}
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrInjectedFault is wrapped by every fault a FaultInjector injects
+var ErrInjectedFault = errors.New("injected fault")
+
+// ErrInjectedRateLimit is returned by requests a FaultInjector rejects as rate limited
+var ErrInjectedRateLimit = fmt.Errorf("%w: 429 Too Many Requests", ErrInjectedFault)
+
+// FaultKind is a kind of fault a FaultInjector injects
+type FaultKind string
+
+const (
+	FaultLatency         FaultKind = "latency"          // Request delayed by FaultPolicy.Latency
+	FaultRateLimit       FaultKind = "rate_limit"       // Request failed with ErrInjectedRateLimit
+	FaultMalformedJSON   FaultKind = "malformed_json"   // Tool call arguments truncated into invalid JSON
+	FaultTruncatedStream FaultKind = "truncated_stream" // Stream cut off with an error before it ended
+	FaultToolFailure     FaultKind = "tool_failure"     // Tool call failed without running the tool
+)
+
+// FaultPolicy configures which faults a FaultInjector injects. Rates are probabilities between
+// 0 and 1, drawn for every request, stream or tool call.
+type FaultPolicy struct {
+	Seed               int64         // Seed of the random source, so runs inject the same faults
+	LatencyRate        float64       // Rate of requests delayed by Latency
+	Latency            time.Duration // Delay added to delayed requests
+	RateLimitRate      float64       // Rate of requests failed as rate limited
+	MalformedJSONRate  float64       // Rate of responses whose tool call arguments are corrupted
+	TruncateStreamRate float64       // Rate of streams cut off before they end
+	ToolFailureRate    float64       // Rate of tool calls that fail
+}
+
+// FaultInjector injects faults into LLM clients and tools, to test how retries, failover and
+// error handling cope with them. Faults are drawn from a random source seeded by the policy,
+// so sequential runs inject the same faults; concurrent requests draw in the order they arrive.
+type FaultInjector struct {
+	policy   FaultPolicy
+	mu       sync.Mutex
+	rng      *rand.Rand
+	injected map[FaultKind]int
+}
+
+// NewFaultInjector creates a fault injector with the given policy
+func NewFaultInjector(policy FaultPolicy) *FaultInjector {
+	return &FaultInjector{
+		policy:   policy,
+		rng:      rand.New(rand.NewSource(policy.Seed)),
+		injected: make(map[FaultKind]int),
+	}
+}
+
+// Injected returns the number of faults injected so far, by kind
+func (f *FaultInjector) Injected() map[FaultKind]int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	injected := make(map[FaultKind]int, len(f.injected))
+	for kind, n := range f.injected {
+		injected[kind] = n
+	}
+	return injected
+}
+
+// inject reports whether to inject a fault of the kind, drawn at the rate
+func (f *FaultInjector) inject(kind FaultKind, rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.rng.Float64() >= rate {
+		return false
+	}
+	f.injected[kind]++
+	return true
+}
+
+// intn returns a random number in [0, n)
+func (f *FaultInjector) intn(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rng.Intn(n)
+}
+
+// beforeRequest applies the latency and rate limit faults of a request
+func (f *FaultInjector) beforeRequest(ctx context.Context) error {
+	if f.inject(FaultLatency, f.policy.LatencyRate) {
+		select {
+		case <-time.After(f.policy.Latency):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if f.inject(FaultRateLimit, f.policy.RateLimitRate) {
+		return ErrInjectedRateLimit
+	}
+	return nil
+}
+
+// corrupt truncates the arguments of the tool calls in the response's messages, leaving the
+// response it was given unchanged
+func (f *FaultInjector) corrupt(resp llm.ChatCompletionResponse) llm.ChatCompletionResponse {
+	resp.Choices = append([]llm.Choice(nil), resp.Choices...)
+	for i, choice := range resp.Choices {
+		if len(choice.Message.ToolCalls) == 0 || !f.inject(FaultMalformedJSON, f.policy.MalformedJSONRate) {
+			continue
+		}
+		calls := make([]llm.ToolCall, len(choice.Message.ToolCalls))
+		for j, call := range choice.Message.ToolCalls {
+			call.Function.Arguments = truncateJSON(call.Function.Arguments)
+			calls[j] = call
+		}
+		resp.Choices[i].Message.ToolCalls = calls
+	}
+	return resp
+}
+
+// truncateJSON cuts a JSON document in half, leaving it unterminated
+func truncateJSON(s string) string {
+	if len(s) < 2 {
+		return "{"
+	}
+	return s[:len(s)/2]
+}
+
+// WrapLLM returns a client that injects faults into the requests made to client
+func (f *FaultInjector) WrapLLM(client llm.LLM) llm.LLM {
+	return &faultyLLM{client: client, faults: f}
+}
+
+// WithFaultInjector injects faults into the swarm's requests. Tool failures are injected into
+// the agents returned by FaultInjector.WrapAgent.
+func (s *Swarm) WithFaultInjector(f *FaultInjector) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wrapClients(f.WrapLLM)
+	return s
+}
+
+// faultyLLM is an LLM client that injects faults
+type faultyLLM struct {
+	client llm.LLM
+	faults *FaultInjector
+}
+
+// CreateChatCompletion implements llm.LLM
+func (c *faultyLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	if err := c.faults.beforeRequest(ctx); err != nil {
+		return llm.ChatCompletionResponse{}, err
+	}
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	return c.faults.corrupt(resp), nil
+}
+
+// CreateChatCompletionStream implements llm.LLM
+func (c *faultyLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	if err := c.faults.beforeRequest(ctx); err != nil {
+		return nil, err
+	}
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	faulty := &faultyStream{stream: stream, faults: c.faults, cutAfter: -1}
+	if c.faults.inject(FaultTruncatedStream, c.faults.policy.TruncateStreamRate) {
+		faulty.cutAfter = c.faults.intn(4)
+	}
+	return faulty, nil
+}
+
+// faultyStream is a stream that corrupts tool calls and may be cut off
+type faultyStream struct {
+	stream   llm.ChatCompletionStream
+	faults   *FaultInjector
+	cutAfter int // Chunks received before the stream is cut off, -1 if it is not
+	received int
+}
+
+// Recv implements llm.ChatCompletionStream
+func (s *faultyStream) Recv() (llm.ChatCompletionResponse, error) {
+	if s.cutAfter >= 0 && s.received >= s.cutAfter {
+		return llm.ChatCompletionResponse{}, fmt.Errorf("%w: stream truncated after %d chunks", ErrInjectedFault, s.received)
+	}
+	chunk, err := s.stream.Recv()
+	if err != nil {
+		return chunk, err
+	}
+	s.received++
+	return s.faults.corrupt(chunk), nil
+}
+
+// Close implements llm.ChatCompletionStream
+func (s *faultyStream) Close() error {
+	return s.stream.Close()
+}
+
+// WrapAgent returns a copy of the agent whose tools fail at the policy's tool failure rate,
+// without running. The agent itself is not modified.
+func (f *FaultInjector) WrapAgent(agent *Agent) *Agent {
+	faulty := *agent
+	faulty.Functions = f.wrapFunctions(agent.Functions)
+	faulty.Skills = make([]*Skill, len(agent.Skills))
+	for i, skill := range agent.Skills {
+		copied := *skill
+		copied.Functions = f.wrapFunctions(skill.Functions)
+		faulty.Skills[i] = &copied
+	}
+	return &faulty
+}
+
+// wrapFunctions wraps the executors of the functions to inject tool failures
+func (f *FaultInjector) wrapFunctions(functions []AgentFunction[map[string]interface{}]) []AgentFunction[map[string]interface{}] {
+	wrapped := make([]AgentFunction[map[string]interface{}], len(functions))
+	for i, af := range functions {
+		name := af.Name
+		failure := func() (Result, bool) {
+			if !f.inject(FaultToolFailure, f.policy.ToolFailureRate) {
+				return Result{}, false
+			}
+			return Result{Success: false, Error: fmt.Errorf("%w: tool %s failed", ErrInjectedFault, name)}, true
+		}
+		if executor := af.executor; executor != nil {
+			af.executor = func(args map[string]interface{}, contextVariables map[string]interface{}) Result {
+				if result, failed := failure(); failed {
+					return result
+				}
+				return executor(args, contextVariables)
+			}
+		}
+		if executor := af.contextExecutor; executor != nil {
+			af.contextExecutor = func(ctx context.Context, args map[string]interface{}, contextVariables map[string]interface{}) Result {
+				if result, failed := failure(); failed {
+					return result
+				}
+				return executor(ctx, args, contextVariables)
+			}
+		}
+		wrapped[i] = af
+	}
+	return wrapped
+}
@@ -0,0 +1,76 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestFaultInjector tests injecting rate limits, malformed tool calls, truncated streams and
+// tool failures
+func TestFaultInjector(t *testing.T) {
+	ctx := context.Background()
+	mockClient := new(MockLLM)
+	toolCall := llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{
+		Role:      llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: `{"query":"weather"}`}}},
+	}}}}
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(toolCall, nil)
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{
+		ctx:    ctx,
+		chunks: []llm.ChatCompletionResponse{tokenChunk("a"), tokenChunk("b"), tokenChunk("c"), tokenChunk("d"), tokenChunk("e")},
+	}, nil)
+
+	limited := NewFaultInjector(FaultPolicy{Seed: 1, RateLimitRate: 1})
+	_, err := limited.WrapLLM(mockClient).CreateChatCompletion(ctx, llm.ChatCompletionRequest{})
+	assert.ErrorIs(t, err, ErrInjectedRateLimit)
+	assert.ErrorIs(t, err, ErrInjectedFault)
+
+	malformed := NewFaultInjector(FaultPolicy{Seed: 1, MalformedJSONRate: 1})
+	resp, err := malformed.WrapLLM(mockClient).CreateChatCompletion(ctx, llm.ChatCompletionRequest{})
+	assert.NoError(t, err)
+	assert.False(t, json.Valid([]byte(resp.Choices[0].Message.ToolCalls[0].Function.Arguments)))
+	assert.Equal(t, `{"query":"weather"}`, toolCall.Choices[0].Message.ToolCalls[0].Function.Arguments)
+
+	truncated := NewFaultInjector(FaultPolicy{Seed: 1, TruncateStreamRate: 1})
+	stream, err := truncated.WrapLLM(mockClient).CreateChatCompletionStream(ctx, llm.ChatCompletionRequest{})
+	assert.NoError(t, err)
+	received := 0
+	for ; received < 10; received++ {
+		if _, err = stream.Recv(); err != nil {
+			break
+		}
+	}
+	assert.ErrorIs(t, err, ErrInjectedFault)
+	assert.Less(t, received, 4)
+
+	ran := 0
+	fn, _ := NewAgentFunction("lookup", "Look something up", func(args struct{}, contextVariables map[string]interface{}) Result {
+		ran++
+		return Result{Success: true, Data: "sunny"}
+	})
+	agent := NewAgent("Agent", "gpt-4", llm.OpenAI).WithFunctions(fn)
+	failing := NewFaultInjector(FaultPolicy{Seed: 1, ToolFailureRate: 1}).WrapAgent(agent)
+	result := failing.Functions[0].executor(map[string]interface{}{}, map[string]interface{}{})
+	assert.False(t, result.Success)
+	assert.ErrorIs(t, result.Error, ErrInjectedFault)
+	assert.Equal(t, 0, ran)
+	agent.Functions[0].executor(map[string]interface{}{}, map[string]interface{}{})
+	assert.Equal(t, 1, ran)
+
+	// The same seed injects the same faults
+	draws := func() []bool {
+		f := NewFaultInjector(FaultPolicy{Seed: 42, ToolFailureRate: 0.5})
+		var failed []bool
+		for i := 0; i < 20; i++ {
+			failed = append(failed, f.inject(FaultToolFailure, 0.5))
+		}
+		return failed
+	}
+	assert.Equal(t, draws(), draws())
+	assert.Equal(t, 1, limited.Injected()[FaultRateLimit])
+}
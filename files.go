@@ -0,0 +1,161 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// uploadedFile is a file the swarm uploaded and deletes when it expires
+type uploadedFile struct {
+	client  llm.LLM
+	expires time.Time // Zero if the file only expires with the swarm
+}
+
+// WithFileTTL sets how long files uploaded through the swarm are kept before SweepFiles
+// deletes them. Files are kept until deleted or until the swarm shuts down if not positive.
+func (s *Swarm) WithFileTTL(ttl time.Duration) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fileTTL = ttl
+	return s
+}
+
+// UploadFile uploads a document to the file store of the client serving the agent, so it can
+// be attached to messages with llm.Message.Files. The swarm keeps track of the upload: expired
+// uploads are deleted by SweepFiles, which runs before every upload, and the remaining ones
+// when the swarm shuts down.
+func (s *Swarm) UploadFile(ctx context.Context, agent *Agent, upload llm.FileUpload) (llm.FileRef, error) {
+	if err := s.SweepFiles(ctx); err != nil {
+		return llm.FileRef{}, err
+	}
+	client, err := s.clientFor(agent)
+	if err != nil {
+		return llm.FileRef{}, err
+	}
+	file, err := llm.UploadFile(ctx, client, upload)
+	if err != nil {
+		return llm.FileRef{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.uploads == nil {
+		s.uploads = make(map[string]uploadedFile)
+		s.shutdownHooks = append(s.shutdownHooks, s.deleteAllFiles)
+	}
+	tracked := uploadedFile{client: client}
+	if s.fileTTL > 0 {
+		tracked.expires = time.Now().Add(s.fileTTL)
+	}
+	s.uploads[file.ID] = tracked
+	return file, nil
+}
+
+// DeleteFile deletes a file uploaded through the swarm
+func (s *Swarm) DeleteFile(ctx context.Context, id string) error {
+	s.mu.Lock()
+	tracked, ok := s.uploads[id]
+	s.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("file %s was not uploaded through the swarm", id)
+	}
+	if err := llm.DeleteFile(ctx, tracked.client, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	return nil
+}
+
+// Files returns the IDs of the files uploaded through the swarm that are not deleted yet
+func (s *Swarm) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.uploads))
+	for id := range s.uploads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// SweepFiles deletes the uploaded files whose TTL has passed
+func (s *Swarm) SweepFiles(ctx context.Context) error {
+	return s.deleteFiles(ctx, func(id string, file uploadedFile) bool {
+		return !file.expires.IsZero() && time.Now().After(file.expires)
+	})
+}
+
+// deleteAllFiles deletes every file still tracked, when the swarm shuts down
+func (s *Swarm) deleteAllFiles(ctx context.Context) error {
+	return s.deleteFiles(ctx, func(string, uploadedFile) bool { return true })
+}
+
+// deleteFiles deletes the tracked files matching the predicate, reporting all failures
+func (s *Swarm) deleteFiles(ctx context.Context, match func(id string, file uploadedFile) bool) error {
+	s.mu.Lock()
+	var ids []string
+	for id, file := range s.uploads {
+		if match(id, file) {
+			ids = append(ids, id)
+		}
+	}
+	s.mu.Unlock()
+
+	var errs []error
+	for _, id := range ids {
+		if err := s.DeleteFile(ctx, id); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Attach uploads a document for the session's agent and attaches it to the next user message
+// sent. The session's uploads are deleted by End.
+func (s *Session) Attach(ctx context.Context, upload llm.FileUpload) (llm.FileRef, error) {
+	s.mu.Lock()
+	agent := s.Agent
+	s.mu.Unlock()
+	file, err := s.swarm.UploadFile(ctx, agent, upload)
+	if err != nil {
+		return llm.FileRef{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files = append(s.files, file.ID)
+	s.attachments = append(s.attachments, file)
+	return file, nil
+}
+
+// End deletes the files uploaded for the session. The history is kept, but messages
+// referencing the deleted files can no longer be sent.
+func (s *Session) End(ctx context.Context) error {
+	s.mu.Lock()
+	files := make(map[string]bool, len(s.files))
+	for _, id := range s.files {
+		files[id] = true
+	}
+	s.files = nil
+	s.attachments = nil
+	s.mu.Unlock()
+
+	// Files the swarm already deleted, e.g. once they expired, are skipped
+	return s.swarm.deleteFiles(ctx, func(id string, file uploadedFile) bool { return files[id] })
+}
+
+// takeAttachments attaches the files attached since the last user message. The session's
+// lock must be held.
+func (s *Session) takeAttachments(message llm.Message) llm.Message {
+	if message.Role == llm.RoleUser && len(s.attachments) > 0 {
+		message.Files = append(message.Files, s.attachments...)
+		s.attachments = nil
+	}
+	return message
+}
@@ -0,0 +1,134 @@
+package swarmgo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// FlagProvider decides whether feature flags are enabled. It is consulted at most once per
+// flag and run, so a run sees consistent values.
+type FlagProvider interface {
+	Enabled(ctx context.Context, flag string, contextVariables map[string]interface{}) bool
+}
+
+// FlagProviderFunc adapts a function to the FlagProvider interface
+type FlagProviderFunc func(ctx context.Context, flag string, contextVariables map[string]interface{}) bool
+
+// Enabled calls f
+func (f FlagProviderFunc) Enabled(ctx context.Context, flag string, contextVariables map[string]interface{}) bool {
+	return f(ctx, flag, contextVariables)
+}
+
+// RolloutFlags enables each flag for a fixed fraction of sessions. The session is identified
+// by the context variable named by Key; runs without it get a flag only at a 100% rollout.
+type RolloutFlags struct {
+	Key         string             // Context variable identifying the session, e.g. "session_id"
+	Percentages map[string]float64 // Fraction of sessions, from 0 to 1, each flag is enabled for
+}
+
+// Enabled reports whether the session falls within the flag's rollout
+func (r RolloutFlags) Enabled(ctx context.Context, flag string, contextVariables map[string]interface{}) bool {
+	percentage := r.Percentages[flag]
+	if percentage >= 1 {
+		return true
+	}
+	session, ok := contextVariables[r.Key].(string)
+	if !ok || session == "" || percentage <= 0 {
+		return false
+	}
+	return stableFraction(flag+"/"+session) < percentage
+}
+
+// WithFlagProvider sets the provider consulted for feature flags during runs
+func (s *Swarm) WithFlagProvider(provider FlagProvider) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flagProvider = provider
+	return s
+}
+
+// WithFlag returns a copy of the function that is only offered and executed while the
+// feature flag is enabled. Without a flag provider the function is disabled.
+func (af AgentFunction[I]) WithFlag(flag string) AgentFunction[I] {
+	af.Flag = flag
+	return af
+}
+
+// flagEvaluations caches the flags evaluated during a run
+type flagEvaluations struct {
+	provider         FlagProvider
+	contextVariables map[string]interface{}
+
+	mu     sync.Mutex
+	values map[string]bool
+}
+
+type flagEvaluationsKey struct{}
+
+// withFlags returns a context that evaluates flags with the swarm's provider, reusing the
+// evaluations of an enclosing run
+func (s *Swarm) withFlags(ctx context.Context, contextVariables map[string]interface{}) (context.Context, *flagEvaluations) {
+	if flags := flagsFromContext(ctx); flags != nil {
+		return ctx, flags
+	}
+	s.mu.Lock()
+	provider := s.flagProvider
+	s.mu.Unlock()
+	flags := &flagEvaluations{provider: provider, contextVariables: contextVariables, values: make(map[string]bool)}
+	return context.WithValue(ctx, flagEvaluationsKey{}, flags), flags
+}
+
+// flagsFromContext returns the flag evaluations of the run the context belongs to, if any
+func flagsFromContext(ctx context.Context) *flagEvaluations {
+	flags, _ := ctx.Value(flagEvaluationsKey{}).(*flagEvaluations)
+	return flags
+}
+
+// flagEnabled reports whether the flag is enabled for the run the context belongs to
+func flagEnabled(ctx context.Context, flag string) bool {
+	return flagsFromContext(ctx).enabled(ctx, flag)
+}
+
+// enabled evaluates the flag on first use. An empty flag is always enabled.
+func (f *flagEvaluations) enabled(ctx context.Context, flag string) bool {
+	if flag == "" {
+		return true
+	}
+	if f == nil || f.provider == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if value, ok := f.values[flag]; ok {
+		return value
+	}
+	value := f.provider.Enabled(ctx, flag, f.contextVariables)
+	f.values[flag] = value
+	return value
+}
+
+// snapshot returns the flags evaluated so far
+func (f *flagEvaluations) snapshot() map[string]bool {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.values) == 0 {
+		return nil
+	}
+	values := make(map[string]bool, len(f.values))
+	for name, value := range f.values {
+		values[name] = value
+	}
+	return values
+}
+
+// stableFraction maps a key to a fraction in [0, 1) that is evenly distributed across keys
+// and always the same for the same key
+func stableFraction(key string) float64 {
+	sum := sha256.Sum256([]byte(key))
+	return float64(binary.BigEndian.Uint64(sum[:8])>>11) / (1 << 53)
+}
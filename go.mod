@@ -12,6 +12,7 @@ require (
 	github.com/ollama/ollama v0.5.4
 	github.com/sashabaranov/go-openai v1.32.2
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/oauth2 v0.24.0
 	google.golang.org/api v0.209.0
 )
 
@@ -49,7 +50,6 @@ require (
 	go.opentelemetry.io/otel/trace v1.29.0 // indirect
 	golang.org/x/crypto v0.29.0 // indirect
 	golang.org/x/net v0.31.0 // indirect
-	golang.org/x/oauth2 v0.24.0 // indirect
 	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
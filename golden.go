@@ -0,0 +1,236 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrGoldenMismatch is returned when a run does not match its golden transcript
+var ErrGoldenMismatch = errors.New("run does not match golden transcript")
+
+// UpdateGoldenEnv is the environment variable that makes AssertGolden rewrite golden files
+// from the runs instead of comparing them, e.g. SWARMGO_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "SWARMGO_UPDATE_GOLDEN"
+
+// DefaultGoldenThreshold is the minimum cosine similarity of semantic matches
+const DefaultGoldenThreshold = 0.85
+
+// GoldenMatch is how the content of a golden message is compared with the run's
+type GoldenMatch string
+
+const (
+	GoldenExact    GoldenMatch = "exact"    // Content must be identical
+	GoldenRegex    GoldenMatch = "regex"    // Golden content is a regular expression the content must match
+	GoldenSemantic GoldenMatch = "semantic" // Embeddings of the contents must be similar
+)
+
+// GoldenMessage is a message of a golden transcript. Tool call arguments are compared as JSON,
+// so formatting and key order do not matter.
+type GoldenMessage struct {
+	Role      llm.Role               `json:"role"`
+	Name      string                 `json:"name,omitempty"`
+	Content   string                 `json:"content"`
+	ToolCalls []llm.ToolCallFunction `json:"tool_calls,omitempty"`
+	Match     GoldenMatch            `json:"match,omitempty"` // GoldenOptions.Match if empty
+}
+
+// EmbedFunc returns the embedding of a text, e.g. from an embeddings API
+type EmbedFunc func(ctx context.Context, text string) ([]float64, error)
+
+// GoldenOptions configures how runs are compared with golden transcripts
+type GoldenOptions struct {
+	Match     GoldenMatch // Matcher of messages that set none, GoldenExact if empty
+	Embed     EmbedFunc   // Embeds contents for semantic matches
+	Threshold float64     // Minimum similarity of semantic matches, DefaultGoldenThreshold if zero
+	// Update writes the run as the golden transcript instead of comparing it, keeping the
+	// matchers of the messages it replaces. Setting UpdateGoldenEnv has the same effect.
+	Update bool
+}
+
+// TestingT is the part of testing.TB the golden helpers use
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// AssertGolden compares the messages of a run with the golden transcript stored as JSON at
+// path, and reports any mismatch with a diff. It reports whether the run matched.
+func AssertGolden(t TestingT, path string, response Response, opts GoldenOptions) bool {
+	t.Helper()
+	if opts.Update || os.Getenv(UpdateGoldenEnv) != "" {
+		if err := UpdateGolden(path, response.Messages); err != nil {
+			t.Errorf("updating golden transcript %s: %v", path, err)
+			return false
+		}
+		return true
+	}
+
+	golden, err := LoadGolden(path)
+	if err != nil {
+		t.Errorf("loading golden transcript %s: %v (set %s=1 to create it)", path, err, UpdateGoldenEnv)
+		return false
+	}
+	if err := CompareGolden(context.Background(), golden, response.Messages, opts); err != nil {
+		expected := make([]llm.Message, len(golden))
+		for i, msg := range golden {
+			expected[i] = msg.message()
+		}
+		t.Errorf("%s: %v\n%s", path, err, RunDiff{Messages: DiffTranscripts(expected, response.Messages)})
+		return false
+	}
+	return true
+}
+
+// LoadGolden reads a golden transcript
+func LoadGolden(path string) ([]GoldenMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var golden []GoldenMessage
+	if err := json.Unmarshal(data, &golden); err != nil {
+		return nil, fmt.Errorf("failed to decode golden transcript: %w", err)
+	}
+	return golden, nil
+}
+
+// UpdateGolden writes the messages as the golden transcript at path, keeping the matchers of
+// the golden messages they replace
+func UpdateGolden(path string, messages []llm.Message) error {
+	previous, _ := LoadGolden(path)
+	golden := make([]GoldenMessage, len(messages))
+	for i, msg := range messages {
+		golden[i] = GoldenMessage{Role: msg.Role, Name: msg.Name, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			golden[i].ToolCalls = append(golden[i].ToolCalls, call.Function)
+		}
+		if i < len(previous) && previous[i].Role == msg.Role {
+			golden[i].Match = previous[i].Match
+		}
+	}
+
+	data, err := json.MarshalIndent(golden, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// CompareGolden compares messages with a golden transcript and describes every mismatch
+func CompareGolden(ctx context.Context, golden []GoldenMessage, messages []llm.Message, opts GoldenOptions) error {
+	var mismatches []string
+	if len(golden) != len(messages) {
+		mismatches = append(mismatches, fmt.Sprintf("expected %d messages, got %d", len(golden), len(messages)))
+	}
+	for i := 0; i < min(len(golden), len(messages)); i++ {
+		if err := golden[i].compare(ctx, messages[i], opts); err != nil {
+			mismatches = append(mismatches, fmt.Sprintf("message %d: %v", i, err))
+		}
+	}
+	if len(mismatches) > 0 {
+		return fmt.Errorf("%w: %s", ErrGoldenMismatch, strings.Join(mismatches, "; "))
+	}
+	return nil
+}
+
+// message returns the golden message as a message
+func (g GoldenMessage) message() llm.Message {
+	msg := llm.Message{Role: g.Role, Name: g.Name, Content: g.Content}
+	for _, call := range g.ToolCalls {
+		msg.ToolCalls = append(msg.ToolCalls, llm.ToolCall{Type: "function", Function: call})
+	}
+	return msg
+}
+
+// compare describes how the message differs from the golden message
+func (g GoldenMessage) compare(ctx context.Context, msg llm.Message, opts GoldenOptions) error {
+	if g.Role != msg.Role {
+		return fmt.Errorf("expected role %s, got %s", g.Role, msg.Role)
+	}
+	if g.Name != msg.Name {
+		return fmt.Errorf("expected name %q, got %q", g.Name, msg.Name)
+	}
+	if len(g.ToolCalls) != len(msg.ToolCalls) {
+		return fmt.Errorf("expected %d tool calls, got %d", len(g.ToolCalls), len(msg.ToolCalls))
+	}
+	for i, call := range g.ToolCalls {
+		actual := msg.ToolCalls[i].Function
+		if call.Name != actual.Name || !jsonEqual(call.Arguments, actual.Arguments) {
+			return fmt.Errorf("expected tool call %s(%s), got %s(%s)", call.Name, call.Arguments, actual.Name, actual.Arguments)
+		}
+	}
+
+	match := g.Match
+	if match == "" {
+		match = opts.Match
+	}
+	switch match {
+	case "", GoldenExact:
+		if g.Content != msg.Content {
+			return fmt.Errorf("expected content %q, got %q", g.Content, msg.Content)
+		}
+	case GoldenRegex:
+		pattern, err := regexp.Compile(g.Content)
+		if err != nil {
+			return fmt.Errorf("invalid golden pattern: %w", err)
+		}
+		if !pattern.MatchString(msg.Content) {
+			return fmt.Errorf("content %q does not match %s", msg.Content, g.Content)
+		}
+	case GoldenSemantic:
+		if opts.Embed == nil {
+			return fmt.Errorf("semantic match requires GoldenOptions.Embed")
+		}
+		threshold := opts.Threshold
+		if threshold == 0 {
+			threshold = DefaultGoldenThreshold
+		}
+		similarity, err := semanticSimilarity(ctx, opts.Embed, g.Content, msg.Content)
+		if err != nil {
+			return err
+		}
+		if similarity < threshold {
+			return fmt.Errorf("content %q is not similar to %q (similarity %.2f, threshold %.2f)", msg.Content, g.Content, similarity, threshold)
+		}
+	default:
+		return fmt.Errorf("unknown golden match %q", match)
+	}
+	return nil
+}
+
+// semanticSimilarity returns the cosine similarity of the texts' embeddings
+func semanticSimilarity(ctx context.Context, embed EmbedFunc, a, b string) (float64, error) {
+	ea, err := embed(ctx, a)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed golden content: %w", err)
+	}
+	eb, err := embed(ctx, b)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed content: %w", err)
+	}
+	if len(ea) != len(eb) || len(ea) == 0 {
+		return 0, fmt.Errorf("embeddings have different dimensions")
+	}
+	return cosineSimilarity(ea, eb), nil
+}
+
+// jsonEqual reports whether two JSON documents are equal, falling back to comparing them as text
+func jsonEqual(a, b string) bool {
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return a == b
+	}
+	return reflect.DeepEqual(va, vb)
+}
@@ -0,0 +1,64 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingT records the failures reported by golden assertions
+type recordingT struct {
+	errors []string
+}
+
+func (r *recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "weather.json")
+	response := Response{Messages: []llm.Message{
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{Function: llm.ToolCallFunction{Name: "weather", Arguments: `{"city":"Paris","unit":"C"}`}}}},
+		{Role: llm.RoleFunction, Name: "weather", Content: "18C"},
+		{Role: llm.RoleAssistant, Content: "It is 18C in Paris."},
+	}}
+	assert.True(t, AssertGolden(t, path, response, GoldenOptions{Update: true}))
+
+	// Loosen the final answer to a pattern; updating again keeps the matcher
+	golden, err := LoadGolden(path)
+	assert.NoError(t, err)
+	golden[2].Content, golden[2].Match = `\b18C\b`, GoldenRegex
+	data, _ := json.MarshalIndent(golden, "", "  ")
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+
+	response.Messages[0].ToolCalls[0].Function.Arguments = `{"unit": "C", "city": "Paris"}`
+	response.Messages[2].Content = "Paris is at 18C right now."
+	assert.True(t, AssertGolden(t, path, response, GoldenOptions{}))
+
+	rec := &recordingT{}
+	response.Messages[2].Content = "It is 20C in Paris."
+	assert.False(t, AssertGolden(rec, path, response, GoldenOptions{}))
+	if assert.Len(t, rec.errors, 1) {
+		assert.Contains(t, rec.errors[0], "message 2")
+	}
+
+	// Semantic matches compare embeddings
+	embed := func(ctx context.Context, text string) ([]float64, error) {
+		if strings.Contains(text, "sunny") {
+			return []float64{1, 0}, nil
+		}
+		return []float64{0, 1}, nil
+	}
+	semantic := []GoldenMessage{{Role: llm.RoleAssistant, Content: "It is sunny.", Match: GoldenSemantic}}
+	assert.NoError(t, CompareGolden(context.Background(), semantic, []llm.Message{{Role: llm.RoleAssistant, Content: "Sunny skies, it's sunny!"}}, GoldenOptions{Embed: embed}))
+	assert.ErrorIs(t, CompareGolden(context.Background(), semantic, []llm.Message{{Role: llm.RoleAssistant, Content: "It is raining."}}, GoldenOptions{Embed: embed}), ErrGoldenMismatch)
+}
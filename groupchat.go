@@ -0,0 +1,234 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// TurnPolicy is how the agents of a group chat take turns answering messages
+type TurnPolicy int
+
+const (
+	// TurnMentions has the agents @mentioned in a message answer it, in the order they are
+	// mentioned. Messages mentioning no agent are answered by the session's Agent, if set.
+	TurnMentions TurnPolicy = iota
+	// TurnRoundRobin has the agents answer messages in turn, one agent per message
+	TurnRoundRobin
+)
+
+// WithGroupChat turns the session into a group chat between the humans sending messages
+// with SendAs and the agents, which answer according to the policy. Every message is
+// attributed to its sender, and each agent sees the others' messages prefixed with their
+// names. Handoffs of group agents do not change who takes part.
+func (s *Session) WithGroupChat(policy TurnPolicy, agents ...*Agent) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.GroupAgents = agents
+	s.TurnPolicy = policy
+	s.nextSpeaker = 0
+	return s
+}
+
+// Join adds human participants to the session
+func (s *Session) Join(names ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		if !s.hasParticipant(name) {
+			s.Participants = append(s.Participants, name)
+		}
+	}
+}
+
+// Leave removes a human participant from the session; their messages stay in the history
+func (s *Session) Leave(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, participant := range s.Participants {
+		if participant == name {
+			s.Participants = append(s.Participants[:i:i], s.Participants[i+1:]...)
+			return
+		}
+	}
+}
+
+// SendAs adds a message from the participant to the session, joining them if needed, and
+// runs the agents whose turn it is to answer. It returns the response of each agent that
+// answered, in order; each answer is added to the history before the next agent runs.
+// Nothing is added while a stream or another run is in progress.
+func (s *Session) SendAs(ctx context.Context, participant, content string) ([]Response, error) {
+	if participant == "" {
+		return nil, errors.New("participant is required")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return nil, errors.New("session already has a run in progress")
+	}
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.streamDone = done
+	s.interrupted = false
+	defer func() {
+		s.mu.Lock()
+		s.cancel = nil
+		s.streamDone = nil
+		close(done)
+		s.mu.Unlock()
+	}()
+	if !s.hasParticipant(participant) {
+		s.Participants = append(s.Participants, participant)
+	}
+	s.appendMessages(s.takeAttachments(llm.Message{Role: llm.RoleUser, Name: participant, Content: content}))
+	responders := s.responders(content)
+	s.UpdatedAt = time.Now()
+	s.mu.Unlock()
+
+	var responses []Response
+	for _, agent := range responders {
+		s.mu.Lock()
+		history := groupHistory(s.Messages, agent.Name)
+		others := s.groupMembers(agent.Name)
+		swarm := s.swarm
+		opts := RunOptions{ContextVariables: s.ContextVariables, ModelOverride: s.Model, Debug: s.Debug, MaxTurns: s.MaxTurns}
+		s.mu.Unlock()
+
+		opts.InstructionsSuffix = swarm.messageCatalog().format(MessageGroupChat, agent.Name, strings.Join(others, ", "))
+		response, err := swarm.RunWithOptions(ctx, agent, history, opts)
+		if err != nil {
+			return responses, err
+		}
+		for i := range response.Messages {
+			if response.Messages[i].Role == llm.RoleAssistant && response.Messages[i].Name == "" {
+				response.Messages[i].Name = agent.Name
+			}
+		}
+
+		s.mu.Lock()
+		s.appendMessages(response.Messages...)
+		s.UpdatedAt = time.Now()
+		s.mu.Unlock()
+		responses = append(responses, response)
+	}
+	return responses, nil
+}
+
+// hasParticipant reports whether the human takes part in the session. It must be called with
+// the lock held.
+func (s *Session) hasParticipant(name string) bool {
+	for _, participant := range s.Participants {
+		if participant == name {
+			return true
+		}
+	}
+	return false
+}
+
+// responders returns the agents answering a message according to the turn policy. It must be
+// called with the lock held.
+func (s *Session) responders(content string) []*Agent {
+	if s.TurnPolicy == TurnRoundRobin {
+		if len(s.GroupAgents) == 0 {
+			return nil
+		}
+		agent := s.GroupAgents[s.nextSpeaker%len(s.GroupAgents)]
+		s.nextSpeaker++
+		return []*Agent{agent}
+	}
+	if mentioned := mentionedAgents(content, s.GroupAgents); len(mentioned) > 0 {
+		return mentioned
+	}
+	if s.Agent != nil {
+		return []*Agent{s.Agent}
+	}
+	return nil
+}
+
+// groupMembers returns the names of the participants and agents other than the agent. It
+// must be called with the lock held.
+func (s *Session) groupMembers(self string) []string {
+	members := append([]string(nil), s.Participants...)
+	for _, agent := range s.GroupAgents {
+		if agent.Name != self {
+			members = append(members, agent.Name)
+		}
+	}
+	return members
+}
+
+// groupHistory returns the history as the agent sees it: messages of the participants and
+// the other agents are user messages prefixed with the sender's name, and the other agents'
+// tool calls are left out. Names are cleared since providers restrict the characters they
+// may contain.
+func groupHistory(messages []llm.Message, self string) []llm.Message {
+	history := make([]llm.Message, 0, len(messages))
+	othersCalls := make(map[string]bool)
+	for _, msg := range messages {
+		switch {
+		case msg.Role == llm.RoleUser && msg.Name != "":
+			msg.Content = msg.Name + ": " + msg.Content
+		case msg.Role == llm.RoleAssistant && msg.Name != "" && msg.Name != self:
+			for _, call := range msg.ToolCalls {
+				othersCalls[call.ID] = true
+			}
+			if msg.Content == "" {
+				continue
+			}
+			msg = llm.Message{Role: llm.RoleUser, Content: msg.Name + ": " + msg.Content}
+		case (msg.Role == llm.RoleTool || msg.Role == llm.RoleFunction) && othersCalls[msg.ToolCallID]:
+			continue
+		}
+		msg.Name = ""
+		history = append(history, msg)
+	}
+	return history
+}
+
+// mentionedAgents returns the agents @mentioned in the text, in the order they are first
+// mentioned. Mentions are not case-sensitive.
+func mentionedAgents(text string, agents []*Agent) []*Agent {
+	lower := strings.ToLower(text)
+	positions := make(map[*Agent]int)
+	var mentioned []*Agent
+	for _, agent := range agents {
+		if at := mentionIndex(lower, strings.ToLower(agent.Name)); at >= 0 {
+			positions[agent] = at
+			mentioned = append(mentioned, agent)
+		}
+	}
+	sort.SliceStable(mentioned, func(i, j int) bool { return positions[mentioned[i]] < positions[mentioned[j]] })
+	return mentioned
+}
+
+// mentionIndex returns the position of the first @mention of the name in the text, or -1.
+// A mention must not run on into a longer word, so @Ann does not mention Anna.
+func mentionIndex(text, name string) int {
+	if name == "" {
+		return -1
+	}
+	mention := "@" + name
+	for offset := 0; ; {
+		i := strings.Index(text[offset:], mention)
+		if i < 0 {
+			return -1
+		}
+		i += offset
+		end := i + len(mention)
+		next, _ := utf8.DecodeRuneInString(text[end:])
+		if end == len(text) || !(unicode.IsLetter(next) || unicode.IsDigit(next) || next == '_') {
+			return i
+		}
+		offset = end
+	}
+}
@@ -0,0 +1,140 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrContentBlocked is returned when an output filter rejects generated content
+var ErrContentBlocked = errors.New("content blocked by output filter")
+
+// DefaultOutputFilterWindow is the number of bytes of streamed output held back for scanning
+const DefaultOutputFilterWindow = 64
+
+// OutputFilter inspects generated text and returns an error describing disallowed content
+type OutputFilter interface {
+	CheckOutput(text string) error
+}
+
+// OutputFilterFunc adapts a function to the OutputFilter interface
+type OutputFilterFunc func(text string) error
+
+// CheckOutput calls f(text)
+func (f OutputFilterFunc) CheckOutput(text string) error {
+	return f(text)
+}
+
+// BlocklistFilter rejects text containing any of the terms, ignoring case
+func BlocklistFilter(terms ...string) OutputFilter {
+	return OutputFilterFunc(func(text string) error {
+		lower := strings.ToLower(text)
+		for _, term := range terms {
+			if strings.Contains(lower, strings.ToLower(term)) {
+				return fmt.Errorf("blocked term %q", term)
+			}
+		}
+		return nil
+	})
+}
+
+// RegexFilter rejects text matching any of the patterns
+func RegexFilter(patterns ...*regexp.Regexp) OutputFilter {
+	return OutputFilterFunc(func(text string) error {
+		for _, pattern := range patterns {
+			if pattern.MatchString(text) {
+				return fmt.Errorf("blocked pattern %s", pattern)
+			}
+		}
+		return nil
+	})
+}
+
+// WithOutputFilters adds filters applied to the agent's generated text. When streaming,
+// the last window bytes are held back so content can be cut off before it reaches the handler.
+func (a *Agent) WithOutputFilters(filters ...OutputFilter) *Agent {
+	a.OutputFilters = append(a.OutputFilters, filters...)
+	return a
+}
+
+// checkOutput runs all filters over the text
+func checkOutput(filters []OutputFilter, text string) error {
+	for _, filter := range filters {
+		if err := filter.CheckOutput(text); err != nil {
+			return fmt.Errorf("%w: %v", ErrContentBlocked, err)
+		}
+	}
+	return nil
+}
+
+// streamOutputFilter scans streamed text through a sliding window. Text is only released
+// once it has been scanned together with the window that follows it, so a match spanning
+// several tokens is caught as long as it is no longer than the window.
+type streamOutputFilter struct {
+	filters []OutputFilter
+	window  int
+	text    strings.Builder // All text received so far
+	emitted int             // Number of bytes of text released to the handler
+	checked int             // Number of bytes of text already scanned
+}
+
+// newStreamOutputFilter returns nil when the agent has no output filters
+func newStreamOutputFilter(agent *Agent) *streamOutputFilter {
+	if len(agent.OutputFilters) == 0 {
+		return nil
+	}
+	window := agent.OutputFilterWindow
+	if window <= 0 {
+		window = DefaultOutputFilterWindow
+	}
+	return &streamOutputFilter{filters: agent.OutputFilters, window: window}
+}
+
+// push adds a token and returns the text that is now safe to release
+func (f *streamOutputFilter) push(token string) (string, error) {
+	f.text.WriteString(token)
+	text := f.text.String()
+	if err := f.scan(text); err != nil {
+		return "", err
+	}
+
+	release := len(text) - f.window
+	for release > f.emitted && !utf8.RuneStart(text[release]) {
+		release--
+	}
+	if release <= f.emitted {
+		return "", nil
+	}
+	out := text[f.emitted:release]
+	f.emitted = release
+	return out, nil
+}
+
+// flush releases the held-back text once the message is complete
+func (f *streamOutputFilter) flush() string {
+	text := f.text.String()
+	out := text[f.emitted:]
+	f.emitted = len(text)
+	return out
+}
+
+// scan checks the unscanned end of the text together with the window before it, so each
+// token only costs a scan of the window
+func (f *streamOutputFilter) scan(text string) error {
+	start := f.checked - f.window
+	if start < 0 {
+		start = 0
+	}
+	for start > 0 && !utf8.RuneStart(text[start]) {
+		start--
+	}
+	f.checked = len(text)
+	return checkOutput(f.filters, text[start:])
+}
+
+// released returns the text released to the handler so far
+func (f *streamOutputFilter) released() string {
+	return f.text.String()[:f.emitted]
+}
@@ -0,0 +1,89 @@
+package swarmgo
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// requestExtras are the HTTP headers and query parameters added to provider requests
+type requestExtras struct {
+	headers map[string]string
+	query   map[string]string
+}
+
+type requestExtrasKey struct{}
+
+// WithRequestHeaders adds HTTP headers to every provider request of the agent, e.g. the API
+// key of a gateway or its routing hints. Headers of the same name set by a run take precedence.
+func (a *Agent) WithRequestHeaders(headers map[string]string) *Agent {
+	a.RequestHeaders = mergeStrings(a.RequestHeaders, headers)
+	return a
+}
+
+// WithRequestQuery adds query parameters to every provider request of the agent, e.g. for
+// gateways that route by query parameter. Parameters of the same name set by a run take
+// precedence.
+func (a *Agent) WithRequestQuery(query map[string]string) *Agent {
+	a.RequestQuery = mergeStrings(a.RequestQuery, query)
+	return a
+}
+
+// withRequestExtras returns a context whose provider requests, including those of nested runs,
+// carry the run's headers and query parameters
+func withRequestExtras(ctx context.Context, headers, query map[string]string) context.Context {
+	extras, _ := ctx.Value(requestExtrasKey{}).(requestExtras)
+	canonical := make(map[string]string, len(headers))
+	for key, value := range headers {
+		canonical[http.CanonicalHeaderKey(key)] = value
+	}
+	return context.WithValue(ctx, requestExtrasKey{}, requestExtras{
+		headers: mergeStrings(extras.headers, canonical),
+		query:   mergeStrings(extras.query, query),
+	})
+}
+
+// providerContext returns the context a provider request of the agent is sent with: it
+// carries the correlation IDs and the agent's and the run's headers and query parameters
+func providerContext(ctx context.Context, agent *Agent) context.Context {
+	extras, _ := ctx.Value(requestExtrasKey{}).(requestExtras)
+	query := mergeStrings(agent.RequestQuery, extras.query)
+
+	ctx = withCorrelationHeader(ctx)
+	if len(agent.RequestHeaders) > 0 || len(extras.headers) > 0 {
+		// Header names are case-insensitive, so the run's are set last to take precedence
+		h := make(http.Header, len(agent.RequestHeaders)+len(extras.headers))
+		for _, headers := range []map[string]string{agent.RequestHeaders, extras.headers} {
+			for key, value := range headers {
+				h.Set(key, value)
+			}
+		}
+		ctx = llm.WithHeaders(ctx, h)
+	}
+	if len(query) > 0 {
+		q := make(url.Values, len(query))
+		for key, value := range query {
+			q.Set(key, value)
+		}
+		ctx = llm.WithQueryParams(ctx, q)
+	}
+	return ctx
+}
+
+// mergeStrings returns the entries of both maps, those of override taking precedence, without
+// modifying either
+func mergeStrings(base, override map[string]string) map[string]string {
+	if len(override) == 0 {
+		return base
+	}
+	merged := make(map[string]string, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,153 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrModelUnavailable is returned by HealthCheck when a model is not offered by the provider
+var ErrModelUnavailable = errors.New("model unavailable")
+
+// ErrInvalidAgent is returned by Agent.Validate for agents that cannot run as configured
+var ErrInvalidAgent = errors.New("invalid agent")
+
+// HealthCheckModels are the cheap models pinged by HealthCheck for providers whose clients
+// cannot list their models, when no model is given
+var HealthCheckModels = map[llm.LLMProvider]string{
+	llm.OpenAI:   "gpt-4o-mini",
+	llm.Claude:   "claude-3-5-haiku-latest",
+	llm.Gemini:   "gemini-1.5-flash",
+	llm.DeepSeek: "deepseek-chat",
+	llm.XAI:      "grok-beta",
+	llm.Cohere:   "command-r",
+}
+
+// HealthReport is the outcome of a health check
+type HealthReport struct {
+	Provider llm.LLMProvider
+	Models   []string      // Models checked, or listed when none were given
+	Latency  time.Duration // Time the check took
+	Usage    llm.Usage     // Tokens spent pinging models
+}
+
+// HealthCheck verifies that the provider is reachable, accepts the swarm's credentials and
+// offers the models, e.g. for a readiness probe. Clients that can list their models are checked
+// without spending tokens; the others are pinged for one token with each model, or with the
+// provider's HealthCheckModels entry. The check also warms up the provider's connections.
+func (s *Swarm) HealthCheck(ctx context.Context, models ...string) (HealthReport, error) {
+	// Requests go where runs send them, such as the swarm's regions
+	client, _ := s.clientFor(&Agent{})
+	report := HealthReport{Provider: s.provider}
+	if client == nil {
+		return report, errors.New("swarm has no LLM client")
+	}
+
+	start := time.Now()
+
+	if lister, ok := client.(llm.ModelLister); ok {
+		available, err := lister.ListModels(ctx)
+		report.Latency = time.Since(start)
+		if err != nil {
+			return report, fmt.Errorf("health check of %s failed: %w", s.provider, err)
+		}
+		if len(models) == 0 {
+			report.Models = available
+			return report, nil
+		}
+		report.Models = models
+		for _, model := range models {
+			if !slices.Contains(available, model) {
+				return report, fmt.Errorf("health check of %s failed: %w: %s", s.provider, ErrModelUnavailable, model)
+			}
+		}
+		return report, nil
+	}
+
+	if len(models) == 0 {
+		model, ok := HealthCheckModels[s.provider]
+		if !ok {
+			return report, fmt.Errorf("health check of %s needs a model to ping", s.provider)
+		}
+		models = []string{model}
+	}
+	report.Models = models
+	for _, model := range models {
+		resp, err := client.CreateChatCompletion(ctx, llm.ChatCompletionRequest{
+			Model:     model,
+			Messages:  []llm.Message{{Role: llm.RoleUser, Content: "ping"}},
+			MaxTokens: 1,
+		})
+		report.Usage = addUsage(report.Usage, resp.Usage)
+		if err != nil {
+			report.Latency = time.Since(start)
+			return report, fmt.Errorf("health check of %s with model %s failed: %w", s.provider, model, err)
+		}
+	}
+	report.Latency = time.Since(start)
+	return report, nil
+}
+
+// toolNamePattern matches the function names every provider accepts
+var toolNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Validate checks that the agent can run as configured: that it has a name and an allowed
+// model, that its tools have valid, unique names, an implementation and an object schema,
+// and that its tool choice names one of its tools. All problems found are reported together.
+func (a *Agent) Validate() error {
+	var errs []error
+	if a.Name == "" {
+		errs = append(errs, errors.New("agent has no name"))
+	}
+	if a.Provider != "" && !knownProviders[a.Provider] {
+		errs = append(errs, fmt.Errorf("%w %q", ErrUnknownProvider, a.Provider))
+	}
+	if a.Model == "" {
+		errs = append(errs, errors.New("agent has no model"))
+	} else if _, err := a.resolveModel(""); err != nil {
+		errs = append(errs, err)
+	}
+
+	names := make(map[string]bool)
+	for _, function := range a.allFunctions() {
+		if !toolNamePattern.MatchString(function.Name) {
+			errs = append(errs, fmt.Errorf("tool name %q must be 1 to 64 letters, digits, underscores or dashes", function.Name))
+		}
+		if names[function.Name] {
+			errs = append(errs, fmt.Errorf("tool %q is defined more than once", function.Name))
+		}
+		names[function.Name] = true
+		if function.executor == nil && function.contextExecutor == nil {
+			errs = append(errs, fmt.Errorf("tool %q has no implementation", function.Name))
+		}
+		if schemaType, ok := function.params["type"]; ok && schemaType != "object" {
+			errs = append(errs, fmt.Errorf("tool %q parameters must be an object schema, not %v", function.Name, schemaType))
+		}
+	}
+	if a.ToolChoice != nil && a.ToolChoice.Function != "" && !names[a.ToolChoice.Function] {
+		errs = append(errs, fmt.Errorf("tool choice forces unknown tool %q", a.ToolChoice.Function))
+	}
+	for _, variant := range a.PromptVariants {
+		if variant.Weight < 0 {
+			errs = append(errs, fmt.Errorf("prompt variant %q has a negative weight", variant.Name))
+		}
+	}
+	for _, tool := range a.HostedTools {
+		if tool.Type == "" {
+			errs = append(errs, errors.New("hosted tool has no type"))
+		}
+	}
+	if a.OutputFilterWindow < 0 {
+		errs = append(errs, errors.New("output filter window must not be negative"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w %s: %w", ErrInvalidAgent, a.Name, errors.Join(errs...))
+	}
+	return nil
+}
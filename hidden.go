@@ -0,0 +1,58 @@
+package swarmgo
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// hiddenRedaction replaces the value of a hidden context variable that would otherwise reach the model
+const hiddenRedaction = "[hidden]"
+
+// minHiddenValueLength is the shortest hidden value that is redacted. Shorter values, such as
+// small numbers or flags, would match ordinary text and are not redacted.
+const minHiddenValueLength = 3
+
+// WithHiddenContext marks context variables as server-only. Instructions functions and tools
+// still receive them, but their values are redacted from the system prompt and tool results
+// before anything is sent to the model.
+func (a *Agent) WithHiddenContext(keys ...string) *Agent {
+	a.HiddenContext = append(a.HiddenContext, keys...)
+	return a
+}
+
+// VisibleContext returns the context variables the model may see, without the hidden ones
+func (a *Agent) VisibleContext(contextVariables map[string]interface{}) map[string]interface{} {
+	visible := make(map[string]interface{}, len(contextVariables))
+	for k, v := range contextVariables {
+		visible[k] = v
+	}
+	for _, key := range a.HiddenContext {
+		delete(visible, key)
+	}
+	return visible
+}
+
+// redactHidden removes the values of hidden context variables from text bound for the model
+func (a *Agent) redactHidden(text string, contextVariables map[string]interface{}) string {
+	if len(a.HiddenContext) == 0 || text == "" {
+		return text
+	}
+
+	var values []string
+	for _, key := range a.HiddenContext {
+		value, ok := contextVariables[key]
+		if !ok || value == nil {
+			continue
+		}
+		if rendered := fmt.Sprintf("%v", value); len(rendered) >= minHiddenValueLength {
+			values = append(values, rendered)
+		}
+	}
+	// Redact longer values first so a value containing another is removed whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, value := range values {
+		text = strings.ReplaceAll(text, value, hiddenRedaction)
+	}
+	return text
+}
@@ -0,0 +1,78 @@
+package swarmgo
+
+import (
+	"sync/atomic"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// History is an immutable message sequence whose snapshots share storage. Appending to the
+// most recent snapshot extends the shared array in place, so a conversation grows without
+// copying its earlier messages; appending to an older snapshot copies it first, so snapshots
+// never see each other's messages. The zero value is an empty history.
+type History struct {
+	messages []llm.Message
+	tip      *atomic.Int64 // Length of the longest snapshot sharing the array
+}
+
+// NewHistory creates a history holding the messages. The slice is shared rather than copied;
+// it is copied on the first append, and must not be modified by the caller afterwards.
+func NewHistory(messages ...llm.Message) History {
+	tip := new(atomic.Int64)
+	tip.Store(int64(len(messages)))
+	return History{messages: messages[:len(messages):len(messages)], tip: tip}
+}
+
+// Len returns the number of messages
+func (h History) Len() int {
+	return len(h.messages)
+}
+
+// Messages returns the messages as a read-only view. Appending to the returned slice
+// always copies it, but its elements must not be modified.
+func (h History) Messages() []llm.Message {
+	return h.messages[:len(h.messages):len(h.messages)]
+}
+
+// Since returns a read-only view of the messages from index i on
+func (h History) Since(i int) []llm.Message {
+	return h.messages[i:len(h.messages):len(h.messages)]
+}
+
+// Last returns the last message and whether there is one
+func (h History) Last() (llm.Message, bool) {
+	if len(h.messages) == 0 {
+		return llm.Message{}, false
+	}
+	return h.messages[len(h.messages)-1], true
+}
+
+// Append returns a history with the messages added
+func (h History) Append(messages ...llm.Message) History {
+	if len(messages) == 0 {
+		return h
+	}
+	n := len(h.messages)
+	full := h.messages[:cap(h.messages)]
+
+	// Extend in place if this is the latest snapshot and the array has room
+	if h.tip != nil && n+len(messages) <= cap(full) && h.tip.CompareAndSwap(int64(n), int64(n+len(messages))) {
+		copy(full[n:], messages)
+		return History{messages: full[:n+len(messages)], tip: h.tip}
+	}
+
+	grown := make([]llm.Message, n+len(messages), growCapacity(n+len(messages)))
+	copy(grown, h.messages)
+	copy(grown[n:], messages)
+	tip := new(atomic.Int64)
+	tip.Store(int64(len(grown)))
+	return History{messages: grown, tip: tip}
+}
+
+// growCapacity returns the capacity to allocate for n messages, leaving room for later turns
+func growCapacity(n int) int {
+	if n < 8 {
+		return 8
+	}
+	return n * 2
+}
@@ -0,0 +1,41 @@
+package swarmgo
+
+import (
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestHistoryCopyOnWrite tests that snapshots share storage but never see each other's appends
+func TestHistoryCopyOnWrite(t *testing.T) {
+	msg := func(content string) llm.Message { return llm.Message{Role: llm.RoleUser, Content: content} }
+
+	input := []llm.Message{msg("a")}
+	base := NewHistory(input...).Append(msg("b"))
+	assert.Equal(t, "a", input[0].Content)
+
+	grown := base.Append(msg("c"))
+	assert.Same(t, &base.Messages()[0], &grown.Messages()[0], "appending to the latest snapshot should not copy")
+
+	forked := base.Append(msg("x"))
+	assert.Equal(t, []llm.Message{msg("a"), msg("b"), msg("c")}, grown.Messages())
+	assert.Equal(t, []llm.Message{msg("a"), msg("b"), msg("x")}, forked.Messages())
+	assert.Equal(t, 2, base.Len())
+	assert.Equal(t, []llm.Message{msg("c")}, grown.Since(2))
+
+	// Appending to a view never writes into the history
+	view := append(base.Messages(), msg("y"))
+	assert.Equal(t, "c", grown.Messages()[2].Content)
+	assert.Equal(t, "y", view[2].Content)
+}
+
+func BenchmarkHistoryAppend(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var h History
+		for turn := 0; turn < 200; turn++ {
+			h = h.Append(llm.Message{Role: llm.RoleAssistant, Content: "reply"})
+		}
+	}
+}
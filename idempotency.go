@@ -0,0 +1,90 @@
+package swarmgo
+
+import (
+	"context"
+	"sync"
+)
+
+// IdempotencyKeyVar is the context variable holding the idempotency key of the tool call
+// being executed. Tools with external side effects can pass it on, e.g. as an Idempotency-Key
+// header, so a retried call is not applied twice.
+const IdempotencyKeyVar = "idempotency_key"
+
+// IdempotencyStore remembers the results of executed tool calls by idempotency key
+type IdempotencyStore interface {
+	Load(ctx context.Context, key string) (Result, bool)
+	Store(ctx context.Context, key string, result Result)
+}
+
+// MemoryIdempotencyStore keeps tool results in memory. It is safe for concurrent use.
+type MemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	results map[string]Result
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{results: make(map[string]Result)}
+}
+
+// Load returns the result stored for the key
+func (m *MemoryIdempotencyStore) Load(ctx context.Context, key string) (Result, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result, ok := m.results[key]
+	return result, ok
+}
+
+// Store records the result for the key
+func (m *MemoryIdempotencyStore) Store(ctx context.Context, key string, result Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.results[key] = result
+}
+
+// WithIdempotencyStore makes the swarm record successful tool results by idempotency key and
+// replay them instead of executing a tool call again. Runs resumed with the same
+// RunOptions.RunID derive the same keys, so tool calls they repeat are not re-executed.
+func (s *Swarm) WithIdempotencyStore(store IdempotencyStore) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idempotencyStore = store
+	return s
+}
+
+// idempotencyKey derives the key of a tool call from the run it belongs to
+func idempotencyKey(ctx context.Context, toolCallID string) string {
+	runID := runIDFromContext(ctx)
+	if runID == "" || toolCallID == "" {
+		return ""
+	}
+	return runID + ":" + toolCallID
+}
+
+// executeIdempotent runs a tool call with its idempotency key in the context variables,
+// replaying the stored result if the call was already executed
+func (s *Swarm) executeIdempotent(ctx context.Context, fn *AgentFunction[map[string]interface{}], toolCallID string, args map[string]interface{}, contextVariables map[string]interface{}, debug bool) Result {
+	key := idempotencyKey(ctx, toolCallID)
+	if key == "" {
+		return executeWithProgress(ctx, fn, toolCallID, args, contextVariables, debug)
+	}
+
+	s.mu.Lock()
+	store := s.idempotencyStore
+	s.mu.Unlock()
+	if store != nil {
+		if result, ok := store.Load(ctx, key); ok {
+			return result
+		}
+	}
+
+	contextVariables[IdempotencyKeyVar] = key
+	result := executeWithProgress(ctx, fn, toolCallID, args, contextVariables, debug)
+	delete(contextVariables, IdempotencyKeyVar)
+
+	// Failed calls are not stored so they can be retried
+	if store != nil && result.Success && result.Error == nil {
+		store.Store(ctx, key, result)
+	}
+	return result
+}
@@ -0,0 +1,205 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// imageCritiqueInstructions tells the critique model how to review a generated image
+const imageCritiqueInstructions = `You review images generated from a prompt. Compare the image with the prompt and answer in two or three sentences: what matches, what is missing or wrong, and how the prompt could be changed to fix it. Say "The image matches the prompt." if nothing needs to change.`
+
+// ArtifactStore keeps generated files, such as images, and returns references to them that can
+// be shown to users or passed between agents
+type ArtifactStore interface {
+	SaveArtifact(ctx context.Context, name, mimeType string, data []byte) (string, error)
+}
+
+// FileArtifactStore stores artifacts as files in a directory and references them by path.
+// Artifacts saved for a principal, found in the context, go in a subdirectory named after
+// its ID so they can be purged with the principal's other data.
+type FileArtifactStore struct {
+	dir string
+}
+
+// NewFileArtifactStore creates the directory if needed and returns a store writing to it
+func NewFileArtifactStore(dir string) (*FileArtifactStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create artifact directory: %w", err)
+	}
+	return &FileArtifactStore{dir: dir}, nil
+}
+
+// SaveArtifact writes the artifact under a unique name derived from the given one
+func (f *FileArtifactStore) SaveArtifact(ctx context.Context, name, mimeType string, data []byte) (string, error) {
+	dir := f.dir
+	if principal, ok := PrincipalFromContext(ctx); ok && sessionIDPattern.MatchString(principal.ID) {
+		dir = filepath.Join(f.dir, principal.ID)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return "", fmt.Errorf("failed to create artifact directory: %w", err)
+		}
+	}
+	path := filepath.Join(dir, generateID()+"-"+filepath.Base(name))
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return "", fmt.Errorf("failed to save artifact: %w", err)
+	}
+	return path, nil
+}
+
+// ArtifactFilter selects artifacts to purge. Artifacts must match every field that is set.
+type ArtifactFilter struct {
+	Before time.Time // Artifacts saved before this time
+	Owner  string    // Artifacts saved for the principal with this ID
+}
+
+// ArtifactPurger is implemented by artifact stores whose artifacts can be deleted, e.g. to
+// enforce a retention period or erase a user's data
+type ArtifactPurger interface {
+	PurgeArtifacts(ctx context.Context, filter ArtifactFilter) (int, error)
+}
+
+// PurgeArtifacts deletes the artifacts matching the filter and returns how many were deleted.
+// An empty filter is rejected rather than deleting every artifact.
+func (f *FileArtifactStore) PurgeArtifacts(ctx context.Context, filter ArtifactFilter) (int, error) {
+	if filter.Before.IsZero() && filter.Owner == "" {
+		return 0, fmt.Errorf("artifact filter is empty")
+	}
+	root := f.dir
+	if filter.Owner != "" {
+		if !sessionIDPattern.MatchString(filter.Owner) {
+			return 0, nil
+		}
+		root = filepath.Join(f.dir, filter.Owner)
+	}
+
+	deleted := 0
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		if !filter.Before.IsZero() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if !info.ModTime().Before(filter.Before) {
+				return nil
+			}
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		deleted++
+		return nil
+	})
+	if err != nil {
+		return deleted, fmt.Errorf("failed to purge artifacts: %w", err)
+	}
+	if filter.Owner != "" && filter.Before.IsZero() {
+		if err := os.RemoveAll(root); err != nil {
+			return deleted, fmt.Errorf("failed to purge artifacts: %w", err)
+		}
+	}
+	return deleted, nil
+}
+
+// ImageToolOptions configures the tool created by ImageGenerationTool
+type ImageToolOptions struct {
+	Swarm     *Swarm             // Swarm the critique runs are executed with; required for critiques
+	Generator llm.ImageGenerator // Generates the images, the swarm's client if nil
+	Store     ArtifactStore      // Keeps the generated images; required
+	Model     string             // Image model, e.g. dall-e-3
+	Size      string             // Image size, the model's default if empty
+	Quality   string             // Image quality, the model's default if empty
+	// CritiqueModel is a vision model shown each generated image to critique it against the
+	// prompt, so the calling agent can refine its prompt. Images are not critiqued if empty.
+	CritiqueModel string
+}
+
+// imageToolArgs are the arguments of the image generation tool
+type imageToolArgs struct {
+	Prompt string `json:"prompt" jsonschema:"required,description=Detailed description of the image to generate"`
+}
+
+// ImageGenerationTool returns a generate_image tool that generates an image with a DALL·E or
+// Imagen-compatible model, saves it to the artifact store and returns the artifact reference,
+// with the model's critique of the image if configured.
+func ImageGenerationTool(opts ImageToolOptions) AgentFunction[map[string]interface{}] {
+	name := "generate_image"
+	// The schema comes from the typed arguments; the run's context is needed to execute
+	fn, _ := NewAgentFunction(name, "Generate an image from a text prompt and get a reference to it", func(args imageToolArgs, contextVariables map[string]interface{}) Result {
+		return Result{Success: false, Error: fmt.Errorf("%s must be called from a run", name)}
+	})
+	fn.contextExecutor = func(ctx context.Context, args map[string]interface{}, contextVariables map[string]interface{}) Result {
+		prompt, _ := args["prompt"].(string)
+		if strings.TrimSpace(prompt) == "" {
+			return Result{Success: false, Error: fmt.Errorf("prompt is required")}
+		}
+		if opts.Store == nil {
+			return Result{Success: false, Error: fmt.Errorf("%s has no artifact store", name)}
+		}
+		generator := opts.Generator
+		if generator == nil && opts.Swarm != nil {
+			opts.Swarm.mu.Lock()
+			generator, _ = opts.Swarm.client.(llm.ImageGenerator)
+			opts.Swarm.mu.Unlock()
+		}
+		if generator == nil {
+			return Result{Success: false, Error: llm.ErrImagesNotSupported}
+		}
+
+		images, err := generator.GenerateImage(ctx, llm.ImageRequest{Prompt: prompt, Model: opts.Model, Size: opts.Size, Quality: opts.Quality})
+		if err != nil {
+			return Result{Success: false, Error: err}
+		}
+		image := images[0]
+		ref, err := opts.Store.SaveArtifact(ctx, "image.png", image.MimeType, image.Data)
+		if err != nil {
+			return Result{Success: false, Error: err}
+		}
+
+		var out strings.Builder
+		fmt.Fprintf(&out, "Image generated: %s", ref)
+		if image.RevisedPrompt != "" {
+			fmt.Fprintf(&out, "\nPrompt used: %s", image.RevisedPrompt)
+		}
+		if opts.CritiqueModel != "" && opts.Swarm != nil {
+			critique, err := opts.Swarm.critiqueImage(ctx, opts.CritiqueModel, prompt, image)
+			if err != nil {
+				fmt.Fprintf(&out, "\nThe image could not be critiqued: %v", err)
+			} else {
+				fmt.Fprintf(&out, "\nCritique: %s", critique)
+			}
+		}
+		return Result{Success: true, Data: out.String()}
+	}
+	return fn
+}
+
+// critiqueImage shows a generated image to a vision model and returns its review
+func (s *Swarm) critiqueImage(ctx context.Context, model, prompt string, image llm.GeneratedImage) (string, error) {
+	critic := NewAgent("ImageCritic", model, s.provider).WithInstructions(imageCritiqueInstructions)
+	response, err := s.RunWithOptions(ctx, critic, []llm.Message{{
+		Role:    llm.RoleUser,
+		Content: "Prompt: " + prompt,
+		Images:  []llm.ImageInput{{URL: image.DataURL()}},
+	}}, RunOptions{MaxTurns: 1})
+	if err != nil {
+		return "", err
+	}
+	critique := lastAssistantContent(response.Messages)
+	if critique == "" {
+		return "", fmt.Errorf("%s did not answer", critic.Name)
+	}
+	return critique, nil
+}
@@ -0,0 +1,47 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrRequestVetoed is returned by runs whose provider request was rejected by the prompt inspector
+var ErrRequestVetoed = errors.New("request vetoed by prompt inspector")
+
+// PromptInspector sees the exact request about to be sent to the provider for each turn of a
+// run, after instructions, history, tools and request parameters have been applied. It may
+// edit the request in place; returning an error vetoes it and fails the run.
+type PromptInspector func(ctx context.Context, agent *Agent, req *llm.ChatCompletionRequest) error
+
+// WithPromptInspector sets the inspector called before each turn's provider request, e.g. to
+// print the final prompt or to try out prompt edits. It only runs for runs started with debug
+// enabled, so an inspector left in place cannot alter production traffic.
+func (s *Swarm) WithPromptInspector(inspector PromptInspector) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.promptInspector = inspector
+	return s
+}
+
+// inspectRequest hands the request to the prompt inspector in debug runs
+func (s *Swarm) inspectRequest(ctx context.Context, agent *Agent, req *llm.ChatCompletionRequest, debug bool) error {
+	if !debug {
+		return nil
+	}
+	s.mu.Lock()
+	inspector := s.promptInspector
+	s.mu.Unlock()
+	if inspector == nil {
+		return nil
+	}
+	if err := inspector(ctx, agent, req); err != nil {
+		if errors.Is(err, ErrRequestVetoed) {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrRequestVetoed, err)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// jsonLiterals maps the literals models borrow from other languages to JSON's
+var jsonLiterals = map[string]string{
+	"true":  "true",
+	"false": "false",
+	"null":  "null",
+	"True":  "true",
+	"False": "false",
+	"None":  "null",
+}
+
+// RepairJSON fixes the mistakes models commonly make when writing JSON: it removes markdown
+// code fences around it and trailing commas, quotes unquoted object keys, turns single-quoted
+// strings into double-quoted ones and replaces Python literals. Text inside strings is kept
+// as is. The result is not guaranteed to be valid JSON.
+func RepairJSON(text string) string {
+	text = stripCodeFence(strings.TrimSpace(text))
+
+	var out strings.Builder
+	out.Grow(len(text))
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\'':
+			end := stringEnd(text, i)
+			if c == '"' {
+				out.WriteString(text[i:end])
+			} else {
+				out.WriteString(requoteString(text[i:end]))
+			}
+			i = end - 1
+		case c == ',':
+			// Drop commas closing an object or array
+			next := skipSpace(text, i+1)
+			if next < len(text) && (text[next] == '}' || text[next] == ']') {
+				continue
+			}
+			out.WriteByte(c)
+		case isIdentStart(c):
+			end := i + 1
+			for end < len(text) && isIdentPart(text[end]) {
+				end++
+			}
+			word := text[i:end]
+			next := skipSpace(text, end)
+			if next < len(text) && text[next] == ':' {
+				out.WriteString(`"` + word + `"`)
+			} else if literal, ok := jsonLiterals[word]; ok {
+				out.WriteString(literal)
+			} else {
+				out.WriteString(word)
+			}
+			i = end - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+// decodeJSON decodes data into out, repairing it with RepairJSON if it is not valid JSON. The
+// error of the original data is returned if the repaired data does not decode either.
+func decodeJSON(data string, out interface{}) error {
+	err := json.Unmarshal([]byte(data), out)
+	if err == nil {
+		return nil
+	}
+	if repaired := RepairJSON(data); repaired != data && json.Unmarshal([]byte(repaired), out) == nil {
+		return nil
+	}
+	return err
+}
+
+// stripCodeFence returns the content of a markdown code block wrapping the text
+func stripCodeFence(text string) string {
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	_, body, ok := strings.Cut(text, "\n")
+	if !ok {
+		return text
+	}
+	if end := strings.LastIndex(body, "```"); end >= 0 {
+		body = body[:end]
+	}
+	return strings.TrimSpace(body)
+}
+
+// stringEnd returns the index after the string literal starting at i, or the end of the text
+// if the string is not terminated
+func stringEnd(text string, i int) int {
+	quote := text[i]
+	for j := i + 1; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		}
+	}
+	return len(text)
+}
+
+// requoteString turns a single-quoted string literal into a double-quoted one
+func requoteString(literal string) string {
+	body := strings.TrimPrefix(literal, "'")
+	body = strings.TrimSuffix(body, "'")
+	body = strings.ReplaceAll(body, `\'`, `'`)
+
+	var out strings.Builder
+	out.WriteByte('"')
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			out.WriteByte('\\')
+			if i+1 < len(body) {
+				i++
+				out.WriteByte(body[i])
+			}
+		case '"':
+			out.WriteString(`\"`)
+		default:
+			out.WriteByte(body[i])
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
+}
+
+// skipSpace returns the index of the first non-whitespace byte at or after i
+func skipSpace(text string, i int) int {
+	for i < len(text) && strings.IndexByte(" \t\r\n", text[i]) >= 0 {
+		i++
+	}
+	return i
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentPart(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
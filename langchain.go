@@ -0,0 +1,109 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LangChainTool is the method set of langchaingo's tools.Tool. Every langchaingo tool
+// satisfies it, and the tools returned by ToLangChainTool can be handed to langchaingo agents,
+// without this package depending on langchaingo.
+type LangChainTool interface {
+	Name() string
+	Description() string
+	Call(ctx context.Context, input string) (string, error)
+}
+
+// langChainToolArgs are the arguments of a langchaingo tool, which takes a single text input
+type langChainToolArgs struct {
+	Input string `json:"input" jsonschema:"required,description=The input passed to the tool"`
+}
+
+// FromLangChainTool wraps a langchaingo tool as an agent function taking its text input as
+// the "input" argument. Names that providers would reject are converted to snake case.
+func FromLangChainTool(tool LangChainTool) AgentFunction[map[string]interface{}] {
+	name := tool.Name()
+	if !toolNamePattern.MatchString(name) {
+		name = strings.Trim(toolNameInvalidChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	}
+
+	// The schema comes from the typed arguments; the run's context is needed to call the tool
+	fn, _ := NewAgentFunction(name, tool.Description(), func(args langChainToolArgs, contextVariables map[string]interface{}) Result {
+		return Result{Success: false, Error: fmt.Errorf("%s must be called from a run", name)}
+	})
+	fn.contextExecutor = func(ctx context.Context, args map[string]interface{}, contextVariables map[string]interface{}) Result {
+		input, _ := args["input"].(string)
+		output, err := tool.Call(ctx, input)
+		if err != nil {
+			return Result{Success: false, Error: err}
+		}
+		return Result{Success: true, Data: output}
+	}
+	return fn
+}
+
+// FromLangChainTools wraps langchaingo tools as agent functions
+func FromLangChainTools(tools ...LangChainTool) []AgentFunction[map[string]interface{}] {
+	functions := make([]AgentFunction[map[string]interface{}], len(tools))
+	for i, tool := range tools {
+		functions[i] = FromLangChainTool(tool)
+	}
+	return functions
+}
+
+// langChainFunction adapts an agent function to langchaingo's tools.Tool
+type langChainFunction struct {
+	fn AgentFunction[map[string]interface{}]
+}
+
+// ToLangChainTool wraps an agent function as a langchaingo tool. The tool's input is decoded
+// as the function's JSON arguments; text that is not a JSON object is passed as the value of
+// the function's only required parameter, if it has exactly one. Functions run without context
+// variables, and their results are rendered as text the way they are for models.
+func ToLangChainTool(fn AgentFunction[map[string]interface{}]) LangChainTool {
+	return langChainFunction{fn: fn}
+}
+
+// Name implements LangChainTool
+func (t langChainFunction) Name() string {
+	return t.fn.Name
+}
+
+// Description implements LangChainTool, describing the expected input for langchaingo agents
+func (t langChainFunction) Description() string {
+	schema, err := json.Marshal(t.fn.params)
+	if err != nil || len(t.fn.params) == 0 {
+		return t.fn.Description
+	}
+	return fmt.Sprintf("%s Input: a JSON object matching %s", t.fn.Description, schema)
+}
+
+// Call implements LangChainTool
+func (t langChainFunction) Call(ctx context.Context, input string) (string, error) {
+	var args map[string]interface{}
+	if err := json.Unmarshal([]byte(input), &args); err != nil || args == nil {
+		var required []string
+		switch keys := t.fn.params["required"].(type) {
+		case []string:
+			required = keys
+		case []interface{}:
+			for _, key := range keys {
+				if name, ok := key.(string); ok {
+					required = append(required, name)
+				}
+			}
+		}
+		if len(required) != 1 {
+			return "", fmt.Errorf("input of %s must be a JSON object of its arguments", t.fn.Name)
+		}
+		args = map[string]interface{}{required[0]: input}
+	}
+
+	result := executeFunction(ctx, &t.fn, args, map[string]interface{}{}, false)
+	if result.Error != nil {
+		return "", result.Error
+	}
+	return fmt.Sprintf("%v", result.Data), nil
+}
@@ -20,7 +20,7 @@ type ClaudeLLM struct {
 
 // NewClaudeLLM creates a new Claude LLM client
 func NewClaudeLLM(apiKey string) *ClaudeLLM {
-	client := anthropic.NewClient(option.WithAPIKey(apiKey))
+	client := anthropic.NewClient(option.WithAPIKey(apiKey), option.WithHTTPClient(SharedHTTPClient()))
 
 	return &ClaudeLLM{client: client}
 }
@@ -104,38 +104,41 @@ func generateJSONSchema[T any]() interface{} {
 	return reflector.Reflect(v)
 }
 
+// claudeStopRefusal is the stop reason of responses Claude declined to give for policy reasons
+const claudeStopRefusal anthropic.MessageStopReason = "refusal"
+
+// claudeToolCache reuses converted tool definitions across requests
+var claudeToolCache toolCache[anthropic.ToolParam]
+
 // convertToClaudeTools converts our generic Tool type to Claude's tool format
 func convertToClaudeTools(tools []Tool) []anthropic.ToolParam {
-	if len(tools) == 0 {
-		return nil
-	}
+	return claudeToolCache.convert(tools, convertToClaudeTool)
+}
 
-	claudeTools := make([]anthropic.ToolParam, len(tools))
-	for i, tool := range tools {
-		// Handle parameters based on their type
-		var schema interface{}
-		var params interface{} = tool.Function.Parameters
-		switch params := params.(type) {
-		case string:
-			// If it's already a JSON string, use it directly
-			schema = params
-		case map[string]interface{}:
-			// For maps, just marshal directly since they should already be in schema format
-			schema = params
-		default:
-			// For any other type, generate a schema using reflection
-			schemaObj := generateJSONSchema[interface{}]()
-			jsonBytes, _ := json.Marshal(schemaObj)
-			schema = string(jsonBytes)
-		}
+// convertToClaudeTool converts a single tool
+func convertToClaudeTool(tool Tool) anthropic.ToolParam {
+	// Handle parameters based on their type
+	var schema interface{}
+	var params interface{} = tool.Function.Parameters
+	switch params := params.(type) {
+	case string:
+		// If it's already a JSON string, use it directly
+		schema = params
+	case map[string]interface{}:
+		// For maps, just marshal directly since they should already be in schema format
+		schema = params
+	default:
+		// For any other type, generate a schema using reflection
+		schemaObj := generateJSONSchema[interface{}]()
+		jsonBytes, _ := json.Marshal(schemaObj)
+		schema = string(jsonBytes)
+	}
 
-		claudeTools[i] = anthropic.ToolParam{
-			Name:        anthropic.F(tool.Function.Name),
-			Description: anthropic.F(tool.Function.Description),
-			InputSchema: anthropic.F(schema),
-		}
+	return anthropic.ToolParam{
+		Name:        anthropic.F(tool.Function.Name),
+		Description: anthropic.F(tool.Function.Description),
+		InputSchema: anthropic.F(schema),
 	}
-	return claudeTools
 }
 
 // convertFromClaudeMessage converts Claude's message type to our generic Message type
@@ -168,6 +171,10 @@ func convertFromClaudeMessage(msg anthropic.Message) Message {
 
 // CreateChatCompletion implements the LLM interface for Claude
 func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return ChatCompletionResponse{}, err
+	}
+
 	// Extract system message if present
 	var systemPrompt string
 	var nonSystemMessages []Message
@@ -192,7 +199,13 @@ func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 		Model:     anthropic.F(req.Model),
 		MaxTokens: anthropic.F(int64(req.MaxTokens)),
 		Messages:  anthropic.F(messages),
-		Tools:     anthropic.F(convertToClaudeTools(req.Tools)),
+		Tools:     anthropic.F(convertToClaudeTools(chosenTools(req.Tools, req.ToolChoice))),
+	}
+	if toolChoice := claudeToolChoice(req.ToolChoice); toolChoice != nil {
+		claudeReq.ToolChoice = anthropic.F(toolChoice)
+	}
+	if len(req.Stop) > 0 {
+		claudeReq.StopSequences = anthropic.F(req.Stop)
 	}
 
 	if systemPrompt != "" {
@@ -206,20 +219,28 @@ func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 	}
 
 	// Make request to Claude API
-	resp, err := c.client.Messages.New(ctx, claudeReq)
+	resp, err := c.client.Messages.New(ctx, claudeReq, claudeServiceTier(req.ServiceTier)...)
 	if err != nil {
 		return ChatCompletionResponse{}, fmt.Errorf("claude API error: %v", err)
 	}
 
 	// Convert response
 	message := convertFromClaudeMessage(*resp)
+	if req.KeepRaw {
+		message.Raw = json.RawMessage(resp.JSON.RawJSON())
+	}
+
+	finishReason := "stop"
+	if resp.StopReason == claudeStopRefusal {
+		finishReason = FinishReasonContentFilter
+	}
 
 	return ChatCompletionResponse{
 		ID: resp.ID,
 		Choices: []Choice{{
 			Index:        0,
 			Message:      message,
-			FinishReason: "stop", // Claude doesn't provide this explicitly
+			FinishReason: finishReason,
 		}},
 		Usage: Usage{
 			PromptTokens:     int(resp.Usage.InputTokens),
@@ -231,6 +252,10 @@ func (c *ClaudeLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 
 // CreateChatCompletionStream implements the LLM interface for Claude streaming
 func (c *ClaudeLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return nil, err
+	}
+
 	// Extract system message if present
 	var systemPrompt string
 	var nonSystemMessages []Message
@@ -255,7 +280,13 @@ func (c *ClaudeLLM) CreateChatCompletionStream(ctx context.Context, req ChatComp
 		Model:     anthropic.F(req.Model),
 		MaxTokens: anthropic.F(int64(req.MaxTokens)),
 		Messages:  anthropic.F(messages),
-		Tools:     anthropic.F(convertToClaudeTools(req.Tools)),
+		Tools:     anthropic.F(convertToClaudeTools(chosenTools(req.Tools, req.ToolChoice))),
+	}
+	if toolChoice := claudeToolChoice(req.ToolChoice); toolChoice != nil {
+		claudeReq.ToolChoice = anthropic.F(toolChoice)
+	}
+	if len(req.Stop) > 0 {
+		claudeReq.StopSequences = anthropic.F(req.Stop)
 	}
 
 	if systemPrompt != "" {
@@ -269,7 +300,7 @@ func (c *ClaudeLLM) CreateChatCompletionStream(ctx context.Context, req ChatComp
 	}
 
 	// Create streaming response
-	stream := c.client.Messages.NewStreaming(ctx, claudeReq)
+	stream := c.client.Messages.NewStreaming(ctx, claudeReq, claudeServiceTier(req.ServiceTier)...)
 
 	return &claudeStreamWrapper{
 		stream:          stream,
@@ -306,6 +337,8 @@ func (w *claudeStreamWrapper) Recv() (ChatCompletionResponse, error) {
 		Content: w.currentContent,
 	}
 
+	var usage Usage
+	finishReason := string(event.Type)
 	switch event := event.AsUnion().(type) {
 	case anthropic.ContentBlockStartEvent:
 		if string(event.ContentBlock.Type) == string(anthropic.ContentBlockTypeToolUse) {
@@ -341,6 +374,16 @@ func (w *claudeStreamWrapper) Recv() (ChatCompletionResponse, error) {
 			message.ToolCalls = []ToolCall{*w.currentToolCall}
 			w.currentToolCall = nil
 		}
+		// The accumulated message holds the input tokens from the start event and the
+		// output tokens from the last delta
+		usage = Usage{
+			PromptTokens:     int(w.message.Usage.InputTokens),
+			CompletionTokens: int(w.message.Usage.OutputTokens),
+			TotalTokens:      int(w.message.Usage.InputTokens + w.message.Usage.OutputTokens),
+		}
+		if w.message.StopReason == claudeStopRefusal {
+			finishReason = FinishReasonContentFilter
+		}
 	}
 
 	return ChatCompletionResponse{
@@ -348,8 +391,9 @@ func (w *claudeStreamWrapper) Recv() (ChatCompletionResponse, error) {
 		Choices: []Choice{{
 			Index:        0,
 			Message:      message,
-			FinishReason: string(event.Type),
+			FinishReason: finishReason,
 		}},
+		Usage: usage,
 	}, nil
 }
 
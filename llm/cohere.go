@@ -0,0 +1,369 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const cohereAPIEndpoint = "https://api.cohere.com/v2/chat"
+
+// CohereLLM implements the LLM interface for Cohere's Command models
+type CohereLLM struct {
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+// NewCohereLLM creates a new Cohere LLM client
+func NewCohereLLM(apiKey string) *CohereLLM {
+	return &CohereLLM{
+		apiKey:   apiKey,
+		endpoint: cohereAPIEndpoint,
+		client:   SharedHTTPClient(),
+	}
+}
+
+// cohereMessage is a message in Cohere's v2 chat format. Assistant replies carry their
+// reasoning about tool use in ToolPlan.
+type cohereMessage struct {
+	Role       string          `json:"role"`
+	Content    json.RawMessage `json:"content,omitempty"` // A string, or content blocks in responses
+	ToolPlan   string          `json:"tool_plan,omitempty"`
+	ToolCalls  []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID string          `json:"tool_call_id,omitempty"`
+}
+
+// cohereContentBlock is a block of a response message's content
+type cohereContentBlock struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type cohereResponseFormat struct {
+	Type       string          `json:"type"`
+	JSONSchema json.RawMessage `json:"json_schema,omitempty"`
+}
+
+type cohereRequest struct {
+	Model          string                `json:"model"`
+	Messages       []cohereMessage       `json:"messages"`
+	Tools          []Tool                `json:"tools,omitempty"`
+	ToolChoice     string                `json:"tool_choice,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	Temperature    float32               `json:"temperature,omitempty"`
+	P              float32               `json:"p,omitempty"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	StopSequences  []string              `json:"stop_sequences,omitempty"`
+	ResponseFormat *cohereResponseFormat `json:"response_format,omitempty"`
+}
+
+type cohereUsage struct {
+	Tokens struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"tokens"`
+}
+
+type cohereResponse struct {
+	ID           string        `json:"id"`
+	FinishReason string        `json:"finish_reason"`
+	Message      cohereMessage `json:"message"`
+	Usage        cohereUsage   `json:"usage"`
+}
+
+// convertToCohereMessages converts messages to Cohere's format. Tool results become tool
+// messages answering the call they reference.
+func convertToCohereMessages(messages []Message) ([]cohereMessage, error) {
+	converted := make([]cohereMessage, 0, len(messages))
+	for _, msg := range messages {
+		content, err := json.Marshal(msg.Content)
+		if err != nil {
+			return nil, err
+		}
+		cm := cohereMessage{Role: string(msg.Role), Content: content}
+		switch msg.Role {
+		case RoleFunction, RoleTool:
+			if msg.ToolCallID == "" {
+				return nil, fmt.Errorf("%w: tool result for %s has no tool call ID", ErrInvalidToolHistory, msg.Name)
+			}
+			cm.Role = "tool"
+			cm.ToolCallID = msg.ToolCallID
+		case RoleAssistant:
+			if len(msg.ToolCalls) > 0 {
+				// Text accompanying tool calls is the model's tool plan
+				cm.Content = nil
+				cm.ToolPlan = msg.Content
+				cm.ToolCalls = make([]ToolCall, len(msg.ToolCalls))
+				for i, call := range msg.ToolCalls {
+					call.Index = nil
+					cm.ToolCalls[i] = call
+				}
+			}
+		}
+		converted = append(converted, cm)
+	}
+	return converted, nil
+}
+
+// convertFromCohereMessage converts a response message, joining its text blocks
+func convertFromCohereMessage(msg cohereMessage) Message {
+	var text strings.Builder
+	var blocks []cohereContentBlock
+	if err := json.Unmarshal(msg.Content, &blocks); err == nil {
+		for _, block := range blocks {
+			if block.Type == "text" {
+				text.WriteString(block.Text)
+			}
+		}
+	}
+	content := text.String()
+	if content == "" {
+		content = msg.ToolPlan
+	}
+	return Message{Role: RoleAssistant, Content: content, ToolCalls: msg.ToolCalls}
+}
+
+// convertFromCohereFinishReason maps Cohere's finish reasons to OpenAI's
+func convertFromCohereFinishReason(reason string) string {
+	switch reason {
+	case "COMPLETE", "STOP_SEQUENCE":
+		return "stop"
+	case "MAX_TOKENS":
+		return "length"
+	case "TOOL_CALL":
+		return "tool_calls"
+	case "ERROR_TOXIC":
+		return FinishReasonContentFilter
+	default:
+		return strings.ToLower(reason)
+	}
+}
+
+// cohereToolChoice converts a tool choice to Cohere's tool_choice, empty for the default. A
+// forced function is required with only that function offered.
+func cohereToolChoice(choice *ToolChoice) string {
+	switch {
+	case choice == nil:
+		return ""
+	case choice.Function != "" || choice.Mode == ToolChoiceRequired:
+		return "REQUIRED"
+	case choice.Mode == ToolChoiceNone:
+		return "NONE"
+	default:
+		return ""
+	}
+}
+
+// newRequest builds the HTTP request for a chat request
+func (c *CohereLLM) newRequest(ctx context.Context, req ChatCompletionRequest, stream bool) (*http.Request, error) {
+	messages, err := convertToCohereMessages(req.Messages)
+	if err != nil {
+		return nil, err
+	}
+	cohereReq := cohereRequest{
+		Model:         req.Model,
+		Messages:      messages,
+		Tools:         req.Tools,
+		ToolChoice:    cohereToolChoice(req.ToolChoice),
+		Stream:        stream,
+		Temperature:   req.Temperature,
+		P:             req.TopP,
+		MaxTokens:     req.MaxTokens,
+		StopSequences: req.Stop,
+	}
+	if req.ToolChoice != nil && req.ToolChoice.Function != "" {
+		cohereReq.Tools = chosenTools(req.Tools, req.ToolChoice)
+	}
+	if req.Grammar != nil {
+		if req.Grammar.GBNF != "" {
+			return nil, fmt.Errorf("%w: Cohere only supports JSON schemas", ErrGrammarNotSupported)
+		}
+		cohereReq.ResponseFormat = &cohereResponseFormat{Type: "json_object", JSONSchema: req.Grammar.JSONSchema}
+	}
+
+	body, err := json.Marshal(cohereReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return httpReq, nil
+}
+
+// do sends the request and checks its status
+func (c *CohereLLM) do(httpReq *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+// CreateChatCompletion implements the LLM interface for Cohere
+func (c *CohereLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	httpReq, err := c.newRequest(ctx, req, false)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	var cohereResp cohereResponse
+	if err := json.Unmarshal(raw, &cohereResp); err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	choices := []Choice{{
+		Message:      convertFromCohereMessage(cohereResp.Message),
+		FinishReason: convertFromCohereFinishReason(cohereResp.FinishReason),
+	}}
+	if req.KeepRaw {
+		attachRaw(choices, raw)
+	}
+	tokens := cohereResp.Usage.Tokens
+	return ChatCompletionResponse{
+		ID:      cohereResp.ID,
+		Choices: choices,
+		Usage: Usage{
+			PromptTokens:     tokens.InputTokens,
+			CompletionTokens: tokens.OutputTokens,
+			TotalTokens:      tokens.InputTokens + tokens.OutputTokens,
+		},
+	}, nil
+}
+
+// cohereStreamEvent is an event of a streamed Cohere response
+type cohereStreamEvent struct {
+	Type  string `json:"type"`
+	ID    string `json:"id"`
+	Index int    `json:"index"`
+	Delta struct {
+		Message struct {
+			Content struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			ToolPlan  string   `json:"tool_plan"`
+			ToolCalls ToolCall `json:"tool_calls"`
+		} `json:"message"`
+		FinishReason string      `json:"finish_reason"`
+		Usage        cohereUsage `json:"usage"`
+	} `json:"delta"`
+}
+
+type cohereStreamWrapper struct {
+	reader    *bufio.Reader
+	response  *http.Response
+	assembler *ToolCallAssembler
+	id        string
+	err       error // Returned after the chunk that completed the stream
+}
+
+// Recv returns the next chunk of the response. Tool calls are returned once complete.
+func (s *cohereStreamWrapper) Recv() (ChatCompletionResponse, error) {
+	if s.err != nil {
+		return ChatCompletionResponse{}, s.err
+	}
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return ChatCompletionResponse{}, io.EOF
+			}
+			return ChatCompletionResponse{}, fmt.Errorf("failed to read stream: %w", err)
+		}
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, sseDataPrefix) {
+			continue
+		}
+		line = bytes.TrimSpace(line[len(sseDataPrefix):])
+		if len(line) == 0 || bytes.Equal(line, sseDone) {
+			continue
+		}
+
+		var event cohereStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return ChatCompletionResponse{}, fmt.Errorf("failed to unmarshal stream response: %w", err)
+		}
+		delta := event.Delta.Message
+		switch event.Type {
+		case "message-start":
+			s.id = event.ID
+		case "content-delta":
+			return s.chunk(Message{Role: RoleAssistant, Content: delta.Content.Text}, "", Usage{}), nil
+		case "tool-plan-delta":
+			return s.chunk(Message{Role: RoleAssistant, Content: delta.ToolPlan}, "", Usage{}), nil
+		case "tool-call-start", "tool-call-delta":
+			index := event.Index
+			call := delta.ToolCalls
+			call.Index = &index
+			s.assembler.Add(call)
+			if ready := s.assembler.Ready(); len(ready) > 0 {
+				return s.chunk(Message{Role: RoleAssistant, ToolCalls: ready}, "", Usage{}), nil
+			}
+		case "message-end":
+			// The chunk still carries the finish reason and usage, so incomplete calls are
+			// reported by the next call
+			remaining, err := s.assembler.Flush()
+			s.err = err
+			tokens := event.Delta.Usage.Tokens
+			return s.chunk(Message{Role: RoleAssistant, ToolCalls: remaining}, convertFromCohereFinishReason(event.Delta.FinishReason), Usage{
+				PromptTokens:     tokens.InputTokens,
+				CompletionTokens: tokens.OutputTokens,
+				TotalTokens:      tokens.InputTokens + tokens.OutputTokens,
+			}), nil
+		}
+	}
+}
+
+// chunk wraps a message delta as a streamed response
+func (s *cohereStreamWrapper) chunk(message Message, finishReason string, usage Usage) ChatCompletionResponse {
+	return ChatCompletionResponse{
+		ID:      s.id,
+		Choices: []Choice{{Message: message, FinishReason: finishReason}},
+		Usage:   usage,
+	}
+}
+
+func (s *cohereStreamWrapper) Close() error {
+	return s.response.Body.Close()
+}
+
+// CreateChatCompletionStream implements the LLM interface for Cohere streaming
+func (c *CohereLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	httpReq, err := c.newRequest(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	return &cohereStreamWrapper{
+		reader:    bufio.NewReader(resp.Body),
+		response:  resp,
+		assembler: NewToolCallAssembler(),
+	}, nil
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCohereToolCalls(t *testing.T) {
+	var sent cohereRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		if sent.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "event: message-start\ndata: {\"type\":\"message-start\",\"id\":\"msg_2\"}\n\n"+
+				"event: content-delta\ndata: {\"type\":\"content-delta\",\"index\":0,\"delta\":{\"message\":{\"content\":{\"text\":\"Sunny\"}}}}\n\n"+
+				"event: message-end\ndata: {\"type\":\"message-end\",\"delta\":{\"finish_reason\":\"COMPLETE\",\"usage\":{\"tokens\":{\"input_tokens\":20,\"output_tokens\":2}}}}\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"msg_1","finish_reason":"TOOL_CALL","message":{"role":"assistant","tool_plan":"I will look up the weather.",`+
+			`"tool_calls":[{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}]},`+
+			`"usage":{"tokens":{"input_tokens":10,"output_tokens":5}}}`)
+	}))
+	defer server.Close()
+
+	client := NewCohereLLM("key")
+	client.endpoint = server.URL
+	req := ChatCompletionRequest{
+		Model:    "command-r-plus",
+		Messages: []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Weather in Paris?"}},
+		Tools:    []Tool{{Type: "function", Function: &Function{Name: "get_weather"}}},
+	}
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	assert.Equal(t, "I will look up the weather.", resp.Choices[0].Message.Content)
+	assert.Equal(t, "get_weather", resp.Choices[0].Message.ToolCalls[0].Function.Name)
+	assert.Equal(t, 15, resp.Usage.TotalTokens)
+
+	req.Messages = append(req.Messages, resp.Choices[0].Message, Message{Role: RoleFunction, Name: "get_weather", Content: "Sunny", ToolCallID: "call_1"})
+	stream, err := client.CreateChatCompletionStream(context.Background(), req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stream.Close()
+	assert.Equal(t, "tool", sent.Messages[3].Role)
+	assert.Equal(t, "call_1", sent.Messages[3].ToolCallID)
+	assert.Equal(t, "I will look up the weather.", sent.Messages[2].ToolPlan)
+
+	chunk, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Sunny", chunk.Choices[0].Message.Content)
+	chunk, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "stop", chunk.Choices[0].FinishReason)
+	assert.Equal(t, 22, chunk.Usage.TotalTokens)
+	_, err = stream.Recv()
+	assert.ErrorIs(t, err, io.EOF)
+}
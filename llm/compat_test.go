@@ -0,0 +1,67 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompatProfileRecoversToolCalls(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"<tool_call>\n{\"name\": \"get_weather\", \"arguments\": {\"city\": \"Paris\"}}\n</tool_call>"},"finish_reason":"eos"}]}`)
+	}))
+	defer server.Close()
+
+	req := ChatCompletionRequest{
+		Model:    "local",
+		Messages: []Message{{Role: RoleUser, Content: "Weather in Paris?"}},
+		Tools:    []Tool{{Type: "function", Function: &Function{Name: "get_weather"}}},
+	}
+	resp, err := NewOpenAICompatibleLLM("", server.URL, CompatVLLM).CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	choice := resp.Choices[0]
+	assert.Equal(t, "tool_calls", choice.FinishReason)
+	assert.Empty(t, choice.Message.Content)
+	if assert.Len(t, choice.Message.ToolCalls, 1) {
+		call := choice.Message.ToolCalls[0]
+		assert.NotEmpty(t, call.ID)
+		assert.Equal(t, "function", call.Type)
+		assert.Equal(t, "get_weather", call.Function.Name)
+		assert.JSONEq(t, `{"city":"Paris"}`, call.Function.Arguments)
+	}
+
+	resp, err = NewOpenAILLMWithHost("", server.URL).CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Choices[0].Message.ToolCalls)
+	assert.Equal(t, "eos", resp.Choices[0].FinishReason)
+}
+
+func TestCompatProfileNoStreamUsage(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	req := ChatCompletionRequest{Model: "local", Messages: []Message{{Role: RoleUser, Content: "Hi"}}}
+	for _, client := range []*OpenAILLM{
+		NewOpenAICompatibleLLM("", server.URL, CompatProfile{NoStreamUsage: true}),
+		NewOpenAICompatibleLLM("", server.URL, CompatVLLM),
+	} {
+		stream, err := client.CreateChatCompletionStream(context.Background(), req)
+		assert.NoError(t, err)
+		stream.Close()
+	}
+	if assert.Len(t, bodies, 2) {
+		assert.NotContains(t, bodies[0], "stream_options")
+		assert.Contains(t, bodies[1], `"stream_options":{"include_usage":true}`)
+	}
+}
@@ -22,7 +22,7 @@ type DeepSeekLLM struct {
 func NewDeepSeekLLM(apiKey string) *DeepSeekLLM {
 	return &DeepSeekLLM{
 		apiKey: apiKey,
-		client: &http.Client{},
+		client: SharedHTTPClient(),
 	}
 }
 
@@ -68,6 +68,7 @@ type deepseekRequest struct {
 	Temperature float32  `json:"temperature,omitempty"`
 	TopP        float32  `json:"top_p,omitempty"`
 	Tools       []Tool   `json:"tools,omitempty"`
+	ToolChoice  any      `json:"tool_choice,omitempty"`
 	Stop        []string `json:"stop,omitempty"`
 }
 
@@ -99,6 +100,10 @@ func convertFromDeepSeekRole(role string) Role {
 
 // CreateChatCompletion implements the LLM interface for DeepSeek
 func (l *DeepSeekLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return ChatCompletionResponse{}, err
+	}
+
 	// Convert messages to DeepSeek format
 	var deepseekMessages []deepseekMessage
 	var lastToolCalls []ToolCall
@@ -106,8 +111,8 @@ func (l *DeepSeekLLM) CreateChatCompletion(ctx context.Context, req ChatCompleti
 	for i, msg := range req.Messages {
 		if msg.Role == RoleFunction {
 			// For function responses, we need to find the corresponding tool call
-			var toolCallID string
-			for j := i - 1; j >= 0; j-- {
+			toolCallID := msg.ToolCallID
+			for j := i - 1; j >= 0 && toolCallID == ""; j-- {
 				if req.Messages[j].Role == RoleAssistant && len(req.Messages[j].ToolCalls) > 0 {
 					for _, toolCall := range req.Messages[j].ToolCalls {
 						if toolCall.Function.Name == msg.Name {
@@ -166,12 +171,14 @@ func (l *DeepSeekLLM) CreateChatCompletion(ctx context.Context, req ChatCompleti
 		Temperature:      req.Temperature,
 		TopP:             req.TopP,
 		Tools:            req.Tools,
+		ToolChoice:       openAIToolChoice(req.ToolChoice),
 		Stop:             req.Stop,
 	}
 
 	// For follow-up responses after tool calls, disable tools to prevent loops
 	if len(req.Messages) > 0 && req.Messages[len(req.Messages)-1].Role == RoleFunction {
 		deepseekReq.Tools = nil
+		deepseekReq.ToolChoice = nil
 	}
 
 	// Set default values if not provided
@@ -209,10 +216,18 @@ func (l *DeepSeekLLM) CreateChatCompletion(ctx context.Context, req ChatCompleti
 		return ChatCompletionResponse{}, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
 	}
 
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to read response: %w", err)
+	}
+
 	var deepseekResp deepseekResponse
-	if err := json.NewDecoder(resp.Body).Decode(&deepseekResp); err != nil {
+	if err := json.Unmarshal(raw, &deepseekResp); err != nil {
 		return ChatCompletionResponse{}, fmt.Errorf("failed to decode response: %w", err)
 	}
+	if req.KeepRaw {
+		attachRaw(deepseekResp.Choices, raw)
+	}
 
 	return ChatCompletionResponse{
 		ID:      deepseekResp.ID,
@@ -222,19 +237,21 @@ func (l *DeepSeekLLM) CreateChatCompletion(ctx context.Context, req ChatCompleti
 }
 
 type deepseekStreamWrapper struct {
-	ctx             context.Context
-	reader          *bufio.Reader
-	response        *http.Response
-	currentToolCall *ToolCall
-	toolCallBuffer  map[string]*ToolCall
+	ctx       context.Context
+	reader    *bufio.Reader
+	response  *http.Response
+	assembler *ToolCallAssembler
+	line      []byte                 // Reused for lines longer than the reader's buffer
+	decoded   deepseekStreamResponse // Reused decode target
+	err       error                  // Returned after the chunk that completed the stream
 }
 
 func newDeepseekStreamWrapper(ctx context.Context, response *http.Response) *deepseekStreamWrapper {
 	return &deepseekStreamWrapper{
-		ctx:            ctx,
-		reader:         bufio.NewReader(response.Body),
-		response:       response,
-		toolCallBuffer: make(map[string]*ToolCall),
+		ctx:       ctx,
+		reader:    bufio.NewReader(response.Body),
+		response:  response,
+		assembler: NewToolCallAssembler(),
 	}
 }
 
@@ -242,44 +259,108 @@ func (s *deepseekStreamWrapper) Close() error {
 	return s.response.Body.Close()
 }
 
+// Server-sent event markers
+var (
+	sseDataPrefix = []byte("data:")
+	sseDone       = []byte("[DONE]")
+)
+
+// nextEvent returns the payload of the next SSE data line, skipping blank lines, comments and
+// other fields. The returned slice is only valid until the next call.
+func (s *deepseekStreamWrapper) nextEvent() ([]byte, error) {
+	for {
+		line, err := s.readLine()
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			return nil, fmt.Errorf("failed to read stream: %w", err)
+		}
+
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, sseDataPrefix) {
+			if err == io.EOF {
+				return nil, io.EOF
+			}
+			continue
+		}
+		line = bytes.TrimSpace(line[len(sseDataPrefix):])
+		if bytes.Equal(line, sseDone) {
+			return nil, io.EOF
+		}
+		if len(line) > 0 {
+			return line, nil
+		}
+	}
+}
+
+// readLine reads a line without allocating unless it exceeds the reader's buffer
+func (s *deepseekStreamWrapper) readLine() ([]byte, error) {
+	line, err := s.reader.ReadSlice('\n')
+	if err != bufio.ErrBufferFull {
+		return line, err
+	}
+	s.line = append(s.line[:0], line...)
+	for err == bufio.ErrBufferFull {
+		line, err = s.reader.ReadSlice('\n')
+		s.line = append(s.line, line...)
+	}
+	return s.line, err
+}
+
 func (s *deepseekStreamWrapper) Recv() (ChatCompletionResponse, error) {
 	select {
 	case <-s.ctx.Done():
 		return ChatCompletionResponse{}, s.ctx.Err()
 	default:
 	}
+	if s.err != nil {
+		return ChatCompletionResponse{}, s.err
+	}
 
-	line, err := s.reader.ReadBytes('\n')
+	line, err := s.nextEvent()
 	if err != nil {
-		if err == io.EOF {
-			return ChatCompletionResponse{}, io.EOF
-		}
-		return ChatCompletionResponse{}, fmt.Errorf("failed to read stream: %w", err)
+		return ChatCompletionResponse{}, err
 	}
 
-	// Remove "data: " prefix
-	line = bytes.TrimPrefix(line, []byte("data: "))
-	line = bytes.TrimSpace(line)
-
-	// Check for stream end
-	if bytes.Equal(line, []byte("[DONE]")) {
-		return ChatCompletionResponse{}, io.EOF
+	// Reset the reused target so no state leaks from the previous chunk
+	streamResp := &s.decoded
+	clear(streamResp.Choices[:cap(streamResp.Choices)])
+	*streamResp = deepseekStreamResponse{Choices: streamResp.Choices[:0]}
+	if err := json.Unmarshal(line, streamResp); err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to unmarshal stream response: %w", err)
 	}
 
-	var streamResp deepseekStreamResponse
-	if err := json.Unmarshal(line, &streamResp); err != nil {
-		return ChatCompletionResponse{}, fmt.Errorf("failed to unmarshal stream response: %w", err)
+	choices := convertStreamChoicesToChoices(streamResp.Choices)
+	for i := range choices {
+		// Buffer tool call fragments; they are only emitted once their arguments are complete
+		for _, tc := range choices[i].Message.ToolCalls {
+			s.assembler.Add(tc)
+		}
+		choices[i].Message.ToolCalls = s.assembler.Ready()
+
+		if choices[i].FinishReason != "" {
+			// The chunk still carries the finish reason and usage, so incomplete calls are
+			// reported by the next call
+			remaining, err := s.assembler.Flush()
+			s.err = err
+			choices[i].Message.ToolCalls = append(choices[i].Message.ToolCalls, remaining...)
+		}
 	}
 
 	return ChatCompletionResponse{
 		ID:      streamResp.ID,
-		Choices: convertStreamChoicesToChoices(streamResp.Choices),
+		Choices: choices,
 		Usage:   streamResp.Usage,
 	}, nil
 }
 
 // CreateChatCompletionStream implements the LLM interface for DeepSeek streaming
 func (l *DeepSeekLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return nil, err
+	}
+
 	// Convert messages to DeepSeek format
 	var deepseekMessages []deepseekMessage
 	var lastToolCalls []ToolCall
@@ -287,8 +368,8 @@ func (l *DeepSeekLLM) CreateChatCompletionStream(ctx context.Context, req ChatCo
 	for i, msg := range req.Messages {
 		if msg.Role == RoleFunction {
 			// For function responses, we need to find the corresponding tool call
-			var toolCallID string
-			for j := i - 1; j >= 0; j-- {
+			toolCallID := msg.ToolCallID
+			for j := i - 1; j >= 0 && toolCallID == ""; j-- {
 				if req.Messages[j].Role == RoleAssistant && len(req.Messages[j].ToolCalls) > 0 {
 					for _, toolCall := range req.Messages[j].ToolCalls {
 						if toolCall.Function.Name == msg.Name {
@@ -348,6 +429,7 @@ func (l *DeepSeekLLM) CreateChatCompletionStream(ctx context.Context, req ChatCo
 		Temperature:      req.Temperature,
 		TopP:             req.TopP,
 		Tools:            req.Tools,
+		ToolChoice:       openAIToolChoice(req.ToolChoice),
 		Stop:             req.Stop,
 		Stream:           true,
 	}
@@ -355,6 +437,7 @@ func (l *DeepSeekLLM) CreateChatCompletionStream(ctx context.Context, req ChatCo
 	// For follow-up responses after tool calls, disable tools to prevent loops
 	if len(req.Messages) > 0 && req.Messages[len(req.Messages)-1].Role == RoleFunction {
 		deepseekReq.Tools = nil
+		deepseekReq.ToolChoice = nil
 	}
 
 	// Set default values if not provided
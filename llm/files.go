@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// ErrFilesNotSupported is returned when uploading files with a client that cannot take file inputs
+var ErrFilesNotSupported = errors.New("file inputs are not supported by this client")
+
+// FileUpload is a document to upload to a provider's file store, e.g. a PDF to analyze
+type FileUpload struct {
+	Name     string // File name, including its extension
+	MimeType string // Content type, application/pdf if empty
+	Data     []byte
+}
+
+// FileRef references a file in a provider's file store. Attached to a message, it lets the
+// model read the file without sending its content again with every request.
+type FileRef struct {
+	ID       string `json:"id"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+// FileStore is implemented by clients whose provider keeps uploaded files for use as inputs
+type FileStore interface {
+	UploadFile(ctx context.Context, upload FileUpload) (FileRef, error)
+	DeleteFile(ctx context.Context, id string) error
+}
+
+// UploadFile uploads a file with the client, failing with ErrFilesNotSupported for clients
+// that do not implement FileStore
+func UploadFile(ctx context.Context, client LLM, upload FileUpload) (FileRef, error) {
+	store, ok := client.(FileStore)
+	if !ok {
+		return FileRef{}, ErrFilesNotSupported
+	}
+	return store.UploadFile(ctx, upload)
+}
+
+// DeleteFile deletes a file uploaded with the client
+func DeleteFile(ctx context.Context, client LLM, id string) error {
+	store, ok := client.(FileStore)
+	if !ok {
+		return ErrFilesNotSupported
+	}
+	return store.DeleteFile(ctx, id)
+}
+
+// filesEndpoint returns the endpoint of the file store next to the responses endpoint
+func (c *OpenAIResponsesLLM) filesEndpoint() string {
+	return strings.TrimSuffix(c.endpoint, "/responses") + "/files"
+}
+
+// UploadFile implements FileStore, uploading the file for use as a model input
+func (c *OpenAIResponsesLLM) UploadFile(ctx context.Context, upload FileUpload) (FileRef, error) {
+	mimeType := upload.MimeType
+	if mimeType == "" {
+		mimeType = "application/pdf"
+	}
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	if err := form.WriteField("purpose", "user_data"); err != nil {
+		return FileRef{}, err
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, upload.Name))
+	header.Set("Content-Type", mimeType)
+	part, err := form.CreatePart(header)
+	if err != nil {
+		return FileRef{}, err
+	}
+	if _, err := part.Write(upload.Data); err != nil {
+		return FileRef{}, err
+	}
+	if err := form.Close(); err != nil {
+		return FileRef{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.filesEndpoint(), &body)
+	if err != nil {
+		return FileRef{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", form.FormDataContentType())
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return FileRef{}, fmt.Errorf("failed to upload %s: %w", upload.Name, err)
+	}
+	defer resp.Body.Close()
+
+	var file struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&file); err != nil {
+		return FileRef{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return FileRef{ID: file.ID, Name: upload.Name, MimeType: mimeType}, nil
+}
+
+// DeleteFile implements FileStore
+func (c *OpenAIResponsesLLM) DeleteFile(ctx context.Context, id string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", c.filesEndpoint()+"/"+id, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to delete file %s: %w", id, err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return resp.Body.Close()
+}
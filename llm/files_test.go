@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponsesFiles(t *testing.T) {
+	var deleted string
+	var input []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/v1/files":
+			file, header, err := r.FormFile("file")
+			if !assert.NoError(t, err) {
+				return
+			}
+			data, _ := io.ReadAll(file)
+			assert.Equal(t, "%PDF-1.7", string(data))
+			assert.Equal(t, "application/pdf", header.Header.Get("Content-Type"))
+			assert.Equal(t, "user_data", r.FormValue("purpose"))
+			io.WriteString(w, `{"id":"file_1","object":"file"}`)
+		case r.Method == "DELETE":
+			deleted = r.URL.Path
+			io.WriteString(w, `{"id":"file_1","deleted":true}`)
+		default:
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			input = body["input"].([]interface{})
+			io.WriteString(w, `{"id":"resp_1","status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"A contract."}]}]}`)
+		}
+	}))
+	defer server.Close()
+
+	client := NewOpenAIResponsesLLM("key")
+	client.endpoint = server.URL + "/v1/responses"
+	file, err := UploadFile(context.Background(), client, FileUpload{Name: "contract.pdf", Data: []byte("%PDF-1.7")})
+	assert.NoError(t, err)
+	assert.Equal(t, FileRef{ID: "file_1", Name: "contract.pdf", MimeType: "application/pdf"}, file)
+
+	_, err = client.CreateChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: RoleUser, Content: "What is this?", Files: []FileRef{file}}},
+	})
+	assert.NoError(t, err)
+	content := input[0].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "input_file", "file_id": "file_1"}, content[1])
+
+	assert.NoError(t, DeleteFile(context.Background(), client, "file_1"))
+	assert.Equal(t, "/v1/files/file_1", deleted)
+	_, err = UploadFile(context.Background(), NewCohereLLM("key"), FileUpload{Name: "contract.pdf"})
+	assert.ErrorIs(t, err, ErrFilesNotSupported)
+}
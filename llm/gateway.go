@@ -0,0 +1,136 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Gateway is an LLM gateway that proxies OpenAI-compatible requests to providers, adding
+// routing, caching, spend tracking and logging
+type Gateway struct {
+	Name    string
+	BaseURL string            // OpenAI-compatible endpoint of the gateway
+	Headers map[string]string // Sent with every request, e.g. the gateway's key and a virtual key
+	// CostHeader is the response header the gateway reports the cost of a request in, in US
+	// dollars. The cost is read into Usage.Cost.
+	CostHeader string
+	// tag adds the metadata of a context's requests in the gateway's format
+	tag func(ctx context.Context, metadata map[string]string) context.Context
+}
+
+// LiteLLMGateway is a LiteLLM proxy at the base URL. Requests are authenticated with the API key
+// the client is created with, usually a LiteLLM virtual key. Metadata is sent in the request
+// body, and the cost LiteLLM computes is read from its response headers.
+func LiteLLMGateway(baseURL string) Gateway {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if !strings.HasSuffix(baseURL, "/v1") {
+		baseURL += "/v1"
+	}
+	return Gateway{
+		Name:       "LiteLLM",
+		BaseURL:    baseURL,
+		CostHeader: "x-litellm-response-cost",
+		tag: func(ctx context.Context, metadata map[string]string) context.Context {
+			return withExtraFields(ctx, map[string]interface{}{"metadata": metadata})
+		},
+	}
+}
+
+// PortkeyGateway is Portkey's hosted gateway, authenticated with the Portkey API key, routing
+// requests to the provider of the virtual key. The API key the client is created with is not
+// needed and can be empty. Metadata is sent in Portkey's metadata header.
+func PortkeyGateway(apiKey, virtualKey string) Gateway {
+	headers := map[string]string{"x-portkey-api-key": apiKey}
+	if virtualKey != "" {
+		headers["x-portkey-virtual-key"] = virtualKey
+	}
+	return Gateway{
+		Name:    "Portkey",
+		BaseURL: "https://api.portkey.ai/v1",
+		Headers: headers,
+		tag: func(ctx context.Context, metadata map[string]string) context.Context {
+			data, err := json.Marshal(metadata)
+			if err != nil {
+				return ctx
+			}
+			return WithHeaders(ctx, http.Header{"X-Portkey-Metadata": {string(data)}})
+		},
+	}
+}
+
+// HeliconeGateway is Helicone's hosted OpenAI proxy, authenticated with the Helicone API key.
+// The API key the client is created with is the OpenAI key requests are forwarded with.
+// Metadata is sent as Helicone custom properties.
+func HeliconeGateway(apiKey string) Gateway {
+	return Gateway{
+		Name:    "Helicone",
+		BaseURL: "https://oai.helicone.ai/v1",
+		Headers: map[string]string{"Helicone-Auth": "Bearer " + apiKey},
+		tag: func(ctx context.Context, metadata map[string]string) context.Context {
+			headers := make(http.Header, len(metadata))
+			for key, value := range metadata {
+				headers.Set("Helicone-Property-"+key, value)
+			}
+			return WithHeaders(ctx, headers)
+		},
+	}
+}
+
+// NewGatewayLLM creates a client sending requests through the gateway with the API key
+func NewGatewayLLM(apiKey string, gateway Gateway) *OpenAILLM {
+	client := NewOpenAILLMWithHost(apiKey, gateway.BaseURL)
+	client.gateway = &gateway
+	return client
+}
+
+type gatewayMetadataKey struct{}
+
+// WithGatewayMetadata returns a context whose requests through a gateway are tagged with the
+// metadata, along with that already added by the context, e.g. to break down spend by
+// feature or customer in the gateway's dashboard
+func WithGatewayMetadata(ctx context.Context, metadata map[string]string) context.Context {
+	merged := make(map[string]string, len(metadata))
+	if existing, ok := ctx.Value(gatewayMetadataKey{}).(map[string]string); ok {
+		for key, value := range existing {
+			merged[key] = value
+		}
+	}
+	for key, value := range metadata {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, gatewayMetadataKey{}, merged)
+}
+
+// apply returns the context a request through the gateway is sent with: it carries the
+// gateway's headers, unless the context sets them, and the context's metadata
+func (g *Gateway) apply(ctx context.Context) context.Context {
+	if len(g.Headers) > 0 {
+		existing, _ := ctx.Value(headersKey{}).(http.Header)
+		headers := make(http.Header, len(g.Headers))
+		for key, value := range g.Headers {
+			if existing.Get(key) == "" {
+				headers.Set(key, value)
+			}
+		}
+		ctx = WithHeaders(ctx, headers)
+	}
+	if metadata, ok := ctx.Value(gatewayMetadataKey{}).(map[string]string); ok && len(metadata) > 0 && g.tag != nil {
+		ctx = g.tag(ctx, metadata)
+	}
+	return ctx
+}
+
+// cost reads the cost the gateway reported in the response headers, or 0 if it did not
+func (g *Gateway) cost(header http.Header) float64 {
+	if g.CostHeader == "" {
+		return 0
+	}
+	cost, err := strconv.ParseFloat(strings.TrimSpace(header.Get(g.CostHeader)), 64)
+	if err != nil {
+		return 0
+	}
+	return cost
+}
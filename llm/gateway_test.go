@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGateways(t *testing.T) {
+	var header http.Header
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		data, _ := io.ReadAll(r.Body)
+		body = nil
+		json.Unmarshal(data, &body)
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("x-litellm-response-cost", "0.0042")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`)
+	}))
+	defer server.Close()
+	req := ChatCompletionRequest{Model: "gpt-4o", Messages: []Message{{Role: RoleUser, Content: "Hi"}}}
+	ctx := WithGatewayMetadata(context.Background(), map[string]string{"feature": "chat"})
+
+	// LiteLLM: virtual key as API key, metadata in the body, cost from the headers
+	resp, err := NewGatewayLLM("sk-virtual", LiteLLMGateway(server.URL+"/")).CreateChatCompletion(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer sk-virtual", header.Get("Authorization"))
+	assert.Equal(t, map[string]interface{}{"feature": "chat"}, body["metadata"])
+	assert.Equal(t, 0.0042, resp.Usage.Cost)
+	assert.Equal(t, 4, resp.Usage.TotalTokens)
+
+	// Portkey: keys and metadata in headers, no cost header
+	portkey := PortkeyGateway("pk", "vk")
+	portkey.BaseURL = server.URL + "/v1"
+	resp, err = NewGatewayLLM("", portkey).CreateChatCompletion(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "pk", header.Get("x-portkey-api-key"))
+	assert.Equal(t, "vk", header.Get("x-portkey-virtual-key"))
+	assert.JSONEq(t, `{"feature":"chat"}`, header.Get("x-portkey-metadata"))
+	assert.NotContains(t, body, "metadata")
+	assert.Zero(t, resp.Usage.Cost)
+
+	// Helicone: metadata as properties, and headers set by the caller take precedence
+	helicone := HeliconeGateway("hk")
+	helicone.BaseURL = server.URL + "/v1"
+	ctx = WithHeaders(ctx, http.Header{"Helicone-Auth": {"Bearer override"}})
+	_, err = NewGatewayLLM("sk-openai", helicone).CreateChatCompletion(ctx, req)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer override", header.Get("Helicone-Auth"))
+	assert.Equal(t, "chat", header.Get("Helicone-Property-feature"))
+	assert.Equal(t, "Bearer sk-openai", header.Get("Authorization"))
+}
@@ -112,58 +112,55 @@ func convertFromGeminiResponse(resp *genai.GenerateContentResponse) Message {
 	}
 }
 
+// geminiToolCache reuses converted tool definitions across requests
+var geminiToolCache toolCache[*genai.Tool]
+
 // convertToGeminiTools converts our generic Tool type to Gemini's tool type
 func convertToGeminiTools(tools []Tool) []*genai.Tool {
-	if len(tools) == 0 {
-		return nil
-	}
-
-	geminiTools := make([]*genai.Tool, len(tools))
-	for i, tool := range tools {
-		schema := &genai.Schema{
-			Type: genai.TypeObject,
-		}
+	return geminiToolCache.convert(tools, convertToGeminiTool)
+}
 
+// convertToGeminiTool converts a single tool
+func convertToGeminiTool(tool Tool) *genai.Tool {
+	schema := &genai.Schema{
+		Type: genai.TypeObject,
+	}
 
-			schema.Properties = make(map[string]*genai.Schema)
-			
-			if properties, ok := tool.Function.Parameters["properties"].(map[string]interface{}); ok {
-				for name, prop := range properties {
-					if propMap, ok := prop.(map[string]interface{}); ok {
-						propSchema := &genai.Schema{}
-						if typ, ok := propMap["type"].(string); ok {
-							propSchema.Type = convertSchemaType(typ)
-						}
-						if desc, ok := propMap["description"].(string); ok {
-							propSchema.Description = desc
-						}
-						schema.Properties[name] = propSchema
-					}
+	schema.Properties = make(map[string]*genai.Schema)
+	if properties, ok := tool.Function.Parameters["properties"].(map[string]interface{}); ok {
+		for name, prop := range properties {
+			if propMap, ok := prop.(map[string]interface{}); ok {
+				propSchema := &genai.Schema{}
+				if typ, ok := propMap["type"].(string); ok {
+					propSchema.Type = convertSchemaType(typ)
 				}
+				if desc, ok := propMap["description"].(string); ok {
+					propSchema.Description = desc
+				}
+				schema.Properties[name] = propSchema
 			}
+		}
+	}
 
-			if required, ok := tool.Function.Parameters["required"].([]interface{}); ok {
-				reqFields := make([]string, len(required))
-				for i, r := range required {
-					if str, ok := r.(string); ok {
-						reqFields[i] = str
-					}
-				}
-				schema.Required = reqFields
+	if required, ok := tool.Function.Parameters["required"].([]interface{}); ok {
+		reqFields := make([]string, len(required))
+		for i, r := range required {
+			if str, ok := r.(string); ok {
+				reqFields[i] = str
 			}
-		
-
-		geminiTools[i] = &genai.Tool{
-			FunctionDeclarations: []*genai.FunctionDeclaration{
-				{
-					Name:        tool.Function.Name,
-					Description: tool.Function.Description,
-					Parameters:  schema,
-				},
-			},
 		}
+		schema.Required = reqFields
+	}
+
+	return &genai.Tool{
+		FunctionDeclarations: []*genai.FunctionDeclaration{
+			{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  schema,
+			},
+		},
 	}
-	return geminiTools
 }
 
 // convertSchemaType converts a JSON Schema type to Gemini schema type
@@ -208,6 +205,10 @@ func convertFromGeminiToolCalls(parts []genai.Part) []ToolCall {
 
 // CreateChatCompletion implements the LLM interface for Gemini
 func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return ChatCompletionResponse{}, err
+	}
+
 	// Create model and configure settings
 	model := g.client.GenerativeModel(req.Model)
 
@@ -220,6 +221,9 @@ func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 	if req.MaxTokens > 0 {
 		model.SetMaxOutputTokens(int32(req.MaxTokens))
 	}
+	if len(req.Stop) > 0 {
+		model.StopSequences = req.Stop
+	}
 
 	// Check if we're in a function calling cycle
 	inFunctionCall := false
@@ -231,6 +235,7 @@ func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 	// Only set tools if we're not in a function calling cycle
 	if len(req.Tools) > 0 && !inFunctionCall {
 		model.Tools = convertToGeminiTools(req.Tools)
+		model.ToolConfig = geminiToolConfig(req.ToolChoice)
 	}
 
 	// Convert messages to Gemini format
@@ -249,6 +254,10 @@ func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 			Role:    RoleAssistant,
 			Content: "",
 		}
+		if c.Content == nil {
+			// Candidates blocked by the safety filter have no content
+			c.Content = &genai.Content{}
+		}
 
 		// Handle function calls and text separately
 		var textParts []string
@@ -337,13 +346,19 @@ func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 			}
 		}
 
+		finishReason, filtered := convertFromGeminiFinishReason(c)
+		msg.Filtered = filtered
 		choices[i] = Choice{
 			Index:        i,
 			Message:      msg,
-			FinishReason: string(c.FinishReason),
+			FinishReason: finishReason,
 		}
 	}
 
+	if len(choices) == 0 {
+		choices = geminiBlockedPrompt(resp.PromptFeedback)
+	}
+
 	// Build response with usage metrics if available
 	response := ChatCompletionResponse{
 		Choices: choices,
@@ -359,6 +374,44 @@ func (g *GeminiLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 	return response, nil
 }
 
+// convertFromGeminiFinishReason maps safety and recitation stops to the content filter finish
+// reason, with the categories whose ratings blocked the candidate
+func convertFromGeminiFinishReason(c *genai.Candidate) (string, []string) {
+	switch c.FinishReason {
+	case genai.FinishReasonSafety:
+		return FinishReasonContentFilter, blockedCategories(c.SafetyRatings)
+	case genai.FinishReasonRecitation:
+		return FinishReasonContentFilter, []string{"recitation"}
+	}
+	return string(c.FinishReason), nil
+}
+
+// geminiBlockedPrompt returns a content filter choice if Gemini blocked the prompt itself
+func geminiBlockedPrompt(feedback *genai.PromptFeedback) []Choice {
+	if feedback == nil || feedback.BlockReason == genai.BlockReasonUnspecified {
+		return nil
+	}
+	filtered := blockedCategories(feedback.SafetyRatings)
+	if len(filtered) == 0 {
+		filtered = []string{feedback.BlockReason.String()}
+	}
+	return []Choice{{
+		Message:      Message{Role: RoleAssistant, Filtered: filtered},
+		FinishReason: FinishReasonContentFilter,
+	}}
+}
+
+// blockedCategories returns the harm categories of the ratings that blocked content
+func blockedCategories(ratings []*genai.SafetyRating) []string {
+	var categories []string
+	for _, rating := range ratings {
+		if rating.Blocked {
+			categories = append(categories, rating.Category.String())
+		}
+	}
+	return categories
+}
+
 // geminiStreamWrapper wraps Gemini's stream to implement our ChatCompletionStream interface
 type geminiStreamWrapper struct {
 	iter              *genai.GenerateContentResponseIterator
@@ -389,6 +442,9 @@ func (w *geminiStreamWrapper) Recv() (ChatCompletionResponse, error) {
 			Role:    RoleAssistant,
 			Content: "",
 		}
+		if c.Content == nil {
+			c.Content = &genai.Content{}
+		}
 
 		// Handle function calls and text separately
 		var textParts []string
@@ -435,10 +491,12 @@ func (w *geminiStreamWrapper) Recv() (ChatCompletionResponse, error) {
 		}
 		msg.Content = strings.Join(textParts, "")
 
+		finishReason, filtered := convertFromGeminiFinishReason(c)
+		msg.Filtered = filtered
 		choices[i] = Choice{
 			Index:        i,
 			Message:      msg,
-			FinishReason: string(c.FinishReason),
+			FinishReason: finishReason,
 		}
 	}
 
@@ -494,6 +552,10 @@ func (w *geminiStreamWrapper) Close() error {
 
 // CreateChatCompletionStream implements the LLM interface for Gemini streaming
 func (g *GeminiLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	if err := checkNoGrammar(req); err != nil {
+		return nil, err
+	}
+
 	// Create model and configure settings
 	model := g.client.GenerativeModel(req.Model)
 
@@ -506,6 +568,9 @@ func (g *GeminiLLM) CreateChatCompletionStream(ctx context.Context, req ChatComp
 	if req.MaxTokens > 0 {
 		model.SetMaxOutputTokens(int32(req.MaxTokens))
 	}
+	if len(req.Stop) > 0 {
+		model.StopSequences = req.Stop
+	}
 
 	// Check if we're in a function calling cycle
 	inFunctionCall := false
@@ -517,6 +582,7 @@ func (g *GeminiLLM) CreateChatCompletionStream(ctx context.Context, req ChatComp
 	// Only set tools if we're not in a function calling cycle
 	if len(req.Tools) > 0 && !inFunctionCall {
 		model.Tools = convertToGeminiTools(req.Tools)
+		model.ToolConfig = geminiToolConfig(req.ToolChoice)
 	}
 
 	// Convert messages to Gemini format
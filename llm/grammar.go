@@ -0,0 +1,147 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrGrammarNotSupported is returned when a request asks for grammar-constrained decoding
+// from a backend that cannot enforce it
+var ErrGrammarNotSupported = errors.New("grammar-constrained decoding is not supported by this backend")
+
+// Grammar constrains decoding so the model's output always matches it. Set one of the fields.
+type Grammar struct {
+	GBNF       string          // Grammar in llama.cpp's GBNF format
+	JSONSchema json.RawMessage // JSON schema the output must match
+}
+
+// GrammarDialect names the request fields an OpenAI-compatible server reads grammars from
+type GrammarDialect int
+
+const (
+	// GrammarOpenAI sends JSON schemas as structured outputs; GBNF grammars are not supported
+	GrammarOpenAI GrammarDialect = iota
+	// GrammarLlamaCpp sends grammars in the "grammar" and "json_schema" fields of llama.cpp's server
+	GrammarLlamaCpp
+	// GrammarVLLM sends grammars in the "guided_grammar" and "guided_json" fields of vLLM
+	GrammarVLLM
+)
+
+// WithGrammarDialect sets how grammars are sent to the server, for OpenAI-compatible servers
+// such as llama.cpp and vLLM that constrain decoding with their own request fields
+func (o *OpenAILLM) WithGrammarDialect(dialect GrammarDialect) *OpenAILLM {
+	o.dialect = dialect
+	return o
+}
+
+// applyGrammar sets the grammar of the request on the OpenAI request, or on the context for
+// fields the OpenAI client does not know about
+func (o *OpenAILLM) applyGrammar(ctx context.Context, grammar *Grammar, req *openai.ChatCompletionRequest) (context.Context, error) {
+	if grammar == nil {
+		return ctx, nil
+	}
+	switch o.dialect {
+	case GrammarLlamaCpp:
+		if grammar.GBNF != "" {
+			return withExtraFields(ctx, map[string]interface{}{"grammar": grammar.GBNF}), nil
+		}
+		return withExtraFields(ctx, map[string]interface{}{"json_schema": grammar.JSONSchema}), nil
+	case GrammarVLLM:
+		if grammar.GBNF != "" {
+			return withExtraFields(ctx, map[string]interface{}{"guided_grammar": grammar.GBNF}), nil
+		}
+		return withExtraFields(ctx, map[string]interface{}{"guided_json": grammar.JSONSchema}), nil
+	default:
+		if grammar.GBNF != "" {
+			return ctx, fmt.Errorf("%w: GBNF grammars need a llama.cpp or vLLM server", ErrGrammarNotSupported)
+		}
+		req.ResponseFormat = &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "output",
+				Schema: grammar.JSONSchema,
+				Strict: true,
+			},
+		}
+		return ctx, nil
+	}
+}
+
+type extraFieldsKey struct{}
+
+// withExtraFields returns a context whose requests have the fields added to their JSON body,
+// along with those already added by the context
+func withExtraFields(ctx context.Context, fields map[string]interface{}) context.Context {
+	if existing, ok := ctx.Value(extraFieldsKey{}).(map[string]interface{}); ok {
+		merged := make(map[string]interface{}, len(existing)+len(fields))
+		for key, value := range existing {
+			merged[key] = value
+		}
+		for key, value := range fields {
+			merged[key] = value
+		}
+		fields = merged
+	}
+	return context.WithValue(ctx, extraFieldsKey{}, fields)
+}
+
+// extraFieldsDoer adds the extra fields of a request's context to its JSON body
+type extraFieldsDoer struct {
+	doer openai.HTTPDoer
+}
+
+// Do sends the request with the extra fields added
+func (d extraFieldsDoer) Do(req *http.Request) (*http.Response, error) {
+	fields, ok := req.Context().Value(extraFieldsKey{}).(map[string]interface{})
+	if !ok || req.Body == nil {
+		return d.doer.Do(req)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, fmt.Errorf("failed to add fields to request: %w", err)
+	}
+	for key, value := range fields {
+		body[key] = value
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return nil, fmt.Errorf("failed to add fields to request: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	req.ContentLength = int64(len(data))
+	req.GetBody = func() (io.ReadCloser, error) { return io.NopCloser(bytes.NewReader(data)), nil }
+	return d.doer.Do(req)
+}
+
+// checkNoGrammar rejects requests with a grammar, for backends that cannot enforce one
+func checkNoGrammar(req ChatCompletionRequest) error {
+	if req.Grammar != nil {
+		return ErrGrammarNotSupported
+	}
+	return nil
+}
+
+// ollamaFormat returns the Ollama output format enforcing the request's grammar. Ollama
+// constrains output to JSON schemas only.
+func ollamaFormat(grammar *Grammar) (json.RawMessage, error) {
+	if grammar == nil {
+		return nil, nil
+	}
+	if grammar.GBNF != "" {
+		return nil, fmt.Errorf("%w: Ollama only supports JSON schemas", ErrGrammarNotSupported)
+	}
+	return grammar.JSONSchema, nil
+}
@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGrammarDialects(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = nil
+		json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"yes"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	req := ChatCompletionRequest{
+		Model:    "local",
+		Messages: []Message{{Role: RoleUser, Content: "Agree?"}},
+		Stop:     []string{"\n"},
+		Grammar:  &Grammar{GBNF: `root ::= "yes" | "no"`},
+	}
+
+	client := NewOpenAILLMWithHost("key", server.URL).WithGrammarDialect(GrammarVLLM)
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "yes", resp.Choices[0].Message.Content)
+	assert.Equal(t, `root ::= "yes" | "no"`, body["guided_grammar"])
+	assert.Equal(t, []interface{}{"\n"}, body["stop"])
+
+	client = NewOpenAILLMWithHost("key", server.URL).WithGrammarDialect(GrammarLlamaCpp)
+	_, err = client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, `root ::= "yes" | "no"`, body["grammar"])
+
+	_, err = NewOpenAILLMWithHost("key", server.URL).CreateChatCompletion(context.Background(), req)
+	assert.ErrorIs(t, err, ErrGrammarNotSupported)
+
+	req.Grammar = &Grammar{JSONSchema: json.RawMessage(`{"type":"object"}`)}
+	_, err = NewOpenAILLMWithHost("key", server.URL).CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "json_schema", body["response_format"].(map[string]interface{})["type"])
+}
@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// ErrImagesNotSupported is returned when generating images with a client that cannot
+var ErrImagesNotSupported = errors.New("image generation is not supported by this client")
+
+// ImageInput is an image a model looks at, for clients that accept vision input
+type ImageInput struct {
+	URL string `json:"url"` // HTTP(S) or data URL of the image
+}
+
+// ImageRequest describes the images to generate
+type ImageRequest struct {
+	Prompt  string
+	Model   string // Image model, e.g. dall-e-3 or an Imagen model on an OpenAI-compatible endpoint
+	Size    string // Size such as 1024x1024, the model's default if empty
+	Quality string // Quality such as standard or hd, the model's default if empty
+	N       int    // Number of images, 1 if not positive
+}
+
+// GeneratedImage is an image returned by an image model
+type GeneratedImage struct {
+	Data          []byte
+	MimeType      string
+	RevisedPrompt string // Prompt the model actually used, if it rewrote the requested one
+}
+
+// DataURL returns the image as a data URL, e.g. to show it to a vision model
+func (img GeneratedImage) DataURL() string {
+	return fmt.Sprintf("data:%s;base64,%s", img.MimeType, base64.StdEncoding.EncodeToString(img.Data))
+}
+
+// ImageGenerator is implemented by clients whose provider generates images
+type ImageGenerator interface {
+	GenerateImage(ctx context.Context, req ImageRequest) ([]GeneratedImage, error)
+}
+
+// GenerateImage generates images with the client, failing with ErrImagesNotSupported for
+// clients that do not implement ImageGenerator
+func GenerateImage(ctx context.Context, client LLM, req ImageRequest) ([]GeneratedImage, error) {
+	generator, ok := client.(ImageGenerator)
+	if !ok {
+		return nil, ErrImagesNotSupported
+	}
+	return generator.GenerateImage(ctx, req)
+}
+
+// GenerateImage implements ImageGenerator with the images endpoint of OpenAI and of
+// compatible servers, such as Gemini's for Imagen models
+func (o *OpenAILLM) GenerateImage(ctx context.Context, req ImageRequest) ([]GeneratedImage, error) {
+	resp, err := o.client.CreateImage(ctx, openai.ImageRequest{
+		Prompt:         req.Prompt,
+		Model:          req.Model,
+		N:              max(req.N, 1),
+		Size:           req.Size,
+		Quality:        req.Quality,
+		ResponseFormat: openai.CreateImageResponseFormatB64JSON,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate image: %w", err)
+	}
+	images := make([]GeneratedImage, 0, len(resp.Data))
+	for _, item := range resp.Data {
+		data, err := base64.StdEncoding.DecodeString(item.B64JSON)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode image: %w", err)
+		}
+		images = append(images, GeneratedImage{Data: data, MimeType: "image/png", RevisedPrompt: item.RevisedPrompt})
+	}
+	if len(images) == 0 {
+		return nil, errors.New("image model returned no images")
+	}
+	return images, nil
+}
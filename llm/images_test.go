@@ -0,0 +1,47 @@
+package llm
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIImages(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&sent)
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/images/generations" {
+			io.WriteString(w, `{"created":1,"data":[{"b64_json":"`+base64.StdEncoding.EncodeToString([]byte("png"))+`","revised_prompt":"A red fox at dawn"}]}`)
+			return
+		}
+		io.WriteString(w, `{"id":"1","choices":[{"message":{"role":"assistant","content":"A fox."},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAILLMWithHost("key", server.URL)
+	images, err := GenerateImage(context.Background(), client, ImageRequest{Prompt: "A fox", Model: "dall-e-3"})
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("png"), images[0].Data)
+	assert.Equal(t, "A red fox at dawn", images[0].RevisedPrompt)
+	assert.Equal(t, "b64_json", sent["response_format"])
+	assert.Equal(t, "data:image/png;base64,cG5n", images[0].DataURL())
+
+	_, err = client.CreateChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "gpt-4o",
+		Messages: []Message{{Role: RoleUser, Content: "What is this?", Images: []ImageInput{{URL: images[0].DataURL()}}}},
+	})
+	assert.NoError(t, err)
+	content := sent["messages"].([]interface{})[0].(map[string]interface{})["content"].([]interface{})
+	assert.Equal(t, "What is this?", content[0].(map[string]interface{})["text"])
+	assert.Equal(t, "data:image/png;base64,cG5n", content[1].(map[string]interface{})["image_url"].(map[string]interface{})["url"])
+
+	_, err = GenerateImage(context.Background(), NewCohereLLM("key"), ImageRequest{Prompt: "A fox"})
+	assert.ErrorIs(t, err, ErrImagesNotSupported)
+}
@@ -0,0 +1,42 @@
+package llm
+
+import (
+	"context"
+	"strings"
+)
+
+// ModelLister is implemented by clients that can list the models available to them, which
+// checks connectivity and credentials without spending tokens
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]string, error)
+}
+
+// ListModels returns the IDs of the models available with the client's API key
+func (o *OpenAILLM) ListModels(ctx context.Context) ([]string, error) {
+	list, err := o.client.ListModels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]string, len(list.Models))
+	for i, model := range list.Models {
+		models[i] = model.ID
+	}
+	return models, nil
+}
+
+// ListModels returns the names of the models pulled on the Ollama server. Both the full name
+// and, for models tagged "latest", the name without the tag are listed.
+func (o *OllamaLLM) ListModels(ctx context.Context) ([]string, error) {
+	list, err := o.client.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]string, 0, len(list.Models))
+	for _, model := range list.Models {
+		models = append(models, model.Name)
+		if name, ok := strings.CutSuffix(model.Name, ":latest"); ok {
+			models = append(models, name)
+		}
+	}
+	return models, nil
+}
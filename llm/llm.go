@@ -2,6 +2,7 @@ package llm
 
 import (
 	"context"
+	"encoding/json"
 )
 
 // Role represents the role of a message participant
@@ -27,14 +28,24 @@ const (
 	Claude          LLMProvider = "CLAUDE"
 	Ollama          LLMProvider = "OLLAMA"
 	DeepSeek        LLMProvider = "DEEPSEEK"
+	XAI             LLMProvider = "XAI"
+	Cohere          LLMProvider = "COHERE"
+	Vertex          LLMProvider = "VERTEX"
 )
 
 // Message represents a single message in a chat conversation
 type Message struct {
-	Role      Role       `json:"role"`
-	Content   string     `json:"content"`
-	Name      string     `json:"name,omitempty"`
-	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	Role        Role            `json:"role"`
+	Content     string          `json:"content"`
+	Name        string          `json:"name,omitempty"`
+	ToolCalls   []ToolCall      `json:"tool_calls,omitempty"`
+	ToolCallID  string          `json:"tool_call_id,omitempty"` // ID of the tool call a function message answers
+	Interrupted bool            `json:"interrupted,omitempty"`  // Set on assistant messages cut short by a cancelled stream, never sent to providers
+	Refusal     string          `json:"refusal,omitempty"`      // Explanation given by a model that declined to answer for policy reasons
+	Filtered    []string        `json:"filtered,omitempty"`     // Categories for which the provider's content filter blocked the message
+	Files       []FileRef       `json:"files,omitempty"`        // Uploaded files attached to the message, read by clients that implement FileStore
+	Images      []ImageInput    `json:"images,omitempty"`       // Images attached to a user message, for clients that accept vision input
+	Raw         json.RawMessage `json:"-"`                      // Raw provider response, set when the request had KeepRaw
 }
 
 // ChatCompletionRequest represents a generic request for chat completion
@@ -51,6 +62,18 @@ type ChatCompletionRequest struct {
 	User             string    `json:"user,omitempty"`
 	Tools            []Tool    `json:"tools,omitempty"`
 	Stream           bool      `json:"stream,omitempty"`
+	Grammar          *Grammar  `json:"grammar,omitempty"` // Constrains decoding, for backends that support it
+	KeepRaw          bool      `json:"-"`                 // Retain the raw provider response on each returned message, except with Gemini
+
+	// ToolChoice controls whether the model may, must or must not call tools, the provider's
+	// default if nil
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+	// ServiceTier is the processing tier the request is served at, for providers that offer
+	// tiers; the provider's default if empty
+	ServiceTier ServiceTier `json:"service_tier,omitempty"`
+	// HostedTools are tools the provider executes itself, sent by clients of APIs with hosted
+	// tools such as OpenAIResponsesLLM and ignored by the others
+	HostedTools []HostedTool `json:"hosted_tools,omitempty"`
 }
 
 // ChatCompletionResponse represents a generic response from chat completion
@@ -60,6 +83,10 @@ type ChatCompletionResponse struct {
 	Usage   Usage    `json:"usage"`
 }
 
+// FinishReasonContentFilter is the finish reason of choices blocked by the provider's content
+// filter; providers report their safety stops with it
+const FinishReasonContentFilter = "content_filter"
+
 // Choice represents a completion choice
 type Choice struct {
 	Index        int     `json:"index"`
@@ -72,6 +99,22 @@ type Usage struct {
 	PromptTokens     int `json:"prompt_tokens"`
 	CompletionTokens int `json:"completion_tokens"`
 	TotalTokens      int `json:"total_tokens"`
+	// Estimated prompt tokens of the messages shortened by prompt compression, before and
+	// after compressing them. Zero if the prompt was not compressed.
+	OriginalTokens   int `json:"original_tokens,omitempty"`
+	CompressedTokens int `json:"compressed_tokens,omitempty"`
+
+	// Cost of the request in US dollars as reported by a gateway, zero if not reported
+	Cost float64 `json:"cost,omitempty"`
+}
+
+// CompressionRatio returns the fraction of the compressed messages' tokens that prompt
+// compression kept, or 1 if nothing was compressed
+func (u Usage) CompressionRatio() float64 {
+	if u.OriginalTokens == 0 {
+		return 1
+	}
+	return float64(u.CompressedTokens) / float64(u.OriginalTokens)
 }
 
 // LLM defines the interface that all LLM providers must implement
@@ -80,7 +123,10 @@ type LLM interface {
 	CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error)
 }
 
-// ChatCompletionStream represents a streaming response
+// ChatCompletionStream represents a streaming response. Chunks may carry token usage, which
+// may arrive in a chunk without choices; the usage of the response is the sum over its chunks.
+// Tool calls are only returned once complete, with arguments that parse, so implementations
+// streaming fragmented calls buffer them with a ToolCallAssembler.
 type ChatCompletionStream interface {
 	Recv() (ChatCompletionResponse, error)
 	Close() error
@@ -116,6 +162,7 @@ type Function struct {
 
 // ToolCall represents a tool/function call from the LLM
 type ToolCall struct {
+	Index    *int             `json:"index,omitempty"` // Position of the call within a streamed response, if reported
 	ID       string           `json:"id"`
 	Type     string           `json:"type"`
 	Function ToolCallFunction `json:"function"`
@@ -125,3 +172,10 @@ type ToolCallFunction struct {
 	Name      string `json:"name"`
 	Arguments string `json:"arguments"`
 }
+
+// attachRaw stores the raw provider payload on every choice's message
+func attachRaw(choices []Choice, raw []byte) {
+	for i := range choices {
+		choices[i].Message.Raw = json.RawMessage(raw)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"net/url"
 
 	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
 )
 
 // OllamaLLM implements the LLM interface for Ollama
@@ -17,10 +18,7 @@ type OllamaLLM struct {
 
 // NewOllamaLLM creates a new Ollama LLM client
 func NewOllamaLLM() (*OllamaLLM, error) {
-	client, err := api.ClientFromEnvironment()
-	if err != nil {
-		return nil, fmt.Errorf("failed to create Ollama client: %w", err)
-	}
+	client := api.NewClient(envconfig.Host(), SharedHTTPClient())
 	return &OllamaLLM{client: client}, nil
 }
 
@@ -30,7 +28,7 @@ func NewOllamaLLMWithURL(baseURL string) (*OllamaLLM, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse URL: %w", err)
 	}
-	client := api.NewClient(parsedURL, nil)
+	client := api.NewClient(parsedURL, SharedHTTPClient())
 	return &OllamaLLM{client: client}, nil
 }
 
@@ -63,74 +61,74 @@ func convertToOllamaMessages(messages []Message) []api.Message {
 	return ollamaMessages
 }
 
+// ollamaToolCache reuses converted tool definitions across requests
+var ollamaToolCache toolCache[api.Tool]
+
 // convertToOllamaTools converts our generic Tool type to Ollama's tool type
 func convertToOllamaTools(tools []Tool) api.Tools {
-	if len(tools) == 0 {
-		return nil
+	return ollamaToolCache.convert(tools, convertToOllamaTool)
+}
+
+// convertToOllamaTool converts a single tool
+func convertToOllamaTool(tool Tool) api.Tool {
+	// Convert required array
+	required := make([]string, len(tool.Function.Parameters["required"].([]interface{})))
+	for i, v := range tool.Function.Parameters["required"].([]interface{}) {
+		required[i] = v.(string)
 	}
 
-	ollamaTools := make([]api.Tool, len(tools))
-	for i, tool := range tools {
-		// Convert required array
-		required := make([]string, len(tool.Function.Parameters["required"].([]interface{})))
-		for i, v := range tool.Function.Parameters["required"].([]interface{}) {
-			required[i] = v.(string)
-		}
+	// Convert properties map
+	rawProps := tool.Function.Parameters["properties"].(map[string]interface{})
+	properties := make(map[string]struct {
+		Type        string   `json:"type"`
+		Description string   `json:"description"`
+		Enum        []string `json:"enum,omitempty"`
+	})
 
-		// Convert properties map
-		rawProps := tool.Function.Parameters["properties"].(map[string]interface{})
-		properties := make(map[string]struct {
+	for propName, propValue := range rawProps {
+		propMap := propValue.(map[string]interface{})
+		prop := struct {
 			Type        string   `json:"type"`
 			Description string   `json:"description"`
 			Enum        []string `json:"enum,omitempty"`
-		})
-
-		for propName, propValue := range rawProps {
-			propMap := propValue.(map[string]interface{})
-			prop := struct {
-				Type        string   `json:"type"`
-				Description string   `json:"description"`
-				Enum        []string `json:"enum,omitempty"`
-			}{
-				Type:        propMap["type"].(string),
-				Description: propMap["description"].(string),
-			}
+		}{
+			Type:        propMap["type"].(string),
+			Description: propMap["description"].(string),
+		}
 
-			// Handle optional enum field
-			if enumVal, ok := propMap["enum"]; ok {
-				enumInterface := enumVal.([]interface{})
-				enumStrings := make([]string, len(enumInterface))
-				for j, e := range enumInterface {
-					enumStrings[j] = e.(string)
-				}
-				prop.Enum = enumStrings
+		// Handle optional enum field
+		if enumVal, ok := propMap["enum"]; ok {
+			enumInterface := enumVal.([]interface{})
+			enumStrings := make([]string, len(enumInterface))
+			for j, e := range enumInterface {
+				enumStrings[j] = e.(string)
 			}
-
-			properties[propName] = prop
+			prop.Enum = enumStrings
 		}
 
-		ollamaTools[i] = api.Tool{
-			Type: "function",
-			Function: api.ToolFunction{
-				Name:        tool.Function.Name,
-				Description: tool.Function.Description,
-				Parameters: struct {
-					Type       string   `json:"type"`
-					Required   []string `json:"required"`
-					Properties map[string]struct {
-						Type        string   `json:"type"`
-						Description string   `json:"description"`
-						Enum        []string `json:"enum,omitempty"`
-					} `json:"properties"`
-				}{
-					Type:       tool.Function.Parameters["type"].(string),
-					Required:   required,
-					Properties: properties,
-				},
+		properties[propName] = prop
+	}
+
+	return api.Tool{
+		Type: "function",
+		Function: api.ToolFunction{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters: struct {
+				Type       string   `json:"type"`
+				Required   []string `json:"required"`
+				Properties map[string]struct {
+					Type        string   `json:"type"`
+					Description string   `json:"description"`
+					Enum        []string `json:"enum,omitempty"`
+				} `json:"properties"`
+			}{
+				Type:       tool.Function.Parameters["type"].(string),
+				Required:   required,
+				Properties: properties,
 			},
-		}
+		},
 	}
-	return ollamaTools
 }
 
 // convertToOllamaToolCalls converts our generic ToolCall type to Ollama's type
@@ -183,19 +181,31 @@ func convertFromOllamaToolCalls(toolCalls []api.ToolCall) []ToolCall {
 
 // CreateChatCompletion implements the LLM interface for Ollama
 func (o *OllamaLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	format, err := ollamaFormat(req.Grammar)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
 	stream := false
 	ollamaReq := &api.ChatRequest{
 		Model:    req.Model,
 		Messages: convertToOllamaMessages(req.Messages),
 		Stream:   &stream,
-		Tools:    convertToOllamaTools(req.Tools),
+		Format:   format,
+		Tools:    convertToOllamaTools(chosenTools(req.Tools, req.ToolChoice)),
 		Options:  make(map[string]interface{}),
 	}
+	if len(req.Stop) > 0 {
+		ollamaReq.Options["stop"] = req.Stop
+	}
 
 	var response ChatCompletionResponse
 	var finalMessage Message
+	var raw *RawCapture
+	if req.KeepRaw {
+		ctx, raw = CaptureRaw(ctx)
+	}
 
-	err := o.client.Chat(ctx, ollamaReq, func(resp api.ChatResponse) error {
+	err = o.client.Chat(ctx, ollamaReq, func(resp api.ChatResponse) error {
 		if resp.Done {
 			finalMessage = Message{
 				Role:      convertFromOllamaRole(resp.Message.Role),
@@ -209,6 +219,7 @@ func (o *OllamaLLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 	if err != nil {
 		return ChatCompletionResponse{}, fmt.Errorf("Ollama chat completion failed: %w", err)
 	}
+	finalMessage.Raw = raw.JSON()
 
 	response.Choices = []Choice{
 		{
@@ -285,14 +296,22 @@ func (s *ollamaStreamWrapper) Close() error {
 
 // CreateChatCompletionStream implements the LLM interface for Ollama streaming
 func (o *OllamaLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	format, err := ollamaFormat(req.Grammar)
+	if err != nil {
+		return nil, err
+	}
 	stream := true
 	ollamaReq := &api.ChatRequest{
 		Model:    req.Model,
 		Messages: convertToOllamaMessages(req.Messages),
 		Stream:   &stream,
-		Tools:    convertToOllamaTools(req.Tools),
+		Format:   format,
+		Tools:    convertToOllamaTools(chosenTools(req.Tools, req.ToolChoice)),
 		Options:  make(map[string]interface{}),
 	}
+	if len(req.Stop) > 0 {
+		ollamaReq.Options["stop"] = req.Stop
+	}
 
 	return newOllamaStreamWrapper(ctx, o.client, ollamaReq), nil
 }
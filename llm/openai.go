@@ -2,7 +2,6 @@ package llm
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,18 +11,24 @@ import (
 
 // OpenAILLM implements the LLM interface for OpenAI
 type OpenAILLM struct {
-	client *openai.Client
+	client  *openai.Client
+	dialect GrammarDialect // How grammars are sent to the server
+	compat  *CompatProfile // Workarounds for an OpenAI-compatible server, if set
+	gateway *Gateway       // Gateway requests are sent through, if set
 }
 
 // NewOpenAILLM creates a new OpenAI LLM client
 func NewOpenAILLM(apiKey string) *OpenAILLM {
-	client := openai.NewClient(apiKey)
+	config := openai.DefaultConfig(apiKey)
+	config.HTTPClient = extraFieldsDoer{doer: SharedHTTPClient()}
+	client := openai.NewClientWithConfig(config)
 	return &OpenAILLM{client: client}
 }
 
 func NewOpenAILLMWithHost(apiKey string, host string) *OpenAILLM {
 	config := openai.DefaultConfig(apiKey)
 	config.BaseURL = host
+	config.HTTPClient = extraFieldsDoer{doer: SharedHTTPClient()}
 	openAIClient := openai.NewClientWithConfig(config)
 	return &OpenAILLM{client: openAIClient}
 }
@@ -37,6 +42,33 @@ func convertToOpenAIMessages(messages []Message) []openai.ChatCompletionMessage
 			Content: msg.Content,
 			Name:    msg.Name,
 		}
+		for _, call := range msg.ToolCalls {
+			openAIMessages[i].ToolCalls = append(openAIMessages[i].ToolCalls, openai.ToolCall{
+				ID:   call.ID,
+				Type: openai.ToolTypeFunction,
+				Function: openai.FunctionCall{
+					Name:      call.Function.Name,
+					Arguments: call.Function.Arguments,
+				},
+			})
+		}
+		if msg.Role == RoleTool || (msg.Role == RoleFunction && msg.ToolCallID != "") {
+			// The answer to a tool call must be a tool message carrying its ID; function-role
+			// messages without an ID are sent as they are
+			openAIMessages[i].Role = string(RoleTool)
+			openAIMessages[i].ToolCallID = msg.ToolCallID
+		}
+		if len(msg.Images) > 0 && msg.Role == RoleUser {
+			// Text and images are sent as content parts
+			openAIMessages[i].Content = ""
+			openAIMessages[i].MultiContent = []openai.ChatMessagePart{{Type: openai.ChatMessagePartTypeText, Text: msg.Content}}
+			for _, image := range msg.Images {
+				openAIMessages[i].MultiContent = append(openAIMessages[i].MultiContent, openai.ChatMessagePart{
+					Type:     openai.ChatMessagePartTypeImageURL,
+					ImageURL: &openai.ChatMessageImageURL{URL: image.URL},
+				})
+			}
+		}
 	}
 	return openAIMessages
 }
@@ -47,6 +79,7 @@ func convertFromOpenAIMessage(msg openai.ChatCompletionMessage) Message {
 		Role:    Role(msg.Role),
 		Content: msg.Content,
 		Name:    msg.Name,
+		Refusal: msg.Refusal,
 	}
 }
 
@@ -58,25 +91,25 @@ func convertFromOpenAIDelta(delta openai.ChatCompletionStreamChoiceDelta) Messag
 	}
 }
 
+// openAIToolCache reuses converted tool definitions across requests
+var openAIToolCache toolCache[openai.Tool]
+
 // convertToOpenAITools converts our generic Tool type to OpenAI's tool type
 func convertToOpenAITools(tools []Tool) []openai.Tool {
-	if len(tools) == 0 {
-		return nil
-	}
+	return openAIToolCache.convert(tools, convertToOpenAITool)
+}
 
-	openAITools := make([]openai.Tool, len(tools))
-	for i, tool := range tools {
-		def := openai.FunctionDefinition{
-			Name:        tool.Function.Name,
-			Description: tool.Function.Description,
-			Parameters:  tool.Function.Parameters,
-		}
-		openAITools[i] = openai.Tool{
-			Type:     openai.ToolTypeFunction,
-			Function: &def,
-		}
+// convertToOpenAITool converts a single tool
+func convertToOpenAITool(tool Tool) openai.Tool {
+	def := openai.FunctionDefinition{
+		Name:        tool.Function.Name,
+		Description: tool.Function.Description,
+		Parameters:  tool.Function.Parameters,
+	}
+	return openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &def,
 	}
-	return openAITools
 }
 
 // convertFromOpenAIToolCalls converts OpenAI's tool calls to our generic type
@@ -108,7 +141,24 @@ func (o *OpenAILLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 		Stop:            req.Stop,
 		MaxTokens:       req.MaxTokens,
 		PresencePenalty: req.PresencePenalty,
+		User:            req.User,
 		Tools:           convertToOpenAITools(req.Tools),
+		ToolChoice:      openAIToolChoice(req.ToolChoice),
+	}
+	ctx, err := o.applyGrammar(ctx, req.Grammar, &openAIReq)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	ctx = withServiceTier(ctx, req.ServiceTier)
+	if o.compat != nil && o.compat.SingleChoice {
+		openAIReq.N = 0
+	}
+	if o.gateway != nil {
+		ctx = o.gateway.apply(ctx)
+	}
+	var raw *RawCapture
+	if req.KeepRaw {
+		ctx, raw = CaptureRaw(ctx)
 	}
 
 	resp, err := o.client.CreateChatCompletion(ctx, openAIReq)
@@ -125,34 +175,51 @@ func (o *OpenAILLM) CreateChatCompletion(ctx context.Context, req ChatCompletion
 			Message:      msg,
 			FinishReason: string(c.FinishReason),
 		}
+		if o.compat != nil {
+			o.compat.normalize(&choices[i], len(req.Tools) > 0)
+		}
+	}
+
+	if raw != nil {
+		attachRaw(choices, raw.JSON())
+	}
+
+	usage := Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	if o.gateway != nil {
+		usage.Cost = o.gateway.cost(resp.Header())
 	}
 
 	return ChatCompletionResponse{
 		ID:      resp.ID,
 		Choices: choices,
-		Usage: Usage{
-			PromptTokens:     resp.Usage.PromptTokens,
-			CompletionTokens: resp.Usage.CompletionTokens,
-			TotalTokens:      resp.Usage.TotalTokens,
-		},
+		Usage:   usage,
 	}, nil
 }
 
 // openAIStreamWrapper wraps the OpenAI stream
 type openAIStreamWrapper struct {
-	stream          *openai.ChatCompletionStream
-	currentToolCall *ToolCall
-	toolCallBuffer  map[string]*ToolCall
+	stream    *openai.ChatCompletionStream
+	assembler *ToolCallAssembler
+	compat    *CompatProfile
+	err       error // Returned after the chunk that completed the stream
 }
 
-func newOpenAIStreamWrapper(stream *openai.ChatCompletionStream) *openAIStreamWrapper {
+func newOpenAIStreamWrapper(stream *openai.ChatCompletionStream, compat *CompatProfile) *openAIStreamWrapper {
 	return &openAIStreamWrapper{
-		stream:         stream,
-		toolCallBuffer: make(map[string]*ToolCall),
+		stream:    stream,
+		assembler: NewToolCallAssembler(),
+		compat:    compat,
 	}
 }
 
 func (w *openAIStreamWrapper) Recv() (ChatCompletionResponse, error) {
+	if w.err != nil {
+		return ChatCompletionResponse{}, w.err
+	}
 	resp, err := w.stream.Recv()
 	if err != nil {
 		if err == io.EOF {
@@ -172,61 +239,26 @@ func (w *openAIStreamWrapper) Recv() (ChatCompletionResponse, error) {
 			Content: c.Delta.Content,
 		}
 
-		// Handle tool calls in delta
-		if len(c.Delta.ToolCalls) > 0 {
-			message.ToolCalls = make([]ToolCall, 0)
-			for _, tc := range c.Delta.ToolCalls {
-				// Get or create tool call buffer
-				toolCall, exists := w.toolCallBuffer[tc.ID]
-				if !exists {
-					if tc.ID == "" {
-						// Skip empty IDs but accumulate arguments if present
-						if tc.Function.Arguments != "" && w.currentToolCall != nil {
-							w.currentToolCall.Function.Arguments += tc.Function.Arguments
-
-							// Try to parse the arguments to verify if it's complete JSON
-							var args map[string]interface{}
-							if err := json.Unmarshal([]byte(w.currentToolCall.Function.Arguments), &args); err == nil {
-								// Add to message's tool calls and remove from buffer
-								message.ToolCalls = append(message.ToolCalls, *w.currentToolCall)
-								delete(w.toolCallBuffer, w.currentToolCall.ID)
-								w.currentToolCall = nil
-							}
-						}
-						continue
-					}
-					toolCall = &ToolCall{
-						ID:   tc.ID,
-						Type: string(tc.Type),
-						Function: ToolCallFunction{
-							Name:      tc.Function.Name,
-							Arguments: "",
-						},
-					}
-					w.toolCallBuffer[tc.ID] = toolCall
-					w.currentToolCall = toolCall
-				}
-
-				// Update function name if provided
-				if tc.Function.Name != "" {
-					toolCall.Function.Name = tc.Function.Name
-				}
-
-				// Update arguments if provided
-				if tc.Function.Arguments != "" {
-					// Always append new arguments
-					toolCall.Function.Arguments += tc.Function.Arguments
+		// Buffer tool call fragments; they are only emitted once their arguments are complete
+		for _, tc := range c.Delta.ToolCalls {
+			w.assembler.Add(ToolCall{
+				Index: tc.Index,
+				ID:    tc.ID,
+				Type:  string(tc.Type),
+				Function: ToolCallFunction{
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				},
+			})
+		}
+		message.ToolCalls = w.assembler.Ready()
 
-					// Try to parse the arguments to verify if it's complete JSON
-					var args map[string]interface{}
-					if err := json.Unmarshal([]byte(toolCall.Function.Arguments), &args); err == nil {
-						// Add to message's tool calls and remove from buffer
-						message.ToolCalls = append(message.ToolCalls, *toolCall)
-						delete(w.toolCallBuffer, tc.ID)
-						w.currentToolCall = nil
-					}
-				}
-			}
+		if c.FinishReason != "" {
+			// The chunk still carries the finish reason, so incomplete calls are reported by
+			// the next call
+			remaining, err := w.assembler.Flush()
+			w.err = err
+			message.ToolCalls = append(message.ToolCalls, remaining...)
 		}
 
 		choices[i] = Choice{
@@ -234,12 +266,28 @@ func (w *openAIStreamWrapper) Recv() (ChatCompletionResponse, error) {
 			Message:      message,
 			FinishReason: string(c.FinishReason),
 		}
+		if w.compat != nil {
+			// Content arrives in fragments, so tool calls written in it cannot be recovered
+			w.compat.fixToolCalls(message.ToolCalls)
+			if reason, ok := finishReasons[choices[i].FinishReason]; ok {
+				choices[i].FinishReason = reason
+			}
+		}
 	}
 
-	return ChatCompletionResponse{
+	response := ChatCompletionResponse{
 		ID:      resp.ID,
 		Choices: choices,
-	}, nil
+	}
+	// Usage is sent in a final chunk without choices
+	if resp.Usage != nil {
+		response.Usage = Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		}
+	}
+	return response, nil
 }
 
 func (w *openAIStreamWrapper) Close() error {
@@ -257,18 +305,38 @@ func (o *OpenAILLM) CreateChatCompletionStream(ctx context.Context, req ChatComp
 		Stop:            req.Stop,
 		MaxTokens:       req.MaxTokens,
 		PresencePenalty: float32(req.PresencePenalty),
+		User:            req.User,
 		Tools:           convertToOpenAITools(req.Tools),
+		ToolChoice:      openAIToolChoice(req.ToolChoice),
 		Stream:          true,
+		StreamOptions:   &openai.StreamOptions{IncludeUsage: true},
+	}
+	ctx, err := o.applyGrammar(ctx, req.Grammar, &openAIReq)
+	if err != nil {
+		return nil, err
+	}
+	ctx = withServiceTier(ctx, req.ServiceTier)
+	if o.compat != nil && o.compat.SingleChoice {
+		openAIReq.N = 0
+	}
+	if o.compat != nil && o.compat.NoStreamUsage {
+		openAIReq.StreamOptions = nil
+	}
+	if o.gateway != nil {
+		ctx = o.gateway.apply(ctx)
 	}
 
 	stream, err := o.client.CreateChatCompletionStream(ctx, openAIReq)
 	if err != nil {
 		var openAIErr *openai.APIError
 		if errors.As(err, &openAIErr) {
-			return nil, fmt.Errorf("OpenAI API error: %s - %s", openAIErr.Code, openAIErr.Message)
+			return nil, &statusError{
+				status: openAIErr.HTTPStatusCode,
+				err:    fmt.Errorf("OpenAI API error: %s - %s", openAIErr.Code, openAIErr.Message),
+			}
 		}
 		return nil, fmt.Errorf("stream creation failed: %w", err)
 	}
 
-	return newOpenAIStreamWrapper(stream), nil
+	return newOpenAIStreamWrapper(stream, o.compat), nil
 }
@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// RawCapture records the body of a provider response as it is read, so KeepRaw can keep the
+// fields the SDKs do not model. Only clients sending requests through SharedTransport are
+// captured.
+type RawCapture struct {
+	mu   sync.Mutex
+	body bytes.Buffer
+}
+
+type rawCaptureKey struct{}
+
+// CaptureRaw returns a context whose provider response body is recorded by the returned
+// capture. If the request is retried, only the last response is kept.
+func CaptureRaw(ctx context.Context) (context.Context, *RawCapture) {
+	capture := &RawCapture{}
+	return context.WithValue(ctx, rawCaptureKey{}, capture), capture
+}
+
+// JSON returns the captured response. A JSON body is returned as is; a stream of server-sent
+// events or JSON lines is returned as an array of its JSON chunks. It returns nil if nothing
+// was captured.
+func (c *RawCapture) JSON() json.RawMessage {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	body := bytes.TrimSpace(c.body.Bytes())
+	if len(body) == 0 {
+		return nil
+	}
+	if json.Valid(body) {
+		return json.RawMessage(append([]byte(nil), body...))
+	}
+
+	var chunks []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "data:"))
+		if line != "" && json.Valid([]byte(line)) {
+			chunks = append(chunks, json.RawMessage(line))
+		}
+	}
+	raw, err := json.Marshal(chunks)
+	if err != nil || len(chunks) == 0 {
+		return nil
+	}
+	return raw
+}
+
+// captureResponse tees the response body into the capture of the request's context, if any
+func captureResponse(req *http.Request, resp *http.Response) {
+	capture, ok := req.Context().Value(rawCaptureKey{}).(*RawCapture)
+	if !ok || resp == nil || resp.Body == nil {
+		return
+	}
+	capture.mu.Lock()
+	capture.body.Reset()
+	capture.mu.Unlock()
+	resp.Body = &captureBody{ReadCloser: resp.Body, capture: capture}
+}
+
+// captureBody is a response body recording what is read from it
+type captureBody struct {
+	io.ReadCloser
+	capture *RawCapture
+}
+
+// Read reads from the body and records the bytes read
+func (b *captureBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.capture.mu.Lock()
+		b.capture.body.Write(p[:n])
+		b.capture.mu.Unlock()
+	}
+	return n, err
+}
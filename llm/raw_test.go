@@ -0,0 +1,37 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeepRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi","reasoning_content":"Greet back"},"finish_reason":"stop"}],"x_provider_trace":"abc"}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAILLMWithHost("key", server.URL)
+	req := ChatCompletionRequest{Model: "gpt-4o", Messages: []Message{{Role: RoleUser, Content: "Hi"}}, KeepRaw: true}
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	// Fields go-openai does not model survive
+	assert.Contains(t, string(resp.Choices[0].Message.Raw), `"reasoning_content":"Greet back"`)
+	assert.Contains(t, string(resp.Choices[0].Message.Raw), `"x_provider_trace":"abc"`)
+
+	req.KeepRaw = false
+	resp, err = client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Nil(t, resp.Choices[0].Message.Raw)
+
+	// Streams are kept as the array of their chunks
+	_, capture := CaptureRaw(context.Background())
+	capture.body.WriteString("data: {\"id\":\"1\",\"x\":1}\n\ndata: {\"id\":\"1\",\"x\":2}\n\ndata: [DONE]\n\n")
+	assert.JSONEq(t, `[{"id":"1","x":1},{"id":"1","x":2}]`, string(capture.JSON()))
+}
@@ -0,0 +1,91 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const openAIRealtimeURL = "wss://api.openai.com/v1/realtime"
+
+// RealtimeEvent represents a single client or server event of the OpenAI Realtime API
+type RealtimeEvent struct {
+	Type    string          `json:"type"`
+	EventID string          `json:"event_id,omitempty"`
+	Raw     json.RawMessage `json:"-"` // Full JSON payload of a received event
+}
+
+// Decode unmarshals the full payload of a received event into v
+func (e RealtimeEvent) Decode(v interface{}) error {
+	return json.Unmarshal(e.Raw, v)
+}
+
+// RealtimeConn is a WebSocket connection to the OpenAI Realtime API
+type RealtimeConn struct {
+	conn  *websocket.Conn
+	mutex sync.Mutex
+}
+
+// DialOpenAIRealtime opens a Realtime API connection for the given model
+func DialOpenAIRealtime(ctx context.Context, apiKey, model string) (*RealtimeConn, error) {
+	return DialOpenAIRealtimeWithURL(ctx, apiKey, model, openAIRealtimeURL)
+}
+
+// DialOpenAIRealtimeWithURL opens a Realtime API connection against a custom endpoint
+func DialOpenAIRealtimeWithURL(ctx context.Context, apiKey, model, endpoint string) (*RealtimeConn, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	query := u.Query()
+	query.Set("model", model)
+	u.RawQuery = query.Encode()
+
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+apiKey)
+	header.Set("OpenAI-Beta", "realtime=v1")
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("realtime dial failed with status %d: %w", resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("realtime dial failed: %w", err)
+	}
+	return &RealtimeConn{conn: conn}, nil
+}
+
+// Send writes a client event. The event is marshaled as-is and must include a "type" field.
+func (c *RealtimeConn) Send(event interface{}) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// Recv blocks until the next server event arrives
+func (c *RealtimeConn) Recv() (RealtimeEvent, error) {
+	_, data, err := c.conn.ReadMessage()
+	if err != nil {
+		return RealtimeEvent{}, err
+	}
+
+	var event RealtimeEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		return RealtimeEvent{}, fmt.Errorf("failed to unmarshal realtime event: %w", err)
+	}
+	event.Raw = data
+	return event, nil
+}
+
+// Close closes the connection
+func (c *RealtimeConn) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	return c.conn.Close()
+}
@@ -0,0 +1,512 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const openAIResponsesEndpoint = "https://api.openai.com/v1/responses"
+
+// maxStoredResponses bounds the conversations an OpenAIResponsesLLM keeps response IDs for
+const maxStoredResponses = 1024
+
+// Hosted tool types of the Responses API
+const (
+	HostedToolWebSearch       = "web_search_preview"
+	HostedToolFileSearch      = "file_search"
+	HostedToolCodeInterpreter = "code_interpreter"
+)
+
+// HostedTool is a tool executed by the provider instead of by the swarm, such as web search
+// over the internet or file search over the provider's vector stores. Hosted tools are only
+// sent by OpenAIResponsesLLM; other clients ignore them.
+type HostedTool struct {
+	Type           string   `json:"type"`
+	VectorStoreIDs []string `json:"vector_store_ids,omitempty"` // Vector stores searched by file search
+	MaxResults     int      `json:"max_num_results,omitempty"`  // Results returned by file search, the provider's default if zero
+	Container      any      `json:"container,omitempty"`        // Container of the code interpreter
+}
+
+// WebSearch returns the hosted web search tool
+func WebSearch() HostedTool {
+	return HostedTool{Type: HostedToolWebSearch}
+}
+
+// FileSearch returns the hosted file search tool searching the vector stores
+func FileSearch(vectorStoreIDs ...string) HostedTool {
+	return HostedTool{Type: HostedToolFileSearch, VectorStoreIDs: vectorStoreIDs}
+}
+
+// CodeInterpreter returns the hosted code interpreter running in an automatically created container
+func CodeInterpreter() HostedTool {
+	return HostedTool{Type: HostedToolCodeInterpreter, Container: map[string]string{"type": "auto"}}
+}
+
+// OpenAIResponsesLLM implements the LLM interface on OpenAI's Responses API, which runs hosted
+// tools and can keep conversation state on the server. With server state enabled, requests
+// continuing a conversation answered by this client send only the new messages along with the
+// ID of the previous response; requests whose history changed are sent in full.
+type OpenAIResponsesLLM struct {
+	apiKey      string
+	endpoint    string
+	client      *http.Client
+	serverState bool
+
+	mu        sync.Mutex
+	responses map[string]string // Response IDs by hash of the conversation they ended
+}
+
+// NewOpenAIResponsesLLM creates a new client for OpenAI's Responses API
+func NewOpenAIResponsesLLM(apiKey string) *OpenAIResponsesLLM {
+	return &OpenAIResponsesLLM{
+		apiKey:   apiKey,
+		endpoint: openAIResponsesEndpoint,
+		client:   SharedHTTPClient(),
+	}
+}
+
+// WithServerState makes the provider store responses so later requests of the same
+// conversation only send their new messages
+func (c *OpenAIResponsesLLM) WithServerState() *OpenAIResponsesLLM {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.serverState = true
+	c.responses = make(map[string]string)
+	return c
+}
+
+type responsesRequest struct {
+	Model              string            `json:"model"`
+	Input              []json.RawMessage `json:"input"`
+	Tools              []any             `json:"tools,omitempty"`
+	ToolChoice         any               `json:"tool_choice,omitempty"`
+	Temperature        float32           `json:"temperature,omitempty"`
+	TopP               float32           `json:"top_p,omitempty"`
+	MaxOutputTokens    int               `json:"max_output_tokens,omitempty"`
+	User               string            `json:"user,omitempty"`
+	ServiceTier        ServiceTier       `json:"service_tier,omitempty"`
+	Text               any               `json:"text,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Store              bool              `json:"store"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+}
+
+// responsesFunctionTool is a function tool in the Responses API's flat format
+type responsesFunctionTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters"`
+}
+
+// responsesItem is an input or output item of a response
+type responsesItem struct {
+	Type      string                 `json:"type"`
+	ID        string                 `json:"id,omitempty"`
+	Role      string                 `json:"role,omitempty"`
+	Content   []responsesContentPart `json:"content,omitempty"`
+	CallID    string                 `json:"call_id,omitempty"`
+	Name      string                 `json:"name,omitempty"`
+	Arguments string                 `json:"arguments,omitempty"`
+}
+
+// responsesContentPart is a part of an output message
+type responsesContentPart struct {
+	Type    string `json:"type"`
+	Text    string `json:"text,omitempty"`
+	Refusal string `json:"refusal,omitempty"`
+}
+
+type responsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+type responsesResponse struct {
+	ID                string          `json:"id"`
+	Status            string          `json:"status"`
+	Output            []responsesItem `json:"output"`
+	Usage             responsesUsage  `json:"usage"`
+	IncompleteDetails *struct {
+		Reason string `json:"reason"`
+	} `json:"incomplete_details"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// convertToResponsesInput converts messages to input items. Tool calls and their results
+// become function call items, matched by call ID.
+func convertToResponsesInput(messages []Message) ([]json.RawMessage, error) {
+	var items []any
+	for _, msg := range messages {
+		switch msg.Role {
+		case RoleFunction, RoleTool:
+			if msg.ToolCallID == "" {
+				return nil, fmt.Errorf("%w: tool result for %s has no tool call ID", ErrInvalidToolHistory, msg.Name)
+			}
+			items = append(items, map[string]string{"type": "function_call_output", "call_id": msg.ToolCallID, "output": msg.Content})
+		case RoleAssistant:
+			if msg.Content != "" {
+				items = append(items, map[string]string{"role": string(RoleAssistant), "content": msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				items = append(items, responsesItem{Type: "function_call", CallID: call.ID, Name: call.Function.Name, Arguments: call.Function.Arguments})
+			}
+		default:
+			if len(msg.Files) == 0 && len(msg.Images) == 0 {
+				items = append(items, map[string]string{"role": string(msg.Role), "content": msg.Content})
+				continue
+			}
+			parts := []map[string]string{{"type": "input_text", "text": msg.Content}}
+			for _, file := range msg.Files {
+				parts = append(parts, map[string]string{"type": "input_file", "file_id": file.ID})
+			}
+			for _, image := range msg.Images {
+				parts = append(parts, map[string]string{"type": "input_image", "image_url": image.URL})
+			}
+			items = append(items, map[string]any{"role": string(msg.Role), "content": parts})
+		}
+	}
+
+	input := make([]json.RawMessage, len(items))
+	for i, item := range items {
+		data, err := json.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+		input[i] = data
+	}
+	return input, nil
+}
+
+// convertFromResponsesOutput converts the output items of a response to a message and its
+// finish reason. Items of hosted tool calls are not part of the message.
+func convertFromResponsesOutput(resp responsesResponse) (Message, string) {
+	msg := Message{Role: RoleAssistant}
+	var text strings.Builder
+	for _, item := range resp.Output {
+		switch item.Type {
+		case "message":
+			for _, part := range item.Content {
+				switch part.Type {
+				case "output_text":
+					text.WriteString(part.Text)
+				case "refusal":
+					msg.Refusal += part.Refusal
+				}
+			}
+		case "function_call":
+			msg.ToolCalls = append(msg.ToolCalls, ToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: ToolCallFunction{Name: item.Name, Arguments: item.Arguments},
+			})
+		}
+	}
+	msg.Content = text.String()
+	return msg, responsesFinishReason(resp, len(msg.ToolCalls) > 0)
+}
+
+// responsesFinishReason maps the status of a response to OpenAI's chat finish reasons
+func responsesFinishReason(resp responsesResponse, toolCalls bool) string {
+	if resp.Status == "incomplete" && resp.IncompleteDetails != nil {
+		switch resp.IncompleteDetails.Reason {
+		case "max_output_tokens":
+			return "length"
+		case "content_filter":
+			return FinishReasonContentFilter
+		default:
+			return resp.IncompleteDetails.Reason
+		}
+	}
+	if toolCalls {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// responsesToolChoice converts a tool choice to the Responses API's tool_choice, nil for the default
+func responsesToolChoice(choice *ToolChoice) any {
+	switch {
+	case choice == nil:
+		return nil
+	case choice.Function != "":
+		return map[string]string{"type": "function", "name": choice.Function}
+	case choice.Mode == "":
+		return nil
+	default:
+		return string(choice.Mode)
+	}
+}
+
+// conversationHashes returns the hash of each prefix of the messages ending with an assistant
+// message, by the number of messages in the prefix
+func conversationHashes(messages []Message) map[int]string {
+	hashes := make(map[int]string)
+	h := sha256.New()
+	for i, msg := range messages {
+		data, _ := json.Marshal(Message{Role: msg.Role, Content: msg.Content, ToolCalls: msg.ToolCalls, ToolCallID: msg.ToolCallID, Files: msg.Files, Images: msg.Images})
+		h.Write(data)
+		if msg.Role == RoleAssistant {
+			hashes[i+1] = hex.EncodeToString(h.Sum(nil))
+		}
+	}
+	return hashes
+}
+
+// previousResponse returns the stored response that the messages continue, and the number of
+// messages it covers
+func (c *OpenAIResponsesLLM) previousResponse(messages []Message) (string, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.serverState {
+		return "", 0
+	}
+	hashes := conversationHashes(messages)
+	for n := len(messages); n > 0; n-- {
+		if hash, ok := hashes[n]; ok {
+			if id, ok := c.responses[hash]; ok {
+				return id, n
+			}
+		}
+	}
+	return "", 0
+}
+
+// storeResponse remembers the response that ended the conversation of the request
+func (c *OpenAIResponsesLLM) storeResponse(messages []Message, reply Message, id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.serverState || id == "" {
+		return
+	}
+	conversation := append(append([]Message{}, messages...), reply)
+	hash, ok := conversationHashes(conversation)[len(conversation)]
+	if !ok {
+		return
+	}
+	if len(c.responses) >= maxStoredResponses {
+		c.responses = make(map[string]string)
+	}
+	c.responses[hash] = id
+}
+
+// newRequest builds the HTTP request for a chat request
+func (c *OpenAIResponsesLLM) newRequest(ctx context.Context, req ChatCompletionRequest, stream bool) (*http.Request, error) {
+	previous, covered := c.previousResponse(req.Messages)
+	input, err := convertToResponsesInput(req.Messages[covered:])
+	if err != nil {
+		return nil, err
+	}
+	respReq := responsesRequest{
+		Model:              req.Model,
+		Input:              input,
+		ToolChoice:         responsesToolChoice(req.ToolChoice),
+		Temperature:        req.Temperature,
+		TopP:               req.TopP,
+		MaxOutputTokens:    req.MaxTokens,
+		User:               req.User,
+		ServiceTier:        req.ServiceTier,
+		Stream:             stream,
+		Store:              c.serverState,
+		PreviousResponseID: previous,
+	}
+	for _, tool := range req.Tools {
+		if tool.Function != nil {
+			respReq.Tools = append(respReq.Tools, responsesFunctionTool{
+				Type:        "function",
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			})
+		}
+	}
+	for _, tool := range req.HostedTools {
+		respReq.Tools = append(respReq.Tools, tool)
+	}
+	if req.Grammar != nil {
+		if req.Grammar.GBNF != "" {
+			return nil, fmt.Errorf("%w: the Responses API only supports JSON schemas", ErrGrammarNotSupported)
+		}
+		respReq.Text = map[string]any{"format": map[string]any{"type": "json_schema", "name": "output", "schema": req.Grammar.JSONSchema}}
+	}
+
+	body, err := json.Marshal(respReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Authorization", "Bearer "+c.apiKey)
+	return httpReq, nil
+}
+
+// do sends the request and checks its status
+func (c *OpenAIResponsesLLM) do(httpReq *http.Request) (*http.Response, error) {
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return resp, nil
+}
+
+// complete converts a finished response and remembers it for server state
+func (c *OpenAIResponsesLLM) complete(req ChatCompletionRequest, resp responsesResponse) (ChatCompletionResponse, error) {
+	if resp.Status == "failed" && resp.Error != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("response failed: %s", resp.Error.Message)
+	}
+	msg, finishReason := convertFromResponsesOutput(resp)
+	c.storeResponse(req.Messages, msg, resp.ID)
+	return ChatCompletionResponse{
+		ID:      resp.ID,
+		Choices: []Choice{{Message: msg, FinishReason: finishReason}},
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+// CreateChatCompletion implements the LLM interface for the Responses API
+func (c *OpenAIResponsesLLM) CreateChatCompletion(ctx context.Context, req ChatCompletionRequest) (ChatCompletionResponse, error) {
+	httpReq, err := c.newRequest(ctx, req, false)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to read response: %w", err)
+	}
+	var respResp responsesResponse
+	if err := json.Unmarshal(raw, &respResp); err != nil {
+		return ChatCompletionResponse{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	result, err := c.complete(req, respResp)
+	if err != nil {
+		return ChatCompletionResponse{}, err
+	}
+	if req.KeepRaw {
+		attachRaw(result.Choices, raw)
+	}
+	return result, nil
+}
+
+// responsesStreamEvent is an event of a streamed response
+type responsesStreamEvent struct {
+	Type     string            `json:"type"`
+	Delta    string            `json:"delta"`
+	Item     responsesItem     `json:"item"`
+	Response responsesResponse `json:"response"`
+}
+
+type responsesStreamWrapper struct {
+	client   *OpenAIResponsesLLM
+	req      ChatCompletionRequest
+	reader   *bufio.Reader
+	response *http.Response
+	id       string
+}
+
+// Recv returns the next chunk of the response. Tool calls are returned once complete, and the
+// last chunk carries the finish reason and usage.
+func (s *responsesStreamWrapper) Recv() (ChatCompletionResponse, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF {
+				return ChatCompletionResponse{}, io.EOF
+			}
+			return ChatCompletionResponse{}, fmt.Errorf("failed to read stream: %w", err)
+		}
+		line = bytes.TrimSpace(line)
+		if !bytes.HasPrefix(line, sseDataPrefix) {
+			continue
+		}
+		line = bytes.TrimSpace(line[len(sseDataPrefix):])
+		if len(line) == 0 || bytes.Equal(line, sseDone) {
+			continue
+		}
+
+		var event responsesStreamEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return ChatCompletionResponse{}, fmt.Errorf("failed to unmarshal stream response: %w", err)
+		}
+		switch event.Type {
+		case "response.created":
+			s.id = event.Response.ID
+		case "response.output_text.delta":
+			return s.chunk(Message{Role: RoleAssistant, Content: event.Delta}, "", Usage{}), nil
+		case "response.output_item.done":
+			if event.Item.Type == "function_call" {
+				call := ToolCall{ID: event.Item.CallID, Type: "function", Function: ToolCallFunction{Name: event.Item.Name, Arguments: event.Item.Arguments}}
+				return s.chunk(Message{Role: RoleAssistant, ToolCalls: []ToolCall{call}}, "", Usage{}), nil
+			}
+		case "response.completed", "response.incomplete", "response.failed":
+			final, err := s.client.complete(s.req, event.Response)
+			if err != nil {
+				return ChatCompletionResponse{}, err
+			}
+			return s.chunk(Message{Role: RoleAssistant}, final.Choices[0].FinishReason, final.Usage), nil
+		case "error":
+			return ChatCompletionResponse{}, fmt.Errorf("stream failed: %s", line)
+		}
+	}
+}
+
+// chunk wraps a message delta as a streamed response
+func (s *responsesStreamWrapper) chunk(message Message, finishReason string, usage Usage) ChatCompletionResponse {
+	return ChatCompletionResponse{
+		ID:      s.id,
+		Choices: []Choice{{Message: message, FinishReason: finishReason}},
+		Usage:   usage,
+	}
+}
+
+func (s *responsesStreamWrapper) Close() error {
+	return s.response.Body.Close()
+}
+
+// CreateChatCompletionStream implements the LLM interface for Responses API streaming
+func (c *OpenAIResponsesLLM) CreateChatCompletionStream(ctx context.Context, req ChatCompletionRequest) (ChatCompletionStream, error) {
+	httpReq, err := c.newRequest(ctx, req, true)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	return &responsesStreamWrapper{
+		client:   c,
+		req:      req,
+		reader:   bufio.NewReader(resp.Body),
+		response: resp,
+	}, nil
+}
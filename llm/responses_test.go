@@ -0,0 +1,82 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAIResponses(t *testing.T) {
+	var sent []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body)
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			io.WriteString(w, "event: response.created\ndata: {\"type\":\"response.created\",\"response\":{\"id\":\"resp_2\"}}\n\n"+
+				"event: response.output_text.delta\ndata: {\"type\":\"response.output_text.delta\",\"delta\":\"Sunny\"}\n\n"+
+				"event: response.completed\ndata: {\"type\":\"response.completed\",\"response\":{\"id\":\"resp_2\",\"status\":\"completed\","+
+				"\"output\":[{\"type\":\"message\",\"role\":\"assistant\",\"content\":[{\"type\":\"output_text\",\"text\":\"Sunny\"}]}],"+
+				"\"usage\":{\"input_tokens\":20,\"output_tokens\":2,\"total_tokens\":22}}}\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"resp_1","status":"completed","output":[{"type":"web_search_call","id":"ws_1","status":"completed"},`+
+			`{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}],`+
+			`"usage":{"input_tokens":10,"output_tokens":5,"total_tokens":15}}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAIResponsesLLM("key").WithServerState()
+	client.endpoint = server.URL
+	req := ChatCompletionRequest{
+		Model:       "gpt-4o",
+		Messages:    []Message{{Role: RoleSystem, Content: "Be brief."}, {Role: RoleUser, Content: "Weather in Paris?"}},
+		Tools:       []Tool{{Type: "function", Function: &Function{Name: "get_weather"}}},
+		HostedTools: []HostedTool{WebSearch(), FileSearch("vs_1")},
+	}
+	resp, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	assert.Equal(t, "call_1", resp.Choices[0].Message.ToolCalls[0].ID)
+	assert.Equal(t, 15, resp.Usage.TotalTokens)
+	tools := sent[0]["tools"].([]interface{})
+	assert.Equal(t, "get_weather", tools[0].(map[string]interface{})["name"])
+	assert.Equal(t, "web_search_preview", tools[1].(map[string]interface{})["type"])
+	assert.Equal(t, []interface{}{"vs_1"}, tools[2].(map[string]interface{})["vector_store_ids"])
+	assert.Len(t, sent[0]["input"], 2)
+	assert.Nil(t, sent[0]["previous_response_id"])
+
+	// The continuation only sends the tool result along with the previous response
+	req.Messages = append(req.Messages, resp.Choices[0].Message, Message{Role: RoleTool, Content: "Sunny", ToolCallID: "call_1"})
+	stream, err := client.CreateChatCompletionStream(context.Background(), req)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer stream.Close()
+	assert.Equal(t, "resp_1", sent[1]["previous_response_id"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"type": "function_call_output", "call_id": "call_1", "output": "Sunny"}}, sent[1]["input"])
+
+	chunk, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Sunny", chunk.Choices[0].Message.Content)
+	chunk, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "stop", chunk.Choices[0].FinishReason)
+	assert.Equal(t, 22, chunk.Usage.TotalTokens)
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+
+	// Changed history is sent in full
+	req.Messages[0].Content = "Be very brief."
+	_, err = client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Nil(t, sent[2]["previous_response_id"])
+	assert.Len(t, sent[2]["input"], 4)
+}
@@ -0,0 +1,99 @@
+package llm
+
+import "strings"
+
+// Sanitizer rewrites a message list so that it satisfies a provider's role and ordering rules
+type Sanitizer func(messages []Message) []Message
+
+// continuationPrompt is inserted when a provider requires the conversation to open with a user turn
+const continuationPrompt = "Continue."
+
+// SanitizerFor returns the sanitizer for a provider. Providers without extra constraints
+// get a sanitizer that only merges system messages.
+func SanitizerFor(provider LLMProvider) Sanitizer {
+	switch provider {
+	case Claude, Gemini:
+		return sanitizeAlternating
+	case "":
+		return func(messages []Message) []Message { return messages }
+	default:
+		return mergeSystemMessages
+	}
+}
+
+// SanitizeMessages rewrites messages into a valid form for the provider
+func SanitizeMessages(provider LLMProvider, messages []Message) []Message {
+	return SanitizerFor(provider)(messages)
+}
+
+// mergeSystemMessages collapses all system messages into a single leading one
+func mergeSystemMessages(messages []Message) []Message {
+	// Most requests already have at most one, leading system message and need no copy
+	if !needsSystemMerge(messages) {
+		return messages
+	}
+
+	var system []string
+	rest := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+			continue
+		}
+		rest = append(rest, msg)
+	}
+	if len(system) == 0 {
+		return rest
+	}
+	return append([]Message{{Role: RoleSystem, Content: strings.Join(system, "\n\n")}}, rest...)
+}
+
+// needsSystemMerge reports whether messages has a system message other than a non-empty first one
+func needsSystemMerge(messages []Message) bool {
+	for i, msg := range messages {
+		if msg.Role == RoleSystem && (i > 0 || msg.Content == "") {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeable reports whether two adjacent plain text messages can be combined into one
+func mergeable(prev, next Message) bool {
+	if prev.Role != next.Role || (prev.Role != RoleUser && prev.Role != RoleAssistant) {
+		return false
+	}
+	return len(prev.ToolCalls) == 0 && len(next.ToolCalls) == 0 && !prev.Interrupted && !next.Interrupted
+}
+
+// sanitizeAlternating enforces the rules of providers that take a single system prompt and
+// require user and assistant turns to alternate, starting with a user turn
+func sanitizeAlternating(messages []Message) []Message {
+	messages = mergeSystemMessages(messages)
+
+	sanitized := make([]Message, 0, len(messages))
+	for _, msg := range messages {
+		// Empty assistant turns are rejected and carry no information
+		if msg.Role == RoleAssistant && msg.Content == "" && len(msg.ToolCalls) == 0 {
+			continue
+		}
+		if n := len(sanitized); n > 0 && mergeable(sanitized[n-1], msg) {
+			sanitized[n-1].Content = strings.TrimSpace(sanitized[n-1].Content + "\n\n" + msg.Content)
+			continue
+		}
+		sanitized = append(sanitized, msg)
+	}
+
+	// The first conversational turn must come from the user
+	first := 0
+	if len(sanitized) > 0 && sanitized[0].Role == RoleSystem {
+		first = 1
+	}
+	if first < len(sanitized) && sanitized[first].Role != RoleUser {
+		sanitized = append(sanitized[:first], append([]Message{{Role: RoleUser, Content: continuationPrompt}}, sanitized[first:]...)...)
+	}
+
+	return sanitized
+}
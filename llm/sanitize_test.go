@@ -0,0 +1,32 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestSanitizeMessagesClaude tests system merging, turn merging and the leading user turn for alternating providers
+func TestSanitizeMessagesClaude(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "You are helpful."},
+		{Role: RoleAssistant, Content: "Hello!"},
+		{Role: RoleSystem, Content: "Be brief."},
+		{Role: RoleUser, Content: "Hi"},
+		{Role: RoleUser, Content: "Are you there?"},
+		{Role: RoleAssistant, Content: ""},
+		{Role: RoleAssistant, Content: "Yes."},
+	}
+
+	sanitized := SanitizeMessages(Claude, messages)
+	assert.Equal(t, []Message{
+		{Role: RoleSystem, Content: "You are helpful.\n\nBe brief."},
+		{Role: RoleUser, Content: continuationPrompt},
+		{Role: RoleAssistant, Content: "Hello!"},
+		{Role: RoleUser, Content: "Hi\n\nAre you there?"},
+		{Role: RoleAssistant, Content: "Yes."},
+	}, sanitized)
+
+	// OpenAI accepts the same history once system messages are merged
+	assert.Len(t, SanitizeMessages(OpenAI, messages), 6)
+}
@@ -0,0 +1,38 @@
+package llm
+
+import (
+	"context"
+
+	"github.com/anthropics/anthropic-sdk-go/option"
+)
+
+// ServiceTier is the processing tier a provider serves a request at, trading latency for cost
+type ServiceTier string
+
+const (
+	ServiceTierAuto     ServiceTier = "auto"     // The provider picks the tier, e.g. by the project's settings
+	ServiceTierDefault  ServiceTier = "default"  // Standard processing
+	ServiceTierFlex     ServiceTier = "flex"     // Slower, cheaper processing for background jobs
+	ServiceTierPriority ServiceTier = "priority" // Faster, more reliable processing for latency-critical requests
+)
+
+// withServiceTier returns a context whose OpenAI requests are sent at the service tier
+func withServiceTier(ctx context.Context, tier ServiceTier) context.Context {
+	if tier == "" {
+		return ctx
+	}
+	return withExtraFields(ctx, map[string]interface{}{"service_tier": string(tier)})
+}
+
+// claudeServiceTier converts a service tier to Claude's request option. Claude only chooses
+// between priority capacity, when available, and standard capacity.
+func claudeServiceTier(tier ServiceTier) []option.RequestOption {
+	switch tier {
+	case ServiceTierAuto, ServiceTierPriority:
+		return []option.RequestOption{option.WithJSONSet("service_tier", "auto")}
+	case ServiceTierDefault, ServiceTierFlex:
+		return []option.RequestOption{option.WithJSONSet("service_tier", "standard_only")}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceTier(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		body = nil
+		json.Unmarshal(data, &body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"{}"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAICompatibleLLM("key", server.URL, CompatVLLM)
+	req := ChatCompletionRequest{
+		Model:       "gpt-4o",
+		Messages:    []Message{{Role: RoleUser, Content: "Hi"}},
+		Grammar:     &Grammar{JSONSchema: json.RawMessage(`{"type":"object"}`)},
+		ServiceTier: ServiceTierFlex,
+	}
+	_, err := client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.Equal(t, "flex", body["service_tier"])
+	assert.NotNil(t, body["guided_json"])
+
+	req.ServiceTier = ""
+	_, err = client.CreateChatCompletion(context.Background(), req)
+	assert.NoError(t, err)
+	assert.NotContains(t, body, "service_tier")
+}
@@ -0,0 +1,71 @@
+package llm
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/sashabaranov/go-openai"
+	"google.golang.org/api/googleapi"
+)
+
+// statusPattern finds the status reported by clients that read error responses themselves
+var statusPattern = regexp.MustCompile(`status(?: code:)? (\d{3})\b`)
+
+// statusError is an error of a request the provider answered with an HTTP status
+type statusError struct {
+	status int
+	err    error
+}
+
+// Error returns the message of the wrapped error
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// HTTPStatus returns the HTTP status a provider answered a failed request with, or 0 if the
+// request did not get a response, e.g. because of a network error or a timeout
+func HTTPStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var withStatus *statusError
+	if errors.As(err, &withStatus) {
+		return withStatus.status
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) && apiErr.HTTPStatusCode > 0 {
+		return apiErr.HTTPStatusCode
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) && requestErr.HTTPStatusCode > 0 {
+		return requestErr.HTTPStatusCode
+	}
+	var claudeErr *anthropic.Error
+	if errors.As(err, &claudeErr) && claudeErr.StatusCode > 0 {
+		return claudeErr.StatusCode
+	}
+	var googleErr *googleapi.Error
+	if errors.As(err, &googleErr) && googleErr.Code > 0 {
+		return googleErr.Code
+	}
+	if match := statusPattern.FindStringSubmatch(err.Error()); match != nil {
+		status, _ := strconv.Atoi(match[1])
+		return status
+	}
+	return 0
+}
+
+// IsRequestError reports whether the provider rejected the request itself, with a 4xx status
+// other than 408 or 429, so sending it again elsewhere would fail the same way
+func IsRequestError(err error) bool {
+	status := HTTPStatus(err)
+	return status >= 400 && status < 500 && status != http.StatusRequestTimeout && status != http.StatusTooManyRequests
+}
@@ -0,0 +1,23 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	assert.Equal(t, 0, HTTPStatus(nil))
+	assert.Equal(t, 0, HTTPStatus(errors.New("connection refused")))
+	assert.Equal(t, 401, HTTPStatus(fmt.Errorf("chat failed: %w", &openai.APIError{HTTPStatusCode: 401})))
+	assert.Equal(t, 400, HTTPStatus(&statusError{status: 400, err: errors.New("OpenAI API error: invalid_request")}))
+	assert.Equal(t, 503, HTTPStatus(errors.New("request failed with status 503: overloaded")))
+
+	assert.True(t, IsRequestError(errors.New("request failed with status 404: no such model")))
+	assert.False(t, IsRequestError(errors.New("request failed with status 429: slow down")))
+	assert.False(t, IsRequestError(errors.New("request failed with status 502: bad gateway")))
+	assert.False(t, IsRequestError(errors.New("i/o timeout")))
+}
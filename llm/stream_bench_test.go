@@ -0,0 +1,102 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sseBody builds a DeepSeek style event stream of content tokens
+func sseBody(tokens int) string {
+	var b strings.Builder
+	b.WriteString(": keep-alive\n\n")
+	for i := 0; i < tokens; i++ {
+		b.WriteString(`data: {"id":"1","choices":[{"index":0,"delta":{"content":"tok "}}]}` + "\n\n")
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func newTestDeepseekStream(body string) *deepseekStreamWrapper {
+	return newDeepseekStreamWrapper(context.Background(), &http.Response{Body: io.NopCloser(strings.NewReader(body))})
+}
+
+func TestDeepseekStreamSkipsBlankLinesAndComments(t *testing.T) {
+	long := strings.Repeat("x", 10000)
+	body := ": comment\n\nevent: message\n" +
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"` + long + `"}}]}` + "\n\n" +
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}` + "\n\n" +
+		`data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"go\"}"}}]},"finish_reason":"tool_calls"}]}` + "\n\n" +
+		"data: [DONE]\n"
+	stream := newTestDeepseekStream(body)
+
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, long, resp.Choices[0].Message.Content)
+
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Choices[0].Message.ToolCalls)
+
+	resp, err = stream.Recv()
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Choices[0].Message.ToolCalls, 1) {
+		assert.Equal(t, `{"q":"go"}`, resp.Choices[0].Message.ToolCalls[0].Function.Arguments)
+	}
+
+	_, err = stream.Recv()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDeepseekStreamIncompleteToolCall(t *testing.T) {
+	body := `data: {"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"lookup","arguments":"{\"q\":"}}]}}]}` + "\n\n" +
+		`data: {"id":"1","choices":[{"index":0,"delta":{"content":"Done"},"finish_reason":"tool_calls"}],"usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}` + "\n\n" +
+		"data: [DONE]\n"
+	stream := newTestDeepseekStream(body)
+
+	_, err := stream.Recv()
+	assert.NoError(t, err)
+
+	// The last chunk is delivered before the incomplete call is reported
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, "Done", resp.Choices[0].Message.Content)
+	assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+	assert.Equal(t, 5, resp.Usage.TotalTokens)
+
+	_, err = stream.Recv()
+	assert.ErrorContains(t, err, "incomplete tool call arguments for: [lookup]")
+}
+
+func BenchmarkDeepseekStreamRecv(b *testing.B) {
+	body := sseBody(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stream := newTestDeepseekStream(body)
+		for {
+			if _, err := stream.Recv(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkToolCallAssembler(b *testing.B) {
+	index := 0
+	fragments := []string{`{"query":`, ` "weather`, ` in`, ` Paris",`, ` "days":`, ` 3}`}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		assembler := NewToolCallAssembler()
+		assembler.Add(ToolCall{Index: &index, ID: "call_1", Type: "function", Function: ToolCallFunction{Name: "search"}})
+		for _, fragment := range fragments {
+			assembler.Add(ToolCall{Index: &index, Function: ToolCallFunction{Arguments: fragment}})
+			assembler.Ready()
+		}
+	}
+}
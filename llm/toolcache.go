@@ -0,0 +1,56 @@
+package llm
+
+import "sync"
+
+// toolCacheSize bounds the number of payloads a toolCache keeps before starting over
+const toolCacheSize = 4096
+
+// toolCacheEntry is a converted tool and the definition fields it was built from
+type toolCacheEntry[T any] struct {
+	name        string
+	description string
+	payload     T
+}
+
+// toolCache memoizes the provider-specific payload of each tool definition, so agents that
+// send the same tools every turn only pay for the conversion once. Definitions are keyed by
+// pointer; their parameters must not be modified after they have been sent.
+type toolCache[T any] struct {
+	mu      sync.Mutex
+	entries map[*Function]toolCacheEntry[T]
+}
+
+// convert returns the payloads of the tools, building those not seen before
+func (c *toolCache[T]) convert(tools []Tool, build func(Tool) T) []T {
+	if len(tools) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[*Function]toolCacheEntry[T])
+	}
+
+	payloads := make([]T, len(tools))
+	for i, tool := range tools {
+		if tool.Function == nil {
+			payloads[i] = build(tool)
+			continue
+		}
+		entry, ok := c.entries[tool.Function]
+		if !ok || entry.name != tool.Function.Name || entry.description != tool.Function.Description {
+			if len(c.entries) >= toolCacheSize {
+				clear(c.entries)
+			}
+			entry = toolCacheEntry[T]{
+				name:        tool.Function.Name,
+				description: tool.Function.Description,
+				payload:     build(tool),
+			}
+			c.entries[tool.Function] = entry
+		}
+		payloads[i] = entry.payload
+	}
+	return payloads
+}
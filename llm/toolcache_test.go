@@ -0,0 +1,21 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolCacheReusesPayloads(t *testing.T) {
+	fn := &Function{Name: "lookup", Description: "Look up", Parameters: map[string]interface{}{"type": "object"}}
+	tools := []Tool{{Type: "function", Function: fn}}
+
+	first := convertToOpenAITools(tools)
+	second := convertToOpenAITools(tools)
+	assert.Same(t, first[0].Function, second[0].Function)
+
+	fn.Description = "Look something up"
+	third := convertToOpenAITools(tools)
+	assert.NotSame(t, first[0].Function, third[0].Function)
+	assert.Equal(t, "Look something up", third[0].Function.Description)
+}
@@ -0,0 +1,160 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// assembledToolCall tracks a tool call whose arguments arrive over several stream chunks
+type assembledToolCall struct {
+	call    ToolCall
+	args    []byte // Arguments received so far, appended in place to avoid a copy per fragment
+	order   int
+	emitted bool
+}
+
+// callKey identifies a buffered call by stream index, or by ID when there is no index
+type callKey struct {
+	index int
+	id    string
+}
+
+// ToolCallAssembler reassembles fragmented tool-call deltas from a stream.
+// Deltas are matched by index when the provider supplies one, then by ID, and
+// otherwise continue the most recent call, so parallel calls interleaved by
+// index are assembled independently.
+type ToolCallAssembler struct {
+	calls   map[callKey]*assembledToolCall
+	last    callKey
+	hasLast bool
+	next    int
+}
+
+// NewToolCallAssembler creates an empty ToolCallAssembler
+func NewToolCallAssembler() *ToolCallAssembler {
+	return &ToolCallAssembler{
+		calls: make(map[callKey]*assembledToolCall),
+	}
+}
+
+// key returns the buffer key a delta belongs to and whether there is one
+func (a *ToolCallAssembler) key(delta ToolCall) (callKey, bool) {
+	if delta.Index != nil {
+		return callKey{index: *delta.Index}, true
+	}
+	if delta.ID != "" {
+		// A call already known by index may later be referenced by ID only
+		for key, pending := range a.calls {
+			if pending.call.ID == delta.ID {
+				return key, true
+			}
+		}
+		return callKey{index: -1, id: delta.ID}, true
+	}
+	return a.last, a.hasLast
+}
+
+// Add merges a streamed tool-call delta into the buffered calls
+func (a *ToolCallAssembler) Add(delta ToolCall) {
+	key, ok := a.key(delta)
+	if !ok {
+		// Arguments without any call to attach them to are dropped
+		return
+	}
+
+	pending, exists := a.calls[key]
+	if !exists {
+		pending = &assembledToolCall{order: a.next}
+		pending.call.Index = delta.Index
+		a.next++
+		a.calls[key] = pending
+	}
+	a.last, a.hasLast = key, true
+
+	if delta.ID != "" {
+		pending.call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		pending.call.Type = delta.Type
+	}
+	if delta.Function.Name != "" {
+		pending.call.Function.Name = delta.Function.Name
+	}
+	pending.args = append(pending.args, delta.Function.Arguments...)
+}
+
+// isJSONObject reports whether data is a complete JSON object, without decoding it
+func isJSONObject(data []byte) bool {
+	data = bytes.TrimSpace(data)
+	return len(data) > 0 && data[0] == '{' && json.Valid(data)
+}
+
+// Ready returns the calls whose arguments now parse as a JSON object and that
+// have not been returned before, in the order they were first seen
+func (a *ToolCallAssembler) Ready() []ToolCall {
+	var ready []*assembledToolCall
+	for _, pending := range a.calls {
+		if pending.emitted || pending.call.Function.Name == "" || !isJSONObject(pending.args) {
+			continue
+		}
+		ready = append(ready, pending)
+	}
+	return a.emit(ready)
+}
+
+// Flush returns every call not yet returned, treating empty arguments as an
+// empty object. It is meant to be called once the stream reports a finish
+// reason; calls whose arguments still do not parse are reported in the error.
+func (a *ToolCallAssembler) Flush() ([]ToolCall, error) {
+	var ready []*assembledToolCall
+	var invalid []string
+	for _, pending := range a.calls {
+		if pending.emitted {
+			continue
+		}
+		if len(pending.args) == 0 {
+			pending.args = append(pending.args, "{}"...)
+		}
+		if !isJSONObject(pending.args) {
+			invalid = append(invalid, pending.call.Function.Name)
+			pending.emitted = true
+			continue
+		}
+		ready = append(ready, pending)
+	}
+
+	calls := a.emit(ready)
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return calls, fmt.Errorf("incomplete tool call arguments for: %v", invalid)
+	}
+	return calls, nil
+}
+
+// Pending reports whether any call has been started but not yet returned
+func (a *ToolCallAssembler) Pending() bool {
+	for _, pending := range a.calls {
+		if !pending.emitted {
+			return true
+		}
+	}
+	return false
+}
+
+// emit marks calls as returned and orders them by first appearance
+func (a *ToolCallAssembler) emit(ready []*assembledToolCall) []ToolCall {
+	if len(ready) == 0 {
+		return nil
+	}
+	sort.Slice(ready, func(i, j int) bool { return ready[i].order < ready[j].order })
+
+	calls := make([]ToolCall, len(ready))
+	for i, pending := range ready {
+		pending.emitted = true
+		pending.call.Function.Arguments = string(pending.args)
+		calls[i] = pending.call
+	}
+	return calls
+}
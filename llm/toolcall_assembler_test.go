@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+// TestToolCallAssemblerInterleaved tests assembling parallel tool calls whose fragments are interleaved by index
+func TestToolCallAssemblerInterleaved(t *testing.T) {
+	a := NewToolCallAssembler()
+
+	a.Add(ToolCall{Index: intPtr(0), ID: "call_a", Type: "function", Function: ToolCallFunction{Name: "get_weather"}})
+	a.Add(ToolCall{Index: intPtr(1), ID: "call_b", Type: "function", Function: ToolCallFunction{Name: "get_time"}})
+	a.Add(ToolCall{Index: intPtr(0), Function: ToolCallFunction{Arguments: `{"city":`}})
+	a.Add(ToolCall{Index: intPtr(1), Function: ToolCallFunction{Arguments: `{"zone":"UTC"`}})
+	assert.Empty(t, a.Ready())
+
+	a.Add(ToolCall{Index: intPtr(1), Function: ToolCallFunction{Arguments: `}`}})
+	ready := a.Ready()
+	assert.Len(t, ready, 1)
+	assert.Equal(t, "call_b", ready[0].ID)
+	assert.Equal(t, `{"zone":"UTC"}`, ready[0].Function.Arguments)
+
+	a.Add(ToolCall{Index: intPtr(0), Function: ToolCallFunction{Arguments: `"Paris"}`}})
+	ready = a.Ready()
+	assert.Len(t, ready, 1)
+	assert.Equal(t, "call_a", ready[0].ID)
+	assert.Equal(t, `{"city":"Paris"}`, ready[0].Function.Arguments)
+
+	assert.False(t, a.Pending())
+}
+
+// TestToolCallAssemblerFlush tests flushing calls with empty or incomplete arguments
+func TestToolCallAssemblerFlush(t *testing.T) {
+	a := NewToolCallAssembler()
+
+	a.Add(ToolCall{ID: "call_a", Function: ToolCallFunction{Name: "no_args"}})
+	a.Add(ToolCall{ID: "call_b", Function: ToolCallFunction{Name: "broken", Arguments: `{"x":`}})
+
+	calls, err := a.Flush()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "broken")
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "{}", calls[0].Function.Arguments)
+	assert.False(t, a.Pending())
+}
@@ -0,0 +1,108 @@
+package llm
+
+import (
+	"github.com/anthropics/anthropic-sdk-go"
+	"github.com/google/generative-ai-go/genai"
+	openai "github.com/sashabaranov/go-openai"
+)
+
+// ToolChoiceMode is whether the model may, must or must not call tools
+type ToolChoiceMode string
+
+const (
+	ToolChoiceAuto     ToolChoiceMode = "auto"     // The model decides whether to call tools
+	ToolChoiceNone     ToolChoiceMode = "none"     // The model must not call tools
+	ToolChoiceRequired ToolChoiceMode = "required" // The model must call at least one tool
+)
+
+// ToolChoice controls the tool calls of a request. Setting Function forces the model to call
+// that function, whatever the mode.
+type ToolChoice struct {
+	Mode     ToolChoiceMode `json:"mode,omitempty"`
+	Function string         `json:"function,omitempty"`
+}
+
+// ForceTool returns a tool choice that makes the model call the named function
+func ForceTool(name string) *ToolChoice {
+	return &ToolChoice{Mode: ToolChoiceRequired, Function: name}
+}
+
+// NewToolChoice returns a tool choice of the mode
+func NewToolChoice(mode ToolChoiceMode) *ToolChoice {
+	return &ToolChoice{Mode: mode}
+}
+
+// openAIToolChoice converts a tool choice to OpenAI's tool_choice, nil for the default
+func openAIToolChoice(choice *ToolChoice) any {
+	switch {
+	case choice == nil:
+		return nil
+	case choice.Function != "":
+		return openai.ToolChoice{Type: openai.ToolTypeFunction, Function: openai.ToolFunction{Name: choice.Function}}
+	case choice.Mode == "":
+		return nil
+	default:
+		return string(choice.Mode)
+	}
+}
+
+// claudeToolChoice converts a tool choice to Claude's tool_choice, nil for the default. Claude
+// has no mode without tools, so ToolChoiceNone is sent by leaving the tools out.
+func claudeToolChoice(choice *ToolChoice) anthropic.ToolChoiceUnionParam {
+	switch {
+	case choice == nil:
+		return nil
+	case choice.Function != "":
+		return anthropic.ToolChoiceToolParam{
+			Type: anthropic.F(anthropic.ToolChoiceToolTypeTool),
+			Name: anthropic.F(choice.Function),
+		}
+	case choice.Mode == ToolChoiceRequired:
+		return anthropic.ToolChoiceAnyParam{Type: anthropic.F(anthropic.ToolChoiceAnyTypeAny)}
+	case choice.Mode == ToolChoiceAuto:
+		return anthropic.ToolChoiceAutoParam{Type: anthropic.F(anthropic.ToolChoiceAutoTypeAuto)}
+	default:
+		return nil
+	}
+}
+
+// geminiToolConfig converts a tool choice to Gemini's tool config, nil for the default
+func geminiToolConfig(choice *ToolChoice) *genai.ToolConfig {
+	var config genai.FunctionCallingConfig
+	switch {
+	case choice == nil:
+		return nil
+	case choice.Function != "":
+		config = genai.FunctionCallingConfig{Mode: genai.FunctionCallingAny, AllowedFunctionNames: []string{choice.Function}}
+	case choice.Mode == ToolChoiceRequired:
+		config = genai.FunctionCallingConfig{Mode: genai.FunctionCallingAny}
+	case choice.Mode == ToolChoiceNone:
+		config = genai.FunctionCallingConfig{Mode: genai.FunctionCallingNone}
+	case choice.Mode == ToolChoiceAuto:
+		config = genai.FunctionCallingConfig{Mode: genai.FunctionCallingAuto}
+	default:
+		return nil
+	}
+	return &genai.ToolConfig{FunctionCallingConfig: &config}
+}
+
+// chosenTools returns the tools a request offers under the tool choice, for backends that
+// cannot constrain tool calls otherwise: none for ToolChoiceNone, and only the forced function
+// when one is set
+func chosenTools(tools []Tool, choice *ToolChoice) []Tool {
+	switch {
+	case choice == nil:
+		return tools
+	case choice.Function != "":
+		for _, tool := range tools {
+			if tool.Function != nil && tool.Function.Name == choice.Function {
+				return []Tool{tool}
+			}
+		}
+		return tools
+	case choice.Mode == ToolChoiceNone:
+		return nil
+	default:
+		return tools
+	}
+}
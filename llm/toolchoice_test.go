@@ -0,0 +1,53 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToolChoice(t *testing.T) {
+	var sent []json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			ToolChoice json.RawMessage `json:"tool_choice"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		sent = append(sent, body.ToolChoice)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"ok"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	client := NewOpenAILLMWithHost("", server.URL)
+	req := ChatCompletionRequest{
+		Model:    "gpt-4",
+		Messages: []Message{{Role: RoleUser, Content: "Book a flight"}},
+		Tools: []Tool{
+			{Type: "function", Function: &Function{Name: "classify"}},
+			{Type: "function", Function: &Function{Name: "book_flight"}},
+		},
+	}
+	for _, choice := range []*ToolChoice{nil, NewToolChoice(ToolChoiceRequired), ForceTool("classify")} {
+		req.ToolChoice = choice
+		_, err := client.CreateChatCompletion(context.Background(), req)
+		assert.NoError(t, err)
+	}
+	assert.Empty(t, sent[0])
+	assert.JSONEq(t, `"required"`, string(sent[1]))
+	assert.JSONEq(t, `{"type":"function","function":{"name":"classify"}}`, string(sent[2]))
+
+	config := geminiToolConfig(ForceTool("classify"))
+	assert.Equal(t, genai.FunctionCallingAny, config.FunctionCallingConfig.Mode)
+	assert.Equal(t, []string{"classify"}, config.FunctionCallingConfig.AllowedFunctionNames)
+	assert.Nil(t, claudeToolChoice(NewToolChoice(ToolChoiceNone)))
+	assert.Empty(t, chosenTools(req.Tools, NewToolChoice(ToolChoiceNone)))
+	assert.Equal(t, req.Tools[1:], chosenTools(req.Tools, ForceTool("book_flight")))
+	assert.Equal(t, "REQUIRED", cohereToolChoice(ForceTool("classify")))
+}
@@ -0,0 +1,164 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidToolHistory is returned when tool messages do not line up with the assistant tool calls they answer
+var ErrInvalidToolHistory = errors.New("invalid tool call history")
+
+// isToolMessage reports whether the message carries a tool result
+func isToolMessage(msg Message) bool {
+	return msg.Role == RoleFunction || msg.Role == RoleTool
+}
+
+// pendingToolCalls tracks the unanswered calls of the most recent assistant tool-call message
+type pendingToolCalls struct {
+	calls    []ToolCall
+	answered []bool
+}
+
+// match finds the unanswered call a tool message refers to. Messages with a ToolCallID
+// match by ID; legacy messages without one fall back to the function name.
+func (p *pendingToolCalls) match(msg Message) int {
+	for i, call := range p.calls {
+		if p.answered[i] {
+			continue
+		}
+		if msg.ToolCallID != "" {
+			if call.ID == msg.ToolCallID {
+				return i
+			}
+			continue
+		}
+		if call.Function.Name == msg.Name {
+			return i
+		}
+	}
+	return -1
+}
+
+// unanswered returns the calls that have no tool message yet
+func (p *pendingToolCalls) unanswered() []ToolCall {
+	var calls []ToolCall
+	for i, call := range p.calls {
+		if !p.answered[i] {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// describeToolCall names a call for error messages
+func describeToolCall(call ToolCall) string {
+	if call.ID != "" {
+		return fmt.Sprintf("%s (%s)", call.ID, call.Function.Name)
+	}
+	return call.Function.Name
+}
+
+// ValidateToolMessages checks that every tool message answers a call made by the assistant
+// message before it and that every assistant tool call is answered before the conversation
+// moves on, which OpenAI-compatible APIs reject with a 400 otherwise.
+func ValidateToolMessages(messages []Message) error {
+	var pending *pendingToolCalls
+
+	checkAnswered := func(index int) error {
+		if pending == nil {
+			return nil
+		}
+		if missing := pending.unanswered(); len(missing) > 0 {
+			return fmt.Errorf("%w: tool call %s at message %d has no result", ErrInvalidToolHistory, describeToolCall(missing[0]), index)
+		}
+		return nil
+	}
+
+	for i, msg := range messages {
+		if isToolMessage(msg) {
+			if pending == nil {
+				return fmt.Errorf("%w: tool message %d does not follow an assistant tool call", ErrInvalidToolHistory, i)
+			}
+			j := pending.match(msg)
+			if j < 0 {
+				if msg.ToolCallID != "" {
+					return fmt.Errorf("%w: tool message %d references unknown tool_call_id %s", ErrInvalidToolHistory, i, msg.ToolCallID)
+				}
+				return fmt.Errorf("%w: tool message %d for %q does not match a pending tool call", ErrInvalidToolHistory, i, msg.Name)
+			}
+			pending.answered[j] = true
+			continue
+		}
+
+		if err := checkAnswered(i); err != nil {
+			return err
+		}
+		pending = nil
+		if msg.Role == RoleAssistant && len(msg.ToolCalls) > 0 {
+			pending = &pendingToolCalls{calls: msg.ToolCalls, answered: make([]bool, len(msg.ToolCalls))}
+		}
+	}
+
+	return checkAnswered(len(messages))
+}
+
+// RepairToolMessages returns a copy of messages that passes ValidateToolMessages. Tool messages
+// without an ID get the ID of the call they match, orphaned tool messages are dropped, and
+// unanswered tool calls are removed from their assistant message (which is dropped if nothing is left).
+func RepairToolMessages(messages []Message) []Message {
+	repaired := make([]Message, 0, len(messages))
+	var pending *pendingToolCalls
+	assistantIndex := -1
+
+	closePending := func() {
+		if pending == nil {
+			return
+		}
+		if missing := pending.unanswered(); len(missing) > 0 {
+			var kept []ToolCall
+			for i, call := range pending.calls {
+				if pending.answered[i] {
+					kept = append(kept, call)
+				}
+			}
+			msg := repaired[assistantIndex]
+			msg.ToolCalls = kept
+			if len(kept) == 0 && msg.Content == "" {
+				// Nothing was answered, so the assistant message is still the last one
+				repaired = repaired[:assistantIndex]
+			} else {
+				repaired[assistantIndex] = msg
+			}
+		}
+		pending = nil
+		assistantIndex = -1
+	}
+
+	for _, msg := range messages {
+		if isToolMessage(msg) {
+			if pending == nil {
+				continue
+			}
+			j := pending.match(msg)
+			if j < 0 {
+				continue
+			}
+			pending.answered[j] = true
+			if msg.ToolCallID == "" {
+				msg.ToolCallID = pending.calls[j].ID
+			}
+			repaired = append(repaired, msg)
+			continue
+		}
+
+		closePending()
+		repaired = append(repaired, msg)
+		if msg.Role == RoleAssistant && len(msg.ToolCalls) > 0 {
+			pending = &pendingToolCalls{calls: msg.ToolCalls, answered: make([]bool, len(msg.ToolCalls))}
+			assistantIndex = len(repaired) - 1
+		}
+	}
+	closePending()
+
+	return repaired
+}
@@ -0,0 +1,76 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func toolCallMessage(calls ...ToolCall) Message {
+	return Message{Role: RoleAssistant, ToolCalls: calls}
+}
+
+func call(id, name string) ToolCall {
+	return ToolCall{ID: id, Type: "function", Function: ToolCallFunction{Name: name, Arguments: "{}"}}
+}
+
+// TestValidateToolMessages tests detection of orphaned, unknown and unanswered tool messages
+func TestValidateToolMessages(t *testing.T) {
+	valid := []Message{
+		{Role: RoleUser, Content: "hi"},
+		toolCallMessage(call("call_1", "a"), call("call_2", "b")),
+		{Role: RoleFunction, Name: "b", ToolCallID: "call_2", Content: "2"},
+		{Role: RoleFunction, Name: "a", Content: "1"}, // Legacy message matched by name
+		{Role: RoleAssistant, Content: "done"},
+	}
+	assert.NoError(t, ValidateToolMessages(valid))
+
+	orphan := []Message{{Role: RoleUser}, {Role: RoleFunction, Name: "a", ToolCallID: "call_1"}}
+	assert.ErrorIs(t, ValidateToolMessages(orphan), ErrInvalidToolHistory)
+
+	unknown := []Message{toolCallMessage(call("call_1", "a")), {Role: RoleFunction, Name: "a", ToolCallID: "call_9"}}
+	assert.ErrorContains(t, ValidateToolMessages(unknown), "call_9")
+
+	unanswered := []Message{toolCallMessage(call("call_1", "a")), {Role: RoleUser, Content: "next"}}
+	assert.ErrorContains(t, ValidateToolMessages(unanswered), "call_1")
+}
+
+// TestRepairToolMessages tests that repaired histories validate and keep answered calls
+func TestRepairToolMessages(t *testing.T) {
+	messages := []Message{
+		{Role: RoleFunction, Name: "x", Content: "orphan"},
+		{Role: RoleUser, Content: "hi"},
+		toolCallMessage(call("call_1", "a"), call("call_2", "b")),
+		{Role: RoleFunction, Name: "a", Content: "1"},
+		{Role: RoleFunction, Name: "a", ToolCallID: "call_1", Content: "duplicate"},
+		{Role: RoleUser, Content: "again"},
+		toolCallMessage(call("call_3", "c")),
+	}
+
+	repaired := RepairToolMessages(messages)
+	assert.NoError(t, ValidateToolMessages(repaired))
+	assert.Len(t, repaired, 4)
+	assert.Equal(t, []ToolCall{call("call_1", "a")}, repaired[1].ToolCalls)
+	assert.Equal(t, "call_1", repaired[2].ToolCallID)
+	assert.Equal(t, "again", repaired[3].Content)
+
+	// The input is left untouched
+	assert.Len(t, messages[2].ToolCalls, 2)
+}
+
+// TestOpenAIToolRoles tests that only tool-role messages are sent as answers to tool calls
+func TestOpenAIToolRoles(t *testing.T) {
+	messages := convertToOpenAIMessages([]Message{
+		toolCallMessage(call("call_1", "a")),
+		{Role: RoleTool, Name: "a", ToolCallID: "call_1", Content: "1"},
+		{Role: RoleFunction, Name: "a", ToolCallID: "call_1", Content: "1"},
+		{Role: RoleFunction, Name: "a", Content: "1"},
+	})
+	assert.Equal(t, "call_1", messages[0].ToolCalls[0].ID)
+	assert.Equal(t, "tool", messages[1].Role)
+	assert.Equal(t, "call_1", messages[1].ToolCallID)
+	assert.Equal(t, "tool", messages[2].Role)
+	assert.Equal(t, "call_1", messages[2].ToolCallID)
+	assert.Equal(t, "function", messages[3].Role)
+	assert.Empty(t, messages[3].ToolCallID)
+}
@@ -0,0 +1,62 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TranslateHistory rewrites a conversation produced by one provider so it can be sent to another.
+// Tool messages are repaired, tool call IDs are made unique and valid for every provider
+// (some providers return empty IDs or reuse the function name), provider payloads are
+// dropped and the result is sanitized for the target provider.
+func TranslateHistory(messages []Message, target LLMProvider) []Message {
+	messages = RepairToolMessages(messages)
+
+	translated := make([]Message, len(messages))
+	used := make(map[string]bool)
+	var renamed map[string][]string // Original ID to new IDs, in call order, for the current tool-call block
+
+	for i, msg := range messages {
+		msg.Raw = nil
+
+		if msg.Role == RoleAssistant && len(msg.ToolCalls) > 0 {
+			renamed = make(map[string][]string)
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			for j, call := range msg.ToolCalls {
+				id := sanitizeToolCallID(call.ID)
+				if id == "" || used[id] {
+					id = fmt.Sprintf("call_%d_%d", i, j)
+				}
+				used[id] = true
+				renamed[call.ID] = append(renamed[call.ID], id)
+				call.ID = id
+				call.Index = nil
+				calls[j] = call
+			}
+			msg.ToolCalls = calls
+		} else if isToolMessage(msg) && renamed != nil {
+			if ids := renamed[msg.ToolCallID]; len(ids) > 0 {
+				renamed[msg.ToolCallID] = ids[1:]
+				msg.ToolCallID = ids[0]
+			}
+		} else {
+			renamed = nil
+		}
+
+		translated[i] = msg
+	}
+
+	return SanitizeMessages(target, translated)
+}
+
+// sanitizeToolCallID replaces characters that are not accepted in tool call IDs by all providers
+func sanitizeToolCallID(id string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, id)
+}
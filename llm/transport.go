@@ -0,0 +1,248 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransportConfig tunes the HTTP connection pool shared by provider clients
+type TransportConfig struct {
+	MaxIdleConns          int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost   int           // Idle connections kept per host; Go's default of 2 throttles concurrent runs
+	MaxConnsPerHost       int           // Limit on connections per host, unlimited if 0
+	IdleConnTimeout       time.Duration // How long idle connections are kept
+	DialTimeout           time.Duration
+	KeepAlive             time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration // Time to wait for response headers, unlimited if 0
+	DisableHTTP2          bool
+}
+
+// DefaultTransportConfig returns a configuration suited to many concurrent requests to a few provider hosts
+func DefaultTransportConfig() TransportConfig {
+	return TransportConfig{
+		MaxIdleConns:        512,
+		MaxIdleConnsPerHost: 128,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         30 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// NewTransport creates an HTTP transport from the configuration
+func NewTransport(cfg TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   cfg.DialTimeout,
+		KeepAlive: cfg.KeepAlive,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     !cfg.DisableHTTP2,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// PoolStats reports activity of a PooledTransport
+type PoolStats struct {
+	Requests     int64 // Requests started
+	InFlight     int64 // Requests waiting for response headers
+	Errors       int64 // Requests that failed without a response
+	ConnsCreated int64 // Requests that had to open a new connection
+	ConnsReused  int64 // Requests served by a pooled connection
+}
+
+// PooledTransport is a RoundTripper that records connection pool metrics and whose
+// underlying transport can be reconfigured while in use
+type PooledTransport struct {
+	mu   sync.RWMutex
+	base *http.Transport
+
+	requests     atomic.Int64
+	inFlight     atomic.Int64
+	errors       atomic.Int64
+	connsCreated atomic.Int64
+	connsReused  atomic.Int64
+}
+
+// NewPooledTransport creates a pooled transport from the configuration
+func NewPooledTransport(cfg TransportConfig) *PooledTransport {
+	return &PooledTransport{base: NewTransport(cfg)}
+}
+
+// RoundTrip sends the request through the pool
+func (t *PooledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.RLock()
+	base := t.base
+	t.mu.RUnlock()
+
+	headers, hasHeaders := req.Context().Value(headersKey{}).(http.Header)
+	query, hasQuery := req.Context().Value(queryKey{}).(url.Values)
+	if hasHeaders || hasQuery {
+		req = req.Clone(req.Context())
+		for key, values := range headers {
+			req.Header[key] = values
+		}
+		if hasQuery {
+			params := req.URL.Query()
+			for key, values := range query {
+				params[key] = values
+			}
+			req.URL.RawQuery = params.Encode()
+		}
+	}
+
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				t.connsReused.Add(1)
+			} else {
+				t.connsCreated.Add(1)
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	t.requests.Add(1)
+	t.inFlight.Add(1)
+	resp, err := base.RoundTrip(req)
+	t.inFlight.Add(-1)
+	if err != nil {
+		t.errors.Add(1)
+	} else {
+		captureResponse(req, resp)
+	}
+	return resp, err
+}
+
+// CorrelationHeader carries the correlation ID of a request to the provider, which OpenAI
+// echoes in its logs and support tooling
+const CorrelationHeader = "X-Client-Request-Id"
+
+type headersKey struct{}
+
+// WithHeaders returns a context whose requests sent through SharedTransport carry the
+// headers, replacing those of the same name, along with the headers already added by the
+// context. This covers every provider client of this package except Gemini's.
+func WithHeaders(ctx context.Context, headers http.Header) context.Context {
+	merged := make(http.Header, len(headers))
+	if existing, ok := ctx.Value(headersKey{}).(http.Header); ok {
+		for key, values := range existing {
+			merged[key] = values
+		}
+	}
+	for key, values := range headers {
+		merged[http.CanonicalHeaderKey(key)] = values
+	}
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+type queryKey struct{}
+
+// WithQueryParams returns a context whose requests sent through SharedTransport carry the
+// query parameters, replacing those of the same name, along with the parameters already added
+// by the context
+func WithQueryParams(ctx context.Context, query url.Values) context.Context {
+	merged := make(url.Values, len(query))
+	if existing, ok := ctx.Value(queryKey{}).(url.Values); ok {
+		for key, values := range existing {
+			merged[key] = values
+		}
+	}
+	for key, values := range query {
+		merged[key] = values
+	}
+	return context.WithValue(ctx, queryKey{}, merged)
+}
+
+// RequestScope returns a key identifying what the context adds to the requests sent with it:
+// headers, query parameters, gateway metadata and extra body fields such as the service tier.
+// Requests are only interchangeable when their scopes match. It returns false if the context
+// captures the raw response, which only the caller making the request receives.
+func RequestScope(ctx context.Context) (string, bool) {
+	if _, ok := ctx.Value(rawCaptureKey{}).(*RawCapture); ok {
+		return "", false
+	}
+	scope := struct {
+		Headers  http.Header            `json:"headers,omitempty"`
+		Query    url.Values             `json:"query,omitempty"`
+		Metadata map[string]string      `json:"metadata,omitempty"`
+		Fields   map[string]interface{} `json:"fields,omitempty"`
+	}{}
+	scope.Headers, _ = ctx.Value(headersKey{}).(http.Header)
+	scope.Query, _ = ctx.Value(queryKey{}).(url.Values)
+	scope.Metadata, _ = ctx.Value(gatewayMetadataKey{}).(map[string]string)
+	scope.Fields, _ = ctx.Value(extraFieldsKey{}).(map[string]interface{})
+	// Maps are encoded with sorted keys, so equal scopes give equal keys
+	data, err := json.Marshal(scope)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Configure replaces the underlying transport. Requests in flight finish on the old
+// transport, whose idle connections are closed.
+func (t *PooledTransport) Configure(cfg TransportConfig) {
+	t.mu.Lock()
+	old := t.base
+	t.base = NewTransport(cfg)
+	t.mu.Unlock()
+	old.CloseIdleConnections()
+}
+
+// CloseIdleConnections closes pooled connections that are not in use
+func (t *PooledTransport) CloseIdleConnections() {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	t.base.CloseIdleConnections()
+}
+
+// Stats returns a snapshot of the pool metrics
+func (t *PooledTransport) Stats() PoolStats {
+	return PoolStats{
+		Requests:     t.requests.Load(),
+		InFlight:     t.inFlight.Load(),
+		Errors:       t.errors.Load(),
+		ConnsCreated: t.connsCreated.Load(),
+		ConnsReused:  t.connsReused.Load(),
+	}
+}
+
+var (
+	sharedOnce      sync.Once
+	sharedTransport *PooledTransport
+	sharedClient    *http.Client
+)
+
+// SharedTransport returns the pooled transport shared by all provider clients created by this
+// package, so concurrent agents reuse connections to the same provider. The Gemini client
+// talks gRPC and manages its own connections.
+func SharedTransport() *PooledTransport {
+	sharedOnce.Do(func() {
+		sharedTransport = NewPooledTransport(DefaultTransportConfig())
+		// No client timeout: streamed responses can legitimately stay open for minutes
+		sharedClient = &http.Client{Transport: sharedTransport}
+	})
+	return sharedTransport
+}
+
+// SharedHTTPClient returns the HTTP client backed by SharedTransport
+func SharedHTTPClient() *http.Client {
+	SharedTransport()
+	return sharedClient
+}
@@ -0,0 +1,68 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPooledTransportReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	transport := NewPooledTransport(DefaultTransportConfig())
+	client := &http.Client{Transport: transport}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if assert.NoError(t, err) {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}
+
+	stats := transport.Stats()
+	assert.Equal(t, int64(3), stats.Requests)
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, int64(1), stats.ConnsCreated)
+	assert.Equal(t, int64(2), stats.ConnsReused)
+
+	// Reconfiguring drops the old pool
+	transport.Configure(DefaultTransportConfig())
+	resp, err := client.Get(server.URL)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, int64(2), transport.Stats().ConnsCreated)
+}
+
+func TestWithHeaders(t *testing.T) {
+	var got http.Header
+	var gotQuery url.Values
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header
+		gotQuery = r.URL.Query()
+	}))
+	defer server.Close()
+
+	ctx := WithHeaders(context.Background(), http.Header{"x-gateway-key": {"secret"}, CorrelationHeader: {"run-1"}})
+	ctx = WithHeaders(ctx, http.Header{CorrelationHeader: {"run-1/turn-2"}})
+	ctx = WithQueryParams(ctx, url.Values{"region": {"eu"}})
+	req, err := http.NewRequestWithContext(ctx, "GET", server.URL+"?api-version=1", nil)
+	assert.NoError(t, err)
+	req.Header.Set("X-Gateway-Key", "default")
+	resp, err := SharedHTTPClient().Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+	}
+	assert.Equal(t, "secret", got.Get("X-Gateway-Key"))
+	assert.Equal(t, "run-1/turn-2", got.Get(CorrelationHeader))
+	assert.Equal(t, url.Values{"api-version": {"1"}, "region": {"eu"}}, gotQuery)
+	assert.Equal(t, "default", req.Header.Get("X-Gateway-Key"), "the caller's request is not modified")
+}
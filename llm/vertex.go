@@ -0,0 +1,92 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/sashabaranov/go-openai"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+// vertexScope is the OAuth scope Vertex AI requests are authorized with
+const vertexScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// VertexConfig configures a client for Google Vertex AI
+type VertexConfig struct {
+	Project  string // Google Cloud project ID; required
+	Location string // Region such as "europe-west4", or "global"; defaults to "us-central1"
+	Endpoint string // API host override, e.g. a Private Service Connect endpoint
+
+	// CredentialsJSON is a service account key. If empty, Application Default Credentials
+	// are used: GOOGLE_APPLICATION_CREDENTIALS, gcloud credentials or the metadata server.
+	CredentialsJSON []byte
+	// TokenSource supplies access tokens instead of CredentialsJSON or the default credentials
+	TokenSource oauth2.TokenSource
+}
+
+// BaseURL returns the URL of the project's OpenAI-compatible endpoint in the configured region
+func (c VertexConfig) BaseURL() string {
+	location := c.Location
+	if location == "" {
+		location = "us-central1"
+	}
+	host := c.Endpoint
+	if host == "" {
+		host = "https://" + location + "-aiplatform.googleapis.com"
+		if location == "global" {
+			host = "https://aiplatform.googleapis.com"
+		}
+	}
+	return fmt.Sprintf("%s/v1beta1/projects/%s/locations/%s/endpoints/openapi", host, c.Project, location)
+}
+
+// NewVertexLLM creates a client for Google Vertex AI, authorized with a service account or
+// Application Default Credentials rather than a Gemini API key. It serves Gemini models, such
+// as "google/gemini-2.0-flash", and partner models offered as a service, such as
+// "meta/llama-3.1-405b-instruct-maas", through Vertex AI's OpenAI-compatible endpoint.
+func NewVertexLLM(ctx context.Context, cfg VertexConfig) (*OpenAILLM, error) {
+	if cfg.Project == "" {
+		return nil, fmt.Errorf("vertex AI project is required")
+	}
+
+	tokens := cfg.TokenSource
+	if tokens == nil {
+		var creds *google.Credentials
+		var err error
+		if len(cfg.CredentialsJSON) > 0 {
+			creds, err = google.CredentialsFromJSON(ctx, cfg.CredentialsJSON, vertexScope)
+		} else {
+			creds, err = google.FindDefaultCredentials(ctx, vertexScope)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Google credentials: %w", err)
+		}
+		tokens = creds.TokenSource
+	}
+
+	config := openai.DefaultConfig("")
+	config.BaseURL = cfg.BaseURL()
+	config.HTTPClient = vertexAuthDoer{
+		doer:   extraFieldsDoer{doer: SharedHTTPClient()},
+		tokens: oauth2.ReuseTokenSource(nil, tokens),
+	}
+	return &OpenAILLM{client: openai.NewClientWithConfig(config)}, nil
+}
+
+// vertexAuthDoer authorizes requests with a fresh access token
+type vertexAuthDoer struct {
+	doer   openai.HTTPDoer
+	tokens oauth2.TokenSource
+}
+
+// Do sends the request with the current access token
+func (d vertexAuthDoer) Do(req *http.Request) (*http.Response, error) {
+	token, err := d.tokens.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get access token: %w", err)
+	}
+	req.Header.Set("Authorization", token.Type()+" "+token.AccessToken)
+	return d.doer.Do(req)
+}
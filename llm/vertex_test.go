@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/oauth2"
+)
+
+func TestVertexLLM(t *testing.T) {
+	var path, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, auth = r.URL.Path, r.Header.Get("Authorization")
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}]}`)
+	}))
+	defer server.Close()
+
+	client, err := NewVertexLLM(context.Background(), VertexConfig{
+		Project:     "my-project",
+		Location:    "europe-west4",
+		Endpoint:    server.URL,
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "ya29.token"}),
+	})
+	assert.NoError(t, err)
+	resp, err := client.CreateChatCompletion(context.Background(), ChatCompletionRequest{
+		Model:    "google/gemini-2.0-flash",
+		Messages: []Message{{Role: RoleUser, Content: "Hello"}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Hi", resp.Choices[0].Message.Content)
+	assert.Equal(t, "/v1beta1/projects/my-project/locations/europe-west4/endpoints/openapi/chat/completions", path)
+	assert.Equal(t, "Bearer ya29.token", auth)
+
+	assert.Equal(t, "https://aiplatform.googleapis.com/v1beta1/projects/p/locations/global/endpoints/openapi",
+		VertexConfig{Project: "p", Location: "global"}.BaseURL())
+}
@@ -0,0 +1,8 @@
+package llm
+
+const xaiBaseURL = "https://api.x.ai/v1"
+
+// NewXAILLM creates a client for xAI's Grok models, which are served over an OpenAI-compatible API
+func NewXAILLM(apiKey string) *OpenAILLM {
+	return NewOpenAILLMWithHost(apiKey, xaiBaseURL)
+}
@@ -0,0 +1,209 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrTooManyFailedChunks is returned when more chunks fail than a MapReduce tolerates
+var ErrTooManyFailedChunks = errors.New("too many failed chunks")
+
+// DefaultChunkSize is the chunk size, in characters, used when none is configured
+const DefaultChunkSize = 4000
+
+// ChunkResult is the mapper's output for one chunk
+type ChunkResult struct {
+	Index    int // Position of the chunk across all documents
+	Document int // Index of the document the chunk came from
+	Chunk    string
+	Output   string
+	Err      error
+}
+
+// MapReduceResult is the outcome of a MapReduce run
+type MapReduceResult struct {
+	Chunks         []ChunkResult // Map results in document and chunk order
+	Failed         int           // Number of chunks whose map step failed
+	Output         string        // The reducer's answer
+	ReduceResponse Response
+}
+
+// MapReduce splits documents into chunks, runs a mapper agent on every chunk concurrently and
+// combines the outputs, in their original order, with a reducer agent
+type MapReduce struct {
+	swarm        *Swarm
+	Mapper       *Agent
+	Reducer      *Agent
+	Task         string // What the mapper should do with each chunk and the reducer with the results
+	ChunkSize    int    // Maximum chunk size in characters
+	ChunkOverlap int    // Characters repeated between consecutive chunks
+	Concurrency  int    // Maximum number of chunks mapped at once, unlimited if not positive
+	Retries      int    // Extra attempts for a failed chunk
+	// MaxFailedChunks is how many chunks may fail before the run is aborted. Failed chunks are
+	// left out of the reduce step and the reducer is told which parts are missing.
+	MaxFailedChunks int
+	Options         RunOptions
+}
+
+// NewMapReduce creates a map-reduce orchestrator for the task
+func NewMapReduce(swarm *Swarm, mapper, reducer *Agent, task string) *MapReduce {
+	return &MapReduce{
+		swarm:     swarm,
+		Mapper:    mapper,
+		Reducer:   reducer,
+		Task:      task,
+		ChunkSize: DefaultChunkSize,
+	}
+}
+
+// WithChunking sets the chunk size and overlap
+func (m *MapReduce) WithChunking(size, overlap int) *MapReduce {
+	m.ChunkSize = size
+	m.ChunkOverlap = overlap
+	return m
+}
+
+// WithConcurrency limits how many chunks are mapped at once
+func (m *MapReduce) WithConcurrency(n int) *MapReduce {
+	m.Concurrency = n
+	return m
+}
+
+// WithMaxFailedChunks sets how many chunks may fail without aborting the run
+func (m *MapReduce) WithMaxFailedChunks(n int) *MapReduce {
+	m.MaxFailedChunks = n
+	return m
+}
+
+// Run maps every chunk of the documents and reduces the outputs
+func (m *MapReduce) Run(ctx context.Context, documents ...string) (MapReduceResult, error) {
+	var result MapReduceResult
+	for d, document := range documents {
+		for _, chunk := range ChunkText(document, m.ChunkSize, m.ChunkOverlap) {
+			result.Chunks = append(result.Chunks, ChunkResult{Index: len(result.Chunks), Document: d, Chunk: chunk})
+		}
+	}
+	if len(result.Chunks) == 0 {
+		return result, errors.New("no content to process")
+	}
+
+	m.mapChunks(ctx, result.Chunks)
+
+	var failures []error
+	for _, chunk := range result.Chunks {
+		if chunk.Err != nil {
+			failures = append(failures, fmt.Errorf("chunk %d: %w", chunk.Index, chunk.Err))
+		}
+	}
+	result.Failed = len(failures)
+	if result.Failed > m.MaxFailedChunks || result.Failed == len(result.Chunks) {
+		return result, fmt.Errorf("%w: %d of %d failed: %w", ErrTooManyFailedChunks, result.Failed, len(result.Chunks), errors.Join(failures...))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Task: %s\n\nCombine the following partial results, given in document order, into one answer.\n\n", m.Task)
+	for _, chunk := range result.Chunks {
+		if chunk.Err != nil {
+			fmt.Fprintf(&b, "[Part %d of document %d: missing, processing failed]\n\n", chunk.Index+1, chunk.Document+1)
+			continue
+		}
+		fmt.Fprintf(&b, "[Part %d of document %d]\n%s\n\n", chunk.Index+1, chunk.Document+1, chunk.Output)
+	}
+
+	response, err := m.swarm.RunWithOptions(ctx, m.Reducer, []llm.Message{{Role: llm.RoleUser, Content: b.String()}}, m.Options)
+	if err != nil {
+		return result, fmt.Errorf("reducer %s failed: %w", m.Reducer.Name, err)
+	}
+	result.ReduceResponse = response
+	result.Output = lastAssistantContent(response.Messages)
+	return result, nil
+}
+
+// mapChunks runs the mapper on every chunk, writing each output back to the chunk's slot
+func (m *MapReduce) mapChunks(ctx context.Context, chunks []ChunkResult) {
+	limit := m.Concurrency
+	if limit <= 0 {
+		limit = len(chunks)
+	}
+	slots := make(chan struct{}, limit)
+
+	var wg sync.WaitGroup
+	for i := range chunks {
+		wg.Add(1)
+		go func(chunk *ChunkResult) {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
+				chunk.Err = ctx.Err()
+				return
+			}
+
+			prompt := fmt.Sprintf("Task: %s\n\nApply the task to this part of a larger document:\n\n%s", m.Task, chunk.Chunk)
+			for attempt := 0; attempt <= m.Retries; attempt++ {
+				response, err := m.swarm.RunWithOptions(ctx, m.Mapper, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, m.Options)
+				if err == nil {
+					chunk.Output = lastAssistantContent(response.Messages)
+					chunk.Err = nil
+					return
+				}
+				chunk.Err = err
+				if ctx.Err() != nil {
+					return
+				}
+			}
+		}(&chunks[i])
+	}
+	wg.Wait()
+}
+
+// ChunkText splits text into chunks of at most size characters, preferring to break at
+// paragraph, line or word boundaries. Consecutive chunks share up to overlap characters.
+func ChunkText(text string, size, overlap int) []string {
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if size <= 0 {
+		size = DefaultChunkSize
+	}
+	if overlap < 0 || overlap >= size {
+		overlap = 0
+	}
+
+	runes := []rune(text)
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := start + size
+		if end >= len(runes) {
+			chunks = append(chunks, string(runes[start:]))
+			break
+		}
+		end = start + breakPoint(string(runes[start:end]))
+		chunks = append(chunks, string(runes[start:end]))
+
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakPoint returns the rune length of the window up to its last natural boundary
+func breakPoint(window string) int {
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		// Only break if it keeps at least half of the window
+		if i := strings.LastIndex(window, sep); i > 0 && utf8.RuneCountInString(window[:i]) >= utf8.RuneCountInString(window)/2 {
+			return utf8.RuneCountInString(window[:i+len(sep)])
+		}
+	}
+	return utf8.RuneCountInString(window)
+}
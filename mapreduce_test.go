@@ -0,0 +1,52 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestMapReduce tests chunk ordering and tolerating a failed chunk
+func TestMapReduce(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	lastMessage := func(contains string) interface{} {
+		return mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+			return strings.Contains(req.Messages[len(req.Messages)-1].Content, contains)
+		})
+	}
+	reply := func(content string) llm.ChatCompletionResponse {
+		return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}}}
+	}
+	mockClient.On("CreateChatCompletion", mock.Anything, lastMessage("Combine")).Return(reply("combined"), nil)
+	mockClient.On("CreateChatCompletion", mock.Anything, lastMessage("alpha")).Return(reply("A"), nil)
+	mockClient.On("CreateChatCompletion", mock.Anything, lastMessage("bravo")).Return(llm.ChatCompletionResponse{}, errors.New("boom"))
+	mockClient.On("CreateChatCompletion", mock.Anything, lastMessage("charlie")).Return(reply("C"), nil)
+
+	mapper := NewAgent("Mapper", "gpt-4", llm.OpenAI)
+	reducer := NewAgent("Reducer", "gpt-4", llm.OpenAI)
+	mr := NewMapReduce(sw, mapper, reducer, "Summarize").WithChunking(8, 0).WithMaxFailedChunks(1)
+
+	result, err := mr.Run(context.Background(), "alpha bravo", "charlie")
+	assert.NoError(t, err)
+	assert.Equal(t, "combined", result.Output)
+	assert.Equal(t, 1, result.Failed)
+	if assert.Len(t, result.Chunks, 3) {
+		assert.Equal(t, []string{"A", "", "C"}, []string{result.Chunks[0].Output, result.Chunks[1].Output, result.Chunks[2].Output})
+		assert.Equal(t, 1, result.Chunks[2].Document)
+	}
+
+	reduceReq := mockClient.Calls[len(mockClient.Calls)-1].Arguments.Get(1).(llm.ChatCompletionRequest)
+	prompt := reduceReq.Messages[len(reduceReq.Messages)-1].Content
+	assert.Less(t, strings.Index(prompt, "\nA\n"), strings.Index(prompt, "missing"))
+	assert.Less(t, strings.Index(prompt, "missing"), strings.Index(prompt, "\nC\n"))
+
+	_, err = mr.WithMaxFailedChunks(0).Run(context.Background(), "alpha bravo")
+	assert.ErrorIs(t, err, ErrTooManyFailedChunks)
+}
@@ -134,3 +134,90 @@ func (ms *MemoryStore) LoadMemories(data []byte) error {
 	ms.longTerm = loaded.LongTerm
 	return nil
 }
+
+// SerializeMemoriesEncrypted serializes all memories and encrypts the result with the cipher
+func (ms *MemoryStore) SerializeMemoriesEncrypted(c Cipher) ([]byte, error) {
+	data, err := ms.SerializeMemories()
+	if err != nil {
+		return nil, err
+	}
+	return c.Encrypt(data, []byte("memories"))
+}
+
+// LoadMemoriesEncrypted decrypts data produced by SerializeMemoriesEncrypted and loads the memories
+func (ms *MemoryStore) LoadMemoriesEncrypted(c Cipher, data []byte) error {
+	plaintext, err := c.Decrypt(data, []byte("memories"))
+	if err != nil {
+		return err
+	}
+	return ms.LoadMemories(plaintext)
+}
+
+// Snapshot returns a copy of the store that shares the existing memories. Memories added to
+// either store afterwards are not visible to the other.
+func (ms *MemoryStore) Snapshot() *MemoryStore {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	longTerm := make(map[string][]Memory, len(ms.longTerm))
+	for memoryType, memories := range ms.longTerm {
+		longTerm[memoryType] = memories[:len(memories):len(memories)]
+	}
+	return &MemoryStore{
+		shortTerm: ms.shortTerm[:len(ms.shortTerm):len(ms.shortTerm)],
+		longTerm:  longTerm,
+		maxShort:  ms.maxShort,
+	}
+}
+
+// RemoveSince removes the memories recorded at or after the given time
+func (ms *MemoryStore) RemoveSince(since time.Time) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	keep := func(memories []Memory) []Memory {
+		kept := make([]Memory, 0, len(memories))
+		for _, m := range memories {
+			if m.Timestamp.Before(since) {
+				kept = append(kept, m)
+			}
+		}
+		return kept
+	}
+
+	ms.shortTerm = keep(ms.shortTerm)
+	for memoryType, memories := range ms.longTerm {
+		ms.longTerm[memoryType] = keep(memories)
+	}
+}
+
+// RemoveWhere removes the memories matching the predicate and returns how many were removed
+func (ms *MemoryStore) RemoveWhere(match func(Memory) bool) int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	// New slices are built since snapshots may share the old ones
+	removed := 0
+	shortTerm := make([]Memory, 0, len(ms.shortTerm))
+	for _, m := range ms.shortTerm {
+		if !match(m) {
+			shortTerm = append(shortTerm, m)
+		} else if m.Type == "" {
+			// Typed memories are also kept long-term, where they are counted
+			removed++
+		}
+	}
+	ms.shortTerm = shortTerm
+	for memoryType, memories := range ms.longTerm {
+		kept := make([]Memory, 0, len(memories))
+		for _, m := range memories {
+			if !match(m) {
+				kept = append(kept, m)
+			} else {
+				removed++
+			}
+		}
+		ms.longTerm[memoryType] = kept
+	}
+	return removed
+}
@@ -0,0 +1,97 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+)
+
+// MessageID identifies a built-in message the swarm adds to conversations
+type MessageID string
+
+const (
+	MessageToolNotFound      MessageID = "tool_not_found"      // Tool call to an unknown tool; %s is the tool name
+	MessageToolNotAuthorized MessageID = "tool_not_authorized" // Tool call the caller may not make; %s is the tool name
+	MessageToolNotApproved   MessageID = "tool_not_approved"   // Tool call denied by the run's ToolApprover; %s is the tool name
+	MessageToolError         MessageID = "tool_error"          // Tool call that failed; %v is the error
+	MessageInvalidArguments  MessageID = "invalid_arguments"   // Tool call with invalid arguments; %v is the error
+	MessageDryRun            MessageID = "dry_run"             // Result of a tool skipped in a dry run; %s is the tool name
+	MessageContinue          MessageID = "continue"            // User message sent by Session.Continue
+	MessageInjectionNotice   MessageID = "injection_notice"    // System prompt notice of agents with an InjectionGuard
+	MessageArgumentTemplates MessageID = "argument_templates"  // System prompt notice of argument placeholders; %s lists them
+	MessageContinueTruncated MessageID = "continue_truncated"  // User message requesting the rest of a response cut off at the token limit
+	MessageInterrupted       MessageID = "interrupted"         // Note ending a response the user interrupted with Session.Interrupt
+	MessageGroupChat         MessageID = "group_chat"          // System prompt notice of group chat agents; %s is the agent's name, %s lists the others
+)
+
+// Messages is a catalog of built-in messages by ID. Each message is a fmt format string taking
+// the arguments documented on its ID. Messages missing from a catalog fall back to
+// DefaultMessages, so a catalog only needs the messages it translates.
+type Messages map[MessageID]string
+
+// DefaultMessages holds the English built-in messages
+var DefaultMessages = Messages{
+	MessageToolNotFound:      "Error: Tool %s not found.",
+	MessageToolNotAuthorized: "Error: Tool %s is not authorized.",
+	MessageToolNotApproved:   "Error: Tool %s was not approved.",
+	MessageToolError:         "Error: %v",
+	MessageInvalidArguments:  "Error: invalid arguments: %v",
+	MessageDryRun:            "[dry run] %s was not executed",
+	MessageContinue:          ContinuePrompt,
+	MessageInjectionNotice:   injectionNotice,
+	MessageArgumentTemplates: "When calling tools you can use these placeholders in arguments instead of actual values, which are not shown to you: %s.",
+	MessageContinueTruncated: "Your response was cut off. Continue exactly where it stopped, without repeating anything or adding a preamble. If it stopped inside a code block or JSON, continue inside it without reopening it.",
+	MessageInterrupted:       "[The user interrupted this response here. Address their new message, picking up the interrupted response only if it still matters.]",
+	MessageGroupChat:         "You are %s in a group chat with %s. Messages from the others start with the sender's name. Address people by name when it helps.",
+}
+
+// format renders the message with the given arguments
+func (m Messages) format(id MessageID, args ...interface{}) string {
+	format, ok := m[id]
+	if !ok {
+		format = DefaultMessages[id]
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// WithMessages replaces the swarm's built-in messages, such as tool errors and system prompt
+// notices, with those of the catalog, e.g. to localize them
+func (s *Swarm) WithMessages(messages Messages) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = messages
+	return s
+}
+
+type messagesKey struct{}
+
+// messageCatalog returns the swarm's message catalog
+func (s *Swarm) messageCatalog() Messages {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.messages == nil {
+		return DefaultMessages
+	}
+	return s.messages
+}
+
+// withMessages returns a context whose runs use the swarm's message catalog
+func (s *Swarm) withMessages(ctx context.Context) context.Context {
+	s.mu.Lock()
+	messages := s.messages
+	s.mu.Unlock()
+	if messages == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, messagesKey{}, messages)
+}
+
+// messagesFromContext returns the message catalog of the run the context belongs to
+func messagesFromContext(ctx context.Context) Messages {
+	if messages, ok := ctx.Value(messagesKey{}).(Messages); ok {
+		return messages
+	}
+	return DefaultMessages
+}
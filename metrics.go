@@ -0,0 +1,83 @@
+package swarmgo
+
+import (
+	"context"
+	"time"
+)
+
+// LatencyMetrics breaks down where the time of a turn, or of a whole run, was spent
+type LatencyMetrics struct {
+	Queue            time.Duration // Time the run waited for an ExecutionManager slot; counted on its first turn
+	TTFT             time.Duration // Time to the first streamed token, zero for requests that were not streamed
+	Provider         time.Duration // Time spent waiting for the provider
+	Tools            time.Duration // Time spent executing tool calls
+	CompletionTokens int           // Tokens generated by the provider
+}
+
+// TokensPerSecond returns the completion tokens generated per second of provider time
+func (m LatencyMetrics) TokensPerSecond() float64 {
+	if m.Provider <= 0 {
+		return 0
+	}
+	return float64(m.CompletionTokens) / m.Provider.Seconds()
+}
+
+// add sums two breakdowns, keeping the time to first token of the first
+func (m LatencyMetrics) add(o LatencyMetrics) LatencyMetrics {
+	ttft := m.TTFT
+	if ttft == 0 {
+		ttft = o.TTFT
+	}
+	return LatencyMetrics{
+		Queue:            m.Queue + o.Queue,
+		TTFT:             ttft,
+		Provider:         m.Provider + o.Provider,
+		Tools:            m.Tools + o.Tools,
+		CompletionTokens: m.CompletionTokens + o.CompletionTokens,
+	}
+}
+
+// MetricsExporter receives every completed turn with the ID of its run, e.g. to publish its
+// latency metrics to a monitoring system
+type MetricsExporter interface {
+	ExportTurn(runID string, turn Turn)
+}
+
+// MetricsExporterFunc adapts a function to the MetricsExporter interface
+type MetricsExporterFunc func(runID string, turn Turn)
+
+// ExportTurn calls f(runID, turn)
+func (f MetricsExporterFunc) ExportTurn(runID string, turn Turn) {
+	f(runID, turn)
+}
+
+// WithMetricsExporter sets the exporter completed turns are reported to
+func (s *Swarm) WithMetricsExporter(exporter MetricsExporter) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.metrics = exporter
+	return s
+}
+
+// exportTurn reports a completed turn to the metrics exporter, if one is set
+func (s *Swarm) exportTurn(ctx context.Context, turn Turn) {
+	s.mu.Lock()
+	exporter := s.metrics
+	s.mu.Unlock()
+	if exporter != nil {
+		exporter.ExportTurn(runIDFromContext(ctx), turn)
+	}
+}
+
+type queueLatencyKey struct{}
+
+// withQueueLatency returns a context whose run reports having waited d for a slot
+func withQueueLatency(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, queueLatencyKey{}, d)
+}
+
+// queueLatencyFromContext returns the time the run waited for a slot before starting
+func queueLatencyFromContext(ctx context.Context) time.Duration {
+	d, _ := ctx.Value(queueLatencyKey{}).(time.Duration)
+	return d
+}
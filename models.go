@@ -0,0 +1,79 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrModelNotAllowed is returned when an agent is asked to use a model it does not allow
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// WithModelAliases maps model aliases, such as "gpt-4o", to the models requests are sent to,
+// such as a dated snapshot. Aliases apply to the agent's model and to model overrides.
+func (a *Agent) WithModelAliases(aliases map[string]string) *Agent {
+	if a.ModelAliases == nil {
+		a.ModelAliases = make(map[string]string, len(aliases))
+	}
+	for alias, model := range aliases {
+		a.ModelAliases[alias] = model
+	}
+	return a
+}
+
+// WithAllowedModels restricts the models the agent may use. A trailing "*" matches a prefix.
+func (a *Agent) WithAllowedModels(models ...string) *Agent {
+	a.AllowedModels = append(a.AllowedModels, models...)
+	return a
+}
+
+// WithBlockedModels forbids the agent from using the models. A trailing "*" matches a prefix.
+func (a *Agent) WithBlockedModels(models ...string) *Agent {
+	a.BlockedModels = append(a.BlockedModels, models...)
+	return a
+}
+
+// PinModel sets the agent's model and allows no other, so overrides cannot change it
+func (a *Agent) PinModel(model string) *Agent {
+	a.Model = model
+	a.AllowedModels = []string{a.aliasedModel(model)}
+	return a
+}
+
+// aliasedModel returns the model an alias refers to, or the model itself
+func (a *Agent) aliasedModel(model string) string {
+	if resolved, ok := a.ModelAliases[model]; ok && resolved != "" {
+		return resolved
+	}
+	return model
+}
+
+// resolveModel returns the model a request of the agent is sent to, applying the override
+// and aliases, and checks it against the allowed and blocked models
+func (a *Agent) resolveModel(override string) (string, error) {
+	requested := a.Model
+	if override != "" {
+		requested = override
+	}
+	model := a.aliasedModel(requested)
+
+	for _, blocked := range a.BlockedModels {
+		if matchModel(blocked, model) || matchModel(blocked, requested) {
+			return "", fmt.Errorf("%w: %q is blocked for agent %s", ErrModelNotAllowed, requested, a.Name)
+		}
+	}
+	if len(a.AllowedModels) == 0 {
+		return model, nil
+	}
+	for _, allowed := range a.AllowedModels {
+		if matchModel(a.aliasedModel(allowed), model) {
+			return model, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %q is not allowed for agent %s", ErrModelNotAllowed, requested, a.Name)
+}
+
+// matchModel reports whether model matches the pattern, where a trailing "*" matches a prefix
+func matchModel(pattern, model string) bool {
+	return pattern == model || (strings.HasSuffix(pattern, "*") && strings.HasPrefix(model, strings.TrimSuffix(pattern, "*")))
+}
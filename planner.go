@@ -0,0 +1,256 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrInvalidPlan is returned when the planner does not produce a valid task list
+var ErrInvalidPlan = errors.New("invalid plan")
+
+// TaskFailedMarker starts an executor reply that reports the task could not be completed
+const TaskFailedMarker = "TASK FAILED:"
+
+// Context variable keys used to track plan progress during execution
+const (
+	PlanGoalKey        = "plan_goal"
+	PlanTasksKey       = "plan_tasks"
+	PlanCurrentTaskKey = "plan_current_task"
+	PlanCompletedKey   = "plan_completed"
+)
+
+// TaskStatus is the execution state of a planned task
+type TaskStatus string
+
+const (
+	TaskPending   TaskStatus = "pending"
+	TaskCompleted TaskStatus = "completed"
+	TaskFailed    TaskStatus = "failed"
+)
+
+// PlanTask is one step of a plan
+type PlanTask struct {
+	ID          int        `json:"id"`
+	Description string     `json:"description"`
+	Status      TaskStatus `json:"status"`
+	Result      string     `json:"result,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// PlanResult is the outcome of a PlannerExecutor run
+type PlanResult struct {
+	Tasks            []PlanTask // Final task list, including tasks from every replan
+	Replans          int        // Number of times the plan was revised after a failure
+	ContextVariables map[string]interface{}
+}
+
+// plannerPrompt asks the planner for a task list
+const plannerPrompt = `Break the goal below into a short ordered list of concrete tasks.
+Reply with only a JSON object of the form {"tasks": [{"description": "..."}]}.
+
+Goal: %s`
+
+// replannerPrompt asks the planner to revise the plan after a failure
+const replannerPrompt = `Break the goal below into a short ordered list of concrete tasks.
+Reply with only a JSON object of the form {"tasks": [{"description": "..."}]}.
+
+Goal: %s
+
+Some work has already been done:
+%s
+Task %q failed: %s
+
+Plan only the remaining tasks needed to reach the goal, working around the failure.`
+
+// PlannerExecutor has a planner agent break a goal into tasks that an executor agent then
+// completes one by one. Progress is kept in context variables, and the planner revises the
+// remaining plan whenever a task fails.
+type PlannerExecutor struct {
+	swarm       *Swarm
+	Planner     *Agent
+	Executor    *Agent
+	MaxTasks    int // Maximum number of tasks in a plan, unlimited if not positive
+	MaxReplans  int // Maximum number of replans before giving up
+	MaxAttempts int // Attempts the planner gets to produce a valid plan
+	Options     RunOptions
+}
+
+// NewPlannerExecutor creates a planner-executor from the two agents
+func NewPlannerExecutor(swarm *Swarm, planner, executor *Agent) *PlannerExecutor {
+	return &PlannerExecutor{
+		swarm:       swarm,
+		Planner:     planner,
+		Executor:    executor,
+		MaxReplans:  2,
+		MaxAttempts: 2,
+	}
+}
+
+// WithMaxReplans sets how many times the plan may be revised after failures
+func (p *PlannerExecutor) WithMaxReplans(n int) *PlannerExecutor {
+	p.MaxReplans = n
+	return p
+}
+
+// Run plans and executes the goal. The returned result holds the task list even when an error occurs.
+func (p *PlannerExecutor) Run(ctx context.Context, goal string) (PlanResult, error) {
+	result := PlanResult{ContextVariables: make(map[string]interface{})}
+	for k, v := range p.Options.ContextVariables {
+		result.ContextVariables[k] = v
+	}
+	result.ContextVariables[PlanGoalKey] = goal
+
+	tasks, err := p.plan(ctx, fmt.Sprintf(plannerPrompt, goal))
+	if err != nil {
+		return result, err
+	}
+	result.Tasks = tasks
+
+	completed := 0
+	for i := 0; i < len(result.Tasks); i++ {
+		task := &result.Tasks[i]
+		result.ContextVariables[PlanTasksKey] = append([]PlanTask{}, result.Tasks...)
+		result.ContextVariables[PlanCurrentTaskKey] = *task
+		result.ContextVariables[PlanCompletedKey] = completed
+
+		output, err := p.execute(ctx, task, result.ContextVariables)
+		if err == nil {
+			task.Status = TaskCompleted
+			task.Result = output
+			completed++
+			continue
+		}
+
+		task.Status = TaskFailed
+		task.Error = err.Error()
+		if result.Replans >= p.MaxReplans {
+			result.ContextVariables[PlanTasksKey] = append([]PlanTask{}, result.Tasks...)
+			return result, fmt.Errorf("task %d failed after %d replans: %w", task.ID, result.Replans, err)
+		}
+
+		// Replace the remaining tasks with a revised plan
+		revised, err := p.plan(ctx, fmt.Sprintf(replannerPrompt, goal, formatTasks(result.Tasks[:i]), task.Description, task.Error))
+		if err != nil {
+			return result, err
+		}
+		result.Replans++
+		for j := range revised {
+			revised[j].ID = i + 2 + j
+		}
+		result.Tasks = append(result.Tasks[:i+1], revised...)
+	}
+
+	result.ContextVariables[PlanTasksKey] = append([]PlanTask{}, result.Tasks...)
+	result.ContextVariables[PlanCompletedKey] = completed
+	delete(result.ContextVariables, PlanCurrentTaskKey)
+	return result, nil
+}
+
+// plan asks the planner for a task list, retrying with the validation error if it is invalid
+func (p *PlannerExecutor) plan(ctx context.Context, prompt string) ([]PlanTask, error) {
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
+	}
+
+	messages := []llm.Message{{Role: llm.RoleUser, Content: prompt}}
+	var lastErr error
+	for attempt := 0; attempt < attempts; attempt++ {
+		opts := p.Options
+		opts.ContextVariables = nil
+		response, err := p.swarm.RunWithOptions(ctx, p.Planner, messages, opts)
+		if err != nil {
+			return nil, fmt.Errorf("planner %s failed: %w", p.Planner.Name, err)
+		}
+
+		reply := lastAssistantContent(response.Messages)
+		tasks, err := p.parsePlan(reply)
+		if err == nil {
+			return tasks, nil
+		}
+		lastErr = err
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, Content: reply},
+			llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf("That plan is invalid: %v. Reply with only the corrected JSON object.", err)},
+		)
+	}
+	return nil, lastErr
+}
+
+// parsePlan parses and validates the planner's reply
+func (p *PlannerExecutor) parsePlan(reply string) ([]PlanTask, error) {
+	var plan struct {
+		Tasks []struct {
+			Description string `json:"description"`
+		} `json:"tasks"`
+	}
+	if err := decodeJSON(extractJSONObject(reply), &plan); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPlan, err)
+	}
+	if len(plan.Tasks) == 0 {
+		return nil, fmt.Errorf("%w: no tasks", ErrInvalidPlan)
+	}
+	if p.MaxTasks > 0 && len(plan.Tasks) > p.MaxTasks {
+		return nil, fmt.Errorf("%w: %d tasks exceeds the limit of %d", ErrInvalidPlan, len(plan.Tasks), p.MaxTasks)
+	}
+
+	tasks := make([]PlanTask, len(plan.Tasks))
+	for i, t := range plan.Tasks {
+		description := strings.TrimSpace(t.Description)
+		if description == "" {
+			return nil, fmt.Errorf("%w: task %d has no description", ErrInvalidPlan, i+1)
+		}
+		tasks[i] = PlanTask{ID: i + 1, Description: description, Status: TaskPending}
+	}
+	return tasks, nil
+}
+
+// execute runs the executor on a single task and returns its output
+func (p *PlannerExecutor) execute(ctx context.Context, task *PlanTask, contextVariables map[string]interface{}) (string, error) {
+	prompt := fmt.Sprintf("Goal: %s\n\nComplete task %d: %s\n\nIf the task cannot be completed, reply with %q followed by the reason.",
+		contextVariables[PlanGoalKey], task.ID, task.Description, TaskFailedMarker)
+
+	opts := p.Options
+	opts.ContextVariables = contextVariables
+	response, err := p.swarm.RunWithOptions(ctx, p.Executor, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, opts)
+	if err != nil {
+		return "", err
+	}
+	// Keep variables the executor's tools set for later tasks
+	for k, v := range response.ContextVariables {
+		contextVariables[k] = v
+	}
+
+	output := lastAssistantContent(response.Messages)
+	if reason, failed := strings.CutPrefix(strings.TrimSpace(output), TaskFailedMarker); failed {
+		return "", errors.New(strings.TrimSpace(reason))
+	}
+	return output, nil
+}
+
+// formatTasks renders tasks and their outcomes for prompts
+func formatTasks(tasks []PlanTask) string {
+	var b strings.Builder
+	for _, task := range tasks {
+		fmt.Fprintf(&b, "%d. [%s] %s", task.ID, task.Status, task.Description)
+		if task.Result != "" {
+			fmt.Fprintf(&b, " -> %s", task.Result)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// extractJSONObject returns the outermost JSON object in text, such as a reply wrapped in a code fence
+func extractJSONObject(text string) string {
+	start := strings.Index(text, "{")
+	end := strings.LastIndex(text, "}")
+	if start < 0 || end < start {
+		return text
+	}
+	return text[start : end+1]
+}
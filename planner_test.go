@@ -0,0 +1,47 @@
+package swarmgo
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestPlannerExecutorReplansOnFailure tests plan validation, progress tracking and replanning
+func TestPlannerExecutorReplansOnFailure(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	reply := func(content string) llm.ChatCompletionResponse {
+		return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}}}
+	}
+	from := func(instructions string) interface{} {
+		return mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+			return len(req.Messages) > 0 && req.Messages[0].Content == instructions
+		})
+	}
+
+	// The first plan is invalid, the second valid; the replan replaces the failed task
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Plan.")).Return(reply(`{"tasks": []}`), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Plan.")).Return(reply("```json\n{\"tasks\": [{\"description\": \"book flight\"}, {\"description\": \"book hotel\"}]}\n```"), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Plan.")).Return(reply(`{"tasks": [{"description": "book hostel"}]}`), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Execute.")).Return(reply("Flight booked"), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Execute.")).Return(reply("TASK FAILED: no rooms left"), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, from("Execute.")).Return(reply("Hostel booked"), nil).Once()
+
+	planner := NewAgent("Planner", "gpt-4", llm.OpenAI).WithInstructions("Plan.")
+	executor := NewAgent("Executor", "gpt-4", llm.OpenAI).WithInstructions("Execute.")
+
+	result, err := NewPlannerExecutor(sw, planner, executor).Run(context.Background(), "Plan a trip")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Replans)
+	assert.Equal(t, []PlanTask{
+		{ID: 1, Description: "book flight", Status: TaskCompleted, Result: "Flight booked"},
+		{ID: 2, Description: "book hotel", Status: TaskFailed, Error: "no rooms left"},
+		{ID: 3, Description: "book hostel", Status: TaskCompleted, Result: "Hostel booked"},
+	}, result.Tasks)
+	assert.Equal(t, 2, result.ContextVariables[PlanCompletedKey])
+	mockClient.AssertExpectations(t)
+}
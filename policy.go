@@ -0,0 +1,127 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrPolicyViolation is returned when a request would break the configured data policy
+var ErrPolicyViolation = errors.New("data policy violation")
+
+// DataPolicy restricts where conversation data may be sent. Empty lists allow everything.
+type DataPolicy struct {
+	AllowedProviders []llm.LLMProvider // Providers requests may be sent to
+	AllowedEndpoints []string          // Custom API hosts requests may be sent to; the provider's default endpoint is always allowed
+	AllowedModels    []string          // Models that may be used; a trailing "*" matches a prefix
+	// AllowedContextKeys lists the context variables whose values may be rendered into the
+	// agent's instructions, the only place the swarm itself sends them to the provider. It does
+	// not restrict the variables tools receive, what tools return, the keys named in audit
+	// records or session snapshots, which stay under the application's control. Nil exposes all
+	// keys; an empty, non-nil list exposes none.
+	AllowedContextKeys []string
+}
+
+// WithPolicy sets the data policy enforced before every request of the swarm
+func (s *Swarm) WithPolicy(policy *DataPolicy) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = policy
+	return s
+}
+
+// WithPolicy sets a data policy for the agent, enforced in addition to the swarm's policy
+func (a *Agent) WithPolicy(policy *DataPolicy) *Agent {
+	a.Policy = policy
+	return a
+}
+
+// check reports whether a request to the provider, endpoint and model is allowed
+func (p *DataPolicy) check(provider llm.LLMProvider, endpoint, model string) error {
+	if p == nil {
+		return nil
+	}
+
+	if len(p.AllowedProviders) > 0 {
+		allowed := false
+		for _, ap := range p.AllowedProviders {
+			if ap == provider {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: provider %q is not allowed", ErrPolicyViolation, provider)
+		}
+	}
+
+	if !p.allowsEndpoint(endpoint) {
+		return fmt.Errorf("%w: endpoint %q is not allowed", ErrPolicyViolation, endpoint)
+	}
+
+	if len(p.AllowedModels) > 0 {
+		allowed := false
+		for _, am := range p.AllowedModels {
+			if matchModel(am, model) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: model %q is not allowed", ErrPolicyViolation, model)
+		}
+	}
+
+	return nil
+}
+
+// allowsEndpoint reports whether requests may be sent to the API host
+func (p *DataPolicy) allowsEndpoint(endpoint string) bool {
+	if p == nil || endpoint == "" || len(p.AllowedEndpoints) == 0 {
+		return true
+	}
+	for _, ae := range p.AllowedEndpoints {
+		if strings.TrimSuffix(ae, "/") == strings.TrimSuffix(endpoint, "/") {
+			return true
+		}
+	}
+	return false
+}
+
+// filterContext returns the context variables that may be rendered into instructions
+func (p *DataPolicy) filterContext(contextVariables map[string]interface{}) map[string]interface{} {
+	if p == nil || p.AllowedContextKeys == nil {
+		return contextVariables
+	}
+	filtered := make(map[string]interface{}, len(p.AllowedContextKeys))
+	for _, key := range p.AllowedContextKeys {
+		if value, ok := contextVariables[key]; ok {
+			filtered[key] = value
+		}
+	}
+	return filtered
+}
+
+// enforcePolicy checks the swarm's and the agent's policies for a request to the given model
+// and returns the context variables that may be rendered into its instructions
+func (s *Swarm) enforcePolicy(agent *Agent, model string, contextVariables map[string]interface{}) (map[string]interface{}, error) {
+	return s.enforcePolicyAt(agent, s.provider, s.endpoint, model, contextVariables)
+}
+
+// enforcePolicyAt is enforcePolicy for a request sent to another provider or endpoint than the
+// swarm's
+func (s *Swarm) enforcePolicyAt(agent *Agent, provider llm.LLMProvider, endpoint, model string, contextVariables map[string]interface{}) (map[string]interface{}, error) {
+	s.mu.Lock()
+	policy := s.policy
+	s.mu.Unlock()
+
+	for _, p := range []*DataPolicy{policy, agent.Policy} {
+		if err := p.check(provider, endpoint, model); err != nil {
+			return nil, err
+		}
+		contextVariables = p.filterContext(contextVariables)
+	}
+	return contextVariables, nil
+}
@@ -0,0 +1,145 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// PredictedToolCall is a tool call a predictor expects the model to request
+type PredictedToolCall struct {
+	Name      string
+	Arguments map[string]interface{}
+}
+
+// ToolPredictor guesses which tool calls the model is about to request so they can run while
+// the model is still generating. Only predict tools that are safe to run speculatively, such
+// as reads: predicted calls execute even if the model never requests them.
+type ToolPredictor func(history []llm.Message, contextVariables map[string]interface{}) []PredictedToolCall
+
+// WithToolPredictors prefetches the tool calls predicted before each model request
+func (a *Agent) WithToolPredictors(predictors ...ToolPredictor) *Agent {
+	a.ToolPredictors = append(a.ToolPredictors, predictors...)
+	return a
+}
+
+// prefetchEntry is a speculative tool execution
+type prefetchEntry struct {
+	done   chan struct{}
+	result Result
+}
+
+// prefetcher holds the speculative tool executions started for one model request
+type prefetcher struct {
+	mu      sync.Mutex
+	entries map[string]*prefetchEntry
+}
+
+// startPrefetch runs the calls predicted by the agent's predictors in the background. Each call
+// gets a copy of the context variables, so changes made by prefetched tools are not kept.
+// Tools needing approval are never prefetched, nor is anything in runs skipping tools.
+// It returns nil if nothing was predicted.
+func (s *Swarm) startPrefetch(ctx context.Context, agent *Agent, history []llm.Message, contextVariables map[string]interface{}) *prefetcher {
+	if len(agent.ToolPredictors) == 0 {
+		return nil
+	}
+	if skip, _ := ctx.Value(skipToolsKey{}).(bool); skip {
+		return nil
+	}
+
+	functions := make(map[string]AgentFunction[map[string]interface{}])
+	for _, af := range agent.availableFunctions(ctx) {
+		functions[af.Name] = af
+	}
+
+	p := &prefetcher{entries: make(map[string]*prefetchEntry)}
+	for _, predictor := range agent.ToolPredictors {
+		for _, call := range predictor(history, contextVariables) {
+			fn, ok := functions[call.Name]
+			if !ok || fn.NeedsApproval {
+				continue
+			}
+			args, key, ok := prefetchKey(call.Name, call.Arguments)
+			if !ok || p.entries[key] != nil {
+				continue
+			}
+
+			snapshot := make(map[string]interface{}, len(contextVariables))
+			for k, v := range contextVariables {
+				snapshot[k] = v
+			}
+			entry := &prefetchEntry{done: make(chan struct{})}
+			p.entries[key] = entry
+			go func() {
+				defer close(entry.done)
+				entry.result = executeFunction(ctx, &fn, args, snapshot, false)
+			}()
+		}
+	}
+	if len(p.entries) == 0 {
+		return nil
+	}
+	return p
+}
+
+// has reports whether the call was prefetched and its result not yet served
+func (p *prefetcher) has(name string, args map[string]interface{}) bool {
+	if p == nil {
+		return false
+	}
+	_, key, ok := prefetchKey(name, args)
+	if !ok {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.entries[key] != nil
+}
+
+// take returns the prefetched result of the call, waiting for it to finish if necessary.
+// Each prefetched result is served at most once.
+func (p *prefetcher) take(ctx context.Context, name string, args map[string]interface{}) (Result, bool) {
+	if p == nil {
+		return Result{}, false
+	}
+	_, key, ok := prefetchKey(name, args)
+	if !ok {
+		return Result{}, false
+	}
+
+	p.mu.Lock()
+	entry := p.entries[key]
+	delete(p.entries, key)
+	p.mu.Unlock()
+	if entry == nil {
+		return Result{}, false
+	}
+
+	select {
+	case <-entry.done:
+		return entry.result, true
+	case <-ctx.Done():
+		return Result{}, false
+	}
+}
+
+// prefetchKey normalizes the arguments through JSON, as the model's arguments would be, and
+// returns them with a key identifying the call
+func prefetchKey(name string, args map[string]interface{}) (map[string]interface{}, string, bool) {
+	data, err := json.Marshal(args)
+	if err != nil {
+		return nil, "", false
+	}
+	var normalized map[string]interface{}
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, "", false
+	}
+	// Marshaling a map sorts its keys, so equal arguments produce equal keys
+	canonical, err := json.Marshal(normalized)
+	if err != nil {
+		return nil, "", false
+	}
+	return normalized, name + "\x00" + string(canonical), true
+}
@@ -0,0 +1,53 @@
+package swarmgo
+
+import "context"
+
+// progressReporterVar is the context variable holding the progress reporter while a tool runs
+const progressReporterVar = "__progress_reporter"
+
+// ToolProgress is a progress update reported by a running tool
+type ToolProgress struct {
+	ToolCallID string  `json:"tool_call_id"`
+	ToolName   string  `json:"tool_name"`
+	Percent    float64 `json:"percent"`          // Completion from 0 to 100
+	Status     string  `json:"status,omitempty"` // Human-readable description of the current step
+}
+
+// ToolProgressHandler can be implemented by a StreamHandler to receive progress updates
+// reported by tools while they run
+type ToolProgressHandler interface {
+	OnToolProgress(progress ToolProgress)
+}
+
+// ReportProgress reports the progress of the running tool. Tools call it with the context
+// variables they were given; it does nothing if no one is listening.
+func ReportProgress(contextVariables map[string]interface{}, percent float64, status string) {
+	if report, ok := contextVariables[progressReporterVar].(func(float64, string)); ok {
+		report(percent, status)
+	}
+}
+
+type progressListenerKey struct{}
+
+// withProgressListener returns a context whose tool calls report progress to the listener
+func withProgressListener(ctx context.Context, listener func(ToolProgress)) context.Context {
+	if listener == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressListenerKey{}, listener)
+}
+
+// executeWithProgress runs a tool call, passing progress it reports to the listener of the
+// context, if any
+func executeWithProgress(ctx context.Context, fn *AgentFunction[map[string]interface{}], toolCallID string, args map[string]interface{}, contextVariables map[string]interface{}, debug bool) Result {
+	listener, ok := ctx.Value(progressListenerKey{}).(func(ToolProgress))
+	if !ok {
+		return executeFunction(ctx, fn, args, contextVariables, debug)
+	}
+
+	contextVariables[progressReporterVar] = func(percent float64, status string) {
+		listener(ToolProgress{ToolCallID: toolCallID, ToolName: fn.Name, Percent: percent, Status: status})
+	}
+	defer delete(contextVariables, progressReporterVar)
+	return executeFunction(ctx, fn, args, contextVariables, debug)
+}
@@ -0,0 +1,217 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultCompressionRatio is the fraction of sentences prompt compression keeps
+const DefaultCompressionRatio = 0.5
+
+// DefaultCompressionKeepRecent is the number of recent messages prompt compression leaves as is
+const DefaultCompressionKeepRecent = 4
+
+// DefaultCompressionMinChars is the length below which messages are not compressed
+const DefaultCompressionMinChars = 400
+
+// Compressor shortens a text to about ratio of its length, keeping what matters most for the
+// query, which is the latest user message
+type Compressor func(text, query string, ratio float64) string
+
+// PromptCompression configures the compression of prompts before they are sent, to cut the
+// cost of long conversations. Compression is lossy: it drops the sentences of old messages that
+// look least informative. The estimated tokens before and after are reported in
+// llm.Usage.OriginalTokens and llm.Usage.CompressedTokens.
+type PromptCompression struct {
+	Ratio      float64 // Fraction of sentences kept, DefaultCompressionRatio if zero
+	KeepRecent int     // Recent messages left as is, DefaultCompressionKeepRecent if zero
+	MinChars   int     // Messages shorter than this are left as is, DefaultCompressionMinChars if zero
+	// RetrievedContext also compresses the recent tool results, such as retrieved documents,
+	// which are otherwise left as is with the other recent messages
+	RetrievedContext bool
+	// Compress shortens the messages, CompressText if nil
+	Compress Compressor
+}
+
+// WithPromptCompression compresses the old messages of every request before it is sent
+func (s *Swarm) WithPromptCompression(compression PromptCompression) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.compression = &compression
+	return s
+}
+
+// compressPrompt returns the messages with the old messages and retrieved context compressed,
+// and the usage recording the estimated tokens before and after. The system message and the
+// messages that are not compressed are returned as is.
+func (s *Swarm) compressPrompt(messages []llm.Message) ([]llm.Message, llm.Usage) {
+	s.mu.Lock()
+	compression := s.compression
+	s.mu.Unlock()
+	if compression == nil {
+		return messages, llm.Usage{}
+	}
+
+	ratio := compression.Ratio
+	if ratio <= 0 || ratio > 1 {
+		ratio = DefaultCompressionRatio
+	}
+	keep := compression.KeepRecent
+	if keep <= 0 {
+		keep = DefaultCompressionKeepRecent
+	}
+	minChars := compression.MinChars
+	if minChars <= 0 {
+		minChars = DefaultCompressionMinChars
+	}
+	compress := compression.Compress
+	if compress == nil {
+		compress = CompressText
+	}
+
+	var query string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == llm.RoleUser {
+			query = messages[i].Content
+			break
+		}
+	}
+
+	var usage llm.Usage
+	var compressed []llm.Message
+	for i, msg := range messages {
+		toolResult := msg.Role == llm.RoleTool || msg.Role == llm.RoleFunction
+		recent := i >= len(messages)-keep
+		if msg.Role == llm.RoleSystem || len(msg.Content) < minChars || (recent && !(toolResult && compression.RetrievedContext)) || json.Valid([]byte(msg.Content)) {
+			continue
+		}
+		content := compress(msg.Content, query, ratio)
+		if len(content) >= len(msg.Content) {
+			continue
+		}
+		if compressed == nil {
+			compressed = append([]llm.Message(nil), messages...)
+		}
+		compressed[i].Content = content
+		usage.OriginalTokens += estimateTokens(msg.Content)
+		usage.CompressedTokens += estimateTokens(content)
+	}
+	if compressed == nil {
+		return messages, usage
+	}
+	return compressed, usage
+}
+
+// estimateTokens estimates the number of tokens of a text at four characters per token
+func estimateTokens(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// compressionStopwords are words that carry little information on their own
+var compressionStopwords = map[string]bool{
+	"a": true, "an": true, "the": true, "and": true, "or": true, "but": true, "of": true,
+	"to": true, "in": true, "on": true, "at": true, "for": true, "with": true, "by": true,
+	"is": true, "are": true, "was": true, "were": true, "be": true, "been": true, "it": true,
+	"this": true, "that": true, "as": true, "from": true, "so": true, "i": true, "you": true,
+	"we": true, "they": true, "he": true, "she": true, "do": true, "does": true, "did": true,
+	"have": true, "has": true, "had": true, "not": true, "if": true, "then": true, "there": true,
+}
+
+// CompressText is an extractive compressor: it keeps the ratio of sentences with the most
+// informative words, favouring words of the query, and drops the rest. The first sentence and
+// the kept sentences' order are preserved.
+func CompressText(text, query string, ratio float64) string {
+	sentences := splitSentences(text)
+	keep := int(float64(len(sentences))*ratio + 0.5)
+	if keep < 1 {
+		keep = 1
+	}
+	if keep >= len(sentences) {
+		return text
+	}
+
+	queryWords := make(map[string]bool)
+	for _, word := range compressionWords(query) {
+		queryWords[word] = true
+	}
+	// Words repeated across sentences carry the topic; rare words carry the details
+	frequency := make(map[string]int)
+	for _, sentence := range sentences {
+		seen := make(map[string]bool)
+		for _, word := range compressionWords(sentence) {
+			if !seen[word] {
+				frequency[word]++
+				seen[word] = true
+			}
+		}
+	}
+
+	scores := make([]float64, len(sentences))
+	for i, sentence := range sentences {
+		words := compressionWords(sentence)
+		if len(words) == 0 {
+			continue
+		}
+		var score float64
+		for _, word := range words {
+			score += 1 / float64(frequency[word])
+			if queryWords[word] {
+				score += 2
+			}
+		}
+		scores[i] = score / float64(len(strings.Fields(sentence)))
+	}
+	scores[0] += 1 // The first sentence usually introduces the text
+
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return scores[order[a]] > scores[order[b]] })
+	kept := order[:keep]
+	sort.Ints(kept)
+
+	parts := make([]string, len(kept))
+	for i, index := range kept {
+		parts[i] = sentences[index]
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitSentences splits a text into sentences at sentence punctuation and line breaks
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	runes := []rune(text)
+	for i, r := range runes {
+		end := r == '\n' || ((r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if !end {
+			continue
+		}
+		if sentence := strings.TrimSpace(string(runes[start : i+1])); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = i + 1
+	}
+	if sentence := strings.TrimSpace(string(runes[start:])); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// compressionWords returns the lowercased words of a text that are not stopwords
+func compressionWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if !compressionStopwords[word] {
+			words = append(words, word)
+		}
+	}
+	return words
+}
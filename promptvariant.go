@@ -0,0 +1,145 @@
+package swarmgo
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+)
+
+// PromptVariant is an alternative set of instructions for an agent, served to a share of runs
+// so prompt changes can be compared before they replace the current instructions
+type PromptVariant struct {
+	Name             string
+	Instructions     string
+	InstructionsFunc func(contextVariables map[string]interface{}) string // Takes precedence over Instructions
+	// Weight is the variant's share of traffic relative to the other variants. If no variant
+	// has a positive weight, traffic is split equally.
+	Weight float64
+}
+
+// WithPromptVariants registers instruction variants for the agent. Each run serves one variant
+// in place of the agent's own instructions; skills and other additions still apply.
+func (a *Agent) WithPromptVariants(variants ...PromptVariant) *Agent {
+	a.PromptVariants = append(a.PromptVariants, variants...)
+	return a
+}
+
+// selectVariant picks a variant by weight. A non-empty key always selects the same variant,
+// keeping a session in one arm of the experiment; otherwise the choice is random.
+func (a *Agent) selectVariant(key string) *PromptVariant {
+	if len(a.PromptVariants) == 0 {
+		return nil
+	}
+
+	var total float64
+	for _, v := range a.PromptVariants {
+		if v.Weight > 0 {
+			total += v.Weight
+		}
+	}
+	weight := func(v PromptVariant) float64 {
+		if total == 0 {
+			return 1
+		}
+		return max(v.Weight, 0)
+	}
+	if total == 0 {
+		total = float64(len(a.PromptVariants))
+	}
+
+	var point float64
+	if key != "" {
+		point = stableFraction(a.Name+"/"+key) * total
+	} else {
+		point = rand.Float64() * total
+	}
+
+	for i := range a.PromptVariants {
+		point -= weight(a.PromptVariants[i])
+		if point < 0 {
+			return &a.PromptVariants[i]
+		}
+	}
+	return &a.PromptVariants[len(a.PromptVariants)-1]
+}
+
+// variantAssignments records the variant each agent serves for the rest of a run
+type variantAssignments struct {
+	mu       sync.Mutex
+	forced   string // Variant name requested by the caller
+	key      string // Experiment key for sticky assignment
+	assigned map[*Agent]*PromptVariant
+}
+
+type variantAssignmentsKey struct{}
+
+// withVariantAssignments returns a context that assigns prompt variants for a run, reusing
+// the assignments of an enclosing run
+func withVariantAssignments(ctx context.Context, forced, key string) (context.Context, *variantAssignments) {
+	if va, ok := ctx.Value(variantAssignmentsKey{}).(*variantAssignments); ok {
+		return ctx, va
+	}
+	va := &variantAssignments{forced: forced, key: key, assigned: make(map[*Agent]*PromptVariant)}
+	return context.WithValue(ctx, variantAssignmentsKey{}, va), va
+}
+
+// promptVariantFor returns the variant the agent serves in the run the context belongs to
+func promptVariantFor(ctx context.Context, agent *Agent) *PromptVariant {
+	if len(agent.PromptVariants) == 0 {
+		return nil
+	}
+	va, ok := ctx.Value(variantAssignmentsKey{}).(*variantAssignments)
+	if !ok {
+		return agent.selectVariant("")
+	}
+	return va.assign(agent)
+}
+
+// assign returns the agent's variant, selecting it on first use
+func (va *variantAssignments) assign(agent *Agent) *PromptVariant {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	if v, ok := va.assigned[agent]; ok {
+		return v
+	}
+
+	var variant *PromptVariant
+	for i := range agent.PromptVariants {
+		if va.forced != "" && agent.PromptVariants[i].Name == va.forced {
+			variant = &agent.PromptVariants[i]
+			break
+		}
+	}
+	if variant == nil {
+		variant = agent.selectVariant(va.key)
+	}
+	va.assigned[agent] = variant
+	return variant
+}
+
+// name returns the name of the variant assigned to the agent, if any
+func (va *variantAssignments) name(agent *Agent) string {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	if v := va.assigned[agent]; v != nil {
+		return v.Name
+	}
+	return ""
+}
+
+// byAgent returns the served variants keyed by agent name
+func (va *variantAssignments) byAgent() map[string]string {
+	va.mu.Lock()
+	defer va.mu.Unlock()
+	var served map[string]string
+	for agent, v := range va.assigned {
+		if v == nil {
+			continue
+		}
+		if served == nil {
+			served = make(map[string]string)
+		}
+		served[agent.Name] = v.Name
+	}
+	return served
+}
@@ -0,0 +1,209 @@
+package swarmgo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// RunPriority determines the order in which queued runs are started
+type RunPriority int
+
+const (
+	PriorityLow RunPriority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// ExecutionManagerConfig holds the limits enforced by an ExecutionManager
+type ExecutionManagerConfig struct {
+	MaxConcurrent  int                     // Maximum number of runs in flight across all providers (0 means unlimited)
+	ProviderLimits map[llm.LLMProvider]int // Maximum number of runs in flight per provider (missing or 0 means unlimited)
+}
+
+// queuedRun represents a run waiting for a free execution slot
+type queuedRun struct {
+	provider llm.LLMProvider
+	priority RunPriority
+	seq      uint64
+	ready    chan struct{}
+}
+
+// ExecutionManager queues runs and starts them according to concurrency limits and priorities
+type ExecutionManager struct {
+	swarm   *Swarm
+	config  ExecutionManagerConfig
+	mu      sync.Mutex
+	queue   []*queuedRun
+	running map[llm.LLMProvider]int
+	total   int
+	seq     uint64
+}
+
+// NewExecutionManager creates a new ExecutionManager for the given swarm
+func NewExecutionManager(swarm *Swarm, config ExecutionManagerConfig) *ExecutionManager {
+	return &ExecutionManager{
+		swarm:   swarm,
+		config:  config,
+		running: make(map[llm.LLMProvider]int),
+	}
+}
+
+// Start queues a run and blocks until it has been executed or the context is cancelled while waiting
+func (em *ExecutionManager) Start(ctx context.Context, config AgentConfig, priority RunPriority) (Response, error) {
+	provider := em.providerFor(config.Agent)
+	queued := time.Now()
+	item := em.enqueue(provider, priority)
+
+	select {
+	case <-item.ready:
+	case <-ctx.Done():
+		if em.remove(item) {
+			return Response{}, ctx.Err()
+		}
+		// The run was started concurrently with the cancellation; give the slot back
+		<-item.ready
+		em.release(provider)
+		return Response{}, ctx.Err()
+	}
+	defer em.release(provider)
+
+	return em.swarm.Run(
+		withQueueLatency(ctx, time.Since(queued)),
+		config.Agent,
+		config.Messages,
+		config.ContextVariables,
+		config.ModelOverride,
+		config.Stream,
+		config.Debug,
+		config.MaxTurns,
+		config.ExecuteTools,
+	)
+}
+
+// Submit queues a run without blocking and delivers its result on the returned channel
+func (em *ExecutionManager) Submit(ctx context.Context, name string, config AgentConfig, priority RunPriority) <-chan ConcurrentResult {
+	resultChan := make(chan ConcurrentResult, 1)
+	go func() {
+		resp, err := em.Start(ctx, config, priority)
+		resultChan <- ConcurrentResult{
+			AgentName: name,
+			Response:  resp,
+			Error:     err,
+		}
+		close(resultChan)
+	}()
+	return resultChan
+}
+
+// QueueDepth returns the number of runs waiting for an execution slot
+func (em *ExecutionManager) QueueDepth() int {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return len(em.queue)
+}
+
+// ProviderQueueDepth returns the number of runs waiting for the given provider
+func (em *ExecutionManager) ProviderQueueDepth(provider llm.LLMProvider) int {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	depth := 0
+	for _, item := range em.queue {
+		if item.provider == provider {
+			depth++
+		}
+	}
+	return depth
+}
+
+// Running returns the number of runs currently executing
+func (em *ExecutionManager) Running() int {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.total
+}
+
+// providerFor returns the provider an agent's requests are sent to
+func (em *ExecutionManager) providerFor(agent *Agent) llm.LLMProvider {
+	if agent != nil && agent.Provider != "" {
+		return agent.Provider
+	}
+	return em.swarm.provider
+}
+
+// enqueue adds a run to the queue and starts whatever is eligible
+func (em *ExecutionManager) enqueue(provider llm.LLMProvider, priority RunPriority) *queuedRun {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.seq++
+	item := &queuedRun{
+		provider: provider,
+		priority: priority,
+		seq:      em.seq,
+		ready:    make(chan struct{}),
+	}
+	em.queue = append(em.queue, item)
+	sort.SliceStable(em.queue, func(i, j int) bool {
+		if em.queue[i].priority != em.queue[j].priority {
+			return em.queue[i].priority > em.queue[j].priority
+		}
+		return em.queue[i].seq < em.queue[j].seq
+	})
+	em.dispatch()
+	return item
+}
+
+// remove drops a run from the queue, reporting whether it was still waiting
+func (em *ExecutionManager) remove(item *queuedRun) bool {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	for i, queued := range em.queue {
+		if queued == item {
+			em.queue = append(em.queue[:i], em.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// release frees the slot held by a finished run and starts the next eligible runs
+func (em *ExecutionManager) release(provider llm.LLMProvider) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	em.total--
+	em.running[provider]--
+	em.dispatch()
+}
+
+// dispatch starts queued runs in priority order while limits allow. Callers must hold em.mu.
+func (em *ExecutionManager) dispatch() {
+	remaining := em.queue[:0]
+	for _, item := range em.queue {
+		if em.hasCapacity(item.provider) {
+			em.total++
+			em.running[item.provider]++
+			close(item.ready)
+			continue
+		}
+		remaining = append(remaining, item)
+	}
+	em.queue = remaining
+}
+
+// hasCapacity reports whether another run may start for the given provider
+func (em *ExecutionManager) hasCapacity(provider llm.LLMProvider) bool {
+	if em.config.MaxConcurrent > 0 && em.total >= em.config.MaxConcurrent {
+		return false
+	}
+	if limit := em.config.ProviderLimits[provider]; limit > 0 && em.running[provider] >= limit {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,92 @@
+package swarmgo
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestExecutionManagerPriority tests that queued runs start in priority order
+func TestExecutionManagerPriority(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	em := NewExecutionManager(sw, ExecutionManagerConfig{MaxConcurrent: 1})
+
+	block := make(chan struct{})
+	var mu sync.Mutex
+	var order []string
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		req := args.Get(1).(llm.ChatCompletionRequest)
+		content := req.Messages[len(req.Messages)-1].Content
+		if content == "first" {
+			<-block
+		}
+		mu.Lock()
+		order = append(order, content)
+		mu.Unlock()
+	}).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "ok"}}},
+	}, nil)
+
+	agent := &Agent{Name: "TestAgent"}
+	config := func(content string) AgentConfig {
+		return AgentConfig{
+			Agent:    agent,
+			Messages: []llm.Message{{Role: llm.RoleUser, Content: content}},
+		}
+	}
+
+	ctx := context.Background()
+	first := em.Submit(ctx, "first", config("first"), PriorityNormal)
+	assert.Eventually(t, func() bool { return em.Running() == 1 }, time.Second, time.Millisecond)
+
+	low := em.Submit(ctx, "low", config("low"), PriorityLow)
+	assert.Eventually(t, func() bool { return em.QueueDepth() == 1 }, time.Second, time.Millisecond)
+	high := em.Submit(ctx, "high", config("high"), PriorityHigh)
+	assert.Eventually(t, func() bool { return em.QueueDepth() == 2 }, time.Second, time.Millisecond)
+
+	close(block)
+	for _, ch := range []<-chan ConcurrentResult{first, low, high} {
+		result := <-ch
+		assert.NoError(t, result.Error)
+	}
+
+	assert.Equal(t, []string{"first", "high", "low"}, order)
+	assert.Equal(t, 0, em.Running())
+}
+
+// TestExecutionManagerCancelWhileQueued tests that a cancelled run leaves the queue
+func TestExecutionManagerCancelWhileQueued(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	em := NewExecutionManager(sw, ExecutionManagerConfig{MaxConcurrent: 1})
+
+	block := make(chan struct{})
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		<-block
+	}).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "ok"}}},
+	}, nil)
+
+	agent := &Agent{Name: "TestAgent"}
+	running := em.Submit(context.Background(), "running", AgentConfig{Agent: agent}, PriorityNormal)
+	assert.Eventually(t, func() bool { return em.Running() == 1 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	queued := em.Submit(ctx, "queued", AgentConfig{Agent: agent}, PriorityNormal)
+	assert.Eventually(t, func() bool { return em.QueueDepth() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	result := <-queued
+	assert.ErrorIs(t, result.Error, context.Canceled)
+	assert.Equal(t, 0, em.QueueDepth())
+
+	close(block)
+	assert.NoError(t, (<-running).Error)
+}
@@ -0,0 +1,236 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrQuotaExceeded is returned when a tenant has used up its quota
+var ErrQuotaExceeded = errors.New("tenant quota exceeded")
+
+// QuotaExceededError describes a run rejected because its tenant used up its quota
+type QuotaExceededError struct {
+	Tenant   string
+	Usage    QuotaUsage
+	Limit    QuotaLimit
+	ResetsAt time.Time // When the quota resets, zero if it never does
+}
+
+// Error implements the error interface
+func (e *QuotaExceededError) Error() string {
+	msg := fmt.Sprintf("%s: tenant %s used %d tokens and %.4f in cost", ErrQuotaExceeded, e.Tenant, e.Usage.Tokens, e.Usage.Cost)
+	if !e.ResetsAt.IsZero() {
+		msg += fmt.Sprintf(", resets at %s", e.ResetsAt.Format(time.RFC3339))
+	}
+	return msg
+}
+
+// Unwrap makes errors.Is match ErrQuotaExceeded
+func (e *QuotaExceededError) Unwrap() error {
+	return ErrQuotaExceeded
+}
+
+// QuotaLimit is the usage a tenant is allowed per period. Zero values mean no limit.
+type QuotaLimit struct {
+	Tokens int64         // Tokens allowed per period
+	Cost   float64       // Cost allowed per period, as computed by the quota's CostFunc
+	Period time.Duration // Length of the periods after which usage resets, never if zero
+}
+
+// QuotaUsage is a tenant's usage in the current period
+type QuotaUsage struct {
+	Tokens   int64
+	Cost     float64
+	ResetsAt time.Time // End of the current period, zero if usage never resets
+}
+
+// exceeds reports whether the usage has reached the limit
+func (u QuotaUsage) exceeds(limit QuotaLimit) bool {
+	return (limit.Tokens > 0 && u.Tokens >= limit.Tokens) || (limit.Cost > 0 && u.Cost >= limit.Cost)
+}
+
+// CostFunc returns the cost of the tokens used by a request to the model
+type CostFunc func(model string, usage llm.Usage) float64
+
+// GatewayCost prices requests with the cost reported by the gateway serving them, as set in
+// llm.Usage.Cost, falling back to fallback for requests without one if it is not nil
+func GatewayCost(fallback CostFunc) CostFunc {
+	return func(model string, usage llm.Usage) float64 {
+		if usage.Cost > 0 || fallback == nil {
+			return usage.Cost
+		}
+		return fallback(model, usage)
+	}
+}
+
+// Quota accounts the usage of tenants, such as customers or users, across runs
+type Quota interface {
+	SetLimit(tenant string, limit QuotaLimit)
+	Limit(tenant string) QuotaLimit
+	Usage(tenant string) QuotaUsage
+	Record(tenant, model string, usage llm.Usage)
+	Reset(tenant string)
+}
+
+// QuotaMode is what happens to runs of a tenant that has used up its quota
+type QuotaMode int
+
+const (
+	QuotaReject QuotaMode = iota // Fail with a *QuotaExceededError
+	QuotaQueue                   // Wait for the quota to reset, failing if it never does
+)
+
+// MemoryQuota keeps usage in memory. It is safe for concurrent use.
+type MemoryQuota struct {
+	cost    CostFunc
+	mu      sync.Mutex
+	limits  map[string]QuotaLimit
+	tenants map[string]*QuotaUsage
+}
+
+// NewMemoryQuota creates an in-memory quota that prices requests with cost, or only counts
+// tokens if cost is nil
+func NewMemoryQuota(cost CostFunc) *MemoryQuota {
+	return &MemoryQuota{
+		cost:    cost,
+		limits:  make(map[string]QuotaLimit),
+		tenants: make(map[string]*QuotaUsage),
+	}
+}
+
+// SetLimit sets the tenant's limit. A new period starts when the period changes.
+func (q *MemoryQuota) SetLimit(tenant string, limit QuotaLimit) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.limits[tenant].Period != limit.Period {
+		if usage, ok := q.tenants[tenant]; ok {
+			usage.ResetsAt = periodEnd(time.Now(), limit.Period)
+		}
+	}
+	q.limits[tenant] = limit
+}
+
+// Limit returns the tenant's limit
+func (q *MemoryQuota) Limit(tenant string) QuotaLimit {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.limits[tenant]
+}
+
+// Usage returns the tenant's usage in the current period
+func (q *MemoryQuota) Usage(tenant string) QuotaUsage {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return *q.current(tenant)
+}
+
+// Record adds the tokens used by a request to the tenant's usage
+func (q *MemoryQuota) Record(tenant, model string, usage llm.Usage) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	current := q.current(tenant)
+	current.Tokens += int64(usage.TotalTokens)
+	if q.cost != nil {
+		current.Cost += q.cost(model, usage)
+	}
+}
+
+// Reset clears the tenant's usage and starts a new period
+func (q *MemoryQuota) Reset(tenant string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.tenants, tenant)
+}
+
+// current returns the tenant's usage, starting a new period if the last one ended. It must be
+// called with the lock held.
+func (q *MemoryQuota) current(tenant string) *QuotaUsage {
+	now := time.Now()
+	usage, ok := q.tenants[tenant]
+	if !ok || (!usage.ResetsAt.IsZero() && !now.Before(usage.ResetsAt)) {
+		usage = &QuotaUsage{ResetsAt: periodEnd(now, q.limits[tenant].Period)}
+		q.tenants[tenant] = usage
+	}
+	return usage
+}
+
+// periodEnd returns the end of a period starting now, zero if periods never end
+func periodEnd(now time.Time, period time.Duration) time.Time {
+	if period <= 0 {
+		return time.Time{}
+	}
+	return now.Add(period)
+}
+
+// WithQuota accounts the usage of runs to the tenant set with RunOptions.Tenant or WithTenant,
+// and rejects or queues the runs of tenants that have used up their quota, depending on mode.
+// A run's token budget is limited to the tokens its tenant has left.
+func (s *Swarm) WithQuota(quota Quota, mode QuotaMode) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quota = quota
+	s.quotaMode = mode
+	return s
+}
+
+type tenantKey struct{}
+
+// WithTenant attributes the runs started with the context, and the runs nested in them, to
+// the tenant
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// tenantFromContext returns the tenant runs are attributed to, if any
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantKey{}).(string)
+	return tenant
+}
+
+// admitTenant waits until the run's tenant has quota left, or fails if it has none in reject
+// mode. It returns the tokens the tenant has left, or zero if its tokens are not limited.
+func (s *Swarm) admitTenant(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	quota, mode := s.quota, s.quotaMode
+	s.mu.Unlock()
+	tenant := tenantFromContext(ctx)
+	if quota == nil || tenant == "" {
+		return 0, nil
+	}
+
+	for {
+		limit, usage := quota.Limit(tenant), quota.Usage(tenant)
+		if !usage.exceeds(limit) {
+			if limit.Tokens > 0 {
+				return int(limit.Tokens - usage.Tokens), nil
+			}
+			return 0, nil
+		}
+		exceeded := &QuotaExceededError{Tenant: tenant, Usage: usage, Limit: limit, ResetsAt: usage.ResetsAt}
+		if mode != QuotaQueue || usage.ResetsAt.IsZero() {
+			return 0, exceeded
+		}
+		timer := time.NewTimer(time.Until(usage.ResetsAt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// recordQuota adds the usage of a request to the run's tenant
+func (s *Swarm) recordQuota(ctx context.Context, model string, usage llm.Usage) {
+	s.mu.Lock()
+	quota := s.quota
+	s.mu.Unlock()
+	if tenant := tenantFromContext(ctx); quota != nil && tenant != "" {
+		quota.Record(tenant, model, usage)
+	}
+}
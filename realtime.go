@@ -0,0 +1,368 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// RealtimeHandler receives events from a realtime (speech-to-speech) session
+type RealtimeHandler interface {
+	OnAudio(audio []byte)               // Audio chunk of the agent's spoken response
+	OnTranscript(delta string)          // Transcript delta of the agent's spoken response
+	OnUserTranscript(transcript string) // Transcript of the user's completed utterance
+	OnToolCall(toolCall llm.ToolCall)   // Tool call executed on behalf of the model
+	OnInterrupt()                       // The user started speaking over the agent
+	OnResponseDone()                    // The agent finished a response
+	OnError(err error)
+}
+
+// DefaultRealtimeHandler provides a basic implementation of RealtimeHandler
+type DefaultRealtimeHandler struct{}
+
+func (h *DefaultRealtimeHandler) OnAudio(audio []byte)               {}
+func (h *DefaultRealtimeHandler) OnTranscript(delta string)          {}
+func (h *DefaultRealtimeHandler) OnUserTranscript(transcript string) {}
+func (h *DefaultRealtimeHandler) OnToolCall(toolCall llm.ToolCall)   {}
+func (h *DefaultRealtimeHandler) OnInterrupt()                       {}
+func (h *DefaultRealtimeHandler) OnResponseDone()                    {}
+func (h *DefaultRealtimeHandler) OnError(err error)                  {}
+
+// RealtimeOptions configures a realtime session
+type RealtimeOptions struct {
+	Model             string   // Realtime model, defaults to the agent's model
+	Voice             string   // Output voice (e.g. "alloy")
+	InputAudioFormat  string   // Defaults to "pcm16"
+	OutputAudioFormat string   // Defaults to "pcm16"
+	Messages          Messages // Built-in messages such as tool errors, DefaultMessages if nil
+	Endpoint          string   // Realtime API endpoint, defaults to OpenAI's, e.g. for Azure OpenAI
+	Debug             bool
+}
+
+// RealtimeSession is a bidirectional audio session with an agent over the OpenAI Realtime API.
+// Tool calls are executed locally against the agent's functions, one at a time and in the
+// background, so a tool awaiting approval does not hold up audio or barge-in. OnError may
+// therefore be called concurrently with the other handler methods.
+type RealtimeSession struct {
+	conn             *llm.RealtimeConn
+	agent            *Agent
+	handler          RealtimeHandler
+	contextVariables map[string]interface{}
+	principal        *Principal      // Principal carried by the context the session was created with
+	ctx              context.Context // Carries the values of the context the session was created with, cancelled by Close
+	cancel           context.CancelFunc
+	tools            sync.Mutex // Runs tool calls one at a time
+	opts             RealtimeOptions
+	done             chan struct{}
+}
+
+// NewRealtimeSession connects to the Realtime API and configures it with the agent's instructions and tools
+func NewRealtimeSession(
+	ctx context.Context,
+	apiKey string,
+	agent *Agent,
+	contextVariables map[string]interface{},
+	opts RealtimeOptions,
+	handler RealtimeHandler,
+) (*RealtimeSession, error) {
+	if handler == nil {
+		handler = &DefaultRealtimeHandler{}
+	}
+	if contextVariables == nil {
+		contextVariables = make(map[string]interface{})
+	}
+
+	model, err := agent.resolveModel(opts.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn *llm.RealtimeConn
+	if opts.Endpoint != "" {
+		conn, err = llm.DialOpenAIRealtimeWithURL(ctx, apiKey, model, opts.Endpoint)
+	} else {
+		conn, err = llm.DialOpenAIRealtime(ctx, apiKey, model)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	principal, _ := PrincipalFromContext(ctx)
+	sessionCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	rs := &RealtimeSession{
+		principal:        principal,
+		ctx:              sessionCtx,
+		cancel:           cancel,
+		conn:             conn,
+		agent:            agent,
+		handler:          handler,
+		contextVariables: contextVariables,
+		opts:             opts,
+		done:             make(chan struct{}),
+	}
+
+	if err := rs.configure(); err != nil {
+		cancel()
+		conn.Close()
+		return nil, err
+	}
+
+	go rs.receiveLoop()
+	return rs, nil
+}
+
+// configure sends the session.update event describing the agent
+func (rs *RealtimeSession) configure() error {
+	opts := rs.opts
+	if err := rs.agent.checkSkills(rs.contextVariables); err != nil {
+		return err
+	}
+	instructions := rs.agent.composeInstructions(instructionOverrides{messages: rs.opts.Messages}, rs.contextVariables)
+
+	// Realtime sessions are not runs of a swarm, so flagged functions are never enabled
+	functions := rs.agent.functionsFor(context.Background(), rs.principal)
+	tools := make([]map[string]interface{}, 0, len(functions))
+	for _, af := range functions {
+		def := FunctionToDefinition(af)
+		tools = append(tools, map[string]interface{}{
+			"type":        "function",
+			"name":        def.Name,
+			"description": def.Description,
+			"parameters":  def.Parameters,
+		})
+	}
+
+	inputFormat := opts.InputAudioFormat
+	if inputFormat == "" {
+		inputFormat = "pcm16"
+	}
+	outputFormat := opts.OutputAudioFormat
+	if outputFormat == "" {
+		outputFormat = "pcm16"
+	}
+
+	session := map[string]interface{}{
+		"modalities":                []string{"audio", "text"},
+		"instructions":              instructions,
+		"tools":                     tools,
+		"input_audio_format":        inputFormat,
+		"output_audio_format":       outputFormat,
+		"input_audio_transcription": map[string]interface{}{"model": "whisper-1"},
+		"turn_detection":            map[string]interface{}{"type": "server_vad"},
+	}
+	if opts.Voice != "" {
+		session["voice"] = opts.Voice
+	}
+
+	return rs.conn.Send(map[string]interface{}{
+		"type":    "session.update",
+		"session": session,
+	})
+}
+
+// SendAudio appends a chunk of input audio to the server-side buffer
+func (rs *RealtimeSession) SendAudio(audio []byte) error {
+	return rs.conn.Send(map[string]interface{}{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(audio),
+	})
+}
+
+// CommitAudio commits the buffered input audio as a user turn and requests a response.
+// It is only needed when server-side voice activity detection is disabled.
+func (rs *RealtimeSession) CommitAudio() error {
+	if err := rs.conn.Send(map[string]interface{}{"type": "input_audio_buffer.commit"}); err != nil {
+		return err
+	}
+	return rs.conn.Send(map[string]interface{}{"type": "response.create"})
+}
+
+// SendText adds a text user message and requests a response
+func (rs *RealtimeSession) SendText(text string) error {
+	err := rs.conn.Send(map[string]interface{}{
+		"type": "conversation.item.create",
+		"item": map[string]interface{}{
+			"type": "message",
+			"role": "user",
+			"content": []map[string]interface{}{
+				{"type": "input_text", "text": text},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return rs.conn.Send(map[string]interface{}{"type": "response.create"})
+}
+
+// Interrupt cancels the response currently being generated
+func (rs *RealtimeSession) Interrupt() error {
+	return rs.conn.Send(map[string]interface{}{"type": "response.cancel"})
+}
+
+// Done returns a channel that is closed when the session ends
+func (rs *RealtimeSession) Done() <-chan struct{} {
+	return rs.done
+}
+
+// Close ends the session. Tool calls still running get a cancelled context, and pending
+// approvals are denied.
+func (rs *RealtimeSession) Close() error {
+	rs.cancel()
+	err := rs.conn.Close()
+	<-rs.done
+	return err
+}
+
+// receiveLoop dispatches server events until the connection closes
+func (rs *RealtimeSession) receiveLoop() {
+	defer close(rs.done)
+	defer rs.cancel()
+
+	for {
+		event, err := rs.conn.Recv()
+		if err != nil {
+			return
+		}
+		if rs.opts.Debug {
+			log.Printf("Realtime event: %s\n", event.Type)
+		}
+
+		switch event.Type {
+		case "response.audio.delta":
+			var payload struct {
+				Delta string `json:"delta"`
+			}
+			if err := event.Decode(&payload); err != nil {
+				rs.handler.OnError(err)
+				continue
+			}
+			audio, err := base64.StdEncoding.DecodeString(payload.Delta)
+			if err != nil {
+				rs.handler.OnError(fmt.Errorf("invalid audio delta: %v", err))
+				continue
+			}
+			rs.handler.OnAudio(audio)
+
+		case "response.audio_transcript.delta":
+			var payload struct {
+				Delta string `json:"delta"`
+			}
+			if err := event.Decode(&payload); err == nil {
+				rs.handler.OnTranscript(payload.Delta)
+			}
+
+		case "conversation.item.input_audio_transcription.completed":
+			var payload struct {
+				Transcript string `json:"transcript"`
+			}
+			if err := event.Decode(&payload); err == nil {
+				rs.handler.OnUserTranscript(payload.Transcript)
+			}
+
+		case "input_audio_buffer.speech_started":
+			// The user barged in; stop the current response
+			rs.handler.OnInterrupt()
+			rs.Interrupt()
+
+		case "response.function_call_arguments.done":
+			var payload struct {
+				CallID    string `json:"call_id"`
+				Name      string `json:"name"`
+				Arguments string `json:"arguments"`
+			}
+			if err := event.Decode(&payload); err != nil {
+				rs.handler.OnError(err)
+				continue
+			}
+			toolCall := llm.ToolCall{
+				ID:   payload.CallID,
+				Type: "function",
+				Function: llm.ToolCallFunction{
+					Name:      payload.Name,
+					Arguments: payload.Arguments,
+				},
+			}
+			rs.handler.OnToolCall(toolCall)
+			go rs.handleToolCall(toolCall)
+
+		case "response.done":
+			rs.handler.OnResponseDone()
+
+		case "error":
+			var payload struct {
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			event.Decode(&payload)
+			rs.handler.OnError(fmt.Errorf("realtime API error: %s - %s", payload.Error.Type, payload.Error.Message))
+		}
+	}
+}
+
+// handleToolCall executes a tool requested by the model and sends its output back. Errors are
+// not reported once the session is closed.
+func (rs *RealtimeSession) handleToolCall(toolCall llm.ToolCall) {
+	rs.tools.Lock()
+	defer rs.tools.Unlock()
+	if rs.ctx.Err() != nil {
+		return
+	}
+
+	var output string
+	var fn *AgentFunction[map[string]interface{}]
+	for _, af := range rs.agent.allFunctions() {
+		if af.Name == toolCall.Function.Name {
+			fn = &af
+			break
+		}
+	}
+
+	if fn == nil || fn.Flag != "" {
+		output = rs.opts.Messages.format(MessageToolNotFound, toolCall.Function.Name)
+	} else if !authorized(rs.principal, fn) {
+		output = rs.opts.Messages.format(MessageToolNotAuthorized, toolCall.Function.Name)
+	} else if ok, err := approved(rs.ctx, fn, toolCall); err != nil || !ok {
+		output = rs.opts.Messages.format(MessageToolNotApproved, toolCall.Function.Name)
+	} else {
+		var args map[string]interface{}
+		err := decodeJSON(toolCall.Function.Arguments, &args)
+		if err == nil {
+			args, err = rs.agent.expandArguments(args, rs.contextVariables)
+		}
+		if err != nil {
+			output = rs.opts.Messages.format(MessageInvalidArguments, err)
+		} else {
+			result := executeFunction(rs.ctx, fn, args, rs.contextVariables, rs.opts.Debug)
+			output = rs.agent.guardToolOutput(fn.Name, resultContent(rs.opts.Messages, result), rs.contextVariables)
+			if result.Agent != nil {
+				// Hand off by reconfiguring the session with the new agent
+				rs.agent = result.Agent
+				if err := rs.configure(); err != nil && rs.ctx.Err() == nil {
+					rs.handler.OnError(err)
+				}
+			}
+		}
+	}
+
+	err := rs.conn.Send(map[string]interface{}{
+		"type": "conversation.item.create",
+		"item": map[string]interface{}{
+			"type":    "function_call_output",
+			"call_id": toolCall.ID,
+			"output":  output,
+		},
+	})
+	if err == nil {
+		err = rs.conn.Send(map[string]interface{}{"type": "response.create"})
+	}
+	if err != nil && rs.ctx.Err() == nil {
+		rs.handler.OnError(err)
+	}
+}
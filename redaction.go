@@ -0,0 +1,100 @@
+package swarmgo
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// debugRedaction replaces sensitive values in debug output
+const debugRedaction = "[redacted]"
+
+// DebugRedaction configures what is removed from debug output so it is safe to share
+type DebugRedaction struct {
+	Fields      []string         // Argument and object keys whose values are redacted, case-insensitively
+	ContextKeys []string         // Context variables whose values are redacted wherever they appear
+	Patterns    []*regexp.Regexp // Text redacted wherever it appears, e.g. PII
+}
+
+// DefaultDebugRedaction is used by swarms without their own redaction. It removes common
+// credential fields, API keys, bearer tokens, email addresses and card numbers.
+var DefaultDebugRedaction = &DebugRedaction{
+	Fields: []string{"api_key", "apikey", "password", "secret", "token", "access_token", "authorization"},
+	Patterns: []*regexp.Regexp{
+		regexp.MustCompile(`\b(sk|pk|rk|xai|gsk)-[A-Za-z0-9_-]{16,}`),
+		regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+		regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/=-]{8,}`),
+		regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+		regexp.MustCompile(`\b(?:\d[ -]?){13,16}\b`),
+	},
+}
+
+// WithDebugRedaction sets what is redacted from the debug output of the swarm's runs. Hidden
+// context variables of the running agent are always redacted as well.
+func (s *Swarm) WithDebugRedaction(redaction *DebugRedaction) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.debugRedaction = redaction
+	return s
+}
+
+// redactDebug formats a value for debug output with sensitive data removed
+func (s *Swarm) redactDebug(agent *Agent, value interface{}, contextVariables map[string]interface{}) string {
+	s.mu.Lock()
+	redaction := s.debugRedaction
+	s.mu.Unlock()
+	if redaction == nil {
+		redaction = DefaultDebugRedaction
+	}
+
+	fields := make(map[string]bool, len(redaction.Fields))
+	for _, field := range redaction.Fields {
+		fields[strings.ToLower(field)] = true
+	}
+	text := fmt.Sprintf("%+v", redactFields(value, fields))
+
+	var values []string
+	for _, key := range append(append([]string{}, redaction.ContextKeys...), agent.HiddenContext...) {
+		v, ok := contextVariables[key]
+		if !ok || v == nil {
+			continue
+		}
+		if rendered := fmt.Sprintf("%v", v); len(rendered) >= minHiddenValueLength {
+			values = append(values, rendered)
+		}
+	}
+	// Redact longer values first so a value containing another is removed whole
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	for _, v := range values {
+		text = strings.ReplaceAll(text, v, debugRedaction)
+	}
+	for _, pattern := range redaction.Patterns {
+		text = pattern.ReplaceAllString(text, debugRedaction)
+	}
+	return text
+}
+
+// redactFields returns a copy of decoded JSON with the values of sensitive keys replaced
+func redactFields(value interface{}, fields map[string]bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if fields[strings.ToLower(key)] {
+				redacted[key] = debugRedaction
+			} else {
+				redacted[key] = redactFields(item, fields)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = redactFields(item, fields)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,262 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrNoRegion is returned when no configured region may serve a request
+var ErrNoRegion = errors.New("no region available")
+
+// Region health tracking defaults
+const (
+	// regionFailureThreshold is the number of consecutive failures after which a region is
+	// skipped while others are healthy
+	regionFailureThreshold = 3
+	// regionCooldown is how long an unhealthy region is skipped before it is tried again
+	regionCooldown = 30 * time.Second
+	// regionLatencyWeight is the weight of the latest request in a region's latency average
+	regionLatencyWeight = 0.2
+)
+
+// RegionSelection is how a swarm with several regions chooses where to send a request
+type RegionSelection string
+
+const (
+	RegionLowestLatency RegionSelection = "lowest_latency" // The healthy region with the lowest average latency
+	RegionInOrder       RegionSelection = "in_order"       // The first healthy region, in configuration order
+)
+
+// Region is a regional endpoint of the swarm's provider
+type Region struct {
+	Name      string  // Name of the region, e.g. eu-west-1
+	Endpoint  string  // API host of the region, checked against data policies
+	Residency string  // Jurisdiction data sent to the region is processed in, e.g. EU
+	Client    llm.LLM // Client sending requests to the region
+}
+
+// RegionStatus is the health of a region as tracked by the swarm
+type RegionStatus struct {
+	Name      string
+	Latency   time.Duration // Average latency of recent requests, zero until one succeeds
+	Failures  int           // Consecutive failed requests
+	Healthy   bool          // Whether the region is not being skipped after failures
+	LastError error         // Error of the last failed request, if any
+}
+
+// regionKey is the context key of the residency the requests of a run are restricted to
+type regionKey struct{}
+
+// WithResidency returns a context whose requests, including those of nested runs, are only
+// sent to regions with the given residency
+func WithResidency(ctx context.Context, residency string) context.Context {
+	return context.WithValue(ctx, regionKey{}, residency)
+}
+
+// residencyFromContext returns the residency requests are restricted to, if any
+func residencyFromContext(ctx context.Context) string {
+	residency, _ := ctx.Value(regionKey{}).(string)
+	return residency
+}
+
+// regionState is a region with its tracked health
+type regionState struct {
+	Region
+	latency   time.Duration
+	failures  int
+	downUntil time.Time
+	lastError error
+}
+
+// regionRouter sends requests to the best region and fails over to the next on errors
+type regionRouter struct {
+	selection RegionSelection
+	mu        sync.Mutex
+	regions   []*regionState
+}
+
+// WithRegions serves requests from several regional endpoints of the provider instead of the
+// swarm's client, so global deployments route each request to the best region. Regions are
+// chosen by the selection among the healthy ones the data policies allow; runs restricted to
+// a residency with RunOptions.Residency or WithResidency only use regions with that
+// residency. A region failing several requests in a row is skipped for a while, and a request
+// failing in a region is retried in the next one, unless the provider rejected the request
+// itself. Clients wrapped by the swarm, e.g. with WithRequestDeduplication, wrap each region.
+func (s *Swarm) WithRegions(selection RegionSelection, regions ...Region) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	router := &regionRouter{selection: selection}
+	for _, region := range regions {
+		// Clients wrapped so far, e.g. with request deduplication, wrap every region
+		for _, wrap := range s.clientWrappers {
+			region.Client = wrap(region.Client)
+		}
+		router.regions = append(router.regions, &regionState{Region: region})
+	}
+	s.regions = router
+	return s
+}
+
+// wrapClients wraps the swarm's client and the clients of its regions, including regions
+// configured later. It must be called with the lock held.
+func (s *Swarm) wrapClients(wrap func(llm.LLM) llm.LLM) {
+	s.client = wrap(s.client)
+	s.clientWrappers = append(s.clientWrappers, wrap)
+	if s.regions != nil {
+		s.regions.mu.Lock()
+		defer s.regions.mu.Unlock()
+		for _, region := range s.regions.regions {
+			region.Client = wrap(region.Client)
+		}
+	}
+}
+
+// RegionStatus returns the tracked health of the swarm's regions, in configuration order
+func (s *Swarm) RegionStatus() []RegionStatus {
+	s.mu.Lock()
+	router := s.regions
+	s.mu.Unlock()
+	if router == nil {
+		return nil
+	}
+
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	statuses := make([]RegionStatus, 0, len(router.regions))
+	for _, region := range router.regions {
+		statuses = append(statuses, RegionStatus{
+			Name:      region.Name,
+			Latency:   region.latency,
+			Failures:  region.failures,
+			Healthy:   region.healthy(time.Now()),
+			LastError: region.lastError,
+		})
+	}
+	return statuses
+}
+
+// regionClient routes the requests of an agent through the swarm's regions
+type regionClient struct {
+	router   *regionRouter
+	policies []*DataPolicy
+}
+
+// CreateChatCompletion sends the request to the best region, failing over to the others
+func (c *regionClient) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	var resp llm.ChatCompletionResponse
+	err := c.router.do(ctx, c.policies, func(client llm.LLM) error {
+		var err error
+		resp, err = client.CreateChatCompletion(ctx, req)
+		return err
+	})
+	return resp, err
+}
+
+// CreateChatCompletionStream opens the stream in the best region, failing over to the others
+// if it cannot be opened
+func (c *regionClient) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	var stream llm.ChatCompletionStream
+	err := c.router.do(ctx, c.policies, func(client llm.LLM) error {
+		var err error
+		stream, err = client.CreateChatCompletionStream(ctx, req)
+		return err
+	})
+	return stream, err
+}
+
+// do calls the request in the candidate regions in order until one succeeds. Errors of the
+// request itself, such as an invalid request or credentials, fail it at once: every region
+// would reject it, and they say nothing about the region's health.
+func (r *regionRouter) do(ctx context.Context, policies []*DataPolicy, call func(client llm.LLM) error) error {
+	candidates := r.candidates(residencyFromContext(ctx), policies)
+	if len(candidates) == 0 {
+		return ErrNoRegion
+	}
+
+	var errs []error
+	for _, region := range candidates {
+		start := time.Now()
+		err := call(region.Client)
+		if llm.IsRequestError(err) {
+			return fmt.Errorf("region %s: %w", region.Name, err)
+		}
+		r.record(region, time.Since(start), err)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("region %s: %w", region.Name, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// candidates returns the regions that may serve a request, best first. Unhealthy regions are
+// kept last so requests still go out when every region is failing.
+func (r *regionRouter) candidates(residency string, policies []*DataPolicy) []*regionState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var healthy, unhealthy []*regionState
+	for _, region := range r.regions {
+		if residency != "" && region.Residency != residency {
+			continue
+		}
+		allowed := true
+		for _, p := range policies {
+			if !p.allowsEndpoint(region.Endpoint) {
+				allowed = false
+				break
+			}
+		}
+		if !allowed {
+			continue
+		}
+		if region.healthy(now) {
+			healthy = append(healthy, region)
+		} else {
+			unhealthy = append(unhealthy, region)
+		}
+	}
+
+	if r.selection == RegionLowestLatency {
+		// Regions without a measurement yet sort first so they get measured
+		sort.SliceStable(healthy, func(i, j int) bool { return healthy[i].latency < healthy[j].latency })
+	}
+	return append(healthy, unhealthy...)
+}
+
+// record updates the health of a region after a request
+func (r *regionRouter) record(region *regionState, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err != nil {
+		region.failures++
+		region.lastError = err
+		if region.failures >= regionFailureThreshold {
+			region.downUntil = time.Now().Add(regionCooldown)
+		}
+		return
+	}
+	region.failures = 0
+	region.downUntil = time.Time{}
+	if region.latency == 0 {
+		region.latency = latency
+	} else {
+		region.latency = time.Duration(regionLatencyWeight*float64(latency) + (1-regionLatencyWeight)*float64(region.latency))
+	}
+}
+
+// healthy reports whether the region is not being skipped after failures
+func (r *regionState) healthy(now time.Time) bool {
+	return !now.Before(r.downUntil)
+}
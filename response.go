@@ -0,0 +1,108 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// ErrNoJSON is returned when a response's final text contains no JSON value
+var ErrNoJSON = errors.New("no JSON in response")
+
+// FinalText returns the content of the last assistant message with text, which is the
+// answer of a run that ended normally
+func (r Response) FinalText() string {
+	return lastAssistantContent(r.Messages)
+}
+
+// ExtractCodeBlocks returns the code in the fenced Markdown blocks of the final text whose
+// language is lang, ignoring case, or in all of them if lang is empty. A block left open at
+// the end of the text is included.
+func (r Response) ExtractCodeBlocks(lang string) []string {
+	var blocks []string
+	var code strings.Builder
+	open, matches := false, false
+	for _, line := range strings.Split(r.FinalText(), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "```") {
+			if open && matches {
+				code.WriteString(line)
+				code.WriteByte('\n')
+			}
+			continue
+		}
+		if open {
+			if matches {
+				blocks = append(blocks, code.String())
+			}
+			open = false
+			continue
+		}
+		info := strings.Fields(strings.TrimPrefix(trimmed, "```"))
+		open = true
+		matches = lang == "" || (len(info) > 0 && strings.EqualFold(info[0], lang))
+		code.Reset()
+	}
+	if open && matches {
+		blocks = append(blocks, code.String())
+	}
+	return blocks
+}
+
+// ExtractJSON returns the first JSON value in the final text: the content of a json code
+// block if there is one, otherwise the first object or array in the text. Almost-valid JSON
+// is fixed with RepairJSON. It fails with ErrNoJSON if the text has no JSON value.
+func (r Response) ExtractJSON() (json.RawMessage, error) {
+	for _, block := range r.ExtractCodeBlocks("json") {
+		if value, ok := validJSON(block); ok {
+			return value, nil
+		}
+	}
+
+	text := r.FinalText()
+	for i := 0; i < len(text); i++ {
+		if text[i] != '{' && text[i] != '[' {
+			continue
+		}
+		end := jsonValueEnd(text, i)
+		if end < 0 {
+			continue
+		}
+		if value, ok := validJSON(text[i:end]); ok {
+			return value, nil
+		}
+	}
+	return nil, ErrNoJSON
+}
+
+// validJSON returns the text as JSON, repairing it if needed
+func validJSON(text string) (json.RawMessage, bool) {
+	text = strings.TrimSpace(text)
+	if json.Valid([]byte(text)) {
+		return json.RawMessage(text), true
+	}
+	if repaired := RepairJSON(text); json.Valid([]byte(repaired)) {
+		return json.RawMessage(repaired), true
+	}
+	return nil, false
+}
+
+// jsonValueEnd returns the index after the bracket closing the object or array starting at
+// i, or -1 if it is not closed
+func jsonValueEnd(text string, i int) int {
+	depth := 0
+	for j := i; j < len(text); j++ {
+		switch text[j] {
+		case '"', '\'':
+			j = stringEnd(text, j) - 1
+		case '{', '[':
+			depth++
+		case '}', ']':
+			depth--
+			if depth == 0 {
+				return j + 1
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,45 @@
+package swarmgo
+
+import (
+	"errors"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrNoResponsesBackend is returned for runs of agents with hosted tools on a swarm without a
+// Responses backend
+var ErrNoResponsesBackend = errors.New("agent has hosted tools but the swarm has no Responses backend")
+
+// WithResponsesBackend sets the client serving the agents that have hosted tools, typically an
+// llm.OpenAIResponsesLLM, so individual agents can opt into hosted capabilities while the
+// others keep using the swarm's client. To run every agent on the Responses API, e.g. for its
+// server-side state, create the swarm with the Responses client instead.
+func (s *Swarm) WithResponsesBackend(client llm.LLM) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses = client
+	return s
+}
+
+// WithHostedTools gives the agent tools the provider executes, such as llm.WebSearch and
+// llm.FileSearch. The agent then runs on the swarm's Responses backend.
+func (a *Agent) WithHostedTools(tools ...llm.HostedTool) *Agent {
+	a.HostedTools = append(a.HostedTools, tools...)
+	return a
+}
+
+// clientFor returns the client serving the agent's requests
+func (s *Swarm) clientFor(agent *Agent) (llm.LLM, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(agent.HostedTools) == 0 {
+		if s.regions != nil {
+			return &regionClient{router: s.regions, policies: []*DataPolicy{s.policy, agent.Policy}}, nil
+		}
+		return s.client, nil
+	}
+	if s.responses == nil {
+		return nil, ErrNoResponsesBackend
+	}
+	return s.responses, nil
+}
@@ -0,0 +1,226 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// ErrNoRetention is returned when deleting user data with a swarm that has no retention configured
+var ErrNoRetention = errors.New("no retention configured")
+
+// UserIDKey ties stored data to a user: it is the session metadata key and the memory context
+// key holding the ID of the user the data belongs to. Runs tag the memories they record with
+// their principal's ID. Audit records and artifacts belong to the principal of the run that
+// produced them.
+const UserIDKey = "user_id"
+
+// RetentionPolicy sets how long stored data is kept. Data of a kind whose TTL is not
+// positive is kept until deleted.
+type RetentionPolicy struct {
+	SessionTTL  time.Duration // Since the session was last updated
+	MemoryTTL   time.Duration // Since the memory was recorded
+	AuditTTL    time.Duration // Since the audit record was written
+	ArtifactTTL time.Duration // Since the artifact was saved
+}
+
+// RetentionReport counts the data deleted by a sweep or a user data deletion
+type RetentionReport struct {
+	Sessions     int
+	Memories     int
+	AuditRecords int
+	Artifacts    int
+}
+
+// Retention enforces a retention policy on the stores holding conversation data, and erases
+// all the data of a user on request, e.g. for GDPR erasure requests
+type Retention struct {
+	Policy    RetentionPolicy
+	Sessions  SessionStore   // Stored sessions, if any
+	Memories  []*MemoryStore // Agent memories
+	Audit     AuditPurger    // Audit records, if the sink can delete them
+	Artifacts ArtifactPurger // Generated artifacts, if the store can delete them
+}
+
+// NewRetention creates a retention enforcing the policy on no stores yet
+func NewRetention(policy RetentionPolicy) *Retention {
+	return &Retention{Policy: policy}
+}
+
+// WithSessions enforces the retention on the session store
+func (r *Retention) WithSessions(store SessionStore) *Retention {
+	r.Sessions = store
+	return r
+}
+
+// WithMemories enforces the retention on the memory stores
+func (r *Retention) WithMemories(stores ...*MemoryStore) *Retention {
+	r.Memories = append(r.Memories, stores...)
+	return r
+}
+
+// WithAudit enforces the retention on the audit sink
+func (r *Retention) WithAudit(sink AuditPurger) *Retention {
+	r.Audit = sink
+	return r
+}
+
+// WithArtifacts enforces the retention on the artifact store
+func (r *Retention) WithArtifacts(store ArtifactPurger) *Retention {
+	r.Artifacts = store
+	return r
+}
+
+// Sweep deletes the data older than the policy allows
+func (r *Retention) Sweep(ctx context.Context) (RetentionReport, error) {
+	now := time.Now()
+	var report RetentionReport
+	var errs []error
+
+	if r.Sessions != nil && r.Policy.SessionTTL > 0 {
+		cutoff := now.Add(-r.Policy.SessionTTL)
+		deleted, err := r.deleteSessions(ctx, func(snapshot SessionSnapshot) bool {
+			return snapshot.UpdatedAt.Before(cutoff)
+		})
+		report.Sessions += deleted
+		errs = append(errs, err)
+	}
+	if r.Policy.MemoryTTL > 0 {
+		cutoff := now.Add(-r.Policy.MemoryTTL)
+		report.Memories += r.removeMemories(func(m Memory) bool { return m.Timestamp.Before(cutoff) })
+	}
+	if r.Audit != nil && r.Policy.AuditTTL > 0 {
+		deleted, err := r.Audit.PurgeAudit(ctx, AuditFilter{Before: now.Add(-r.Policy.AuditTTL)})
+		report.AuditRecords += deleted
+		errs = append(errs, err)
+	}
+	if r.Artifacts != nil && r.Policy.ArtifactTTL > 0 {
+		deleted, err := r.Artifacts.PurgeArtifacts(ctx, ArtifactFilter{Before: now.Add(-r.Policy.ArtifactTTL)})
+		report.Artifacts += deleted
+		errs = append(errs, err)
+	}
+	return report, errors.Join(errs...)
+}
+
+// DeleteUserData deletes everything stored about the user: the sessions and memories tagged
+// with UserIDKey, and the audit records and artifacts of runs acting for the user as principal.
+// Deletion continues past failures, which are all reported.
+func (r *Retention) DeleteUserData(ctx context.Context, userID string) (RetentionReport, error) {
+	if userID == "" {
+		return RetentionReport{}, fmt.Errorf("user ID is empty")
+	}
+	belongs := func(data map[string]interface{}) bool {
+		owner, ok := data[UserIDKey]
+		return ok && fmt.Sprint(owner) == userID
+	}
+
+	var report RetentionReport
+	var errs []error
+	if r.Sessions != nil {
+		deleted, err := r.deleteSessions(ctx, func(snapshot SessionSnapshot) bool { return belongs(snapshot.Metadata) })
+		report.Sessions += deleted
+		errs = append(errs, err)
+	}
+	report.Memories += r.removeMemories(func(m Memory) bool { return belongs(m.Context) })
+	if r.Audit != nil {
+		deleted, err := r.Audit.PurgeAudit(ctx, AuditFilter{PrincipalID: userID})
+		report.AuditRecords += deleted
+		errs = append(errs, err)
+	}
+	if r.Artifacts != nil {
+		deleted, err := r.Artifacts.PurgeArtifacts(ctx, ArtifactFilter{Owner: userID})
+		report.Artifacts += deleted
+		errs = append(errs, err)
+	}
+	return report, errors.Join(errs...)
+}
+
+// deleteSessions deletes the stored sessions matching the predicate
+func (r *Retention) deleteSessions(ctx context.Context, match func(SessionSnapshot) bool) (int, error) {
+	ids, err := r.Sessions.ListSessions(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	deleted := 0
+	var errs []error
+	for _, id := range ids {
+		snapshot, err := r.Sessions.LoadSession(ctx, id)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !match(snapshot) {
+			continue
+		}
+		if err := r.Sessions.DeleteSession(ctx, id); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted++
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// removeMemories removes the memories matching the predicate from every memory store
+func (r *Retention) removeMemories(match func(Memory) bool) int {
+	removed := 0
+	for _, store := range r.Memories {
+		removed += store.RemoveWhere(match)
+	}
+	return removed
+}
+
+// WithRetention enforces the retention in the background, sweeping every interval until the
+// swarm shuts down, and lets DeleteUserData erase users' data from its stores. If interval is
+// not positive, nothing sweeps in the background, e.g. when the caller calls Sweep itself.
+func (s *Swarm) WithRetention(retention *Retention, interval time.Duration) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stopRetention != nil {
+		s.stopRetention()
+	}
+	s.retention = retention
+	s.stopRetention = nil
+	if interval <= 0 {
+		return s
+	}
+
+	stop := make(chan struct{})
+	s.stopRetention = sync.OnceFunc(func() { close(stop) })
+	stopSweeper := s.stopRetention
+	s.shutdownHooks = append(s.shutdownHooks, func(ctx context.Context) error {
+		stopSweeper()
+		return nil
+	})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, err := retention.Sweep(context.Background()); err != nil {
+					log.Printf("Retention sweep failed: %v", err)
+				}
+			}
+		}
+	}()
+	return s
+}
+
+// DeleteUserData deletes everything stored about the user from the stores of the swarm's
+// retention, failing with ErrNoRetention if none is configured
+func (s *Swarm) DeleteUserData(ctx context.Context, userID string) (RetentionReport, error) {
+	s.mu.Lock()
+	retention := s.retention
+	s.mu.Unlock()
+	if retention == nil {
+		return RetentionReport{}, ErrNoRetention
+	}
+	return retention.DeleteUserData(ctx, userID)
+}
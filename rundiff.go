@@ -0,0 +1,274 @@
+package swarmgo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DiffOp is the kind of a difference between two transcripts
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = "equal"  // Present in both transcripts
+	DiffDelete DiffOp = "delete" // Only present in the first transcript
+	DiffInsert DiffOp = "insert" // Only present in the second transcript
+	DiffChange DiffOp = "change" // Present in both transcripts with different content
+)
+
+// MessageDiff is an entry of the comparison of two transcripts' messages
+type MessageDiff struct {
+	Op          DiffOp
+	Before      *llm.Message // Message of the first transcript, nil for insertions
+	After       *llm.Message // Message of the second transcript, nil for deletions
+	BeforeIndex int          // Index of Before, -1 for insertions
+	AfterIndex  int          // Index of After, -1 for deletions
+	Fields      []string     // Fields that differ for changes: "content", "name" and "tool_calls"
+}
+
+// ToolCallDiff is an entry of the comparison of two runs' tool calls, in call order
+type ToolCallDiff struct {
+	Op     DiffOp
+	Before *llm.ToolCall // Call of the first run, nil for insertions
+	After  *llm.ToolCall // Call of the second run, nil for deletions
+}
+
+// RunDiff is the comparison of two runs, e.g. of one eval case before and after a prompt or
+// model change
+type RunDiff struct {
+	Messages    []MessageDiff
+	ToolCalls   []ToolCallDiff
+	BeforeUsage llm.Usage
+	AfterUsage  llm.Usage
+}
+
+// DiffResponses compares the transcripts, tool calls and token usage of two runs
+func DiffResponses(before, after Response) RunDiff {
+	return RunDiff{
+		Messages:    DiffTranscripts(before.Messages, after.Messages),
+		ToolCalls:   diffToolCalls(transcriptToolCalls(before.Messages), transcriptToolCalls(after.Messages)),
+		BeforeUsage: before.Usage,
+		AfterUsage:  after.Usage,
+	}
+}
+
+// DiffTranscripts compares two message sequences. Messages are matched in order; a message
+// replaced by one of the same role is reported as a change rather than a deletion and insertion.
+func DiffTranscripts(before, after []llm.Message) []MessageDiff {
+	steps := diffSequence(len(before), len(after), func(i, j int) bool {
+		return len(messageFieldDiff(before[i], after[j])) == 0 && before[i].Role == after[j].Role
+	})
+
+	diffs := make([]MessageDiff, 0, len(steps))
+	for k := 0; k < len(steps); k++ {
+		step := steps[k]
+		switch step.op {
+		case DiffEqual:
+			diffs = append(diffs, MessageDiff{Op: DiffEqual, Before: &before[step.i], After: &after[step.j], BeforeIndex: step.i, AfterIndex: step.j})
+		case DiffDelete:
+			// A deletion followed by an insertion of the same role is a change
+			if k+1 < len(steps) && steps[k+1].op == DiffInsert && before[step.i].Role == after[steps[k+1].j].Role {
+				j := steps[k+1].j
+				diffs = append(diffs, MessageDiff{
+					Op:          DiffChange,
+					Before:      &before[step.i],
+					After:       &after[j],
+					BeforeIndex: step.i,
+					AfterIndex:  j,
+					Fields:      messageFieldDiff(before[step.i], after[j]),
+				})
+				k++
+				continue
+			}
+			diffs = append(diffs, MessageDiff{Op: DiffDelete, Before: &before[step.i], BeforeIndex: step.i, AfterIndex: -1})
+		case DiffInsert:
+			diffs = append(diffs, MessageDiff{Op: DiffInsert, After: &after[step.j], BeforeIndex: -1, AfterIndex: step.j})
+		}
+	}
+	return diffs
+}
+
+// Equal reports whether the runs produced the same messages and tool calls
+func (d RunDiff) Equal() bool {
+	for _, diff := range d.Messages {
+		if diff.Op != DiffEqual {
+			return false
+		}
+	}
+	for _, diff := range d.ToolCalls {
+		if diff.Op != DiffEqual {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the comparison for humans, in the style of a unified diff: lines only in the
+// first run start with "-", lines only in the second with "+", and changed lines show both
+func (d RunDiff) String() string {
+	var b strings.Builder
+	for _, diff := range d.Messages {
+		switch diff.Op {
+		case DiffEqual:
+			fmt.Fprintf(&b, "  %s\n", describeMessage(*diff.Before))
+		case DiffDelete:
+			fmt.Fprintf(&b, "- %s\n", describeMessage(*diff.Before))
+		case DiffInsert:
+			fmt.Fprintf(&b, "+ %s\n", describeMessage(*diff.After))
+		case DiffChange:
+			fmt.Fprintf(&b, "- %s\n+ %s\n", describeMessage(*diff.Before), describeMessage(*diff.After))
+		}
+	}
+
+	changed := 0
+	for _, diff := range d.ToolCalls {
+		if diff.Op != DiffEqual {
+			changed++
+		}
+	}
+	fmt.Fprintf(&b, "tool calls: %d before, %d after, %d differ\n", countCalls(d.ToolCalls, true), countCalls(d.ToolCalls, false), changed)
+	fmt.Fprintf(&b, "tokens: %d -> %d (%+d)\n", d.BeforeUsage.TotalTokens, d.AfterUsage.TotalTokens, d.AfterUsage.TotalTokens-d.BeforeUsage.TotalTokens)
+	return b.String()
+}
+
+// countCalls counts the tool calls of the first run, or of the second if before is false
+func countCalls(diffs []ToolCallDiff, before bool) int {
+	n := 0
+	for _, diff := range diffs {
+		if (before && diff.Before != nil) || (!before && diff.After != nil) {
+			n++
+		}
+	}
+	return n
+}
+
+// describeMessage renders a message on one line
+func describeMessage(msg llm.Message) string {
+	var b strings.Builder
+	b.WriteString(string(msg.Role))
+	if msg.Name != "" {
+		fmt.Fprintf(&b, " (%s)", msg.Name)
+	}
+	b.WriteString(": ")
+	b.WriteString(strings.ReplaceAll(msg.Content, "\n", `\n`))
+	for _, call := range msg.ToolCalls {
+		fmt.Fprintf(&b, " [%s(%s)]", call.Function.Name, call.Function.Arguments)
+	}
+	return b.String()
+}
+
+// messageFieldDiff returns the fields in which two messages differ, ignoring their role
+func messageFieldDiff(a, b llm.Message) []string {
+	var fields []string
+	if a.Content != b.Content {
+		fields = append(fields, "content")
+	}
+	if a.Name != b.Name {
+		fields = append(fields, "name")
+	}
+	if len(a.ToolCalls) != len(b.ToolCalls) {
+		return append(fields, "tool_calls")
+	}
+	for i := range a.ToolCalls {
+		if a.ToolCalls[i].Function != b.ToolCalls[i].Function {
+			return append(fields, "tool_calls")
+		}
+	}
+	return fields
+}
+
+// transcriptToolCalls returns the tool calls requested in the messages, in order
+func transcriptToolCalls(messages []llm.Message) []llm.ToolCall {
+	var calls []llm.ToolCall
+	for _, msg := range messages {
+		calls = append(calls, msg.ToolCalls...)
+	}
+	return calls
+}
+
+// diffToolCalls compares two sequences of tool calls by function and arguments, ignoring their
+// IDs. A call replaced by one of the same function is reported as a change.
+func diffToolCalls(before, after []llm.ToolCall) []ToolCallDiff {
+	steps := diffSequence(len(before), len(after), func(i, j int) bool {
+		return before[i].Function == after[j].Function
+	})
+
+	diffs := make([]ToolCallDiff, 0, len(steps))
+	for k := 0; k < len(steps); k++ {
+		step := steps[k]
+		switch step.op {
+		case DiffEqual:
+			diffs = append(diffs, ToolCallDiff{Op: DiffEqual, Before: &before[step.i], After: &after[step.j]})
+		case DiffDelete:
+			if k+1 < len(steps) && steps[k+1].op == DiffInsert && before[step.i].Function.Name == after[steps[k+1].j].Function.Name {
+				diffs = append(diffs, ToolCallDiff{Op: DiffChange, Before: &before[step.i], After: &after[steps[k+1].j]})
+				k++
+				continue
+			}
+			diffs = append(diffs, ToolCallDiff{Op: DiffDelete, Before: &before[step.i]})
+		case DiffInsert:
+			diffs = append(diffs, ToolCallDiff{Op: DiffInsert, After: &after[step.j]})
+		}
+	}
+	return diffs
+}
+
+// diffStep is a step of the edit script turning one sequence into another
+type diffStep struct {
+	op   DiffOp // DiffEqual, DiffDelete or DiffInsert
+	i, j int    // Indices into the first and second sequence
+}
+
+// diffSequence returns the edit script between sequences of lengths n and m based on their
+// longest common subsequence. Deletions come before the insertions they are adjacent to.
+func diffSequence(n, m int, equal func(i, j int) bool) []diffStep {
+	// lcs[i][j] is the length of the longest common subsequence of the suffixes from i and j
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if equal(i, j) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	steps := make([]diffStep, 0, max(n, m))
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && equal(i, j):
+			steps = append(steps, diffStep{op: DiffEqual, i: i, j: j})
+			i++
+			j++
+		case i < n && (j == m || lcs[i+1][j] >= lcs[i][j+1]):
+			steps = append(steps, diffStep{op: DiffDelete, i: i, j: -1})
+			i++
+		default:
+			steps = append(steps, diffStep{op: DiffInsert, i: -1, j: j})
+			j++
+		}
+	}
+	return steps
+}
+
+// DiffEvalReports compares the runs of the cases two evaluations have in common, by case name,
+// e.g. to review how a prompt or model change affected each case
+func DiffEvalReports(before, after EvalReport) map[string]RunDiff {
+	previous := make(map[string]Response, len(before.Results))
+	for _, result := range before.Results {
+		previous[result.Name] = result.Response
+	}
+	diffs := make(map[string]RunDiff, len(after.Results))
+	for _, result := range after.Results {
+		if response, ok := previous[result.Name]; ok {
+			diffs[result.Name] = DiffResponses(response, result.Response)
+		}
+	}
+	return diffs
+}
@@ -0,0 +1,48 @@
+package swarmgo
+
+import (
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffResponses(t *testing.T) {
+	lookup := func(args string) llm.ToolCall {
+		return llm.ToolCall{Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: args}}
+	}
+	before := Response{
+		Messages: []llm.Message{
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{lookup(`{"city":"Paris"}`)}},
+			{Role: llm.RoleFunction, Name: "lookup", Content: "sunny"},
+			{Role: llm.RoleAssistant, Content: "It is sunny."},
+		},
+		Usage: llm.Usage{TotalTokens: 100},
+	}
+	after := Response{
+		Messages: []llm.Message{
+			{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{lookup(`{"city":"paris"}`)}},
+			{Role: llm.RoleFunction, Name: "lookup", Content: "sunny"},
+			{Role: llm.RoleAssistant, Content: "It is sunny in Paris."},
+			{Role: llm.RoleAssistant, Content: "Anything else?"},
+		},
+		Usage: llm.Usage{TotalTokens: 120},
+	}
+
+	diff := DiffResponses(before, after)
+	assert.False(t, diff.Equal())
+	ops := make([]DiffOp, len(diff.Messages))
+	for i, d := range diff.Messages {
+		ops[i] = d.Op
+	}
+	assert.Equal(t, []DiffOp{DiffChange, DiffEqual, DiffChange, DiffInsert}, ops)
+	assert.Equal(t, []string{"tool_calls"}, diff.Messages[0].Fields)
+	assert.Equal(t, []string{"content"}, diff.Messages[2].Fields)
+	if assert.Len(t, diff.ToolCalls, 1) {
+		assert.Equal(t, DiffChange, diff.ToolCalls[0].Op)
+	}
+	assert.Contains(t, diff.String(), "+ assistant: It is sunny in Paris.")
+	assert.Contains(t, diff.String(), "tokens: 100 -> 120 (+20)")
+
+	assert.True(t, DiffResponses(before, before).Equal())
+}
@@ -0,0 +1,221 @@
+package swarmgo
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultRecentRuns is the number of finished runs a RunMonitor keeps when none is configured
+const DefaultRecentRuns = 100
+
+// RunStatus is the state a run is in
+type RunStatus string
+
+const (
+	RunAwaitingModel  RunStatus = "awaiting_model"  // Waiting for the provider's response
+	RunExecutingTools RunStatus = "executing_tools" // Executing the tool calls of the last response
+	RunCompleted      RunStatus = "completed"       // Finished successfully
+	RunFailed         RunStatus = "failed"          // Finished with an error
+)
+
+// RunInfo is the state of a run observed by a RunMonitor
+type RunInfo struct {
+	ID          string        `json:"id"`
+	Agent       string        `json:"agent"`        // Agent the run started with
+	ActiveAgent string        `json:"active_agent"` // Agent currently handling the run
+	Status      RunStatus     `json:"status"`
+	Turns       int           `json:"turns"`
+	Usage       llm.Usage     `json:"usage"`
+	Error       string        `json:"error,omitempty"`
+	StartedAt   time.Time     `json:"started_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	EndedAt     time.Time     `json:"ended_at,omitempty"`
+	Transcript  []llm.Message `json:"transcript,omitempty"` // Messages of a finished run, including its input
+}
+
+// RunMetrics are totals over the runs a RunMonitor observed
+type RunMetrics struct {
+	Started   int64         `json:"started"`
+	Completed int64         `json:"completed"`
+	Failed    int64         `json:"failed"`
+	Active    int           `json:"active"`
+	Turns     int64         `json:"turns"`
+	Usage     llm.Usage     `json:"usage"`
+	Provider  time.Duration `json:"provider"` // Time spent waiting for providers
+	Tools     time.Duration `json:"tools"`    // Time spent executing tools
+}
+
+// RunMonitor keeps the state of a swarm's active runs and the transcripts of its most recent
+// finished runs, e.g. for a debug dashboard. Runs nested in other runs are observed too.
+type RunMonitor struct {
+	mu      sync.Mutex
+	size    int
+	active  map[string]*RunInfo
+	recent  []RunInfo // Oldest first
+	metrics RunMetrics
+}
+
+// NewRunMonitor creates a monitor keeping the last recent finished runs, DefaultRecentRuns if
+// not positive
+func NewRunMonitor(recent int) *RunMonitor {
+	if recent <= 0 {
+		recent = DefaultRecentRuns
+	}
+	return &RunMonitor{size: recent, active: make(map[string]*RunInfo)}
+}
+
+// WithRunMonitor reports the state of the swarm's runs to the monitor
+func (s *Swarm) WithRunMonitor(monitor *RunMonitor) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.monitor = monitor
+	return s
+}
+
+// Active returns the runs in progress, oldest first
+func (m *RunMonitor) Active() []RunInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]RunInfo, 0, len(m.active))
+	for _, run := range m.active {
+		runs = append(runs, *run)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	return runs
+}
+
+// Recent returns the most recently finished runs, newest first
+func (m *RunMonitor) Recent() []RunInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	runs := make([]RunInfo, len(m.recent))
+	for i, run := range m.recent {
+		runs[len(runs)-1-i] = run
+	}
+	return runs
+}
+
+// Run returns the active or recently finished run with the ID
+func (m *RunMonitor) Run(id string) (RunInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if run, ok := m.active[id]; ok {
+		return *run, true
+	}
+	for _, run := range m.recent {
+		if run.ID == id {
+			return run, true
+		}
+	}
+	return RunInfo{}, false
+}
+
+// Metrics returns the totals over the observed runs
+func (m *RunMonitor) Metrics() RunMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	metrics := m.metrics
+	metrics.Active = len(m.active)
+	return metrics
+}
+
+// monitoredRun reports the progress of a run to the swarm's monitor. Its methods do nothing
+// when the swarm has no monitor.
+type monitoredRun struct {
+	monitor *RunMonitor
+	id      string
+	input   []llm.Message
+}
+
+// monitorRun registers the run the context belongs to with the swarm's monitor
+func (s *Swarm) monitorRun(ctx context.Context, agent *Agent, messages []llm.Message) *monitoredRun {
+	s.mu.Lock()
+	monitor := s.monitor
+	s.mu.Unlock()
+	if monitor == nil {
+		return nil
+	}
+
+	now := time.Now()
+	run := &monitoredRun{monitor: monitor, id: runIDFromContext(ctx), input: messages}
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+	monitor.active[run.id] = &RunInfo{
+		ID:          run.id,
+		Agent:       agent.Name,
+		ActiveAgent: agent.Name,
+		Status:      RunAwaitingModel,
+		StartedAt:   now,
+		UpdatedAt:   now,
+	}
+	monitor.metrics.Started++
+	return run
+}
+
+// setStatus records the state the run entered and the agent handling it
+func (r *monitoredRun) setStatus(status RunStatus, agent string) {
+	if r == nil {
+		return
+	}
+	r.monitor.mu.Lock()
+	defer r.monitor.mu.Unlock()
+	if info, ok := r.monitor.active[r.id]; ok {
+		info.Status = status
+		info.ActiveAgent = agent
+		info.UpdatedAt = time.Now()
+	}
+}
+
+// addTurn records a completed turn of the run
+func (r *monitoredRun) addTurn(turn Turn) {
+	if r == nil {
+		return
+	}
+	r.monitor.mu.Lock()
+	defer r.monitor.mu.Unlock()
+	if info, ok := r.monitor.active[r.id]; ok {
+		info.Turns++
+		info.Usage = addUsage(info.Usage, turn.Usage)
+		info.UpdatedAt = time.Now()
+	}
+	r.monitor.metrics.Turns++
+	r.monitor.metrics.Usage = addUsage(r.monitor.metrics.Usage, turn.Usage)
+	r.monitor.metrics.Provider += turn.Metrics.Provider
+	r.monitor.metrics.Tools += turn.Metrics.Tools
+}
+
+// end moves the run to the recently finished runs with its transcript
+func (r *monitoredRun) end(response Response, err error) {
+	if r == nil {
+		return
+	}
+	r.monitor.mu.Lock()
+	defer r.monitor.mu.Unlock()
+	info, ok := r.monitor.active[r.id]
+	if !ok {
+		return
+	}
+	delete(r.monitor.active, r.id)
+
+	now := time.Now()
+	info.UpdatedAt = now
+	info.EndedAt = now
+	if err != nil {
+		info.Status = RunFailed
+		info.Error = err.Error()
+		r.monitor.metrics.Failed++
+	} else {
+		info.Status = RunCompleted
+		r.monitor.metrics.Completed++
+	}
+	info.Transcript = append(append([]llm.Message{}, r.input...), response.Messages...)
+
+	r.monitor.recent = append(r.monitor.recent, *info)
+	if len(r.monitor.recent) > r.monitor.size {
+		r.monitor.recent = r.monitor.recent[len(r.monitor.recent)-r.monitor.size:]
+	}
+}
@@ -0,0 +1,194 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// RunOptions configures a run started with RunWithOptions
+type RunOptions struct {
+	ContextVariables map[string]interface{} // Variables passed to instructions and tools
+	ModelOverride    string                 // Model to use instead of the agent's model
+	Stream           bool                   // Whether streaming was requested
+	Debug            bool                   // Whether to enable debug logging
+	MaxTurns         int                    // Maximum number of model turns, DefaultMaxTurns if not positive
+	SkipTools        bool                   // Stop at the first tool calls instead of executing them
+
+	// DryRun skips tool execution. Each tool call is recorded in Response.Plan and answered
+	// with a stub so the agent can continue planning its next steps.
+	DryRun bool
+	// DryRunStubs maps tool names to the static results returned for them during a dry run
+	DryRunStubs map[string]interface{}
+	// PredictDryRunResults asks the model to predict the results of tools without a stub
+	PredictDryRunResults bool
+
+	// BestOfN samples this many completions in parallel on every turn and continues with the
+	// highest scoring one. The other candidates are kept in Turn.Candidates.
+	BestOfN int
+	// Scorer rates best-of-N candidates; a JudgeScorer using the run's model if nil
+	Scorer Scorer
+	// Choices requests this many completions in a single request on every turn, for providers
+	// that support it (n > 1). All of them are kept in Turn.Candidates and the turn continues
+	// with the one ChoicePolicy selects. Ignored when BestOfN is set.
+	Choices int
+	// ChoicePolicy selects the choice a turn continues with, ChoiceFirst by default
+	ChoicePolicy ChoicePolicy
+
+	// MaxContinuations is how many times a response cut off at the output token limit is
+	// continued with another request. The pieces are joined into one message, so callers get
+	// the complete answer; a response still cut off after that many has FinishReasonLength.
+	MaxContinuations int
+
+	// Stop lists sequences that end generation on every turn
+	Stop []string
+	// Grammar constrains decoding on every turn so outputs always parse, for backends that
+	// support it. Backends that cannot enforce it fail with llm.ErrGrammarNotSupported.
+	Grammar *llm.Grammar
+
+	// OnToolProgress receives progress updates reported by tools with ReportProgress
+	OnToolProgress func(ToolProgress)
+
+	// MaxTokens is the run's token budget, counting the runs nested in it. Zero means no
+	// budget of its own; a nested run is still limited by its share of the enclosing budget.
+	MaxTokens int
+	// BudgetShare is the fraction of the enclosing run's remaining token budget a nested run
+	// may use, DefaultBudgetShare if zero
+	BudgetShare float64
+
+	// RunID identifies the run in audit records and tool idempotency keys instead of a random
+	// ID. Pass the ID of an interrupted run to resume it without re-executing its tool calls.
+	RunID string
+
+	// CompensateOnError undoes the tool calls of a run that fails by running their
+	// compensations in reverse order. Runs started with a context carrying a Saga record into
+	// it and compensate all of its steps; otherwise the run uses its own saga.
+	CompensateOnError bool
+
+	// InstructionsPrefix and InstructionsSuffix are placed before and after the instructions
+	// of every agent in the run, e.g. for tenant policies, the current date or the locale.
+	// Skill instructions and other additions follow the suffix.
+	InstructionsPrefix string
+	InstructionsSuffix string
+
+	// PromptVariant serves the named prompt variant to agents that have one, instead of
+	// selecting by weight
+	PromptVariant string
+	// ExperimentKey, such as a session or user ID, makes variant selection deterministic so
+	// the same key is always served the same variant
+	ExperimentKey string
+
+	// ToolChoice sets whether the model may, must or must not call tools on the first turn of
+	// the run, e.g. llm.ForceTool("classify") to always classify first. It takes precedence
+	// over the agent's ToolChoice.
+	ToolChoice *llm.ToolChoice
+
+	// Tenant is the tenant, such as a customer or user, whose quota the run is accounted to
+	// when the swarm has one. Runs nested in the run are accounted to it too.
+	Tenant string
+
+	// Timeout bounds the duration of the run, including its provider requests, tool calls and
+	// nested runs. A run that exceeds it fails with ErrRunTimeout.
+	Timeout time.Duration
+	// ServiceTier is the provider tier the requests of the run and its nested runs are served
+	// at, e.g. llm.ServiceTierPriority for latency-critical interactive runs and
+	// llm.ServiceTierFlex for background batch jobs sharing the swarm
+	ServiceTier llm.ServiceTier
+	// Residency restricts the requests of the run and its nested runs to the swarm's regions
+	// with this residency, e.g. EU for data that must stay in the EU
+	Residency string
+
+	// Headers and QueryParams are added to every provider request of the run and its nested
+	// runs, e.g. a gateway's API key or routing hints for LiteLLM or Portkey. They take
+	// precedence over the agents' RequestHeaders and RequestQuery.
+	Headers     map[string]string
+	QueryParams map[string]string
+	// GatewayMetadata tags the requests of the run and its nested runs sent through an
+	// llm.Gateway, e.g. with the feature or customer, to break down spend in the gateway
+	GatewayMetadata map[string]string
+}
+
+// dryRunPrompt asks the model to predict a tool result during a dry run
+const dryRunPrompt = `You are simulating a tool for a dry run. Predict a realistic result of calling the tool below and reply with only the result, without explanation.
+
+Tool: %s
+Description: %s
+Arguments: %s`
+
+// dryRunToolCall answers a tool call without executing it
+func (s *Swarm) dryRunToolCall(ctx context.Context, toolCall *llm.ToolCall, agent *Agent, opts RunOptions) (Response, error) {
+	name := toolCall.Function.Name
+
+	var args interface{}
+	_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
+
+	var data interface{}
+	if stub, ok := opts.DryRunStubs[name]; ok {
+		data = stub
+	} else if opts.PredictDryRunResults {
+		predicted, err := s.predictToolResult(ctx, toolCall, agent, opts.ModelOverride)
+		if err != nil {
+			return Response{}, err
+		}
+		data = predicted
+	} else {
+		data = messagesFromContext(ctx).format(MessageDryRun, name)
+	}
+
+	result := Result{Success: true, Data: data}
+	return Response{
+		Messages: []llm.Message{s.toolMessage(toolCall, resultContent(messagesFromContext(ctx), result))},
+		ToolResults: []ToolResult{{
+			ToolCallID: toolCall.ID,
+			ToolName:   name,
+			Args:       args,
+			Result:     result,
+		}},
+	}, nil
+}
+
+// predictToolResult asks the model what a tool would return
+func (s *Swarm) predictToolResult(ctx context.Context, toolCall *llm.ToolCall, agent *Agent, modelOverride string) (string, error) {
+	var description string
+	for _, af := range agent.allFunctions() {
+		if af.Name == toolCall.Function.Name {
+			description = af.Description
+			break
+		}
+	}
+
+	model, err := agent.resolveModel(modelOverride)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.enforcePolicy(agent, model, nil); err != nil {
+		return "", err
+	}
+
+	req := llm.ChatCompletionRequest{
+		Model: model,
+		Messages: []llm.Message{{
+			Role:    llm.RoleUser,
+			Content: fmt.Sprintf(dryRunPrompt, toolCall.Function.Name, description, toolCall.Function.Arguments),
+		}},
+	}
+	if err := s.auditRequest(ctx, agent, req); err != nil {
+		return "", err
+	}
+
+	client, err := s.clientFor(agent)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.CreateChatCompletion(providerContext(ctx, agent), req)
+	if err != nil {
+		return "", fmt.Errorf("failed to predict result of %s: %w", toolCall.Function.Name, err)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no choices in prediction for %s", toolCall.Function.Name)
+	}
+	return resp.Choices[0].Message.Content, nil
+}
@@ -0,0 +1,130 @@
+package swarmgo
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// Names of the scratchpad tools
+const (
+	ScratchpadWriteTool = "write_scratchpad"
+	ScratchpadReadTool  = "read_scratchpad"
+)
+
+// scratchpadKey is the context variable holding the scratchpad notes of the current run. It is
+// removed when the run ends, so notes never outlive the run.
+const scratchpadKey = "_scratchpad"
+
+// scratchpadInstructions explains the scratchpad tools to the model
+const scratchpadInstructions = `You have a private scratchpad for planning. Write plans, intermediate results and reasoning with write_scratchpad and read them back with read_scratchpad. The user never sees the scratchpad, and it is cleared when you finish answering.`
+
+// ScratchpadSkill returns a skill that gives the model a scratchpad for planning, with the
+// write_scratchpad and read_scratchpad tools. Notes last for the run only, and the scratchpad
+// tool calls are left out of Response.Messages and Response.ToolResults, so they appear
+// neither in transcripts nor in session history. Response.Turns still records them.
+func ScratchpadSkill() *Skill {
+	write, _ := NewAgentFunction(ScratchpadWriteTool, "Write a note to your private scratchpad", func(args struct {
+		Note string `json:"note" jsonschema:"required,description=The note to keep"`
+	}, contextVariables map[string]interface{}) Result {
+		if strings.TrimSpace(args.Note) == "" {
+			return Result{Success: false, Error: fmt.Errorf("note is required")}
+		}
+		notes, _ := contextVariables[scratchpadKey].([]string)
+		contextVariables[scratchpadKey] = append(notes, args.Note)
+		return Result{Success: true, Data: fmt.Sprintf("Noted (%d notes on the scratchpad).", len(notes)+1)}
+	})
+
+	read, _ := NewAgentFunction(ScratchpadReadTool, "Read the notes on your private scratchpad", func(args struct{}, contextVariables map[string]interface{}) Result {
+		notes, _ := contextVariables[scratchpadKey].([]string)
+		if len(notes) == 0 {
+			return Result{Success: true, Data: "The scratchpad is empty."}
+		}
+		var b strings.Builder
+		for i, note := range notes {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, note)
+		}
+		return Result{Success: true, Data: b.String()}
+	})
+
+	return NewSkill("Scratchpad", scratchpadInstructions, write, read).
+		WithDescription("Gives the model a private scratchpad for planning")
+}
+
+// WithScratchpad gives the agent a private scratchpad for planning
+func (a *Agent) WithScratchpad() *Agent {
+	return a.WithSkills(ScratchpadSkill())
+}
+
+// isScratchpadTool reports whether the tool is one of the scratchpad tools
+func isScratchpadTool(name string) bool {
+	return name == ScratchpadWriteTool || name == ScratchpadReadTool
+}
+
+// stripScratchpad returns the messages without scratchpad tool calls and their results. The
+// messages are returned as is if they contain none.
+func stripScratchpad(messages []llm.Message) []llm.Message {
+	found := false
+	for _, msg := range messages {
+		if isScratchpadTool(msg.Name) && (msg.Role == llm.RoleTool || msg.Role == llm.RoleFunction) {
+			found = true
+			break
+		}
+		for _, call := range msg.ToolCalls {
+			if isScratchpadTool(call.Function.Name) {
+				found = true
+			}
+		}
+		if found {
+			break
+		}
+	}
+	if !found {
+		return messages
+	}
+
+	stripped := make([]llm.Message, 0, len(messages))
+	for _, msg := range messages {
+		if isScratchpadTool(msg.Name) && (msg.Role == llm.RoleTool || msg.Role == llm.RoleFunction) {
+			continue
+		}
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]llm.ToolCall, 0, len(msg.ToolCalls))
+			for _, call := range msg.ToolCalls {
+				if !isScratchpadTool(call.Function.Name) {
+					calls = append(calls, call)
+				}
+			}
+			if len(calls) == 0 && msg.Content == "" {
+				continue
+			}
+			msg.ToolCalls = calls
+			if len(calls) == 0 {
+				msg.ToolCalls = nil
+			}
+		}
+		stripped = append(stripped, msg)
+	}
+	return stripped
+}
+
+// stripScratchpadResults returns the tool results without those of scratchpad tools
+func stripScratchpadResults(results []ToolResult) []ToolResult {
+	var stripped []ToolResult
+	for i, result := range results {
+		if !isScratchpadTool(result.ToolName) {
+			if stripped != nil {
+				stripped = append(stripped, result)
+			}
+			continue
+		}
+		if stripped == nil {
+			stripped = append(make([]ToolResult, 0, len(results)), results[:i]...)
+		}
+	}
+	if stripped == nil {
+		return results
+	}
+	return stripped
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/prathyushnallamothu/swarmgo"
+)
+
+// Server exposes agents of a swarm to web frontends over SSE and WebSocket
+type Server struct {
+	swarm          *swarmgo.Swarm
+	agents         map[string]*swarmgo.Agent
+	defaultAgent   string
+	allowedOrigins []string
+	onApproval     ApprovalFunc
+	upgrader       websocket.Upgrader
+	mutex          sync.Mutex
+}
+
+// NewServer creates a new server for the given swarm
+func NewServer(swarm *swarmgo.Swarm) *Server {
+	s := &Server{
+		swarm:  swarm,
+		agents: make(map[string]*swarmgo.Agent),
+	}
+	s.upgrader.CheckOrigin = s.checkOrigin
+	return s
+}
+
+// RegisterAgent makes an agent available to clients. The first registered agent is the default.
+func (s *Server) RegisterAgent(agent *swarmgo.Agent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.defaultAgent == "" {
+		s.defaultAgent = agent.Name
+	}
+	s.agents[agent.Name] = agent
+}
+
+// SetApprovalHandler sets the callback notified of the answers clients give to approval requests
+func (s *Server) SetApprovalHandler(fn ApprovalFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onApproval = fn
+}
+
+// SetAllowedOrigins allows WebSocket connections from pages on the given origins, such as
+// "https://app.example.com", besides those served from the server's own host. "*" allows
+// any origin.
+func (s *Server) SetAllowedOrigins(origins ...string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.allowedOrigins = origins
+}
+
+// Handler returns an http.Handler serving /stream (SSE) and /ws (WebSocket)
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stream", s.handleSSE)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+	return mux
+}
+
+// checkOrigin reports whether a WebSocket connection may be opened from the request's origin:
+// requests without one, such as from non-browser clients, those from the server's own host
+// and those from an allowed origin are accepted
+func (s *Server) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupAgent returns the named agent, or the default agent when name is empty
+func (s *Server) lookupAgent(name string) (*swarmgo.Agent, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if name == "" {
+		name = s.defaultAgent
+	}
+	agent, exists := s.agents[name]
+	if !exists {
+		return nil, errors.New("unknown agent: " + name)
+	}
+	return agent, nil
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/prathyushnallamothu/swarmgo"
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider serves the streamed responses in turn as an OpenAI-compatible API, recording
+// the requests it receives
+type fakeProvider struct {
+	*httptest.Server
+	mutex     sync.Mutex
+	responses [][]string
+	requests  []map[string]interface{}
+}
+
+func newFakeProvider(t *testing.T, responses ...[]string) *fakeProvider {
+	p := &fakeProvider{responses: responses}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+
+		p.mutex.Lock()
+		p.requests = append(p.requests, body)
+		chunks := p.responses[0]
+		if len(p.responses) > 1 {
+			p.responses = p.responses[1:]
+		}
+		p.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func textResponse(text string) []string {
+	return []string{
+		`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","content":"` + text + `"}}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+		`{"id":"1","choices":[],"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`,
+	}
+}
+
+func toolCallResponse(id, name string) []string {
+	return []string{
+		`{"id":"1","choices":[{"index":0,"delta":{"role":"assistant","tool_calls":[{"index":0,"id":"` + id + `","type":"function","function":{"name":"` + name + `","arguments":"{}"}}]}}]}`,
+		`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	}
+}
+
+func newTestServer(provider *fakeProvider, agent *swarmgo.Agent) (*Server, *httptest.Server) {
+	s := NewServer(swarmgo.NewSwarmWithHost("test-key", provider.URL+"/v1", llm.OpenAI))
+	s.RegisterAgent(agent)
+	ts := httptest.NewServer(s.Handler())
+	return s, ts
+}
+
+func TestSSE(t *testing.T) {
+	provider := newFakeProvider(t, textResponse("Hi"))
+	_, ts := newTestServer(provider, swarmgo.NewAgent("Assistant", "gpt-4o", llm.OpenAI))
+	defer ts.Close()
+
+	body := `{"messages":[{"role":"user","content":"Hello"}]}`
+	resp, err := http.Post(ts.URL+"/stream", "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	var events []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event Event
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			events = append(events, event)
+		}
+	}
+
+	var types []EventType
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []EventType{EventStart, EventDelta, EventUsage, EventComplete}, types)
+	assert.Equal(t, "Hi", events[1].Delta)
+	assert.Equal(t, 4, events[2].Usage.TotalTokens)
+	assert.Equal(t, "Hi", events[3].Message.Content)
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	provider := newFakeProvider(t, textResponse("Hi"))
+	s, ts := newTestServer(provider, swarmgo.NewAgent("Assistant", "gpt-4o", llm.OpenAI))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	dial := func(origin string) (*http.Response, error) {
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, http.Header{"Origin": {origin}})
+		if err == nil {
+			conn.Close()
+		}
+		return resp, err
+	}
+
+	// Pages served by the server itself may connect, others may not
+	_, err := dial(ts.URL)
+	assert.NoError(t, err)
+	resp, err := dial("https://evil.example")
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	s.SetAllowedOrigins("https://app.example")
+	_, err = dial("https://app.example")
+	assert.NoError(t, err)
+	_, err = dial("https://evil.example")
+	assert.Error(t, err)
+}
+
+func TestWebSocketApproval(t *testing.T) {
+	provider := newFakeProvider(t, toolCallResponse("call_1", "delete_all"), textResponse("Kept"))
+	ran := false
+	deleteAll, err := swarmgo.NewAgentFunction("delete_all", "Deletes everything",
+		func(args struct{}, contextVariables map[string]interface{}) swarmgo.Result {
+			ran = true
+			return swarmgo.Result{Success: true, Data: "deleted"}
+		})
+	require.NoError(t, err)
+	agent := swarmgo.NewAgent("Assistant", "gpt-4o", llm.OpenAI).WithFunctions(deleteAll.WithApproval())
+
+	s, ts := newTestServer(provider, agent)
+	defer ts.Close()
+	var answers []bool
+	s.SetApprovalHandler(func(sessionID, toolCallID string, approved bool) {
+		answers = append(answers, approved)
+	})
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: ClientUserInput, SessionID: "s1", Content: "Delete everything"}))
+
+	// The tool waits for the client's answer
+	var event Event
+	for event.Type != EventApproval {
+		require.NoError(t, conn.ReadJSON(&event))
+		require.NotEqual(t, EventComplete, event.Type)
+	}
+	assert.Equal(t, "s1", event.SessionID)
+	assert.Equal(t, "call_1", event.ToolCall.ID)
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: ClientApproval, SessionID: "s1", ToolCallID: "call_1", Approved: false}))
+
+	var usage *llm.Usage
+	for event.Type != EventComplete {
+		require.NoError(t, conn.ReadJSON(&event))
+		require.NotEqual(t, EventError, event.Type, event.Error)
+		if event.Type == EventUsage {
+			usage = event.Usage
+		}
+	}
+	assert.Equal(t, "Kept", event.Message.Content)
+	assert.NotNil(t, usage)
+	assert.False(t, ran)
+	assert.Equal(t, []bool{false}, answers)
+
+	// The model is told the call was denied
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	require.Len(t, provider.requests, 2)
+	messages := provider.requests[1]["messages"].([]interface{})
+	denial := messages[len(messages)-1].(map[string]interface{})
+	assert.Equal(t, "Error: Tool delete_all was not approved.", denial["content"])
+}
+
+func TestWebSocketSessionsPerConnection(t *testing.T) {
+	provider := newFakeProvider(t, textResponse("Hi"))
+	_, ts := newTestServer(provider, swarmgo.NewAgent("Assistant", "gpt-4o", llm.OpenAI))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http") + "/ws"
+
+	// A second client using the same session ID does not see the first one's conversation
+	for _, content := range []string{"Secret", "Hello"} {
+		conn, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteJSON(ClientMessage{Type: ClientUserInput, SessionID: "shared", Content: content}))
+		var event Event
+		for event.Type != EventComplete {
+			require.NoError(t, conn.ReadJSON(&event))
+		}
+		conn.Close()
+	}
+
+	provider.mutex.Lock()
+	defer provider.mutex.Unlock()
+	require.Len(t, provider.requests, 2)
+	messages := provider.requests[1]["messages"].([]interface{})
+	for _, message := range messages {
+		assert.NotEqual(t, "Secret", message.(map[string]interface{})["content"])
+	}
+}
+
+func TestStreamErrorReportedOnce(t *testing.T) {
+	// The provider rejects requests asking it to fail
+	ok := newFakeProvider(t, textResponse("Hi"))
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if strings.Contains(string(body), "Fail") {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"error":{"message":"bad request","type":"invalid_request_error"}}`)
+			return
+		}
+		proxied, err := http.Post(ok.URL+r.URL.Path, "application/json", strings.NewReader(string(body)))
+		require.NoError(t, err)
+		defer proxied.Body.Close()
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.Copy(w, proxied.Body)
+	}))
+	defer provider.Close()
+	s := NewServer(swarmgo.NewSwarmWithHost("test-key", provider.URL+"/v1", llm.OpenAI))
+	s.RegisterAgent(swarmgo.NewAgent("Assistant", "gpt-4o", llm.OpenAI))
+	ts := httptest.NewServer(s.Handler())
+	defer ts.Close()
+
+	countErrors := func(events []Event) int {
+		n := 0
+		for _, event := range events {
+			if event.Type == EventError {
+				n++
+			}
+		}
+		return n
+	}
+
+	// Over SSE
+	resp, err := http.Post(ts.URL+"/stream", "application/json", strings.NewReader(`{"messages":[{"role":"user","content":"Fail"}]}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var events []Event
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+			var event Event
+			require.NoError(t, json.Unmarshal([]byte(data), &event))
+			events = append(events, event)
+		}
+	}
+	assert.Equal(t, 1, countErrors(events))
+
+	// Over WebSocket, everything sent until the next turn completes follows the failed turn
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/ws", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: ClientUserInput, SessionID: "s1", Content: "Fail"}))
+	var event Event
+	for event.Type != EventError {
+		require.NoError(t, conn.ReadJSON(&event))
+	}
+	require.NoError(t, conn.WriteJSON(ClientMessage{Type: ClientUserInput, SessionID: "s2", Content: "Hello"}))
+	events = nil
+	for event.Type != EventComplete {
+		require.NoError(t, conn.ReadJSON(&event))
+		events = append(events, event)
+	}
+	assert.Equal(t, 0, countErrors(events))
+}
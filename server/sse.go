@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/prathyushnallamothu/swarmgo"
+)
+
+// handleSSE streams a single response as Server-Sent Events. Clients cannot answer approval
+// requests over SSE, so calls to tools needing approval are denied.
+func (s *Server) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req StreamRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	agent, err := s.lookupAgent(req.Agent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	it := s.swarm.Stream(r.Context(), agent, req.Messages, req.ContextVariables, "", false)
+	defer it.Close()
+
+	// Errors the stream reported as events are not sent again when it ends
+	reported := false
+	for it.Next() {
+		event, ok := toEvent(it.Event())
+		if !ok {
+			continue
+		}
+		if err := writeSSE(w, event); err != nil {
+			return
+		}
+		flusher.Flush()
+		reported = reported || event.Type == EventError
+	}
+	if err := it.Err(); err != nil && !reported {
+		writeSSE(w, Event{Type: EventError, Error: err.Error()})
+		flusher.Flush()
+	}
+}
+
+// writeSSE writes a single event in SSE framing
+func writeSSE(w http.ResponseWriter, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// toEvent converts a swarm stream event to a client event, reporting false for events
+// clients are not sent, such as heartbeats
+func toEvent(event swarmgo.StreamEvent) (Event, bool) {
+	switch event.Type {
+	case swarmgo.StreamEventStart:
+		return Event{Type: EventStart}, true
+	case swarmgo.StreamEventToken:
+		return Event{Type: EventDelta, Delta: event.Token}, true
+	case swarmgo.StreamEventToolCallReady:
+		return Event{Type: EventToolCallReady, ToolCall: event.ToolCall}, true
+	case swarmgo.StreamEventToolCall:
+		return Event{Type: EventToolCall, ToolCall: event.ToolCall}, true
+	case swarmgo.StreamEventUsage:
+		return Event{Type: EventUsage, Usage: event.Usage}, true
+	case swarmgo.StreamEventComplete:
+		return Event{Type: EventComplete, Message: event.Message}, true
+	case swarmgo.StreamEventError:
+		var message string
+		if event.Err != nil {
+			message = event.Err.Error()
+		}
+		return Event{Type: EventError, Error: message}, true
+	default:
+		return Event{}, false
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// EventType represents the types of events sent to clients
+type EventType string
+
+const (
+	EventStart         EventType = "start"
+	EventDelta         EventType = "delta"
+	EventToolCallReady EventType = "tool_call_ready"
+	EventToolCall      EventType = "tool_call"
+	EventApproval      EventType = "approval" // A tool call waits for the client's approval
+	EventUsage         EventType = "usage"
+	EventComplete      EventType = "complete"
+	EventInterrupted   EventType = "interrupted"
+	EventError         EventType = "error"
+)
+
+// Event represents an event sent to a client over SSE or WebSocket
+type Event struct {
+	Type      EventType     `json:"type"`
+	SessionID string        `json:"session_id,omitempty"`
+	Delta     string        `json:"delta,omitempty"`
+	ToolCall  *llm.ToolCall `json:"tool_call,omitempty"`
+	Message   *llm.Message  `json:"message,omitempty"`
+	Usage     *llm.Usage    `json:"usage,omitempty"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ClientMessageType represents the types of messages accepted from WebSocket clients
+type ClientMessageType string
+
+const (
+	ClientUserInput ClientMessageType = "user_input"
+	ClientCancel    ClientMessageType = "cancel"
+	ClientApproval  ClientMessageType = "approval"
+)
+
+// ClientMessage represents a message received from a WebSocket client
+type ClientMessage struct {
+	Type       ClientMessageType `json:"type"`
+	SessionID  string            `json:"session_id,omitempty"`
+	Agent      string            `json:"agent,omitempty"`
+	Content    string            `json:"content,omitempty"`
+	ToolCallID string            `json:"tool_call_id,omitempty"`
+	Approved   bool              `json:"approved,omitempty"`
+}
+
+// StreamRequest represents the body of an SSE stream request
+type StreamRequest struct {
+	Agent            string                 `json:"agent"`
+	Messages         []llm.Message          `json:"messages"`
+	ContextVariables map[string]interface{} `json:"context_variables,omitempty"`
+}
+
+// ApprovalFunc is called when a client answers an approval request for a tool call, e.g. to
+// audit the answer. The answer itself decides whether the tool runs.
+type ApprovalFunc func(sessionID, toolCallID string, approved bool)
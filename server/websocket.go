@@ -0,0 +1,207 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/prathyushnallamothu/swarmgo"
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// wsConn is a client's WebSocket connection. Sessions belong to the connection that created
+// them, so clients cannot reach each other's sessions, and end when it closes.
+type wsConn struct {
+	conn      *websocket.Conn
+	mutex     sync.Mutex // Serializes writes
+	sessions  map[string]*swarmgo.Session
+	approvals map[string]chan bool // Answers awaited by tool call ID
+	pending   sync.Mutex           // Guards approvals
+}
+
+func (c *wsConn) send(event Event) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.conn.WriteJSON(event)
+}
+
+// session returns the connection's session with the given ID, creating one for the agent if it
+// does not exist
+func (c *wsConn) session(s *Server, id, agentName string) (*swarmgo.Session, error) {
+	if session, exists := c.sessions[id]; exists {
+		return session, nil
+	}
+
+	agent, err := s.lookupAgent(agentName)
+	if err != nil {
+		return nil, err
+	}
+
+	session := swarmgo.NewSession(s.swarm, agent)
+	if id != "" {
+		session.ID = id
+	}
+	c.sessions[session.ID] = session
+	return session, nil
+}
+
+// approver asks the client to approve the session's tool calls needing approval, waiting for
+// its answer
+func (c *wsConn) approver(sessionID string) swarmgo.ToolApprover {
+	return func(ctx context.Context, toolCall llm.ToolCall) (bool, error) {
+		answer := make(chan bool, 1)
+		c.pending.Lock()
+		c.approvals[toolCall.ID] = answer
+		c.pending.Unlock()
+		defer func() {
+			c.pending.Lock()
+			delete(c.approvals, toolCall.ID)
+			c.pending.Unlock()
+		}()
+
+		if err := c.send(Event{Type: EventApproval, SessionID: sessionID, ToolCall: &toolCall}); err != nil {
+			return false, err
+		}
+		select {
+		case approved := <-answer:
+			return approved, nil
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+}
+
+// answer passes the client's answer to the run awaiting approval of the tool call, reporting
+// whether one was
+func (c *wsConn) answer(toolCallID string, approved bool) bool {
+	c.pending.Lock()
+	defer c.pending.Unlock()
+	answer, waiting := c.approvals[toolCallID]
+	if !waiting {
+		return false
+	}
+	delete(c.approvals, toolCallID)
+	answer <- approved
+	return true
+}
+
+// wsStreamHandler forwards stream callbacks to a WebSocket client as typed events
+type wsStreamHandler struct {
+	conn      *wsConn
+	sessionID string
+	reported  bool // Whether an error was sent to the client
+}
+
+func (h *wsStreamHandler) OnStart() {
+	h.conn.send(Event{Type: EventStart, SessionID: h.sessionID})
+}
+
+func (h *wsStreamHandler) OnToken(token string) {
+	h.conn.send(Event{Type: EventDelta, SessionID: h.sessionID, Delta: token})
+}
+
+func (h *wsStreamHandler) OnToolCallReady(toolCall llm.ToolCall) {
+	h.conn.send(Event{Type: EventToolCallReady, SessionID: h.sessionID, ToolCall: &toolCall})
+}
+
+func (h *wsStreamHandler) OnToolCall(toolCall llm.ToolCall) {
+	h.conn.send(Event{Type: EventToolCall, SessionID: h.sessionID, ToolCall: &toolCall})
+}
+
+func (h *wsStreamHandler) OnUsage(usage llm.Usage) {
+	h.conn.send(Event{Type: EventUsage, SessionID: h.sessionID, Usage: &usage})
+}
+
+func (h *wsStreamHandler) OnComplete(message llm.Message) {
+	h.conn.send(Event{Type: EventComplete, SessionID: h.sessionID, Message: &message})
+}
+
+func (h *wsStreamHandler) OnError(err error) {
+	if errors.Is(err, context.Canceled) {
+		return // Reported as an interrupted event once the stream has stopped
+	}
+	h.reported = true
+	h.conn.send(Event{Type: EventError, SessionID: h.sessionID, Error: err.Error()})
+}
+
+// handleWebSocket runs an interactive protocol: clients send user input, cancel and
+// approval messages, and receive typed events for every session they talk to. Calls to tools
+// needing approval wait for the client to answer an approval event.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	c, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("Upgrade error:", err)
+		return
+	}
+	conn := &wsConn{
+		conn:      c,
+		sessions:  make(map[string]*swarmgo.Session),
+		approvals: make(map[string]chan bool),
+	}
+	defer c.Close()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	for {
+		var msg ClientMessage
+		if err := c.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case ClientUserInput:
+			session, err := conn.session(s, msg.SessionID, msg.Agent)
+			if err != nil {
+				conn.send(Event{Type: EventError, SessionID: msg.SessionID, Error: err.Error()})
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.streamToClient(ctx, conn, session, msg.Content)
+			}()
+
+		case ClientCancel:
+			if session, exists := conn.sessions[msg.SessionID]; exists {
+				session.Cancel()
+			}
+
+		case ClientApproval:
+			if !conn.answer(msg.ToolCallID, msg.Approved) {
+				conn.send(Event{Type: EventError, SessionID: msg.SessionID, Error: "no approval pending for tool call: " + msg.ToolCallID})
+				continue
+			}
+			s.mutex.Lock()
+			onApproval := s.onApproval
+			s.mutex.Unlock()
+			if onApproval != nil {
+				onApproval(msg.SessionID, msg.ToolCallID, msg.Approved)
+			}
+
+		default:
+			conn.send(Event{Type: EventError, SessionID: msg.SessionID, Error: "unknown message type: " + string(msg.Type)})
+		}
+	}
+}
+
+// streamToClient streams a session turn to the client and reports interruptions and errors
+// the stream did not report itself
+func (s *Server) streamToClient(ctx context.Context, conn *wsConn, session *swarmgo.Session, content string) {
+	handler := &wsStreamHandler{conn: conn, sessionID: session.ID}
+
+	// Input arriving while the session is streaming barges in on the response
+	err := session.Interrupt(swarmgo.WithToolApprover(ctx, conn.approver(session.ID)), content, handler)
+	switch {
+	case errors.Is(err, swarmgo.ErrStreamInterrupted):
+		conn.send(Event{Type: EventInterrupted, SessionID: session.ID})
+	case err != nil && !handler.reported:
+		conn.send(Event{Type: EventError, SessionID: session.ID, Error: err.Error()})
+	}
+}
@@ -0,0 +1,374 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrStreamInterrupted is returned when a session's stream is stopped with Cancel or Interrupt
+var ErrStreamInterrupted = errors.New("stream interrupted")
+
+// ContinuePrompt is the user message sent by Session.Continue to resume an interrupted response
+const ContinuePrompt = "Continue exactly where you left off."
+
+// Session holds an ongoing conversation with an agent across multiple runs
+type Session struct {
+	ID               string                 // Unique identifier of the session
+	ParentID         string                 // Session this one was forked from, if any
+	Agent            *Agent                 // Currently active agent
+	Messages         []llm.Message          // Conversation history
+	ContextVariables map[string]interface{} // Context variables carried between runs
+	Metadata         map[string]interface{} // Arbitrary caller-defined metadata
+	MaxTurns         int                    // Maximum turns per run
+	Model            string                 // Model override for runs, empty to use the agent's model
+	Debug            bool                   // Whether to enable debug logging
+	Participants     []string               // Humans taking part in a group chat, see SendAs
+	GroupAgents      []*Agent               // Agents taking part in a group chat, see WithGroupChat
+	TurnPolicy       TurnPolicy             // How the agents of a group chat take turns
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+
+	swarm       *Swarm
+	mu          sync.Mutex
+	cancel      context.CancelFunc // Cancels the in-flight stream or run, if any
+	streamDone  chan struct{}      // Closed once the in-flight stream or run has recorded its messages
+	interrupted bool               // Set when Cancel stopped the in-flight stream
+	addedAt     []time.Time        // When each message was added, parallel to Messages
+	files       []string           // Files uploaded for the session, deleted by End
+	attachments []llm.FileRef      // Uploaded files attached to the next user message
+	nextSpeaker int                // Index of the group agent answering next with TurnRoundRobin
+}
+
+// NewSession creates a new session with the given agent
+func NewSession(swarm *Swarm, agent *Agent) *Session {
+	now := time.Now()
+	return &Session{
+		ID:               swarm.newID(),
+		Agent:            agent,
+		Messages:         make([]llm.Message, 0),
+		ContextVariables: make(map[string]interface{}),
+		Metadata:         make(map[string]interface{}),
+		MaxTurns:         5,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+		swarm:            swarm,
+	}
+}
+
+// History returns a copy of the conversation history
+func (s *Session) History() []llm.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history := make([]llm.Message, len(s.Messages))
+	copy(history, s.Messages)
+	return history
+}
+
+// Fork creates a new session continuing from the first index messages of this one, so an
+// alternative path can be explored without changing the original. The sessions share the
+// earlier messages and memories until either adds to them. The active agent is copied with
+// a snapshot of its memory; context variables and metadata are copied shallowly.
+func (s *Session) Fork(index int) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if index < 0 || index > len(s.Messages) {
+		return nil, fmt.Errorf("fork index %d out of range [0, %d]", index, len(s.Messages))
+	}
+
+	agent := s.Agent
+	if agent != nil {
+		forked := *agent
+		if agent.Memory != nil {
+			forked.Memory = agent.Memory.Snapshot()
+		}
+		agent = &forked
+	}
+
+	fork := NewSession(s.swarm, agent)
+	fork.ParentID = s.ID
+	// Capping the slice makes the first append copy it, leaving the original untouched
+	fork.Messages = s.Messages[:index:index]
+	if index <= len(s.addedAt) {
+		fork.addedAt = s.addedAt[:index:index]
+	}
+	for k, v := range s.ContextVariables {
+		fork.ContextVariables[k] = v
+	}
+	for k, v := range s.Metadata {
+		fork.Metadata[k] = v
+	}
+	fork.MaxTurns = s.MaxTurns
+	fork.Model = s.Model
+	fork.Debug = s.Debug
+	return fork, nil
+}
+
+// EditMessage replaces the content of the user message at index i and discards every
+// message after it, along with the tool results they carried and the agent memories recorded
+// since the message was sent. Call Regenerate(ctx, i+1) to get a new response.
+func (s *Session) EditMessage(i int, content string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errors.New("cannot edit messages while a stream is in progress")
+	}
+	if i < 0 || i >= len(s.Messages) {
+		return fmt.Errorf("message index %d out of range [0, %d)", i, len(s.Messages))
+	}
+	if s.Messages[i].Role != llm.RoleUser {
+		return fmt.Errorf("message %d is a %s message, only user messages can be edited", i, s.Messages[i].Role)
+	}
+
+	s.truncate(i)
+	s.appendMessages(llm.Message{Role: llm.RoleUser, Content: content})
+	return nil
+}
+
+// Regenerate discards the messages from fromIndex on, along with their tool results and the
+// agent memories recorded since, and runs the active agent again from that point. The
+// message before fromIndex must be a user message. If the run fails, the discarded messages
+// are restored.
+func (s *Session) Regenerate(ctx context.Context, fromIndex int) (Response, error) {
+	return s.run(ctx, func() (llm.Message, error) {
+		if fromIndex < 1 || fromIndex > len(s.Messages) {
+			return llm.Message{}, fmt.Errorf("regenerate index %d out of range [1, %d]", fromIndex, len(s.Messages))
+		}
+		if s.Messages[fromIndex-1].Role != llm.RoleUser {
+			return llm.Message{}, fmt.Errorf("message %d is a %s message, regeneration must follow a user message", fromIndex-1, s.Messages[fromIndex-1].Role)
+		}
+
+		// The user message is run again and records its memory anew
+		last := s.Messages[fromIndex-1]
+		s.truncate(fromIndex - 1)
+		return last, nil
+	})
+}
+
+// truncate drops the messages from index i on and forgets the agent memories recorded since
+// the first of them was added. It must be called with the lock held.
+func (s *Session) truncate(i int) {
+	if i < len(s.addedAt) && s.Agent != nil && s.Agent.Memory != nil && !s.addedAt[i].IsZero() {
+		s.Agent.Memory.RemoveSince(s.addedAt[i])
+	}
+	// Copy rather than reslice so forks sharing the history keep the discarded messages
+	s.Messages = append([]llm.Message(nil), s.Messages[:i]...)
+	if i <= len(s.addedAt) {
+		s.addedAt = append([]time.Time(nil), s.addedAt[:i]...)
+	} else {
+		s.addedAt = nil
+	}
+	s.interrupted = false
+	s.UpdatedAt = time.Now()
+}
+
+// appendMessages adds messages to the history, recording when they were added. It must be
+// called with the lock held.
+func (s *Session) appendMessages(messages ...llm.Message) {
+	// Messages assigned directly leave the timestamps incomplete; only track whole histories
+	if len(s.addedAt) == len(s.Messages) {
+		now := time.Now()
+		for range messages {
+			s.addedAt = append(s.addedAt, now)
+		}
+	}
+	s.Messages = append(s.Messages, messages...)
+}
+
+// Send adds a user message to the session and runs the active agent
+func (s *Session) Send(ctx context.Context, content string) (Response, error) {
+	return s.run(ctx, func() (llm.Message, error) {
+		return llm.Message{Role: llm.RoleUser, Content: content}, nil
+	})
+}
+
+// run adds the message returned by next, called with the lock held, to the session and runs
+// the active agent. Nothing is added while a stream or another run is in progress. If the run
+// fails, the history is restored and the memories recorded since the message was added are
+// forgotten.
+func (s *Session) run(ctx context.Context, next func() (llm.Message, error)) (Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return Response{}, errors.New("session already has a run in progress")
+	}
+	messages, addedAt, attachments := s.Messages, s.addedAt, s.attachments
+	message, err := next()
+	if err != nil {
+		s.mu.Unlock()
+		return Response{}, err
+	}
+	s.appendMessages(s.takeAttachments(message))
+	sentAt := time.Now()
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.streamDone = done
+	s.interrupted = false
+	history := NewHistory(s.Messages...).Messages()
+	agent := s.Agent
+	swarm := s.swarm
+	model := s.Model
+	contextVariables := s.ContextVariables
+	debug := s.Debug
+	maxTurns := s.MaxTurns
+	s.mu.Unlock()
+
+	response, err := swarm.Run(ctx, agent, history, contextVariables, model, false, debug, maxTurns, true)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel = nil
+	s.streamDone = nil
+	defer close(done)
+	if err != nil {
+		s.Messages, s.addedAt, s.attachments = messages, addedAt, attachments
+		if agent != nil && agent.Memory != nil {
+			agent.Memory.RemoveSince(sentAt)
+		}
+		return response, err
+	}
+
+	s.appendMessages(response.Messages...)
+	if response.Compaction != nil {
+		s.applyCompaction(response.Compaction)
+	}
+	if response.Agent != nil {
+		s.Agent = response.Agent
+	}
+	s.UpdatedAt = time.Now()
+	return response, nil
+}
+
+// Stream adds a user message to the session and streams the active agent's response.
+// The stream can be stopped with Cancel, in which case the partial assistant message is
+// kept in the history marked as interrupted and ErrStreamInterrupted is returned.
+func (s *Session) Stream(ctx context.Context, content string, handler StreamHandler) error {
+	return s.stream(ctx, content, handler, false)
+}
+
+// Interrupt barges in on the in-flight stream, as when the user speaks or types while the
+// agent is responding: the stream is cancelled, its partial response is kept in the history
+// marked as interrupted, and the message is streamed as the next turn, with the agent told
+// where it was interrupted. Without a stream in progress it is the same as Stream.
+func (s *Session) Interrupt(ctx context.Context, content string, handler StreamHandler) error {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.interrupted = true
+		s.cancel()
+	}
+	done := s.streamDone
+	s.mu.Unlock()
+
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return s.stream(ctx, content, handler, true)
+}
+
+// Continue resumes after an interrupted stream by asking the agent to continue its partial response
+func (s *Session) Continue(ctx context.Context, handler StreamHandler) error {
+	s.mu.Lock()
+	interrupted := len(s.Messages) > 0 && s.Messages[len(s.Messages)-1].Interrupted
+	swarm := s.swarm
+	s.mu.Unlock()
+
+	if !interrupted {
+		return errors.New("no interrupted response to continue")
+	}
+	return s.Stream(ctx, swarm.messageCatalog().format(MessageContinue), handler)
+}
+
+// SwitchModel moves the session to another swarm (and so possibly another provider) and model.
+// The history is translated for the new provider so the conversation can continue where it left off.
+// An empty model keeps using the agent's model.
+func (s *Session) SwitchModel(swarm *Swarm, model string) error {
+	if swarm == nil {
+		return errors.New("swarm is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel != nil {
+		return errors.New("cannot switch models while a stream is in progress")
+	}
+	s.Messages = llm.TranslateHistory(s.Messages, swarm.provider)
+	s.swarm = swarm
+	s.Model = model
+	s.UpdatedAt = time.Now()
+	return nil
+}
+
+// Cancel stops the in-flight stream or run, if any, and reports whether one was running
+func (s *Session) Cancel() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cancel == nil {
+		return false
+	}
+	s.interrupted = true
+	s.cancel()
+	return true
+}
+
+// stream adds a user message to the session and runs the active agent in streaming mode
+// against the history. If the message barges in on an interrupted response, the agent is
+// told where it was interrupted. Nothing is added while another stream is in progress.
+func (s *Session) stream(ctx context.Context, content string, handler StreamHandler, bargeIn bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.mu.Unlock()
+		return errors.New("session already has a stream in progress")
+	}
+	s.appendMessages(s.takeAttachments(llm.Message{Role: llm.RoleUser, Content: content}))
+	done := make(chan struct{})
+	s.cancel = cancel
+	s.streamDone = done
+	s.interrupted = false
+	history := NewHistory(s.Messages...).Messages()
+	agent := s.Agent
+	swarm := s.swarm
+	model := s.Model
+	contextVariables := s.ContextVariables
+	debug := s.Debug
+	s.mu.Unlock()
+
+	if n := len(history); bargeIn && n >= 2 && history[n-2].Interrupted {
+		history = append([]llm.Message(nil), history...)
+		history[n-2].Content += "\n\n" + swarm.messageCatalog().format(MessageInterrupted)
+	}
+
+	produced, err := swarm.streamMessages(ctx, agent, history, contextVariables, model, handler, debug)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cancel = nil
+	s.streamDone = nil
+	s.appendMessages(produced...)
+	s.UpdatedAt = time.Now()
+	close(done)
+
+	if err != nil && s.interrupted {
+		return ErrStreamInterrupted
+	}
+	return err
+}
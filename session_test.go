@@ -0,0 +1,580 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// tokenRecorder signals every received token on a channel
+type tokenRecorder struct {
+	DefaultStreamHandler
+	tokens chan string
+}
+
+func (r *tokenRecorder) OnToken(token string) {
+	r.tokens <- token
+}
+
+// TestSessionStreamCancel tests that cancelling a stream keeps the partial message and allows continuing
+func TestSessionStreamCancel(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	session := NewSession(sw, &Agent{Name: "TestAgent"})
+
+	interrupted := &fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("Once upon")}, block: true}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		interrupted.ctx = args.Get(0).(context.Context)
+	}).Return(interrupted, nil).Once()
+
+	handler := &tokenRecorder{tokens: make(chan string, 10)}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- session.Stream(context.Background(), "Tell me a story", handler)
+	}()
+
+	select {
+	case <-handler.tokens:
+	case <-time.After(time.Second):
+		t.Fatal("no token received")
+	}
+	// Messages sent while the stream runs are rejected without being added
+	assert.Error(t, session.Stream(context.Background(), "Another one", handler))
+	assert.Len(t, session.History(), 1)
+
+	assert.True(t, session.Cancel())
+	assert.ErrorIs(t, <-errChan, ErrStreamInterrupted)
+
+	history := session.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, "Once upon", history[1].Content)
+	assert.True(t, history[1].Interrupted)
+
+	// The interrupted mark is not sent to the provider
+	var sent []llm.Message
+	resumed := &fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk(" a time.")}}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		sent = args.Get(1).(llm.ChatCompletionRequest).Messages
+	}).Return(resumed, nil).Once()
+
+	assert.NoError(t, session.Continue(context.Background(), handler))
+	for _, msg := range sent {
+		assert.False(t, msg.Interrupted)
+	}
+	assert.Equal(t, "Once upon", sent[len(sent)-2].Content)
+	history = session.History()
+	assert.Len(t, history, 4)
+	assert.Equal(t, ContinuePrompt, history[2].Content)
+	assert.Equal(t, " a time.", history[3].Content)
+	assert.False(t, history[3].Interrupted)
+}
+
+// TestSessionSwitchModel tests that switching providers translates tool call IDs and uses the new model
+func TestSessionSwitchModel(t *testing.T) {
+	session := NewSession(NewMockSwarm(new(MockLLM)), &Agent{Name: "TestAgent", Model: "gemini-pro"})
+	session.Messages = []llm.Message{
+		{Role: llm.RoleUser, Content: "Weather in Paris and Rome?"},
+		{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{
+			{Type: "function", Function: llm.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+			{Type: "function", Function: llm.ToolCallFunction{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+		}},
+		{Role: llm.RoleFunction, Name: "get_weather", Content: "Sunny"},
+		{Role: llm.RoleFunction, Name: "get_weather", Content: "Rainy"},
+		{Role: llm.RoleAssistant, Content: "Sunny in Paris, rainy in Rome."},
+	}
+
+	mockClient := new(MockLLM)
+	target := NewMockSwarm(mockClient)
+	target.provider = llm.OpenAI
+	assert.NoError(t, session.SwitchModel(target, "gpt-4o"))
+
+	history := session.History()
+	calls := history[1].ToolCalls
+	assert.NotEmpty(t, calls[0].ID)
+	assert.NotEqual(t, calls[0].ID, calls[1].ID)
+	assert.Equal(t, calls[0].ID, history[2].ToolCallID)
+	assert.Equal(t, calls[1].ID, history[3].ToolCallID)
+	assert.NoError(t, llm.ValidateToolMessages(history))
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+		return req.Model == "gpt-4o"
+	})).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Anything else?"}}},
+	}, nil).Once()
+
+	_, err := session.Send(context.Background(), "Thanks")
+	assert.NoError(t, err)
+	mockClient.AssertExpectations(t)
+}
+
+// TestSessionFork tests that a forked session diverges without changing the original
+func TestSessionFork(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	session := NewSession(sw, NewAgent("TestAgent", "gpt-4", llm.OpenAI))
+	session.ContextVariables["user"] = "alice"
+
+	for _, reply := range []string{"Paris.", "About 2 million.", "Lyon."} {
+		mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+			Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: reply}}},
+		}, nil).Once()
+	}
+	_, err := session.Send(context.Background(), "What is the capital of France?")
+	assert.NoError(t, err)
+	_, err = session.Send(context.Background(), "How many people live there?")
+	assert.NoError(t, err)
+
+	fork, err := session.Fork(2)
+	assert.NoError(t, err)
+	assert.Equal(t, session.ID, fork.ParentID)
+	assert.Equal(t, "alice", fork.ContextVariables["user"])
+
+	_, err = fork.Send(context.Background(), "And the second largest city?")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "Lyon.", fork.History()[3].Content)
+	assert.Len(t, session.History(), 4)
+	assert.Equal(t, "About 2 million.", session.History()[3].Content)
+	assert.Len(t, fork.Agent.Memory.GetRecentMemories(10), 3)
+	assert.Len(t, session.Agent.Memory.GetRecentMemories(10), 2)
+
+	_, err = session.Fork(5)
+	assert.Error(t, err)
+}
+
+// TestSessionEditAndRegenerate tests that editing a message discards what followed it and
+// regenerating runs the agent again from that point
+func TestSessionEditAndRegenerate(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	session := NewSession(sw, NewAgent("TestAgent", "gpt-4", llm.OpenAI))
+
+	for _, reply := range []string{"Paris.", "About 2 million.", "Berlin.", "Germany's capital is Berlin."} {
+		mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+			Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: reply}}},
+		}, nil).Once()
+	}
+	_, err := session.Send(context.Background(), "What is the capital of France?")
+	assert.NoError(t, err)
+	_, err = session.Send(context.Background(), "How many people live there?")
+	assert.NoError(t, err)
+
+	assert.Error(t, session.EditMessage(1, "Not a user message"))
+	assert.NoError(t, session.EditMessage(0, "What is the capital of Germany?"))
+	assert.Len(t, session.History(), 1)
+	assert.Empty(t, session.Agent.Memory.GetRecentMemories(10))
+
+	_, err = session.Regenerate(context.Background(), 1)
+	assert.NoError(t, err)
+	_, err = session.Regenerate(context.Background(), 1)
+	assert.NoError(t, err)
+
+	history := session.History()
+	assert.Len(t, history, 2)
+	assert.Equal(t, "What is the capital of Germany?", history[0].Content)
+	assert.Equal(t, "Germany's capital is Berlin.", history[1].Content)
+	assert.Len(t, session.Agent.Memory.GetRecentMemories(10), 1)
+}
+
+// TestSessionTaskTracking tests that the task list survives between runs and restores
+func TestSessionTaskTracking(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	agent := NewAgent("TestAgent", "gpt-4", llm.OpenAI).WithTaskTracking()
+	session := NewSession(sw, agent)
+
+	toolCall := func(id, name, args string) llm.ChatCompletionResponse {
+		return llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{
+			Role:      llm.RoleAssistant,
+			ToolCalls: []llm.ToolCall{{ID: id, Type: "function", Function: llm.ToolCallFunction{Name: name, Arguments: args}}},
+		}}}}
+	}
+	reply := llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "OK."}}}}
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(toolCall("call_1", "add_task", `{"description":"Book flight"}`), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(toolCall("call_2", "add_task", `{"description":"Book hotel"}`), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(reply, nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(toolCall("call_3", "complete_task", `{"id":1}`), nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(reply, nil).Once()
+
+	resp, err := session.Send(context.Background(), "Plan my trip")
+	assert.NoError(t, err)
+	assert.Len(t, resp.Tasks, 2)
+
+	// Restoring goes through JSON like a session store would
+	data, err := json.Marshal(session.Snapshot())
+	assert.NoError(t, err)
+	var snapshot SessionSnapshot
+	assert.NoError(t, json.Unmarshal(data, &snapshot))
+	restored := RestoreSession(sw, agent, snapshot)
+
+	resp, err = restored.Send(context.Background(), "The flight is booked")
+	assert.NoError(t, err)
+	assert.Equal(t, []TrackedTask{
+		{ID: 1, Description: "Book flight", Status: TaskCompleted},
+		{ID: 2, Description: "Book hotel", Status: TaskPending},
+	}, resp.Tasks)
+	assert.Equal(t, resp.Tasks, restored.Tasks())
+}
+
+func TestSessionCompactHistory(t *testing.T) {
+	mockClient := new(MockLLM)
+	agent := NewAgent("TestAgent", "gpt-4", llm.OpenAI).WithHistoryCompaction(CompactionOptions{KeepRecent: 2})
+	session := NewSession(NewMockSwarm(mockClient), agent)
+	for i := 0; i < 3; i++ {
+		session.Messages = append(session.Messages,
+			llm.Message{Role: llm.RoleUser, Content: "question"},
+			llm.Message{Role: llm.RoleAssistant, Content: "answer"},
+		)
+	}
+
+	summaryRequest := func(req llm.ChatCompletionRequest) bool {
+		return req.Messages[0].Content == DefaultCompactionPrompt
+	}
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+		return !summaryRequest(req) && len(req.Messages) == 8
+	})).Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{
+		Role: llm.RoleAssistant,
+		ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{
+			Name: CompactHistoryToolName, Arguments: "{}",
+		}}},
+	}}}}, nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.MatchedBy(summaryRequest)).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "The user asked three questions."}}},
+	}, nil).Once()
+	// The final request sends the summary and the kept tool call and result
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.MatchedBy(func(req llm.ChatCompletionRequest) bool {
+		return !summaryRequest(req) && len(req.Messages) == 4
+	})).Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Done"}}}}, nil).Once()
+
+	resp, err := session.Send(context.Background(), "next")
+	assert.NoError(t, err)
+	if assert.NotNil(t, resp.Compaction) {
+		assert.Equal(t, "The user asked three questions.", resp.Compaction.Summary)
+		assert.Equal(t, 7, resp.Compaction.Replaced)
+	}
+	assert.Len(t, session.Messages, 4)
+	assert.Contains(t, session.Messages[0].Content, "The user asked three questions.")
+	assert.Equal(t, "Done", session.Messages[3].Content)
+	mockClient.AssertExpectations(t)
+}
+
+// TestSessionInterrupt tests that a message sent mid-stream cancels the stream and continues with both
+func TestSessionInterrupt(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	session := NewSession(sw, &Agent{Name: "TestAgent"})
+
+	interrupted := &fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("The weather in Paris")}, block: true}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		interrupted.ctx = args.Get(0).(context.Context)
+	}).Return(interrupted, nil).Once()
+	var request llm.ChatCompletionRequest
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		request = args.Get(1).(llm.ChatCompletionRequest)
+	}).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("London is rainy.")}}, nil).Once()
+
+	handler := &tokenRecorder{tokens: make(chan string, 10)}
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- session.Stream(context.Background(), "Weather in Paris?", handler)
+	}()
+	select {
+	case <-handler.tokens:
+	case <-time.After(time.Second):
+		t.Fatal("no token received")
+	}
+
+	assert.NoError(t, session.Interrupt(context.Background(), "Actually, London", handler))
+	assert.ErrorIs(t, <-errChan, ErrStreamInterrupted)
+
+	history := session.History()
+	assert.Len(t, history, 4)
+	assert.Equal(t, "The weather in Paris", history[1].Content)
+	assert.True(t, history[1].Interrupted)
+	assert.Equal(t, "Actually, London", history[2].Content)
+	assert.Equal(t, "London is rainy.", history[3].Content)
+
+	// The agent is told where it was interrupted, without changing the stored history
+	sent := request.Messages[len(request.Messages)-2]
+	assert.Contains(t, sent.Content, "The weather in Paris\n\n[The user interrupted")
+	assert.Equal(t, "Actually, London", request.Messages[len(request.Messages)-1].Content)
+}
+
+// TestSessionGroupChat tests attributed messages and mention-based and round-robin turn taking
+func TestSessionGroupChat(t *testing.T) {
+	mockClient := new(MockLLM)
+	billing := NewAgent("Billing", "gpt-4", llm.OpenAI)
+	tech := NewAgent("Tech", "gpt-4", llm.OpenAI)
+	session := NewSession(NewMockSwarm(mockClient), billing).WithGroupChat(TurnMentions, billing, tech)
+	session.Join("Alice")
+
+	var requests []llm.ChatCompletionRequest
+	reply := func(content string) {
+		mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+			requests = append(requests, args.Get(1).(llm.ChatCompletionRequest))
+		}).Return(llm.ChatCompletionResponse{Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}}}, nil).Once()
+	}
+	reply("Restart the router.")
+	reply("No charge for that.")
+
+	responses, err := session.SendAs(context.Background(), "Bob", "@tech my internet is down, will @Billing refund me?")
+	assert.NoError(t, err)
+	assert.Len(t, responses, 2)
+	assert.Equal(t, []string{"Alice", "Bob"}, session.Participants)
+	assert.Equal(t, "Bob", session.Messages[0].Name)
+	assert.Equal(t, "Tech", session.Messages[1].Name)
+	assert.Equal(t, "Billing", session.Messages[2].Name)
+
+	// Billing sees Bob's and Tech's messages attributed, and who else takes part
+	billingRequest := requests[1]
+	assert.Contains(t, billingRequest.Messages[0].Content, "You are Billing in a group chat with Alice, Bob, Tech.")
+	assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: "Bob: @tech my internet is down, will @Billing refund me?"}, billingRequest.Messages[1])
+	assert.Equal(t, llm.Message{Role: llm.RoleUser, Content: "Tech: Restart the router."}, billingRequest.Messages[2])
+
+	// Without a mention the session's agent answers; round robin alternates
+	reply("Happy to help.")
+	responses, err = session.SendAs(context.Background(), "Alice", "thanks all")
+	assert.NoError(t, err)
+	assert.Len(t, responses, 1)
+	assert.Equal(t, "Billing", session.Messages[4].Name)
+
+	session.WithGroupChat(TurnRoundRobin, billing, tech)
+	reply("First")
+	reply("Second")
+	for _, content := range []string{"one", "two"} {
+		_, err = session.SendAs(context.Background(), "Alice", content)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, "Billing", session.Messages[6].Name)
+	assert.Equal(t, "Tech", session.Messages[8].Name)
+	assert.Equal(t, "Alice", session.Snapshot().Participants[0])
+	mockClient.AssertExpectations(t)
+}
+
+// realtimeRecorder records the events of a realtime session
+type realtimeRecorder struct {
+	DefaultRealtimeHandler
+	audio      []byte
+	transcript string
+	toolCalls  []string
+	interrupts int
+	responses  int
+}
+
+func (r *realtimeRecorder) OnAudio(audio []byte) {
+	r.audio = append(r.audio, audio...)
+}
+
+func (r *realtimeRecorder) OnTranscript(delta string) {
+	r.transcript += delta
+}
+
+func (r *realtimeRecorder) OnToolCall(toolCall llm.ToolCall) {
+	r.toolCalls = append(r.toolCalls, toolCall.Function.Name)
+}
+
+func (r *realtimeRecorder) OnInterrupt() {
+	r.interrupts++
+}
+
+func (r *realtimeRecorder) OnResponseDone() {
+	r.responses++
+}
+
+// TestRealtimeSession tests configuring a realtime session, streaming its audio, running tools and barging in
+func TestRealtimeSession(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	finished := make(chan struct{})
+	var model, auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		model, auth = r.URL.Query().Get("model"), r.Header.Get("Authorization")
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+		<-finished
+	}))
+	defer server.Close()
+	defer close(finished)
+
+	lookup, err := NewAgentFunction("lookup", "Look up an order", func(args map[string]interface{}, cv map[string]interface{}) Result {
+		return Result{Success: true, Data: "shipped"}
+	})
+	assert.NoError(t, err)
+	agent := NewAgent("Voice", "gpt-4o-realtime-preview", llm.OpenAI).WithFunctions(lookup)
+	agent.Instructions = "Answer order questions."
+
+	handler := &realtimeRecorder{}
+	rs, err := NewRealtimeSession(context.Background(), "test-key", agent, nil, RealtimeOptions{Endpoint: "ws" + strings.TrimPrefix(server.URL, "http")}, handler)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn := <-conns
+	defer conn.Close()
+	assert.Equal(t, "gpt-4o-realtime-preview", model)
+	assert.Equal(t, "Bearer test-key", auth)
+
+	recv := func() map[string]interface{} {
+		var event map[string]interface{}
+		assert.NoError(t, conn.ReadJSON(&event))
+		return event
+	}
+	send := func(event string) {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(event)))
+	}
+
+	// The session is configured with the agent's instructions and tools
+	update := recv()
+	assert.Equal(t, "session.update", update["type"])
+	session := update["session"].(map[string]interface{})
+	assert.Contains(t, session["instructions"], "Answer order questions.")
+	assert.Equal(t, "lookup", session["tools"].([]interface{})[0].(map[string]interface{})["name"])
+
+	assert.NoError(t, rs.SendText("Where is my order?"))
+	assert.Equal(t, "conversation.item.create", recv()["type"])
+	assert.Equal(t, "response.create", recv()["type"])
+
+	// Tool calls run locally and their output is sent back before a new response is requested
+	send(`{"type":"response.audio_transcript.delta","delta":"Let me check."}`)
+	send(`{"type":"response.audio.delta","delta":"` + base64.StdEncoding.EncodeToString([]byte("pcm")) + `"}`)
+	send(`{"type":"response.function_call_arguments.done","call_id":"call_1","name":"lookup","arguments":"{}"}`)
+	output := recv()
+	assert.Equal(t, "conversation.item.create", output["type"])
+	item := output["item"].(map[string]interface{})
+	assert.Equal(t, "function_call_output", item["type"])
+	assert.Equal(t, "call_1", item["call_id"])
+	assert.Equal(t, "shipped", item["output"])
+	assert.Equal(t, "response.create", recv()["type"])
+
+	// The user speaking over the agent cancels its response
+	send(`{"type":"input_audio_buffer.speech_started"}`)
+	assert.Equal(t, "response.cancel", recv()["type"])
+	send(`{"type":"response.done"}`)
+	conn.Close()
+
+	select {
+	case <-rs.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session did not end when the connection closed")
+	}
+	assert.Equal(t, []byte("pcm"), handler.audio)
+	assert.Equal(t, "Let me check.", handler.transcript)
+	assert.Equal(t, []string{"lookup"}, handler.toolCalls)
+	assert.Equal(t, 1, handler.interrupts)
+	assert.Equal(t, 1, handler.responses)
+}
+
+// transcriptNotifier passes the transcript deltas of a realtime session to a channel
+type transcriptNotifier struct {
+	DefaultRealtimeHandler
+	deltas chan string
+}
+
+func (n *transcriptNotifier) OnTranscript(delta string) {
+	n.deltas <- delta
+}
+
+// TestRealtimeSessionPendingApproval tests that a tool awaiting approval does not hold up the
+// session's events, and that closing the session denies it
+func TestRealtimeSessionPendingApproval(t *testing.T) {
+	conns := make(chan *websocket.Conn, 1)
+	finished := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		conns <- conn
+		<-finished
+	}))
+	defer server.Close()
+	defer close(finished)
+
+	ran := false
+	refund, err := NewAgentFunction("refund", "Refund the order", func(args map[string]interface{}, cv map[string]interface{}) Result {
+		ran = true
+		return Result{Success: true, Data: "refunded"}
+	})
+	assert.NoError(t, err)
+	agent := NewAgent("Voice", "gpt-4o-realtime-preview", llm.OpenAI).WithFunctions(refund.WithApproval())
+
+	asked := make(chan struct{})
+	ctx := WithToolApprover(context.Background(), func(ctx context.Context, toolCall llm.ToolCall) (bool, error) {
+		close(asked)
+		<-ctx.Done()
+		return false, ctx.Err()
+	})
+	handler := &transcriptNotifier{deltas: make(chan string, 1)}
+	rs, err := NewRealtimeSession(ctx, "test-key", agent, nil, RealtimeOptions{Endpoint: "ws" + strings.TrimPrefix(server.URL, "http")}, handler)
+	if !assert.NoError(t, err) {
+		return
+	}
+	conn := <-conns
+	defer conn.Close()
+
+	send := func(event string) {
+		assert.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(event)))
+	}
+	send(`{"type":"response.function_call_arguments.done","call_id":"call_1","name":"refund","arguments":"{}"}`)
+	<-asked
+	send(`{"type":"response.audio_transcript.delta","delta":"One moment."}`)
+	select {
+	case delta := <-handler.deltas:
+		assert.Equal(t, "One moment.", delta)
+	case <-time.After(time.Second):
+		t.Fatal("events stalled while a tool awaited approval")
+	}
+
+	closed := make(chan error, 1)
+	go func() { closed <- rs.Close() }()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on the pending approval")
+	}
+	assert.False(t, ran)
+}
+
+// TestSessionRunRollback tests that a failed run leaves the history as it was and that runs
+// do not overlap
+func TestSessionRunRollback(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	session := NewSession(sw, NewAgent("TestAgent", "gpt-4", llm.OpenAI))
+
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{}, errors.New("provider down")).Once()
+	_, err := session.Send(context.Background(), "Hello")
+	assert.Error(t, err)
+	assert.Empty(t, session.History())
+	assert.Empty(t, session.Agent.Memory.GetRecentMemories(10))
+
+	release := make(chan struct{})
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Hi"}}},
+	}, nil).Run(func(mock.Arguments) { <-release }).Once()
+	sent := make(chan error)
+	go func() {
+		_, err := session.Send(context.Background(), "Hello")
+		sent <- err
+	}()
+	assert.Eventually(t, func() bool { return len(session.History()) == 1 }, time.Second, time.Millisecond)
+	_, err = session.Send(context.Background(), "Are you there?")
+	assert.Error(t, err)
+	_, err = session.SendAs(context.Background(), "ada", "Hi all")
+	assert.Error(t, err)
+	close(release)
+	assert.NoError(t, <-sent)
+	assert.Len(t, session.History(), 2)
+}
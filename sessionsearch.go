@@ -0,0 +1,191 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultSearchLimit is the maximum number of matches a session search returns when none is configured
+const DefaultSearchLimit = 20
+
+// DefaultSearchMinScore is the similarity a semantic match needs when none is configured
+const DefaultSearchMinScore = 0.75
+
+// SessionSearchOptions configures a search over stored sessions
+type SessionSearchOptions struct {
+	// Embed enables semantic search: messages and memories similar in meaning to the query
+	// match even without sharing its words. Only keywords are matched if nil. Texts are
+	// embedded on every search, so pass a caching function for large stores.
+	Embed    EmbedFunc
+	MinScore float64      // Similarity semantic matches need, DefaultSearchMinScore if zero
+	Memories *MemoryStore // Memories searched along with the transcripts, if set
+	Limit    int          // Maximum number of matches, DefaultSearchLimit if not positive
+}
+
+// SessionMatch is a message of a stored session, or a memory, matching a search
+type SessionMatch struct {
+	SessionID    string       // Session the message belongs to, empty for memories
+	Title        string       // Title of the session, if it has one
+	MessageIndex int          // Position of the message in the session
+	Message      *llm.Message // Matched message, nil for memories
+	Memory       *Memory      // Matched memory, nil for messages
+	Score        float64      // Relevance from 0 to 1
+}
+
+// SessionSearcher is implemented by session stores with their own search, such as an index
+type SessionSearcher interface {
+	Search(ctx context.Context, query string, opts SessionSearchOptions) ([]SessionMatch, error)
+}
+
+// SearchSessions finds the messages of the stored sessions and the memories that match the
+// query, best first, e.g. to find past conversations for support and audit workflows. A text
+// matches by keyword with the fraction of the query's words it contains, and by meaning with
+// its embedding's similarity to the query's when opts.Embed is set. Stores implementing
+// SessionSearcher search themselves; others are scanned.
+func SearchSessions(ctx context.Context, store SessionStore, query string, opts SessionSearchOptions) ([]SessionMatch, error) {
+	if searcher, ok := store.(SessionSearcher); ok {
+		return searcher.Search(ctx, query, opts)
+	}
+	return scanSessions(ctx, store, query, opts)
+}
+
+// Search implements SessionSearcher by scanning the stored sessions
+func (f *FileSessionStore) Search(ctx context.Context, query string, opts SessionSearchOptions) ([]SessionMatch, error) {
+	return scanSessions(ctx, f, query, opts)
+}
+
+// scanSessions searches every stored session and the memories one by one
+func scanSessions(ctx context.Context, store SessionStore, query string, opts SessionSearchOptions) ([]SessionMatch, error) {
+	if strings.TrimSpace(query) == "" {
+		return nil, fmt.Errorf("search query is empty")
+	}
+	scorer, err := newSearchScorer(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	ids, err := store.ListSessions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var matches []SessionMatch
+	for _, id := range ids {
+		snapshot, err := store.LoadSession(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		title, _ := snapshot.Metadata[SessionTitleKey].(string)
+		for i := range snapshot.Messages {
+			msg := snapshot.Messages[i]
+			score, err := scorer.score(ctx, msg.Content)
+			if err != nil {
+				return nil, err
+			}
+			if score > 0 {
+				matches = append(matches, SessionMatch{SessionID: id, Title: title, MessageIndex: i, Message: &msg, Score: score})
+			}
+		}
+	}
+
+	for _, memory := range storedMemories(opts.Memories) {
+		score, err := scorer.score(ctx, memory.Content)
+		if err != nil {
+			return nil, err
+		}
+		if score > 0 {
+			memory := memory
+			matches = append(matches, SessionMatch{Memory: &memory, Score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
+
+// searchScorer rates texts against a query
+type searchScorer struct {
+	terms    []string
+	embed    EmbedFunc
+	query    []float64
+	minScore float64
+}
+
+// newSearchScorer prepares the query's keywords and, for semantic search, its embedding
+func newSearchScorer(ctx context.Context, query string, opts SessionSearchOptions) (*searchScorer, error) {
+	scorer := &searchScorer{terms: strings.Fields(strings.ToLower(query)), embed: opts.Embed, minScore: opts.MinScore}
+	if scorer.minScore == 0 {
+		scorer.minScore = DefaultSearchMinScore
+	}
+	if opts.Embed != nil {
+		embedding, err := opts.Embed(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed query: %w", err)
+		}
+		scorer.query = embedding
+	}
+	return scorer, nil
+}
+
+// score returns the better of the keyword and semantic scores of the text, or 0 if it does
+// not match
+func (s *searchScorer) score(ctx context.Context, text string) (float64, error) {
+	if strings.TrimSpace(text) == "" {
+		return 0, nil
+	}
+	lower := strings.ToLower(text)
+	found := 0
+	for _, term := range s.terms {
+		if strings.Contains(lower, term) {
+			found++
+		}
+	}
+	score := float64(found) / float64(len(s.terms))
+
+	if s.embed != nil {
+		embedding, err := s.embed(ctx, text)
+		if err != nil {
+			return 0, fmt.Errorf("failed to embed text: %w", err)
+		}
+		if similarity := cosineSimilarity(s.query, embedding); similarity >= s.minScore && similarity > score {
+			score = similarity
+		}
+	}
+	return score, nil
+}
+
+// storedMemories returns every memory of the store once
+func storedMemories(store *MemoryStore) []Memory {
+	if store == nil {
+		return nil
+	}
+	store.mu.RLock()
+	defer store.mu.RUnlock()
+
+	// Typed memories are all kept long-term; untyped ones only short-term
+	var memories []Memory
+	for _, memory := range store.shortTerm {
+		if memory.Type == "" {
+			memories = append(memories, memory)
+		}
+	}
+	types := make([]string, 0, len(store.longTerm))
+	for memoryType := range store.longTerm {
+		types = append(types, memoryType)
+	}
+	sort.Strings(types)
+	for _, memoryType := range types {
+		memories = append(memories, store.longTerm[memoryType]...)
+	}
+	return memories
+}
@@ -0,0 +1,241 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrSessionNotFound is returned when a session store has no session with the requested ID
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionSnapshot is the persistable state of a session
+type SessionSnapshot struct {
+	ID               string                 `json:"id"`
+	ParentID         string                 `json:"parent_id,omitempty"`
+	AgentName        string                 `json:"agent_name"`
+	Messages         []llm.Message          `json:"messages"`
+	ContextVariables map[string]interface{} `json:"context_variables"`
+	Metadata         map[string]interface{} `json:"metadata"`
+	MaxTurns         int                    `json:"max_turns"`
+	Model            string                 `json:"model,omitempty"`
+	Participants     []string               `json:"participants,omitempty"`
+	CreatedAt        time.Time              `json:"created_at"`
+	UpdatedAt        time.Time              `json:"updated_at"`
+}
+
+// SessionStore persists session snapshots
+type SessionStore interface {
+	SaveSession(ctx context.Context, snapshot SessionSnapshot) error
+	LoadSession(ctx context.Context, id string) (SessionSnapshot, error)
+	DeleteSession(ctx context.Context, id string) error
+	ListSessions(ctx context.Context) ([]string, error)
+}
+
+// Snapshot returns the persistable state of the session. Its context variables and metadata
+// are deep copies, so changing them does not affect the session.
+func (s *Session) Snapshot() SessionSnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snapshot := SessionSnapshot{
+		ID:               s.ID,
+		ParentID:         s.ParentID,
+		Messages:         append([]llm.Message{}, s.Messages...),
+		ContextVariables: copyValues(s.ContextVariables),
+		Metadata:         copyValues(s.Metadata),
+		MaxTurns:         s.MaxTurns,
+		Model:            s.Model,
+		Participants:     append([]string(nil), s.Participants...),
+		CreatedAt:        s.CreatedAt,
+		UpdatedAt:        s.UpdatedAt,
+	}
+	if s.Agent != nil {
+		snapshot.AgentName = s.Agent.Name
+	}
+	return snapshot
+}
+
+// copyValues returns a deep copy of context variables or metadata: nested maps and slices are
+// copied so the copy can be changed without affecting the original, other values are shared
+func copyValues(values map[string]interface{}) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	copied := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		copied[key] = copyValue(value)
+	}
+	return copied
+}
+
+// copyValue returns a deep copy of a map or slice value, or the value itself
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return copyValues(v)
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, item := range v {
+			copied[i] = copyValue(item)
+		}
+		return copied
+	case map[string]string:
+		copied := make(map[string]string, len(v))
+		for key, item := range v {
+			copied[key] = item
+		}
+		return copied
+	case []string:
+		return append([]string(nil), v...)
+	default:
+		return value
+	}
+}
+
+// RestoreSession recreates a session from a snapshot. Agents are not persisted, so the
+// caller passes the agent matching snapshot.AgentName and sets up the agents of a group chat
+// again with WithGroupChat.
+func RestoreSession(swarm *Swarm, agent *Agent, snapshot SessionSnapshot) *Session {
+	session := NewSession(swarm, agent)
+	session.ID = snapshot.ID
+	session.ParentID = snapshot.ParentID
+	session.Messages = append(session.Messages, snapshot.Messages...)
+	if snapshot.ContextVariables != nil {
+		session.ContextVariables = snapshot.ContextVariables
+	}
+	if snapshot.Metadata != nil {
+		session.Metadata = snapshot.Metadata
+	}
+	if snapshot.MaxTurns > 0 {
+		session.MaxTurns = snapshot.MaxTurns
+	}
+	session.Model = snapshot.Model
+	session.Participants = snapshot.Participants
+	session.CreatedAt = snapshot.CreatedAt
+	session.UpdatedAt = snapshot.UpdatedAt
+	return session
+}
+
+var sessionIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// FileSessionStore stores each session as a file in a directory, optionally encrypted
+type FileSessionStore struct {
+	dir    string
+	cipher Cipher
+}
+
+// NewFileSessionStore creates the directory if needed and returns a store writing to it
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	return &FileSessionStore{dir: dir}, nil
+}
+
+// WithCipher encrypts sessions written by the store and decrypts them when loaded
+func (f *FileSessionStore) WithCipher(c Cipher) *FileSessionStore {
+	f.cipher = c
+	return f
+}
+
+// path returns the file of a session, rejecting IDs that could escape the directory
+func (f *FileSessionStore) path(id string) (string, error) {
+	if !sessionIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid session ID: %q", id)
+	}
+	return filepath.Join(f.dir, id+".session"), nil
+}
+
+// SaveSession writes the snapshot, replacing any previous version
+func (f *FileSessionStore) SaveSession(ctx context.Context, snapshot SessionSnapshot) error {
+	path, err := f.path(snapshot.ID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	if f.cipher != nil {
+		if data, err = f.cipher.Encrypt(data, sessionAssociatedData(snapshot.ID)); err != nil {
+			return err
+		}
+	}
+
+	// Write to a temporary file first so a crash never leaves a truncated session behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LoadSession reads a snapshot
+func (f *FileSessionStore) LoadSession(ctx context.Context, id string) (SessionSnapshot, error) {
+	path, err := f.path(id)
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return SessionSnapshot{}, fmt.Errorf("%w: %s", ErrSessionNotFound, id)
+	}
+	if err != nil {
+		return SessionSnapshot{}, err
+	}
+	if f.cipher != nil {
+		if data, err = f.cipher.Decrypt(data, sessionAssociatedData(id)); err != nil {
+			return SessionSnapshot{}, err
+		}
+	}
+
+	var snapshot SessionSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return SessionSnapshot{}, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+	return snapshot, nil
+}
+
+// sessionAssociatedData binds an encrypted session to its ID, so a session file renamed to
+// another ID fails to decrypt
+func sessionAssociatedData(id string) []byte {
+	return []byte("session:" + id)
+}
+
+// DeleteSession removes a session
+func (f *FileSessionStore) DeleteSession(ctx context.Context, id string) error {
+	path, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ListSessions returns the IDs of all stored sessions
+func (f *FileSessionStore) ListSessions(ctx context.Context) ([]string, error) {
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasSuffix(name, ".session") {
+			ids = append(ids, strings.TrimSuffix(name, ".session"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
@@ -0,0 +1,225 @@
+package swarmgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestEncryptedFileSessionStore tests that sessions round-trip through an encrypted store without plaintext on disk
+func TestEncryptedFileSessionStore(t *testing.T) {
+	ctx := context.Background()
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	c, err := NewAESGCMCipherFromCredential(ctx, StaticCredentials{"session_key": key}, "session_key")
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	store, err := NewFileSessionStore(dir)
+	assert.NoError(t, err)
+	store.WithCipher(c)
+
+	agent := &Agent{Name: "TestAgent"}
+	session := NewSession(NewMockSwarm(new(MockLLM)), agent)
+	session.Messages = append(session.Messages, llm.Message{Role: llm.RoleUser, Content: "my card is 4111 1111 1111 1111"})
+	assert.NoError(t, store.SaveSession(ctx, session.Snapshot()))
+
+	raw, err := os.ReadFile(filepath.Join(dir, session.ID+".session"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(raw), "4111")
+
+	snapshot, err := store.LoadSession(ctx, session.ID)
+	assert.NoError(t, err)
+	restored := RestoreSession(session.swarm, agent, snapshot)
+	assert.Equal(t, session.Messages, restored.History())
+	assert.Equal(t, "TestAgent", snapshot.AgentName)
+
+	ids, err := store.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{session.ID}, ids)
+
+	_, err = store.LoadSession(ctx, "missing")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	// A session file copied to another ID does not decrypt
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "other.session"), raw, 0600))
+	_, err = store.LoadSession(ctx, "other")
+	assert.Error(t, err)
+	assert.NoError(t, os.Remove(filepath.Join(dir, "other.session")))
+
+	memories := NewMemoryStore(10)
+	memories.AddMemory(Memory{Content: "prefers email", Type: "preference"})
+	data, err := memories.SerializeMemoriesEncrypted(c)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "prefers email")
+	loaded := NewMemoryStore(10)
+	assert.NoError(t, loaded.LoadMemoriesEncrypted(c, data))
+	assert.Equal(t, "prefers email", loaded.GetRecentMemories(1)[0].Content)
+}
+
+// TestSessionTitles tests that saving a session generates its title once and refreshes its summary
+func TestSessionTitles(t *testing.T) {
+	ctx := context.Background()
+	mockClient := new(MockLLM)
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: `{"title": "Refund for order 42", "summary": "The user asked for a refund."}`}}},
+	}, nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: `{"title": "Refunds", "summary": "The refund was approved."}`}}},
+	}, nil).Once()
+
+	store, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+	session := NewSession(NewMockSwarm(mockClient).WithSessionTitles("gpt-4o-mini"), &Agent{Name: "Support"})
+	session.Messages = append(session.Messages, llm.Message{Role: llm.RoleUser, Content: "I want a refund for order 42"})
+	assert.NoError(t, session.Save(ctx, store))
+
+	// Saving without new messages does not describe the session again
+	assert.NoError(t, session.Save(ctx, store))
+	session.Messages = append(session.Messages, llm.Message{Role: llm.RoleAssistant, Content: "Your refund was approved."})
+	assert.NoError(t, session.Save(ctx, store))
+
+	snapshot, err := store.LoadSession(ctx, session.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Refund for order 42", snapshot.Metadata[SessionTitleKey])
+	assert.Equal(t, "The refund was approved.", snapshot.Metadata[SessionSummaryKey])
+	mockClient.AssertNumberOfCalls(t, "CreateChatCompletion", 2)
+}
+
+// TestSearchSessions tests keyword and semantic search over stored transcripts and memories
+func TestSearchSessions(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+
+	refund := NewSession(NewMockSwarm(new(MockLLM)), &Agent{Name: "Support"})
+	refund.Metadata[SessionTitleKey] = "Refund"
+	refund.Messages = append(refund.Messages,
+		llm.Message{Role: llm.RoleUser, Content: "I want a refund for order 42"},
+		llm.Message{Role: llm.RoleAssistant, Content: "Your money is on its way back."},
+	)
+	other := NewSession(refund.swarm, &Agent{Name: "Support"})
+	other.Messages = append(other.Messages, llm.Message{Role: llm.RoleUser, Content: "How do I reset my password?"})
+	assert.NoError(t, store.SaveSession(ctx, refund.Snapshot()))
+	assert.NoError(t, store.SaveSession(ctx, other.Snapshot()))
+
+	memories := NewMemoryStore(10)
+	memories.AddMemory(Memory{Content: "Refund policy allows 30 days", Type: "fact"})
+
+	matches, err := SearchSessions(ctx, store, "refund order", SessionSearchOptions{Memories: memories})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, refund.ID, matches[0].SessionID)
+	assert.Equal(t, "Refund", matches[0].Title)
+	assert.Equal(t, 0, matches[0].MessageIndex)
+	assert.Equal(t, 1.0, matches[0].Score)
+	assert.Equal(t, "Refund policy allows 30 days", matches[1].Memory.Content)
+
+	// Semantic search matches messages about money returns without the keyword
+	embed := func(ctx context.Context, text string) ([]float64, error) {
+		lower := strings.ToLower(text)
+		if strings.Contains(lower, "refund") || strings.Contains(lower, "money") {
+			return []float64{1, 0}, nil
+		}
+		return []float64{0, 1}, nil
+	}
+	matches, err = SearchSessions(ctx, store, "refund", SessionSearchOptions{Embed: embed, Limit: 3})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 2)
+	assert.Equal(t, 1, matches[1].MessageIndex)
+}
+
+type recordingAuditPurger struct {
+	filters []AuditFilter
+}
+
+func (r *recordingAuditPurger) PurgeAudit(ctx context.Context, filter AuditFilter) (int, error) {
+	r.filters = append(r.filters, filter)
+	return 1, nil
+}
+
+// TestRetention tests that sweeps delete expired data and DeleteUserData erases a user's data
+func TestRetention(t *testing.T) {
+	ctx := context.Background()
+	sessions, err := NewFileSessionStore(t.TempDir())
+	assert.NoError(t, err)
+	artifacts, err := NewFileArtifactStore(t.TempDir())
+	assert.NoError(t, err)
+	memories := NewMemoryStore(10)
+	audit := &recordingAuditPurger{}
+	retention := NewRetention(RetentionPolicy{SessionTTL: time.Hour, MemoryTTL: time.Hour, AuditTTL: 24 * time.Hour}).
+		WithSessions(sessions).WithMemories(memories).WithAudit(audit).WithArtifacts(artifacts)
+
+	sw := NewMockSwarm(new(MockLLM))
+	stale := NewSession(sw, &Agent{Name: "Support"})
+	stale.UpdatedAt = time.Now().Add(-2 * time.Hour)
+	ada := NewSession(sw, &Agent{Name: "Support"})
+	ada.Metadata[UserIDKey] = "ada"
+	assert.NoError(t, sessions.SaveSession(ctx, stale.Snapshot()))
+	assert.NoError(t, sessions.SaveSession(ctx, ada.Snapshot()))
+	memories.AddMemory(Memory{Content: "old", Type: "fact", Timestamp: time.Now().Add(-2 * time.Hour)})
+	memories.AddMemory(Memory{Content: "likes tea", Type: "preference", Timestamp: time.Now(), Context: map[string]interface{}{UserIDKey: "ada"}})
+	memories.AddMemory(Memory{Content: "recent", Timestamp: time.Now()})
+	_, err = artifacts.SaveArtifact(WithPrincipal(ctx, &Principal{ID: "ada"}), "image.png", "image/png", []byte("png"))
+	assert.NoError(t, err)
+	_, err = artifacts.SaveArtifact(ctx, "chart.png", "image/png", []byte("png"))
+	assert.NoError(t, err)
+
+	report, err := retention.Sweep(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, RetentionReport{Sessions: 1, Memories: 1, AuditRecords: 1}, report)
+	ids, err := sessions.ListSessions(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{ada.ID}, ids)
+
+	_, err = sw.DeleteUserData(ctx, "ada")
+	assert.ErrorIs(t, err, ErrNoRetention)
+	sw.WithRetention(retention, time.Hour)
+	defer sw.Shutdown(ctx)
+	report, err = sw.DeleteUserData(ctx, "ada")
+	assert.NoError(t, err)
+	assert.Equal(t, RetentionReport{Sessions: 1, Memories: 1, AuditRecords: 1, Artifacts: 1}, report)
+	assert.Equal(t, AuditFilter{PrincipalID: "ada"}, audit.filters[1])
+	assert.Equal(t, "recent", memories.GetRecentMemories(10)[0].Content)
+	assert.Len(t, memories.GetRecentMemories(10), 1)
+
+	// Memories recorded by runs belong to their principal
+	mockLLM := new(MockLLM)
+	mockLLM.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Noted"}}},
+	}, nil)
+	sw = NewMockSwarm(mockLLM).WithRetention(NewRetention(RetentionPolicy{}).WithMemories(memories), 0)
+	agent := &Agent{Name: "Support", Model: "gpt-4", Memory: memories}
+	_, err = sw.Run(WithPrincipal(ctx, &Principal{ID: "grace"}), agent, []llm.Message{{Role: llm.RoleUser, Content: "I prefer email"}}, nil, "", false, false, 1, true)
+	assert.NoError(t, err)
+	report, err = sw.DeleteUserData(ctx, "grace")
+	assert.NoError(t, err)
+	assert.Equal(t, RetentionReport{Memories: 1}, report)
+}
+
+// TestSessionSnapshotCopy tests that snapshots do not share context variables with their session
+func TestSessionSnapshotCopy(t *testing.T) {
+	session := NewSession(NewMockSwarm(new(MockLLM)), &Agent{Name: "TestAgent"})
+	session.ContextVariables["user"] = map[string]interface{}{"name": "ada", "tags": []interface{}{"vip"}}
+	session.Metadata = map[string]interface{}{"channel": "web"}
+
+	snapshot := session.Snapshot()
+	snapshot.ContextVariables["user"].(map[string]interface{})["name"] = "eve"
+	snapshot.ContextVariables["user"].(map[string]interface{})["tags"].([]interface{})[0] = "banned"
+	snapshot.ContextVariables["extra"] = true
+	snapshot.Metadata["channel"] = "sms"
+
+	user := session.ContextVariables["user"].(map[string]interface{})
+	assert.Equal(t, "ada", user["name"])
+	assert.Equal(t, []interface{}{"vip"}, user["tags"])
+	assert.NotContains(t, session.ContextVariables, "extra")
+	assert.Equal(t, "web", session.Metadata["channel"])
+}
@@ -0,0 +1,133 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// Metadata keys of the title and summary generated when a session is saved
+const (
+	SessionTitleKey   = "title"
+	SessionSummaryKey = "summary"
+	// sessionSummarizedKey holds the number of messages the summary covers
+	sessionSummarizedKey = "summarized_messages"
+)
+
+// sessionTranscriptChars is how much of the end of a conversation is shown to the model
+// generating its title and summary
+const sessionTranscriptChars = 8000
+
+// sessionTitleInstructions tells the model how to describe a conversation
+const sessionTitleInstructions = `You describe conversations for a list of past chats. Give a title of at most eight words naming the topic, without quotes or a trailing period, and a summary of one or two sentences of what was asked and what was concluded.`
+
+// WithSessionTitles generates a title and a summary of sessions when they are saved with
+// Session.Save, using the model, which can be a small, cheap one. They are stored in the
+// session's metadata under SessionTitleKey and SessionSummaryKey so UIs can list and search
+// conversations. The title is kept once set; the summary is refreshed when messages were
+// added since it was written.
+func (s *Swarm) WithSessionTitles(model string) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessionTitles = model
+	return s
+}
+
+// Save persists the session to the store. If the swarm generates session titles, the title
+// and summary are updated first; the session is saved even if that fails, and the error is
+// returned afterwards.
+func (s *Session) Save(ctx context.Context, store SessionStore) error {
+	titleErr := s.describe(ctx)
+	if err := store.SaveSession(ctx, s.Snapshot()); err != nil {
+		return err
+	}
+	if titleErr != nil {
+		return fmt.Errorf("session saved without an updated title: %w", titleErr)
+	}
+	return nil
+}
+
+// describe generates the session's title and summary if the swarm is configured to and
+// messages were added since the last summary
+func (s *Session) describe(ctx context.Context) error {
+	s.swarm.mu.Lock()
+	model := s.swarm.sessionTitles
+	s.swarm.mu.Unlock()
+	if model == "" {
+		return nil
+	}
+
+	s.mu.Lock()
+	messages := s.Messages[:len(s.Messages):len(s.Messages)]
+	summarized := metadataInt(s.Metadata[sessionSummarizedKey])
+	s.mu.Unlock()
+	if len(messages) == 0 || len(messages) == summarized {
+		return nil
+	}
+
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"title":   map[string]interface{}{"type": "string"},
+			"summary": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"title", "summary"},
+		"additionalProperties": false,
+	}
+	var description struct {
+		Title   string `json:"title"`
+		Summary string `json:"summary"`
+	}
+	describer := NewAgent("SessionDescriber", model, s.swarm.provider).WithInstructions(sessionTitleInstructions)
+	prompt := "Conversation:\n" + sessionTranscript(messages)
+	_, err := s.swarm.runStructured(ctx, describer, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, schema, &description, func() error {
+		if strings.TrimSpace(description.Title) == "" {
+			return fmt.Errorf("the title is empty")
+		}
+		return nil
+	}, DefaultStructuredRetries)
+	if err != nil {
+		return fmt.Errorf("failed to describe session: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Metadata == nil {
+		s.Metadata = make(map[string]interface{})
+	}
+	if title, _ := s.Metadata[SessionTitleKey].(string); title == "" {
+		s.Metadata[SessionTitleKey] = strings.TrimSpace(description.Title)
+	}
+	s.Metadata[SessionSummaryKey] = strings.TrimSpace(description.Summary)
+	s.Metadata[sessionSummarizedKey] = len(messages)
+	return nil
+}
+
+// sessionTranscript renders the user and assistant text of a conversation, keeping its end
+// if it is too long
+func sessionTranscript(messages []llm.Message) string {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		if (msg.Role == llm.RoleUser || msg.Role == llm.RoleAssistant) && msg.Content != "" {
+			fmt.Fprintf(&transcript, "%s: %s\n", msg.Role, msg.Content)
+		}
+	}
+	text := transcript.String()
+	if len(text) > sessionTranscriptChars {
+		text = "..." + text[len(text)-sessionTranscriptChars:]
+	}
+	return text
+}
+
+// metadataInt reads a number from metadata, which is a float64 once loaded from JSON
+func metadataInt(value interface{}) int {
+	switch n := value.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSwarmShutdown is returned when a run is started after Shutdown has been called
+var ErrSwarmShutdown = errors.New("swarm is shutting down")
+
+// ShutdownHook is called during Shutdown once in-flight runs have drained, e.g. to flush recorders or metrics
+type ShutdownHook func(ctx context.Context) error
+
+// OnShutdown registers a hook to be called during Shutdown
+func (s *Swarm) OnShutdown(hook ShutdownHook) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// beginRun registers an in-flight run and returns a context that is cancelled if Shutdown's deadline expires.
+// The returned function must be called when the run finishes.
+func (s *Swarm) beginRun(ctx context.Context) (context.Context, func(), error) {
+	runID := s.newID()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ctx, func() {}, ErrSwarmShutdown
+	}
+	if s.inflightCancels == nil {
+		s.inflightCancels = make(map[uint64]context.CancelFunc)
+	}
+
+	runCtx, cancel := context.WithCancel(context.WithValue(ctx, runIDKey{}, runID))
+	s.runSeq++
+	id := s.runSeq
+	s.inflightCancels[id] = cancel
+	s.inflight.Add(1)
+
+	return runCtx, func() {
+		s.mu.Lock()
+		delete(s.inflightCancels, id)
+		s.mu.Unlock()
+		cancel()
+		s.inflight.Done()
+	}, nil
+}
+
+// Shutdown stops the swarm from accepting new runs and waits for in-flight runs to finish.
+// If ctx expires first, in-flight runs are cancelled, which closes their provider streams.
+// Registered shutdown hooks are called afterwards in registration order.
+func (s *Swarm) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	hooks := make([]ShutdownHook, len(s.shutdownHooks))
+	copy(hooks, s.shutdownHooks)
+	s.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(drained)
+	}()
+
+	var drainErr error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		drainErr = fmt.Errorf("shutdown deadline exceeded, cancelling in-flight runs: %w", ctx.Err())
+		s.mu.Lock()
+		for _, cancel := range s.inflightCancels {
+			cancel()
+		}
+		s.mu.Unlock()
+		<-drained
+	}
+
+	var errs []error
+	if drainErr != nil {
+		errs = append(errs, drainErr)
+	}
+	for _, hook := range hooks {
+		// Hooks get a fresh context when the caller's has already expired so they can still flush
+		hookCtx := ctx
+		if ctx.Err() != nil {
+			hookCtx = context.Background()
+		}
+		if err := hook(hookCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// IsShutdown reports whether Shutdown has been called
+func (s *Swarm) IsShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
@@ -0,0 +1,118 @@
+package swarmgo
+
+import (
+	"fmt"
+	"sync"
+)
+
+// SimulatedToolHandler implements a tool against the state of a simulated environment.
+// Handlers may read and modify state freely; calls are serialized by the environment.
+type SimulatedToolHandler func(state map[string]interface{}, args map[string]interface{}) Result
+
+// SimulatedCall records a tool call handled by a simulated environment
+type SimulatedCall struct {
+	Tool   string
+	Args   map[string]interface{}
+	Result Result
+}
+
+// SimulatedEnvironment backs an agent's tools with user-defined handlers over in-memory
+// state (e.g. a fake CRM), so agents can be exercised end to end without real side effects
+type SimulatedEnvironment struct {
+	mu       sync.Mutex
+	initial  func() map[string]interface{}
+	state    map[string]interface{}
+	handlers map[string]SimulatedToolHandler
+	calls    []SimulatedCall
+}
+
+// NewSimulatedEnvironment creates an environment whose state is produced by initial,
+// which is called again on every Reset
+func NewSimulatedEnvironment(initial func() map[string]interface{}) *SimulatedEnvironment {
+	if initial == nil {
+		initial = func() map[string]interface{} { return make(map[string]interface{}) }
+	}
+	return &SimulatedEnvironment{
+		initial:  initial,
+		state:    initial(),
+		handlers: make(map[string]SimulatedToolHandler),
+	}
+}
+
+// Handle registers the handler simulating the named tool
+func (e *SimulatedEnvironment) Handle(tool string, handler SimulatedToolHandler) *SimulatedEnvironment {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.handlers[tool] = handler
+	return e
+}
+
+// Reset restores the initial state and clears the recorded calls
+func (e *SimulatedEnvironment) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.state = e.initial()
+	e.calls = nil
+}
+
+// State returns a shallow copy of the current state
+func (e *SimulatedEnvironment) State() map[string]interface{} {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	state := make(map[string]interface{}, len(e.state))
+	for k, v := range e.state {
+		state[k] = v
+	}
+	return state
+}
+
+// Calls returns the tool calls handled so far
+func (e *SimulatedEnvironment) Calls() []SimulatedCall {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]SimulatedCall{}, e.calls...)
+}
+
+// call runs the handler for a tool. Tools without a handler fail instead of touching real systems.
+func (e *SimulatedEnvironment) call(tool string, args map[string]interface{}) Result {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	handler, ok := e.handlers[tool]
+	var result Result
+	if ok {
+		result = handler(e.state, args)
+	} else {
+		result = Result{Success: false, Error: fmt.Errorf("no simulation registered for tool %s", tool)}
+	}
+	e.calls = append(e.calls, SimulatedCall{Tool: tool, Args: args, Result: result})
+	return result
+}
+
+// Apply returns a copy of the agent whose tools, including those of its skills, are backed
+// by the environment. Names, descriptions and schemas are kept so the model sees the same tools.
+func (e *SimulatedEnvironment) Apply(agent *Agent) *Agent {
+	simulated := *agent
+	simulated.Functions = e.simulate(agent.Functions)
+	simulated.Skills = make([]*Skill, len(agent.Skills))
+	for i, skill := range agent.Skills {
+		copied := *skill
+		copied.Functions = e.simulate(skill.Functions)
+		simulated.Skills[i] = &copied
+	}
+	return &simulated
+}
+
+// simulate replaces the executors of the functions with environment calls
+func (e *SimulatedEnvironment) simulate(functions []AgentFunction[map[string]interface{}]) []AgentFunction[map[string]interface{}] {
+	simulated := make([]AgentFunction[map[string]interface{}], len(functions))
+	for i, af := range functions {
+		name := af.Name
+		af.executor = func(args map[string]interface{}, contextVariables map[string]interface{}) Result {
+			return e.call(name, args)
+		}
+		af.contextExecutor = nil
+		simulated[i] = af
+	}
+	return simulated
+}
@@ -0,0 +1,63 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// TestEvaluateWithSimulatedEnvironment tests running an eval case against a fake CRM
+func TestEvaluateWithSimulatedEnvironment(t *testing.T) {
+	realCalls := 0
+	updateEmail, err := NewAgentFunction("update_email", "Update a contact's email", func(args map[string]interface{}, cv map[string]interface{}) Result {
+		realCalls++
+		return Result{Success: true}
+	})
+	assert.NoError(t, err)
+	agent := NewAgent("CRMAgent", "gpt-4", llm.OpenAI).WithFunctions(updateEmail)
+
+	crm := NewSimulatedEnvironment(func() map[string]interface{} {
+		return map[string]interface{}{"alice": "alice@old.example"}
+	}).Handle("update_email", func(state map[string]interface{}, args map[string]interface{}) Result {
+		name, _ := args["name"].(string)
+		if _, ok := state[name]; !ok {
+			return Result{Success: false, Error: fmt.Errorf("unknown contact %s", name)}
+		}
+		state[name] = args["email"]
+		return Result{Success: true, Data: "updated"}
+	})
+
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{
+			Role: llm.RoleAssistant,
+			ToolCalls: []llm.ToolCall{{ID: "call_1", Type: "function", Function: llm.ToolCallFunction{
+				Name: "update_email", Arguments: `{"name":"alice","email":"alice@new.example"}`,
+			}}},
+		}}},
+	}, nil).Once()
+	mockClient.On("CreateChatCompletion", mock.Anything, mock.Anything).Return(llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: "Done."}}},
+	}, nil).Once()
+
+	report := sw.Evaluate(context.Background(), agent, []EvalCase{{
+		Name:        "update email",
+		Messages:    []llm.Message{{Role: llm.RoleUser, Content: "Alice's email is now alice@new.example"}},
+		Environment: crm,
+		Check: func(response Response, env *SimulatedEnvironment) error {
+			if env.State()["alice"] != "alice@new.example" {
+				return fmt.Errorf("email not updated: %v", env.State()["alice"])
+			}
+			return nil
+		},
+	}}, RunOptions{})
+
+	assert.Equal(t, 1, report.Passed, "%v", report.Results)
+	assert.Equal(t, 0, realCalls)
+	assert.Len(t, crm.Calls(), 1)
+}
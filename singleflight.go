@@ -0,0 +1,118 @@
+package swarmgo
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// SingleFlightLLM is an LLM client that shares one provider call between identical requests
+// in flight at the same time, e.g. from batch jobs or from retries of impatient upstream
+// services. Requests are identical when their hashes match and their contexts add the same
+// headers, query parameters, gateway metadata and body fields, so tenants sending different
+// gateway keys, and runs sending different correlation IDs, never share a call. Requests
+// capturing the raw response are not shared either. The first caller's response
+// reports the usage; the callers sharing it get it with zero usage so the tokens are only
+// counted once. Streams are not shared.
+type SingleFlightLLM struct {
+	client  llm.LLM
+	mu      sync.Mutex
+	flights map[string]*flight
+	shared  atomic.Int64
+}
+
+// flight is a provider call awaited by one or more callers
+type flight struct {
+	done    chan struct{}
+	resp    llm.ChatCompletionResponse
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// NewSingleFlightLLM returns a client deduplicating the concurrent requests made to client
+func NewSingleFlightLLM(client llm.LLM) *SingleFlightLLM {
+	return &SingleFlightLLM{client: client, flights: make(map[string]*flight)}
+}
+
+// WithRequestDeduplication makes identical concurrent requests of the swarm's runs share one
+// provider call
+func (s *Swarm) WithRequestDeduplication() *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.wrapClients(func(client llm.LLM) llm.LLM { return NewSingleFlightLLM(client) })
+	return s
+}
+
+// Shared returns the number of requests that were answered by another caller's provider call
+func (c *SingleFlightLLM) Shared() int64 {
+	return c.shared.Load()
+}
+
+// CreateChatCompletion implements llm.LLM
+func (c *SingleFlightLLM) CreateChatCompletion(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionResponse, error) {
+	key := hashRequest(req)
+	scope, shareable := llm.RequestScope(ctx)
+	if key == "" || !shareable {
+		return c.client.CreateChatCompletion(ctx, req)
+	}
+	key += "\x00" + scope
+
+	c.mu.Lock()
+	f, joined := c.flights[key]
+	if joined {
+		f.waiters++
+		c.shared.Add(1)
+	} else {
+		// The call outlives its first caller as long as another caller still waits for it
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		c.flights[key] = f
+		go c.call(callCtx, key, f, req)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		c.mu.Lock()
+		f.waiters--
+		if f.waiters == 0 {
+			// Nobody waits for the call anymore; later requests start a new one
+			f.cancel()
+			if c.flights[key] == f {
+				delete(c.flights, key)
+			}
+		}
+		c.mu.Unlock()
+		return llm.ChatCompletionResponse{}, ctx.Err()
+	}
+
+	resp := f.resp
+	// Callers may modify the choices of their response
+	resp.Choices = append([]llm.Choice(nil), f.resp.Choices...)
+	if joined {
+		resp.Usage = llm.Usage{}
+	}
+	return resp, f.err
+}
+
+// call makes the provider call of a flight and hands its result to the waiting callers
+func (c *SingleFlightLLM) call(ctx context.Context, key string, f *flight, req llm.ChatCompletionRequest) {
+	defer f.cancel()
+	f.resp, f.err = c.client.CreateChatCompletion(ctx, req)
+
+	c.mu.Lock()
+	if c.flights[key] == f {
+		delete(c.flights, key)
+	}
+	c.mu.Unlock()
+	close(f.done)
+}
+
+// CreateChatCompletionStream implements llm.LLM without sharing streams
+func (c *SingleFlightLLM) CreateChatCompletionStream(ctx context.Context, req llm.ChatCompletionRequest) (llm.ChatCompletionStream, error) {
+	return c.client.CreateChatCompletionStream(ctx, req)
+}
@@ -0,0 +1,148 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Skill bundles a reusable set of tools with the instructions that explain how to use them
+// and the context variables the tools depend on
+type Skill struct {
+	Name            string                                  // Unique name of the skill
+	Description     string                                  // Short summary of what the skill provides
+	Instructions    string                                  // Instruction snippet appended to the agent's instructions
+	Functions       []AgentFunction[map[string]interface{}] // Tools provided by the skill
+	RequiredContext []string                                // Context variables that must be set for the skill to work
+}
+
+// NewSkill creates a new skill with the given instructions and tools
+func NewSkill(name, instructions string, functions ...AgentFunction[map[string]interface{}]) *Skill {
+	return &Skill{
+		Name:         name,
+		Instructions: instructions,
+		Functions:    functions,
+	}
+}
+
+// WithDescription sets the description of the skill
+func (s *Skill) WithDescription(description string) *Skill {
+	s.Description = description
+	return s
+}
+
+// WithRequiredContext declares context variables the skill's tools need
+func (s *Skill) WithRequiredContext(keys ...string) *Skill {
+	s.RequiredContext = append(s.RequiredContext, keys...)
+	return s
+}
+
+// WithSkills adds skills to the agent. Their tools are offered alongside the agent's own
+// functions and their instructions are appended to the agent's instructions.
+// Conflicts are reported when the agent runs.
+func (a *Agent) WithSkills(skills ...*Skill) *Agent {
+	a.Skills = append(a.Skills, skills...)
+	return a
+}
+
+// allFunctions returns the agent's functions followed by the functions of its skills
+func (a *Agent) allFunctions() []AgentFunction[map[string]interface{}] {
+	if len(a.Skills) == 0 {
+		return a.Functions
+	}
+	functions := make([]AgentFunction[map[string]interface{}], 0, len(a.Functions))
+	functions = append(functions, a.Functions...)
+	for _, skill := range a.Skills {
+		functions = append(functions, skill.Functions...)
+	}
+	return functions
+}
+
+// instructionOverrides adjusts an agent's instructions for a single run
+type instructionOverrides struct {
+	variant  *PromptVariant // Served in place of the agent's own instructions, if set
+	prefix   string         // Placed before the agent's instructions
+	suffix   string         // Placed after the agent's instructions, before skill instructions
+	messages Messages       // Built-in notices, DefaultMessages if nil
+}
+
+// runInstructions builds the agent's instructions with the overrides of the run the context
+// belongs to
+func (a *Agent) runInstructions(ctx context.Context, contextVariables map[string]interface{}) string {
+	overrides := instructionOverrides{variant: promptVariantFor(ctx, a), messages: messagesFromContext(ctx)}
+	if run, ok := ctx.Value(instructionOverridesKey{}).(instructionOverrides); ok {
+		overrides.prefix, overrides.suffix = run.prefix, run.suffix
+	}
+	return a.composeInstructions(overrides, contextVariables)
+}
+
+type instructionOverridesKey struct{}
+
+// composeInstructions builds the agent's instructions with the overrides applied
+func (a *Agent) composeInstructions(overrides instructionOverrides, contextVariables map[string]interface{}) string {
+	instructions := a.Instructions
+	if a.InstructionsFunc != nil {
+		instructions = a.InstructionsFunc(contextVariables)
+	}
+	if variant := overrides.variant; variant != nil {
+		instructions = variant.Instructions
+		if variant.InstructionsFunc != nil {
+			instructions = variant.InstructionsFunc(contextVariables)
+		}
+	}
+	if overrides.prefix != "" {
+		instructions = strings.TrimSpace(overrides.prefix + "\n\n" + instructions)
+	}
+	if overrides.suffix != "" {
+		instructions = strings.TrimSpace(instructions + "\n\n" + overrides.suffix)
+	}
+
+	for _, skill := range a.Skills {
+		if skill.Instructions == "" {
+			continue
+		}
+		instructions = strings.TrimSpace(instructions + "\n\n## " + skill.Name + "\n" + skill.Instructions)
+	}
+	if a.InjectionGuard != nil {
+		instructions = strings.TrimSpace(instructions + "\n\n" + overrides.messages.format(MessageInjectionNotice))
+	}
+	if notice := a.argumentTemplateNotice(overrides.messages); notice != "" {
+		instructions = strings.TrimSpace(instructions + "\n\n" + notice)
+	}
+	return a.redactHidden(instructions, contextVariables)
+}
+
+// checkSkills reports duplicate skills, tools defined by more than one source and
+// required context variables that are missing
+func (a *Agent) checkSkills(contextVariables map[string]interface{}) error {
+	if len(a.Skills) == 0 {
+		return nil
+	}
+
+	owners := make(map[string]string)
+	for _, f := range a.Functions {
+		owners[f.Name] = "agent " + a.Name
+	}
+
+	skillNames := make(map[string]bool)
+	for _, skill := range a.Skills {
+		if skillNames[skill.Name] {
+			return fmt.Errorf("skill %s is added more than once", skill.Name)
+		}
+		skillNames[skill.Name] = true
+
+		for _, f := range skill.Functions {
+			if owner, exists := owners[f.Name]; exists {
+				return fmt.Errorf("skill %s conflicts with %s: both define tool %s", skill.Name, owner, f.Name)
+			}
+			owners[f.Name] = "skill " + skill.Name
+		}
+
+		for _, key := range skill.RequiredContext {
+			if _, ok := contextVariables[key]; !ok {
+				return fmt.Errorf("skill %s requires context variable %q", skill.Name, key)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package swarmgo
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultSpeechChunkChars is the length a speech chunk is cut at when a sentence runs longer
+const DefaultSpeechChunkChars = 250
+
+// sentenceClosers are the characters that may follow sentence punctuation within the sentence
+const sentenceClosers = "\"')]}”’»*_"
+
+// speechAbbreviations are words ending in a period that do not end a sentence
+var speechAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "sr": true, "jr": true,
+	"st": true, "vs": true, "e.g": true, "i.e": true, "approx": true, "no": true, "fig": true,
+}
+
+// SentenceChunker groups streamed tokens into sentence-sized chunks, e.g. to feed a
+// text-to-speech engine, which sounds natural when given whole sentences but should start
+// speaking before the response is complete. A chunk ends at sentence punctuation followed by
+// a space, at a line break, or, for a sentence longer than MaxChars, at its last clause or
+// word break before MaxChars. Periods of abbreviations, initials and decimals do not end
+// a sentence. It is not safe for concurrent use.
+type SentenceChunker struct {
+	MinChars int // Sentences shorter are merged with the next one, e.g. to avoid choppy speech
+	MaxChars int // Length long sentences are cut at, DefaultSpeechChunkChars if not positive
+	buf      []byte
+}
+
+// NewSentenceChunker creates a chunker emitting every sentence as a chunk
+func NewSentenceChunker() *SentenceChunker {
+	return &SentenceChunker{MaxChars: DefaultSpeechChunkChars}
+}
+
+// Push adds a token and returns the chunks it completes, if any
+func (c *SentenceChunker) Push(token string) []string {
+	c.buf = append(c.buf, token...)
+	text := string(c.buf)
+	var chunks []string
+	for {
+		end := c.sentenceEnd(text)
+		if end < 0 {
+			end = c.cut(text)
+		}
+		if end < 0 {
+			break
+		}
+		if chunk := strings.TrimSpace(text[:end]); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		text = strings.TrimLeftFunc(text[end:], unicode.IsSpace)
+	}
+	if len(text) < len(c.buf) {
+		c.buf = append(c.buf[:0], text...)
+	}
+	return chunks
+}
+
+// Flush returns the text not yet emitted as a chunk, e.g. once the response is complete, and
+// empties the chunker
+func (c *SentenceChunker) Flush() string {
+	rest := strings.TrimSpace(string(c.buf))
+	c.buf = c.buf[:0]
+	return rest
+}
+
+// Reset discards the text not yet emitted
+func (c *SentenceChunker) Reset() {
+	c.buf = c.buf[:0]
+}
+
+// sentenceEnd returns the end of the first complete sentence of at least MinChars in text,
+// or -1 if there is none yet
+func (c *SentenceChunker) sentenceEnd(text string) int {
+	for i, r := range text {
+		end := -1
+		switch r {
+		case '\n':
+			end = i + 1
+		case '。', '！', '？':
+			// CJK sentences are not followed by a space
+			end = skipClosers(text, i+utf8.RuneLen(r))
+		case '.', '!', '?', '…':
+			j := skipClosers(text, i+utf8.RuneLen(r))
+			if j == len(text) {
+				// The next token tells whether the sentence ends, e.g. "3." may become "3.5"
+				return -1
+			}
+			next, _ := utf8.DecodeRuneInString(text[j:])
+			if unicode.IsSpace(next) && (r != '.' || !abbreviated(text[:i])) {
+				end = j
+			}
+		}
+		if end >= 0 && len(strings.TrimSpace(text[:end])) >= c.MinChars {
+			return end
+		}
+	}
+	return -1
+}
+
+// cut returns where to break text longer than MaxChars: after its last clause punctuation
+// or space before MaxChars, or at MaxChars if it has neither. It returns -1 if text is not
+// too long, and always cuts after at least one rune, even if MaxChars is shorter.
+func (c *SentenceChunker) cut(text string) int {
+	limit := c.MaxChars
+	if limit <= 0 {
+		limit = DefaultSpeechChunkChars
+	}
+	if len(text) <= limit {
+		return -1
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	if limit == 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		return size
+	}
+	head := text[:limit]
+	if i := strings.LastIndexAny(head, ",;:—"); i > 0 {
+		_, size := utf8.DecodeRuneInString(head[i:])
+		return i + size
+	}
+	if i := strings.LastIndexFunc(head, unicode.IsSpace); i > 0 {
+		return i
+	}
+	return limit
+}
+
+// skipClosers returns the position after the quotes, brackets and emphasis markers at i
+func skipClosers(text string, i int) int {
+	for i < len(text) {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !strings.ContainsRune(sentenceClosers, r) {
+			break
+		}
+		i += size
+	}
+	return i
+}
+
+// abbreviated reports whether the text before a period ends with an abbreviation, an
+// initial or a list number, whose period does not end a sentence
+func abbreviated(before string) bool {
+	start := strings.LastIndexFunc(before, unicode.IsSpace) + 1
+	word := strings.TrimLeft(before[start:], "(\"'“‘")
+	if word == "" {
+		return false
+	}
+	if speechAbbreviations[strings.ToLower(word)] {
+		return true
+	}
+	if r, size := utf8.DecodeRuneInString(word); size == len(word) && unicode.IsUpper(r) {
+		return true
+	}
+	// A number opening a line is a list item, as in "1. First"
+	lineStart := strings.LastIndex(before, "\n") + 1
+	return start == lineStart && strings.Trim(word, "0123456789") == ""
+}
+
+// SpeechStreamHandler feeds the text of a streamed response to a text-to-speech engine in
+// sentence-sized chunks as it streams, for voice agents. The text before a tool call is
+// spoken when the call starts; text pending when the stream fails, such as when it is
+// cancelled because the user interrupted, is dropped.
+type SpeechStreamHandler struct {
+	DefaultStreamHandler
+	Chunker *SentenceChunker
+	onChunk func(chunk string)
+}
+
+// NewSpeechStreamHandler creates a handler passing every sentence-sized chunk of streamed
+// text to onChunk
+func NewSpeechStreamHandler(onChunk func(chunk string)) *SpeechStreamHandler {
+	return &SpeechStreamHandler{Chunker: NewSentenceChunker(), onChunk: onChunk}
+}
+
+// OnStart discards text left over from a previous stream
+func (h *SpeechStreamHandler) OnStart() {
+	h.Chunker.Reset()
+}
+
+// OnToken speaks the sentences the token completes
+func (h *SpeechStreamHandler) OnToken(token string) {
+	for _, chunk := range h.Chunker.Push(token) {
+		h.onChunk(chunk)
+	}
+}
+
+// OnToolCall speaks the text before the tool call
+func (h *SpeechStreamHandler) OnToolCall(toolCall llm.ToolCall) {
+	h.flush()
+}
+
+// OnComplete speaks the rest of the response
+func (h *SpeechStreamHandler) OnComplete(message llm.Message) {
+	h.flush()
+}
+
+// OnError drops the text not yet spoken
+func (h *SpeechStreamHandler) OnError(err error) {
+	h.Chunker.Reset()
+}
+
+// flush speaks the pending text
+func (h *SpeechStreamHandler) flush() {
+	if rest := h.Chunker.Flush(); rest != "" {
+		h.onChunk(rest)
+	}
+}
@@ -0,0 +1,182 @@
+package swarmgo
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// StreamEventType identifies the kind of a StreamEvent
+type StreamEventType string
+
+const (
+	StreamEventStart         StreamEventType = "start"
+	StreamEventToken         StreamEventType = "token"
+	StreamEventToolCallReady StreamEventType = "tool_call_ready"
+	StreamEventToolCall      StreamEventType = "tool_call"
+	StreamEventToolProgress  StreamEventType = "tool_progress"
+	StreamEventUsage         StreamEventType = "usage"
+	StreamEventHeartbeat     StreamEventType = "heartbeat"
+	StreamEventStall         StreamEventType = "stall"
+	StreamEventComplete      StreamEventType = "complete"
+	StreamEventError         StreamEventType = "error"
+)
+
+// StreamEvent is a single event produced while streaming a response
+type StreamEvent struct {
+	Type     StreamEventType `json:"type"`
+	Token    string          `json:"token,omitempty"`     // Set for token events
+	ToolCall *llm.ToolCall   `json:"tool_call,omitempty"` // Set for tool call events
+	Progress *ToolProgress   `json:"progress,omitempty"`  // Set for tool progress events
+	Usage    *llm.Usage      `json:"usage,omitempty"`     // Set for usage events, with the usage of the run so far
+	Idle     time.Duration   `json:"idle,omitempty"`      // Set for heartbeat events, with the time since the last chunk
+	Message  *llm.Message    `json:"message,omitempty"`   // Set for complete events
+	Err      error           `json:"-"`                   // Set for error and stall events
+}
+
+// eventStreamHandler forwards StreamHandler callbacks as events on a channel,
+// blocking until the consumer receives each one
+type eventStreamHandler struct {
+	ctx    context.Context
+	events chan<- StreamEvent
+}
+
+func (h *eventStreamHandler) send(event StreamEvent) {
+	select {
+	case h.events <- event:
+	case <-h.ctx.Done():
+	}
+}
+
+func (h *eventStreamHandler) OnStart() {
+	h.send(StreamEvent{Type: StreamEventStart})
+}
+
+func (h *eventStreamHandler) OnToken(token string) {
+	h.send(StreamEvent{Type: StreamEventToken, Token: token})
+}
+
+func (h *eventStreamHandler) OnToolCallReady(toolCall llm.ToolCall) {
+	h.send(StreamEvent{Type: StreamEventToolCallReady, ToolCall: &toolCall})
+}
+
+func (h *eventStreamHandler) OnToolCall(toolCall llm.ToolCall) {
+	h.send(StreamEvent{Type: StreamEventToolCall, ToolCall: &toolCall})
+}
+
+func (h *eventStreamHandler) OnToolProgress(progress ToolProgress) {
+	h.send(StreamEvent{Type: StreamEventToolProgress, Progress: &progress})
+}
+
+func (h *eventStreamHandler) OnUsage(usage llm.Usage) {
+	h.send(StreamEvent{Type: StreamEventUsage, Usage: &usage})
+}
+
+func (h *eventStreamHandler) OnHeartbeat(idle time.Duration) {
+	h.send(StreamEvent{Type: StreamEventHeartbeat, Idle: idle})
+}
+
+func (h *eventStreamHandler) OnStall(err error) {
+	h.send(StreamEvent{Type: StreamEventStall, Err: err})
+}
+
+func (h *eventStreamHandler) OnComplete(message llm.Message) {
+	h.send(StreamEvent{Type: StreamEventComplete, Message: &message})
+}
+
+func (h *eventStreamHandler) OnError(err error) {
+	h.send(StreamEvent{Type: StreamEventError, Err: err})
+}
+
+// StreamIterator exposes a streaming response as a pull-based sequence of events.
+// The producer waits for the consumer to call Next before continuing, so slow
+// consumers apply backpressure instead of dropping events.
+type StreamIterator struct {
+	events   chan StreamEvent
+	current  StreamEvent
+	cancel   context.CancelFunc
+	done     chan struct{}
+	mu       sync.Mutex
+	err      error
+	messages []llm.Message
+	closed   bool
+}
+
+// Stream starts a streaming response and returns an iterator over its events
+func (s *Swarm) Stream(
+	ctx context.Context,
+	agent *Agent,
+	messages []llm.Message,
+	contextVariables map[string]interface{},
+	modelOverride string,
+	debug bool,
+) *StreamIterator {
+	ctx, cancel := context.WithCancel(ctx)
+	it := &StreamIterator{
+		events: make(chan StreamEvent),
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(it.done)
+		defer close(it.events)
+
+		handler := &eventStreamHandler{ctx: ctx, events: it.events}
+		produced, err := s.streamMessages(ctx, agent, messages, contextVariables, modelOverride, handler, debug)
+
+		it.mu.Lock()
+		defer it.mu.Unlock()
+		it.messages = produced
+		if !it.closed {
+			it.err = err
+		}
+	}()
+
+	return it
+}
+
+// Next blocks until the next event is available and reports whether there is one
+func (it *StreamIterator) Next() bool {
+	event, ok := <-it.events
+	if !ok {
+		return false
+	}
+	it.current = event
+	return true
+}
+
+// Event returns the event read by the last call to Next
+func (it *StreamIterator) Event() StreamEvent {
+	return it.current
+}
+
+// Err returns the error that ended the stream, if any. It is only meaningful once Next has returned false.
+func (it *StreamIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// Messages returns the messages produced by the stream. It is only meaningful once Next has returned false.
+func (it *StreamIterator) Messages() []llm.Message {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.messages
+}
+
+// Close stops the stream and releases its resources. It is safe to call more than once.
+func (it *StreamIterator) Close() error {
+	it.mu.Lock()
+	it.closed = true
+	it.mu.Unlock()
+
+	it.cancel()
+	for range it.events {
+		// Drain so the producer can observe cancellation and exit
+	}
+	<-it.done
+	return nil
+}
@@ -2,8 +2,10 @@ package swarmgo
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/prathyushnallamothu/swarmgo/llm"
 )
@@ -17,6 +19,18 @@ type StreamHandler interface {
 	OnError(err error)
 }
 
+// ToolCallReadyHandler can be implemented by a StreamHandler to be notified as soon as
+// a streamed tool call has fully assembled, parseable arguments, before it is executed
+type ToolCallReadyHandler interface {
+	OnToolCallReady(toolCall llm.ToolCall)
+}
+
+// UsageHandler can be implemented by a StreamHandler to receive the token usage of the
+// streamed run so far, whenever the provider reports usage
+type UsageHandler interface {
+	OnUsage(usage llm.Usage)
+}
+
 // DefaultStreamHandler provides a basic implementation of StreamHandler
 type DefaultStreamHandler struct{}
 
@@ -36,25 +50,95 @@ func (s *Swarm) StreamingResponse(
 	handler StreamHandler,
 	debug bool,
 ) error {
+	_, err := s.streamMessages(ctx, agent, messages, contextVariables, modelOverride, handler, debug)
+	return err
+}
+
+// streamMessages streams a chat completion and returns the messages produced, including
+// tool results. If the stream is cancelled, the partial assistant message is returned
+// marked as interrupted along with the context error.
+func (s *Swarm) streamMessages(
+	ctx context.Context,
+	agent *Agent,
+	messages []llm.Message,
+	contextVariables map[string]interface{},
+	modelOverride string,
+	handler StreamHandler,
+	debug bool,
+) (streamed []llm.Message, err error) {
 	if handler == nil {
 		handler = &DefaultStreamHandler{}
 	}
 
+	ctx, done, err := s.beginRun(ctx)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	defer done()
+	ctx, _ = withVariantAssignments(ctx, "", "")
+	ctx = s.withMessages(ctx)
+	maxTokens, err := s.admitTenant(ctx)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	ctx, budget, err := s.enterRun(ctx, maxTokens, 0)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	if progressHandler, ok := handler.(ToolProgressHandler); ok {
+		ctx = withProgressListener(ctx, progressHandler.OnToolProgress)
+	}
+
+	if err := s.audit(ctx, AuditRecord{Type: AuditRunStart, Agent: agent.Name, ContextKeys: contextKeys(contextVariables)}); err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	defer func() { s.auditRunEnd(ctx, agent, err) }()
+
 	if contextVariables == nil {
 		contextVariables = make(map[string]interface{})
 	}
+	defer delete(contextVariables, scratchpadKey)
+	defer func() { streamed = stripScratchpad(streamed) }()
+	ctx, _ = s.withFlags(ctx, contextVariables)
 
 	if debug {
 		fmt.Printf("Debug: Using model: %s\n", agent.Model)
 		fmt.Printf("Debug: Number of messages: %d\n", len(messages))
-		fmt.Printf("Debug: Number of tools: %d\n", len(agent.Functions))
+		fmt.Printf("Debug: Number of tools: %d\n", len(agent.allFunctions()))
 	}
 
-	// Prepare the initial system message with agent instructions
-	instructions := agent.Instructions
-	if agent.InstructionsFunc != nil {
-		instructions = agent.InstructionsFunc(contextVariables)
+	principal, _ := PrincipalFromContext(ctx)
+
+	messages, err = checkToolHistory(ctx, agent, messages)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+
+	if err := agent.checkSkills(contextVariables); err != nil {
+		handler.OnError(err)
+		return nil, err
 	}
+
+	model, err := agent.resolveModel(modelOverride)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+
+	// Only context variables allowed by the data policy are rendered into the instructions
+	exposed, err := s.enforcePolicy(agent, model, contextVariables)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+
+	// Prepare the initial system message with agent instructions
+	instructions := agent.runInstructions(ctx, exposed)
 	allMessages := append([]llm.Message{
 		{
 			Role:    llm.RoleSystem,
@@ -63,49 +147,89 @@ func (s *Swarm) StreamingResponse(
 	}, messages...)
 
 	// Build tool definitions
-	var tools []llm.Tool
-	for _, af := range agent.Functions {
-		def := FunctionToDefinition(af)
-		if debug {
-			fmt.Printf("Debug: Adding tool: %s\n", def.Name)
+	tools, err := agent.selectTools(ctx, agent.toolDefinitions(ctx, contextVariables), messages)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	if debug {
+		for _, tool := range tools {
+			fmt.Printf("Debug: Adding tool: %s\n", tool.Function.Name)
 		}
-		tools = append(tools, llm.Tool{
-			Type: "function",
-			Function: &llm.Function{
-				Name:        def.Name,
-				Description: def.Description,
-				Parameters:  def.Parameters,
-			},
-		})
 	}
 
 	// Prepare the streaming request
-	model := agent.Model
-	if modelOverride != "" {
-		model = modelOverride
-	}
-
 	if debug {
 		fmt.Printf("Debug: Final model: %s\n", model)
 		fmt.Printf("Debug: Creating stream with %d messages\n", len(allMessages))
 	}
 
+	// Compress old messages, reporting the estimated savings with the first request's usage
+	prompt, promptUsage := s.compressPrompt(allMessages)
 	req := llm.ChatCompletionRequest{
-		Model:    model,
-		Messages: allMessages,
-		Tools:    tools,
-		Stream:   true,
+		Model:       model,
+		Messages:    s.requestMessages(prompt),
+		Tools:       tools,
+		Stream:      true,
+		HostedTools: agent.HostedTools,
+		User:        requestUser(ctx),
+	}
+	applyRequestParams(ctx, &req)
+	// The agent's tool choice applies to its first request only
+	if agent.ToolChoice != nil && len(req.Tools) > 0 {
+		req.ToolChoice = agent.ToolChoice
+	}
+
+	if err := s.inspectRequest(ctx, agent, &req, debug); err != nil {
+		handler.OnError(err)
+		return nil, err
 	}
+	if err := s.auditRequest(ctx, agent, req); err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+
+	// Start predicted tool calls while the model streams
+	prefetch := s.startPrefetch(ctx, agent, allMessages, contextVariables)
 
-	stream, err := s.client.CreateChatCompletionStream(ctx, req)
+	// Watch streams for stalls, failing over to the fallback client if they keep stalling
+	timeout := s.streamTimeoutConfig()
+	client, err := s.clientFor(agent)
+	if err != nil {
+		handler.OnError(err)
+		return nil, err
+	}
+	var onHeartbeat func(idle time.Duration)
+	if heartbeatHandler, ok := handler.(HeartbeatHandler); ok {
+		onHeartbeat = heartbeatHandler.OnHeartbeat
+	}
+	// Each request is a turn with its own correlation ID
+	turnID := s.newID()
+	var raw *llm.RawCapture
+	openStream := func() (llm.ChatCompletionStream, error) {
+		streamCtx := providerContext(withTurnID(ctx, turnID), agent)
+		if agent.KeepRaw {
+			streamCtx, raw = llm.CaptureRaw(streamCtx)
+		}
+		stream, err := client.CreateChatCompletionStream(streamCtx, req)
+		if err != nil {
+			return nil, err
+		}
+		return watchStream(stream, timeout, onHeartbeat), nil
+	}
+
+	requestStart := time.Now()
+	stream, err := openStream()
 	if err != nil {
 		if debug {
 			fmt.Printf("Debug: Stream creation error: %v\n", err)
 		}
 		handler.OnError(fmt.Errorf("failed to create chat completion stream: %v", err))
-		return err
+		return nil, err
 	}
-	defer stream.Close()
+	defer func() {
+		stream.Close()
+	}()
 
 	handler.OnStart()
 
@@ -113,10 +237,83 @@ func (s *Swarm) StreamingResponse(
 	currentMessage.Role = llm.RoleAssistant
 	currentMessage.Name = agent.Name
 
-	// Track tool calls being built
-	toolCallsInProgress := make(map[string]*llm.ToolCall)
+	// produced returns the messages generated so far, excluding the request history
+	initLen := len(allMessages)
+	produced := func(interrupted bool) []llm.Message {
+		result := append([]llm.Message{}, allMessages[initLen:]...)
+		if currentMessage.Content != "" || len(currentMessage.ToolCalls) > 0 {
+			final := currentMessage
+			final.Interrupted = interrupted
+			result = append(result, final)
+		}
+		return result
+	}
+
+	// Hold back streamed text until output filters have scanned it
+	filter := newStreamOutputFilter(agent)
+	flushFilter := func() {
+		if filter == nil {
+			return
+		}
+		if rest := filter.flush(); rest != "" {
+			handler.OnToken(rest)
+		}
+	}
+	// cutToReleased drops text that was received but never shown because the stream stopped
+	cutToReleased := func() {
+		if filter != nil {
+			currentMessage.Content = filter.released()
+		}
+	}
+
+	// Track the tokens used by all requests of the run
+	usage := promptUsage
 	processedToolCalls := make(map[string]bool)
 
+	// Measure each request for the metrics exporter
+	var turnIndex int
+	turnUsage := promptUsage
+	var ttft, toolTime time.Duration
+	var stalls int
+	var failedOver bool
+	finishTurn := func(finishReason string) {
+		now := time.Now()
+		if raw != nil {
+			// The chunks of the turn's stream, as the provider sent them
+			currentMessage.Raw = raw.JSON()
+		}
+		turn := Turn{
+			ID:           turnID,
+			Index:        turnIndex,
+			AgentName:    agent.Name,
+			Model:        req.Model,
+			Message:      currentMessage,
+			FinishReason: finishReason,
+			Usage:        turnUsage,
+			Latency:      now.Sub(requestStart) - toolTime,
+			StartTime:    requestStart,
+			EndTime:      now,
+		}
+		turn.Metrics = LatencyMetrics{
+			TTFT:             ttft,
+			Provider:         turn.Latency,
+			Tools:            toolTime,
+			CompletionTokens: turnUsage.CompletionTokens,
+		}
+		if turnIndex == 0 {
+			turn.Metrics.Queue = queueLatencyFromContext(ctx)
+		}
+		s.exportTurn(ctx, turn)
+
+		turnIndex++
+		turnID = s.newID()
+		turnUsage = llm.Usage{}
+		ttft, toolTime = 0, 0
+		stalls = 0
+		requestStart = now
+	}
+	var finishReason string
+
 	// createNewStream creates a new stream and handles errors
 	createNewStream := func() error {
 		if err := stream.Close(); err != nil {
@@ -124,7 +321,16 @@ func (s *Swarm) StreamingResponse(
 			return err
 		}
 
-		newStream, err := s.client.CreateChatCompletionStream(ctx, req)
+		if err := s.inspectRequest(ctx, agent, &req, debug); err != nil {
+			handler.OnError(err)
+			return err
+		}
+		if err := s.auditRequest(ctx, agent, req); err != nil {
+			handler.OnError(err)
+			return err
+		}
+
+		newStream, err := openStream()
 		if err != nil {
 			if debug {
 				fmt.Printf("Debug: Error creating new stream: %v\n", err)
@@ -139,19 +345,50 @@ func (s *Swarm) StreamingResponse(
 	for {
 		select {
 		case <-ctx.Done():
+			cutToReleased()
 			handler.OnError(ctx.Err())
-			return ctx.Err()
+			return produced(true), ctx.Err()
 		default:
 			response, err := stream.Recv()
 			if err != nil {
-				if err.Error() == "EOF" {
+				if errors.Is(err, io.EOF) {
+					flushFilter()
+					finishTurn(finishReason)
 					handler.OnComplete(currentMessage)
-					return nil
+					return produced(false), nil
+				}
+				if ctx.Err() != nil {
+					// The stream was cancelled while waiting for the next chunk
+					cutToReleased()
+					handler.OnError(ctx.Err())
+					return produced(true), ctx.Err()
+				}
+				if errors.Is(err, ErrStreamStalled) {
+					// Send the request again only if none of its output was received
+					stalls++
+					retry := ttft == 0 && stalls <= timeout.Retries
+					if !retry && ttft == 0 && timeout.Fallback != nil && !failedOver {
+						client = timeout.Fallback
+						failedOver = true
+						retry = true
+					}
+					if !retry {
+						cutToReleased()
+						handler.OnError(err)
+						return produced(true), err
+					}
+					if stallHandler, ok := handler.(StallHandler); ok {
+						stallHandler.OnStall(err)
+					}
+					if err := createNewStream(); err != nil {
+						return produced(false), err
+					}
+					continue
 				}
 				if err.Error() == "stream closed" {
 					// If stream is closed, try to create a new one
 					if err := createNewStream(); err != nil {
-						return err
+						return produced(false), err
 					}
 					continue
 				}
@@ -159,7 +396,22 @@ func (s *Swarm) StreamingResponse(
 					fmt.Printf("Debug: Error receiving from stream: %v\n", err)
 				}
 				handler.OnError(fmt.Errorf("error receiving from stream: %v", err))
-				return err
+				return produced(false), err
+			}
+
+			// Usage may arrive in a chunk of its own, after the last choice
+			if response.Usage != (llm.Usage{}) {
+				usage = addUsage(usage, response.Usage)
+				turnUsage = addUsage(turnUsage, response.Usage)
+				if usageHandler, ok := handler.(UsageHandler); ok {
+					usageHandler.OnUsage(usage)
+				}
+				s.recordQuota(ctx, req.Model, response.Usage)
+				if err := budget.charge(response.Usage.TotalTokens); err != nil {
+					cutToReleased()
+					handler.OnError(err)
+					return produced(false), err
+				}
 			}
 
 			if len(response.Choices) == 0 {
@@ -167,165 +419,208 @@ func (s *Swarm) StreamingResponse(
 			}
 
 			choice := response.Choices[0]
+			if filtered := contentFilterError(agent.Name, req.Model, choice); filtered != nil {
+				cutToReleased()
+				handler.OnError(filtered)
+				return produced(true), filtered
+			}
+			if ttft == 0 && (choice.Message.Content != "" || len(choice.Message.ToolCalls) > 0) {
+				ttft = time.Since(requestStart)
+			}
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
 
 			// Handle content streaming
 			if choice.Message.Content != "" {
 				currentMessage.Content += choice.Message.Content
-				handler.OnToken(choice.Message.Content)
+				token := choice.Message.Content
+				if filter != nil {
+					if token, err = filter.push(token); err != nil {
+						// Cut the response off before the blocked content reaches the handler
+						cutToReleased()
+						handler.OnError(err)
+						return produced(false), err
+					}
+				}
+				if token != "" {
+					handler.OnToken(token)
+				}
 			}
 
-			// Handle tool calls
-			if len(choice.Message.ToolCalls) > 0 {
-				for _, toolCall := range choice.Message.ToolCalls {
+			// Streams return tool calls once their arguments are complete
+			readyCalls := choice.Message.ToolCalls
+			if len(readyCalls) == 0 {
+				continue
+			}
+
+			var functionMessages []llm.Message
+			// refuse answers a tool call that may not run with the built-in message, so the next
+			// request pairs every tool call with a result
+			refuse := func(toolCall llm.ToolCall, id MessageID) {
+				if toolCall.ID != "" {
+					processedToolCalls[toolCall.ID] = true
+				}
+				currentMessage.ToolCalls = append(currentMessage.ToolCalls, toolCall)
+				functionMessages = append(functionMessages, llm.Message{
+					Role:       s.toolRole(),
+					Content:    messagesFromContext(ctx).format(id, toolCall.Function.Name),
+					Name:       toolCall.Function.Name,
+					ToolCallID: toolCall.ID,
+				})
+			}
+			for _, toolCall := range readyCalls {
+				if debug {
+					fmt.Printf("Debug: Processing tool call: ID=%s Name=%s\n",
+						toolCall.ID, toolCall.Function.Name)
+				}
+				// Skip if we've already processed this tool call
+				if toolCall.ID != "" && processedToolCalls[toolCall.ID] {
 					if debug {
-						fmt.Printf("Debug: Processing tool call: ID=%s Name=%s\n",
-							toolCall.ID, toolCall.Function.Name)
+						fmt.Printf("Debug: Skipping already processed tool call: %s\n", toolCall.ID)
 					}
+					continue
+				}
+				toolCall.Index = nil
 
-					// Skip empty tool calls
-					if toolCall.ID == "" {
-						if debug {
-							fmt.Printf("Debug: Skipping empty tool call ID\n")
-						}
-						continue
-					}
+				if readyHandler, ok := handler.(ToolCallReadyHandler); ok {
+					readyHandler.OnToolCallReady(toolCall)
+				}
 
-					// Skip if we've already processed this tool call
-					if processedToolCalls[toolCall.ID] {
-						if debug {
-							fmt.Printf("Debug: Skipping already processed tool call: %s\n", toolCall.ID)
-						}
-						continue
+				// Find and execute the corresponding function
+				var fn *AgentFunction[map[string]interface{}]
+				for _, f := range agent.allFunctions() {
+					if f.Name == toolCall.Function.Name {
+						fn = &f
+						break
 					}
+				}
 
-					// Get or create the in-progress tool call
-					inProgress, exists := toolCallsInProgress[toolCall.ID]
-					if !exists {
-						inProgress = &llm.ToolCall{
-							ID:   toolCall.ID,
-							Type: toolCall.Type,
-							Function: llm.ToolCallFunction{
-								Name:      toolCall.Function.Name,
-								Arguments: "",
-							},
-						}
-						toolCallsInProgress[toolCall.ID] = inProgress
-						if debug {
-							fmt.Printf("Debug: Created new tool call: %s, Name: %s\n",
-								toolCall.ID, toolCall.Function.Name)
-						}
+				if fn == nil || !flagEnabled(ctx, fn.Flag) {
+					switch s.unknownToolStrategy() {
+					case UnknownToolFail:
+						err := fmt.Errorf("%w: %s", ErrUnknownTool, toolCall.Function.Name)
+						handler.OnError(err)
+						return produced(false), err
+					case UnknownToolResult:
+						// Answer the call so the next request pairs every tool call with a result
+						refuse(toolCall, MessageToolNotFound)
+					default:
+						handler.OnError(fmt.Errorf("unknown function: %s", toolCall.Function.Name))
 					}
+					continue
+				}
+				if !authorized(principal, fn) {
+					refuse(toolCall, MessageToolNotAuthorized)
+					continue
+				}
+				if ok, err := approved(ctx, fn, toolCall); err != nil {
+					handler.OnError(err)
+					return produced(false), err
+				} else if !ok {
+					refuse(toolCall, MessageToolNotApproved)
+					continue
+				}
 
-					// Update function name if provided
-					if toolCall.Function.Name != "" && inProgress.Function.Name == "" {
-						inProgress.Function.Name = toolCall.Function.Name
-						if debug {
-							fmt.Printf("Debug: Updated function name for tool call %s: %s\n",
-								toolCall.ID, toolCall.Function.Name)
-						}
-					}
+				var args map[string]interface{}
+				if err := decodeJSON(toolCall.Function.Arguments, &args); err != nil {
+					handler.OnError(fmt.Errorf("invalid arguments for tool call %s: %v", toolCall.ID, err))
+					continue
+				}
+
+				if debug {
+					fmt.Printf("Debug: Executing function %s with args: %s\n",
+						toolCall.Function.Name, s.redactDebug(agent, args, contextVariables))
+				}
+
+				// Fill in context variables the model referred to by placeholder
+				if args, err = agent.expandArguments(args, contextVariables); err != nil {
+					handler.OnError(fmt.Errorf("invalid arguments for tool call %s: %v", toolCall.ID, err))
+					continue
+				}
 
-					// Accumulate function arguments
-					if toolCall.Function.Arguments != "" {
-						// Always append new arguments
-						inProgress.Function.Arguments += toolCall.Function.Arguments
-						if debug {
-							fmt.Printf("Debug: Updated arguments for tool call %s: %s\n",
-								toolCall.ID, inProgress.Function.Arguments)
-						}
-
-						// Try to parse the arguments to verify it's complete JSON
-						var args map[string]interface{}
-						if err := json.Unmarshal([]byte(inProgress.Function.Arguments), &args); err == nil {
-							if debug {
-								fmt.Printf("Debug: Valid JSON arguments for tool call %s: %v\n",
-									toolCall.ID, args)
-							}
-
-							// Only execute if we haven't processed this tool call yet
-							if !processedToolCalls[toolCall.ID] {
-								// Find and execute the corresponding function
-								var fn *AgentFunction[map[string]interface{}]
-								for _, f := range agent.Functions {
-									if f.Name == inProgress.Function.Name {
-										fn = &f
-										break
-									}
-								}
-
-								if fn == nil {
-									err := fmt.Errorf("unknown function: %s", inProgress.Function.Name)
-									handler.OnError(err)
-									continue
-								}
-
-								if debug {
-									fmt.Printf("Debug: Executing function %s with args: %v\n",
-										inProgress.Function.Name, args)
-								}
-
-								// Execute the function
-								result := fn.executor(args, contextVariables)
-
-								// Create function response message
-								var resultContent string
-								if result.Error != nil {
-									resultContent = fmt.Sprintf("Error: %v", result.Error)
-									if debug {
-										fmt.Printf("Debug: Function execution error: %v\n", result.Error)
-									}
-								} else {
-									resultContent = fmt.Sprintf("%v", result.Data)
-									if debug {
-										fmt.Printf("Debug: Function execution success: %v\n", result.Data)
-									}
-								}
-
-								// Mark as processed and clean up
-								processedToolCalls[toolCall.ID] = true
-								delete(toolCallsInProgress, toolCall.ID)
-
-								// Add to current message and notify handler
-								currentMessage.ToolCalls = append(currentMessage.ToolCalls, *inProgress)
-								handler.OnToolCall(*inProgress)
-
-								// Add function response message
-								functionMessage := llm.Message{
-									Role:    llm.RoleFunction,
-									Content: resultContent,
-									Name:    inProgress.Function.Name,
-								}
-
-								// Add messages and create new stream
-								allMessages = append(allMessages, currentMessage)
-								allMessages = append(allMessages, functionMessage)
-								req.Messages = allMessages
-
-								if debug {
-									fmt.Printf("Debug: Added function response message: %s = %s\n",
-										functionMessage.Name, functionMessage.Content)
-								}
-
-								if err := createNewStream(); err != nil {
-									handler.OnError(fmt.Errorf("failed to create new stream after tool call: %v", err))
-									return err
-								}
-
-								if debug {
-									fmt.Printf("Debug: Created new stream after tool call, messages count: %d\n", len(allMessages))
-								}
-
-								// Reset current message for new response
-								currentMessage = llm.Message{
-									Role: llm.RoleAssistant,
-									Name: agent.Name,
-								}
-							}
-						} else if debug {
-							fmt.Printf("Debug: Incomplete JSON for tool call %s: %v\n", toolCall.ID, err)
-						}
+				if err := s.audit(ctx, AuditRecord{
+					Type:       AuditToolCall,
+					Agent:      agent.Name,
+					Tool:       toolCall.Function.Name,
+					ToolCallID: toolCall.ID,
+					Arguments:  toolCall.Function.Arguments,
+					Prefetched: prefetch.has(toolCall.Function.Name, args),
+				}); err != nil {
+					handler.OnError(err)
+					return produced(false), err
+				}
+
+				// Execute the function, unless it was prefetched
+				toolStart := time.Now()
+				toolCtx := withToolCallID(withTurnID(ctx, turnID), toolCall.ID)
+				result, prefetched := prefetch.take(toolCtx, toolCall.Function.Name, args)
+				if !prefetched {
+					result = s.executeIdempotent(toolCtx, fn, toolCall.ID, args, contextVariables, debug)
+				}
+				toolTime += time.Since(toolStart)
+				if prefetched && debug {
+					fmt.Printf("Debug: Serving prefetched result for %s\n", toolCall.Function.Name)
+				}
+				recordCompensation(ctx, fn, toolCall.ID, args, result)
+
+				// Create function response message
+				if debug {
+					if result.Error != nil {
+						fmt.Printf("Debug: Function execution error: %s\n", s.redactDebug(agent, result.Error, contextVariables))
+					} else {
+						fmt.Printf("Debug: Function execution success: %s\n", s.redactDebug(agent, result.Data, contextVariables))
 					}
 				}
+
+				// Mark as processed and notify handler
+				if toolCall.ID != "" {
+					processedToolCalls[toolCall.ID] = true
+				}
+				currentMessage.ToolCalls = append(currentMessage.ToolCalls, toolCall)
+				handler.OnToolCall(toolCall)
+
+				functionMessages = append(functionMessages, llm.Message{
+					Role:       s.toolRole(),
+					Content:    agent.guardToolOutput(toolCall.Function.Name, resultContent(messagesFromContext(ctx), result), contextVariables),
+					Name:       toolCall.Function.Name,
+					ToolCallID: toolCall.ID,
+				})
+			}
+
+			if len(functionMessages) == 0 {
+				continue
+			}
+
+			// Add messages and create new stream
+			flushFilter()
+			finishTurn(finishReason)
+			finishReason = ""
+			allMessages = append(allMessages, currentMessage)
+			allMessages = append(allMessages, functionMessages...)
+			filter = newStreamOutputFilter(agent)
+			currentMessage = llm.Message{
+				Role: llm.RoleAssistant,
+				Name: agent.Name,
+			}
+			prompt, promptUsage = s.compressPrompt(allMessages)
+			req.Messages = s.requestMessages(prompt)
+			req.ToolChoice = nil
+			turnUsage = addUsage(turnUsage, promptUsage)
+			usage = addUsage(usage, promptUsage)
+
+			if debug {
+				fmt.Printf("Debug: Added %d function response messages\n", len(functionMessages))
+			}
+
+			prefetch = s.startPrefetch(ctx, agent, allMessages, contextVariables)
+			// createNewStream reports its errors to the handler
+			if err := createNewStream(); err != nil {
+				return produced(false), err
+			}
+
+			if debug {
+				fmt.Printf("Debug: Created new stream after tool call, messages count: %d\n", len(allMessages))
 			}
 		}
 	}
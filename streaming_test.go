@@ -0,0 +1,313 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// fakeStream replays canned chunks, then either ends or blocks until its context is cancelled
+type fakeStream struct {
+	ctx    context.Context
+	chunks []llm.ChatCompletionResponse
+	block  bool
+}
+
+func (f *fakeStream) Recv() (llm.ChatCompletionResponse, error) {
+	if len(f.chunks) > 0 {
+		chunk := f.chunks[0]
+		f.chunks = f.chunks[1:]
+		return chunk, nil
+	}
+	if f.block {
+		<-f.ctx.Done()
+		return llm.ChatCompletionResponse{}, f.ctx.Err()
+	}
+	return llm.ChatCompletionResponse{}, io.EOF
+}
+
+func (f *fakeStream) Close() error {
+	return nil
+}
+
+func tokenChunk(content string) llm.ChatCompletionResponse {
+	return llm.ChatCompletionResponse{
+		Choices: []llm.Choice{{Message: llm.Message{Role: llm.RoleAssistant, Content: content}}},
+	}
+}
+
+// TestStreamIterator tests pulling streamed events through the iterator API
+func TestStreamIterator(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	stream := &fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("Hello"), tokenChunk(", world")}}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(stream, nil).Once()
+
+	it := sw.Stream(context.Background(), &Agent{Name: "TestAgent"}, []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, nil, "", false)
+	defer it.Close()
+
+	var types []StreamEventType
+	var content string
+	for it.Next() {
+		event := it.Event()
+		types = append(types, event.Type)
+		content += event.Token
+	}
+
+	assert.NoError(t, it.Err())
+	assert.Equal(t, []StreamEventType{StreamEventStart, StreamEventToken, StreamEventToken, StreamEventComplete}, types)
+	assert.Equal(t, "Hello, world", content)
+	assert.Len(t, it.Messages(), 1)
+}
+
+// TestStreamOutputFilter tests that blocked content spanning several tokens is cut off before reaching the handler
+func TestStreamOutputFilter(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	chunks := []llm.ChatCompletionResponse{tokenChunk("The admin password "), tokenChunk("is hun"), tokenChunk("ter2 and more")}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: chunks}, nil).Once()
+
+	agent := (&Agent{Name: "TestAgent", OutputFilterWindow: 8}).WithOutputFilters(BlocklistFilter("hunter2"))
+	it := sw.Stream(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, nil, "", false)
+	defer it.Close()
+
+	var content string
+	for it.Next() {
+		content += it.Event().Token
+	}
+
+	assert.ErrorIs(t, it.Err(), ErrContentBlocked)
+	assert.NotContains(t, content, "hunter2")
+	assert.Equal(t, "The admin passwor", content)
+	assert.Equal(t, content, it.Messages()[0].Content)
+}
+
+// TestStreamUsage tests that usage reported by a stream, including in a chunk without choices, is surfaced as events
+func TestStreamUsage(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	chunks := []llm.ChatCompletionResponse{
+		tokenChunk("Hello"),
+		{Usage: llm.Usage{PromptTokens: 12, CompletionTokens: 3, TotalTokens: 15}},
+	}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: chunks}, nil).Once()
+
+	it := sw.Stream(context.Background(), &Agent{Name: "TestAgent"}, []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, nil, "", false)
+	defer it.Close()
+
+	var usage *llm.Usage
+	for it.Next() {
+		if event := it.Event(); event.Type == StreamEventUsage {
+			usage = event.Usage
+		}
+	}
+
+	assert.NoError(t, it.Err())
+	if assert.NotNil(t, usage) {
+		assert.Equal(t, 15, usage.TotalTokens)
+	}
+}
+
+func TestStreamStallFailover(t *testing.T) {
+	stalled, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	primary := new(MockLLM)
+	primary.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{ctx: stalled, block: true}, nil).Twice()
+	fallback := new(MockLLM)
+	fallback.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("Hello")}}, nil).Once()
+	sw := NewMockSwarm(primary).WithStreamTimeout(StreamTimeout{
+		Idle:      40 * time.Millisecond,
+		Heartbeat: 10 * time.Millisecond,
+		Retries:   1,
+		Fallback:  fallback,
+	})
+
+	it := sw.Stream(context.Background(), &Agent{Name: "TestAgent"}, []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, nil, "", false)
+	defer it.Close()
+
+	counts := map[StreamEventType]int{}
+	var message *llm.Message
+	for it.Next() {
+		event := it.Event()
+		counts[event.Type]++
+		if event.Type == StreamEventStall {
+			assert.ErrorIs(t, event.Err, ErrStreamStalled)
+		}
+		if event.Type == StreamEventComplete {
+			message = event.Message
+		}
+	}
+
+	assert.NoError(t, it.Err())
+	assert.Equal(t, 2, counts[StreamEventStall])
+	assert.Positive(t, counts[StreamEventHeartbeat])
+	if assert.NotNil(t, message) {
+		assert.Equal(t, "Hello", message.Content)
+	}
+	primary.AssertExpectations(t)
+}
+
+func TestSentenceChunker(t *testing.T) {
+	chunker := NewSentenceChunker()
+	var chunks []string
+	for _, token := range []string{"Hi", " Mr. Smith", "! Pi is 3", ".14 today", ". \"Really?\"", " Yes", "\n- first\n", "你好。再见"} {
+		chunks = append(chunks, chunker.Push(token)...)
+	}
+	assert.Equal(t, []string{"Hi Mr. Smith!", "Pi is 3.14 today.", "\"Really?\"", "Yes", "- first", "你好。"}, chunks)
+	assert.Equal(t, "再见", chunker.Flush())
+
+	chunker = &SentenceChunker{MinChars: 10, MaxChars: 20}
+	assert.Equal(t, []string{"Ok. Sounds good."}, chunker.Push("Ok. Sounds good. Go"))
+	assert.Equal(t, []string{"Go on, then,", "keep going and"}, chunker.Push(" on, then, keep going and going on"))
+	assert.Equal(t, "going on", chunker.Flush())
+
+	// Runes longer than MaxChars are cut one at a time
+	chunker = &SentenceChunker{MaxChars: 2}
+	assert.Equal(t, []string{"你", "好", "世", "界"}, chunker.Push("你好世界"))
+	assert.Empty(t, chunker.Flush())
+
+	var spoken []string
+	handler := NewSpeechStreamHandler(func(chunk string) { spoken = append(spoken, chunk) })
+	handler.OnStart()
+	handler.OnToken("Let me check. One")
+	handler.OnToolCall(llm.ToolCall{})
+	handler.OnToken("It is sunny")
+	handler.OnComplete(llm.Message{})
+	handler.OnToken("Never spoken")
+	handler.OnError(context.Canceled)
+	assert.Equal(t, []string{"Let me check.", "One", "It is sunny"}, spoken)
+}
+
+func TestStreamKeepRaw(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"}}],\"x_trace\":\"abc\"}\n\n")
+		io.WriteString(w, "data: {\"id\":\"1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		io.WriteString(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	sw := NewSwarmWithHost("test-key", server.URL+"/v1", llm.OpenAI)
+	agent := NewAgent("Assistant", "gpt-4o", llm.OpenAI).WithKeepRaw(true)
+	messages, err := sw.streamMessages(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Hello"}}, nil, "", nil, false)
+	assert.NoError(t, err)
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "Hi", messages[0].Content)
+	var chunks []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(messages[0].Raw, &chunks))
+	assert.Len(t, chunks, 2)
+	assert.Equal(t, "abc", chunks[0]["x_trace"])
+}
+
+// TestStreamToolNotAuthorized tests that a streamed call to a tool the principal may not use is answered with an error result
+func TestStreamToolNotAuthorized(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient).WithToolRoleMessages(true)
+
+	refund, err := NewAgentFunction("issue_refund", "Issue a refund", func(args map[string]interface{}, cv map[string]interface{}) Result {
+		return Result{Success: true, Data: "refunded"}
+	})
+	assert.NoError(t, err)
+	agent := NewAgent("TestAgent", "gpt-4", llm.OpenAI).WithFunctions(refund.WithRoles("supervisor"))
+
+	index := 0
+	toolCall := llm.ChatCompletionResponse{Choices: []llm.Choice{{
+		Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+			Index: &index, ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "issue_refund", Arguments: "{}"},
+		}}},
+		FinishReason: "tool_calls",
+	}}}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{toolCall}}, nil).Once()
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("I can't issue refunds.")}}, nil).Once()
+
+	ctx := WithPrincipal(context.Background(), &Principal{ID: "user-1", Roles: []string{"support"}})
+	messages, err := sw.streamMessages(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: "Refund me"}}, nil, "", nil, false)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 3) {
+		assert.Equal(t, llm.RoleTool, messages[1].Role)
+		assert.Equal(t, "call_1", messages[1].ToolCallID)
+		assert.Equal(t, "Error: Tool issue_refund is not authorized.", messages[1].Content)
+		assert.Equal(t, "I can't issue refunds.", messages[2].Content)
+	}
+	mockClient.AssertExpectations(t)
+}
+
+func TestStreamUnknownToolResult(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient).WithUnknownToolStrategy(UnknownToolResult)
+	agent := NewAgent("TestAgent", "gpt-4", llm.OpenAI)
+
+	index := 0
+	toolCall := llm.ChatCompletionResponse{Choices: []llm.Choice{{
+		Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+			Index: &index, ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "missing", Arguments: "{}"},
+		}}},
+		FinishReason: "tool_calls",
+	}}}
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{toolCall}}, nil).Once()
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{tokenChunk("Sorry")}}, nil).Once()
+
+	messages, err := sw.streamMessages(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Hi"}}, nil, "", nil, false)
+	assert.NoError(t, err)
+	if assert.Len(t, messages, 3) {
+		// The result takes the swarm's tool role like any other tool result
+		assert.Equal(t, llm.RoleFunction, messages[1].Role)
+		assert.Equal(t, "call_1", messages[1].ToolCallID)
+		assert.Equal(t, "Error: Tool missing not found.", messages[1].Content)
+	}
+	mockClient.AssertExpectations(t)
+}
+
+// errorRecorder records the errors reported to a stream handler
+type errorRecorder struct {
+	DefaultStreamHandler
+	errs []error
+}
+
+func (h *errorRecorder) OnError(err error) {
+	h.errs = append(h.errs, err)
+}
+
+func TestStreamNewStreamError(t *testing.T) {
+	mockClient := new(MockLLM)
+	sw := NewMockSwarm(mockClient)
+
+	lookup, err := NewAgentFunction("lookup", "Look something up", func(args map[string]interface{}, cv map[string]interface{}) Result {
+		return Result{Success: true, Data: "found"}
+	})
+	assert.NoError(t, err)
+	agent := NewAgent("TestAgent", "gpt-4", llm.OpenAI).WithFunctions(lookup)
+
+	toolCall := llm.ChatCompletionResponse{Choices: []llm.Choice{{
+		Message: llm.Message{Role: llm.RoleAssistant, ToolCalls: []llm.ToolCall{{
+			ID: "call_1", Type: "function", Function: llm.ToolCallFunction{Name: "lookup", Arguments: "{}"},
+		}}},
+		FinishReason: "tool_calls",
+	}}}
+	unavailable := errors.New("service unavailable")
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return(&fakeStream{chunks: []llm.ChatCompletionResponse{toolCall}}, nil).Once()
+	mockClient.On("CreateChatCompletionStream", mock.Anything, mock.Anything).Return((*fakeStream)(nil), unavailable).Once()
+
+	// The error is reported once, and the tool call and its result are returned once each
+	handler := &errorRecorder{}
+	messages, err := sw.streamMessages(context.Background(), agent, []llm.Message{{Role: llm.RoleUser, Content: "Look it up"}}, nil, "", handler, false)
+	assert.ErrorIs(t, err, unavailable)
+	assert.Len(t, handler.errs, 1)
+	if assert.Len(t, messages, 2) {
+		assert.Equal(t, "call_1", messages[0].ToolCalls[0].ID)
+		assert.Equal(t, "found", messages[1].Content)
+	}
+	mockClient.AssertExpectations(t)
+}
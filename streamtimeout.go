@@ -0,0 +1,146 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrStreamStalled is returned when a provider stream sends no chunk within the idle timeout
+var ErrStreamStalled = errors.New("stream stalled")
+
+// StreamTimeout configures how stalled provider streams are detected and recovered from
+type StreamTimeout struct {
+	Idle      time.Duration // Longest wait for a chunk before the stream counts as stalled; no limit if zero
+	Heartbeat time.Duration // Interval of heartbeats sent to a HeartbeatHandler while waiting; none if zero
+	// Retries is the number of times a stalled request is sent again. Only requests that have
+	// not produced any output yet are retried, so no token is delivered twice.
+	Retries int
+	// Fallback receives stalled requests once the retries are exhausted, and the rest of the
+	// stream's requests after that, if set
+	Fallback llm.LLM
+}
+
+// HeartbeatHandler can be implemented by a StreamHandler to hear that a stream is still waiting
+// for the provider, with the time since the last chunk
+type HeartbeatHandler interface {
+	OnHeartbeat(idle time.Duration)
+}
+
+// StallHandler can be implemented by a StreamHandler to be notified when a stalled request is
+// retried or failed over. Stalls that end the stream are reported to OnError.
+type StallHandler interface {
+	OnStall(err error)
+}
+
+// WithStreamTimeout sets how stalled provider streams are detected and recovered from
+func (s *Swarm) WithStreamTimeout(timeout StreamTimeout) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.streamTimeout = timeout
+	return s
+}
+
+// streamTimeoutConfig returns the swarm's stream timeout settings
+func (s *Swarm) streamTimeoutConfig() StreamTimeout {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.streamTimeout
+}
+
+// streamChunk is a chunk, or the error that ended a stream, read ahead by a watchedStream
+type streamChunk struct {
+	resp llm.ChatCompletionResponse
+	err  error
+}
+
+// watchedStream fails with ErrStreamStalled when its stream sends nothing within the idle
+// timeout. A background reader receives the chunks so that waiting for them can time out.
+type watchedStream struct {
+	stream      llm.ChatCompletionStream
+	timeout     StreamTimeout
+	onHeartbeat func(idle time.Duration)
+	chunks      chan streamChunk
+	done        chan struct{}
+	closeOnce   sync.Once
+	closeErr    error
+	err         error // Error that ended the stream, returned by every later Recv
+}
+
+// watchStream wraps a stream to detect stalls, or returns it as is if no timeout is set
+func watchStream(stream llm.ChatCompletionStream, timeout StreamTimeout, onHeartbeat func(idle time.Duration)) llm.ChatCompletionStream {
+	if timeout.Idle <= 0 && (timeout.Heartbeat <= 0 || onHeartbeat == nil) {
+		return stream
+	}
+	w := &watchedStream{
+		stream:      stream,
+		timeout:     timeout,
+		onHeartbeat: onHeartbeat,
+		chunks:      make(chan streamChunk),
+		done:        make(chan struct{}),
+	}
+	go w.read()
+	return w
+}
+
+// read receives chunks until the stream ends or is closed
+func (w *watchedStream) read() {
+	for {
+		resp, err := w.stream.Recv()
+		select {
+		case w.chunks <- streamChunk{resp: resp, err: err}:
+		case <-w.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Recv returns the next chunk, or ErrStreamStalled if none arrives within the idle timeout
+func (w *watchedStream) Recv() (llm.ChatCompletionResponse, error) {
+	if w.err != nil {
+		return llm.ChatCompletionResponse{}, w.err
+	}
+
+	var idle, heartbeat <-chan time.Time
+	if w.timeout.Idle > 0 {
+		timer := time.NewTimer(w.timeout.Idle)
+		defer timer.Stop()
+		idle = timer.C
+	}
+	if w.timeout.Heartbeat > 0 && w.onHeartbeat != nil {
+		ticker := time.NewTicker(w.timeout.Heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	start := time.Now()
+	for {
+		select {
+		case chunk := <-w.chunks:
+			w.err = chunk.err
+			return chunk.resp, chunk.err
+		case <-heartbeat:
+			w.onHeartbeat(time.Since(start))
+		case <-idle:
+			// Closing the stream unblocks the background reader
+			w.Close()
+			w.err = fmt.Errorf("%w: no chunk received for %s", ErrStreamStalled, w.timeout.Idle)
+			return llm.ChatCompletionResponse{}, w.err
+		}
+	}
+}
+
+// Close closes the stream and stops the background reader. It is safe to call more than once.
+func (w *watchedStream) Close() error {
+	w.closeOnce.Do(func() {
+		close(w.done)
+		w.closeErr = w.stream.Close()
+	})
+	return w.closeErr
+}
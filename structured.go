@@ -0,0 +1,89 @@
+package swarmgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrInvalidStructuredOutput is returned when the model keeps replying with output that does
+// not match the requested structure
+var ErrInvalidStructuredOutput = errors.New("invalid structured output")
+
+// DefaultStructuredRetries is the number of times an invalid structured reply is sent back to
+// the model for correction
+const DefaultStructuredRetries = 2
+
+// structuredInstructions asks for JSON on backends that cannot enforce a schema
+const structuredInstructions = "Reply with only a JSON object matching this JSON schema, without any other text:\n%s"
+
+// structuredRetryPrompt sends an invalid reply back to the model
+const structuredRetryPrompt = "Your reply was invalid: %v. Reply again with only a JSON object matching the schema."
+
+// runStructured runs the agent for a single turn without tools and decodes its reply into out,
+// which the reply must match the schema of. Backends that cannot constrain decoding to the
+// schema are asked for JSON in the instructions instead. Replies that do not decode, or that
+// validate rejects, are sent back with the error up to retries times.
+func (s *Swarm) runStructured(ctx context.Context, agent *Agent, messages []llm.Message, schema map[string]interface{}, out interface{}, validate func() error, retries int) (llm.Usage, error) {
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return llm.Usage{}, fmt.Errorf("failed to encode schema: %w", err)
+	}
+	opts := RunOptions{MaxTurns: 1, SkipTools: true, Grammar: &llm.Grammar{JSONSchema: data}}
+	messages = messages[:len(messages):len(messages)]
+
+	var usage llm.Usage
+	for attempt := 0; ; attempt++ {
+		resp, err := s.RunWithOptions(ctx, agent, messages, opts)
+		if errors.Is(err, llm.ErrGrammarNotSupported) && opts.Grammar != nil {
+			opts.Grammar = nil
+			opts.InstructionsSuffix = fmt.Sprintf(structuredInstructions, data)
+			attempt--
+			continue
+		}
+		if err != nil {
+			return usage, err
+		}
+		usage = addUsage(usage, resp.Usage)
+
+		var reply string
+		if len(resp.Messages) > 0 {
+			reply = resp.Messages[len(resp.Messages)-1].Content
+		}
+		err = decodeJSONReply(reply, out)
+		if err == nil && validate != nil {
+			err = validate()
+		}
+		if err == nil {
+			return usage, nil
+		}
+		if attempt >= retries {
+			return usage, fmt.Errorf("%w: %v", ErrInvalidStructuredOutput, err)
+		}
+		messages = append(messages,
+			llm.Message{Role: llm.RoleAssistant, Content: reply},
+			llm.Message{Role: llm.RoleUser, Content: fmt.Sprintf(structuredRetryPrompt, err)},
+		)
+	}
+}
+
+// decodeJSONReply decodes the JSON object in a reply, ignoring code fences and text around it
+func decodeJSONReply(reply string, out interface{}) error {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return fmt.Errorf("no JSON object in reply")
+	}
+	// Clear what an earlier invalid reply decoded
+	if v := reflect.ValueOf(out); v.Kind() == reflect.Pointer && !v.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+	if err := decodeJSON(reply[start:end+1], out); err != nil {
+		return fmt.Errorf("failed to decode reply: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// SummaryMode is how a Summarizer handles inputs longer than one chunk
+type SummaryMode string
+
+const (
+	// SummaryMapReduce summarizes the chunks concurrently and then combines their summaries
+	SummaryMapReduce SummaryMode = "map_reduce"
+	// SummaryRefine summarizes the first chunk and refines the summary with each following
+	// chunk in turn, which is slower but keeps context across chunks
+	SummaryRefine SummaryMode = "refine"
+)
+
+// SummaryLength is the target length of a summary
+type SummaryLength string
+
+const (
+	SummaryShort  SummaryLength = "short"  // One or two sentences
+	SummaryMedium SummaryLength = "medium" // A paragraph
+	SummaryLong   SummaryLength = "long"   // Several paragraphs covering every main point
+)
+
+// summaryLengths describes the target lengths to the model
+var summaryLengths = map[SummaryLength]string{
+	SummaryShort:  "one or two sentences",
+	SummaryMedium: "a single paragraph",
+	SummaryLong:   "several paragraphs covering every main point",
+}
+
+// Summarizer condenses text of any length with a model. Inputs longer than a chunk are
+// summarized with map-reduce or refinement. It is safe for concurrent use once configured.
+type Summarizer struct {
+	Model        string
+	Mode         SummaryMode   // How long inputs are handled, SummaryMapReduce if empty
+	Length       SummaryLength // Target length, SummaryMedium if empty
+	MaxWords     int           // Word limit of the summary, none if zero
+	Tone         string        // Tone of the summary, e.g. "neutral" or "executive"
+	Bullets      bool          // Write the summary as a bulleted list
+	Instructions string        // Additional guidance, such as what to focus on
+	ChunkSize    int           // Maximum chunk size in characters, DefaultChunkSize if zero
+	ChunkOverlap int           // Characters repeated between consecutive chunks
+	Concurrency  int           // Maximum number of chunks summarized at once in map-reduce mode
+	swarm        *Swarm
+}
+
+// NewSummarizer creates a summarizer using the model
+func NewSummarizer(sw *Swarm, model string) *Summarizer {
+	return &Summarizer{Model: model, ChunkSize: DefaultChunkSize, swarm: sw}
+}
+
+// WithMode sets how long inputs are handled
+func (s *Summarizer) WithMode(mode SummaryMode) *Summarizer {
+	s.Mode = mode
+	return s
+}
+
+// WithLength sets the target length and word limit of summaries
+func (s *Summarizer) WithLength(length SummaryLength, maxWords int) *Summarizer {
+	s.Length = length
+	s.MaxWords = maxWords
+	return s
+}
+
+// WithTone sets the tone of summaries
+func (s *Summarizer) WithTone(tone string) *Summarizer {
+	s.Tone = tone
+	return s
+}
+
+// WithBullets makes summaries bulleted lists
+func (s *Summarizer) WithBullets(bullets bool) *Summarizer {
+	s.Bullets = bullets
+	return s
+}
+
+// WithInstructions adds guidance for the summarizer
+func (s *Summarizer) WithInstructions(instructions string) *Summarizer {
+	s.Instructions = instructions
+	return s
+}
+
+// Summarize returns a summary of the text in the configured style
+func (s *Summarizer) Summarize(ctx context.Context, text string) (string, error) {
+	chunks := ChunkText(text, s.ChunkSize, s.ChunkOverlap)
+	if len(chunks) == 0 {
+		return "", errors.New("no text to summarize")
+	}
+	agent := NewAgent("Summarizer", s.Model, s.swarm.provider).WithInstructions(s.style())
+	if len(chunks) == 1 {
+		return s.run(ctx, agent, "Summarize this text:\n\n"+chunks[0])
+	}
+
+	switch s.Mode {
+	case SummaryRefine:
+		summary, err := s.run(ctx, agent, "Summarize this text, the first part of a longer text:\n\n"+chunks[0])
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize part 1 of %d: %w", len(chunks), err)
+		}
+		for i, chunk := range chunks[1:] {
+			prompt := fmt.Sprintf("Here is a summary of the first %d parts of a longer text:\n\n%s\n\nRefine it with part %d of %d, keeping what still matters and adding what is new:\n\n%s", i+1, summary, i+2, len(chunks), chunk)
+			if summary, err = s.run(ctx, agent, prompt); err != nil {
+				return "", fmt.Errorf("failed to refine summary with part %d of %d: %w", i+2, len(chunks), err)
+			}
+		}
+		return summary, nil
+	case SummaryMapReduce, "":
+		// Part summaries only need to keep the facts; the style applies to the final summary
+		mapper := NewAgent("SummaryMapper", s.Model, s.swarm.provider).WithInstructions("Summarize the text concisely, keeping every key fact, name and number.")
+		mr := NewMapReduce(s.swarm, mapper, agent, "Summarize the text").WithChunking(s.ChunkSize, s.ChunkOverlap).WithConcurrency(s.Concurrency)
+		result, err := mr.Run(ctx, text)
+		if err != nil {
+			return "", err
+		}
+		return result.Output, nil
+	default:
+		return "", fmt.Errorf("unknown summary mode %q", s.Mode)
+	}
+}
+
+// run sends the prompt to the summarizer agent and returns its reply
+func (s *Summarizer) run(ctx context.Context, agent *Agent, prompt string) (string, error) {
+	response, err := s.swarm.RunWithOptions(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, RunOptions{MaxTurns: 1, SkipTools: true})
+	if err != nil {
+		return "", err
+	}
+	return lastAssistantContent(response.Messages), nil
+}
+
+// style returns the instructions describing the configured summary style
+func (s *Summarizer) style() string {
+	length := s.Length
+	if length == "" {
+		length = SummaryMedium
+	}
+	description, ok := summaryLengths[length]
+	if !ok {
+		description = string(length)
+	}
+
+	var b strings.Builder
+	b.WriteString("You write accurate summaries that only state what the text says.\n")
+	fmt.Fprintf(&b, "Length: %s", description)
+	if s.MaxWords > 0 {
+		fmt.Fprintf(&b, ", at most %d words", s.MaxWords)
+	}
+	b.WriteString(".\n")
+	if s.Tone != "" {
+		fmt.Fprintf(&b, "Tone: %s.\n", s.Tone)
+	}
+	if s.Bullets {
+		b.WriteString("Format: a bulleted list with one point per line, each starting with \"- \".\n")
+	} else {
+		b.WriteString("Format: prose without headings or lists.\n")
+	}
+	if s.Instructions != "" {
+		b.WriteString(s.Instructions + "\n")
+	}
+	return b.String()
+}
@@ -2,17 +2,63 @@ package swarmgo
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/prathyushnallamothu/swarmgo/llm"
 )
 
+// DefaultMaxTurns is the number of turns Run allows when maxTurns is not positive: one round
+// of tool calls and the model's follow-up. Runs that need more tool rounds opt in with a
+// higher limit.
+const DefaultMaxTurns = 2
+
 // Swarm represents the main structure
 type Swarm struct {
-	client llm.LLM
+	client   llm.LLM
+	provider llm.LLMProvider
+
+	mu               sync.Mutex
+	closed           bool                          // Set once Shutdown has been called
+	inflight         sync.WaitGroup                // Tracks in-flight runs
+	inflightCancels  map[uint64]context.CancelFunc // Cancels in-flight runs when draining times out
+	runSeq           uint64
+	shutdownHooks    []ShutdownHook
+	auditSink        AuditSink           // Receives audit records, if set
+	policy           *DataPolicy         // Restricts where requests may be sent, if set
+	endpoint         string              // Custom API host, empty for the provider's default
+	flagProvider     FlagProvider        // Evaluates feature flags, if set
+	idempotencyStore IdempotencyStore    // Replays results of tool calls already executed, if set
+	maxRunDepth      int                 // Maximum nesting depth of runs, DefaultMaxRunDepth if not positive
+	messages         Messages            // Built-in messages, DefaultMessages if nil
+	unknownTool      UnknownToolStrategy // Handling of calls to unknown tools
+	toolRoleMessages bool                // Answer tool calls with tool messages instead of assistant and function messages
+	metrics          MetricsExporter     // Receives completed turns, if set
+	streamTimeout    StreamTimeout       // Detection of stalled provider streams
+	quota            Quota               // Accounts usage per tenant, if set
+	quotaMode        QuotaMode           // Handling of runs of tenants over quota
+	compression      *PromptCompression  // Compression of old messages before sending, if set
+	monitor          *RunMonitor         // Observes the state of runs, if set
+	debugRedaction   *DebugRedaction     // Removed from debug output, DefaultDebugRedaction if nil
+	responses        llm.LLM             // Serves agents with hosted tools, if set
+	fileTTL          time.Duration       // Lifetime of uploaded files, unlimited if not positive
+	promptInspector  PromptInspector     // Sees and may edit the requests of debug runs, if set
+	sessionTitles    string              // Model generating session titles and summaries, if set
+	retention        *Retention          // Stores swept and erased on request, if set
+	stopRetention    func()              // Stops the retention sweeper, if running
+	regions          *regionRouter       // Regional endpoints serving requests instead of client, if set
+	idGenerator      IDGenerator         // Generates run, turn and session IDs, NewULID if nil
+
+	// Recovery from responses blocked by content filters
+	contentFilter ContentFilterFallback
+
+	// Wrappers applied to the client and to every region's client
+	clientWrappers []func(llm.LLM) llm.LLM
+
+	// Files uploaded through the swarm, by ID
+	uploads map[string]uploadedFile
 }
 
 // NewSwarm initializes a new Swarm instance with an LLM client
@@ -20,7 +66,8 @@ func NewSwarm(apiKey string, provider llm.LLMProvider) *Swarm {
 	if provider == llm.OpenAI {
 		client := llm.NewOpenAILLM(apiKey)
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
 		}
 	}
 	if provider == llm.Gemini {
@@ -29,14 +76,16 @@ func NewSwarm(apiKey string, provider llm.LLMProvider) *Swarm {
 			log.Fatalf("Failed to create Gemini client: %v", err)
 		}
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
 		}
 	}
 	if provider == llm.Claude {
 		client := llm.NewClaudeLLM(apiKey)
 
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
 		}
 	}
 	if provider == llm.Ollama {
@@ -45,13 +94,29 @@ func NewSwarm(apiKey string, provider llm.LLMProvider) *Swarm {
 			log.Fatalf("Failed to create Ollama client: %v", err)
 		}
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
 		}
 	}
 	if provider == llm.DeepSeek {
 		client := llm.NewDeepSeekLLM(apiKey)
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
+		}
+	}
+	if provider == llm.XAI {
+		client := llm.NewXAILLM(apiKey)
+		return &Swarm{
+			client:   client,
+			provider: provider,
+		}
+	}
+	if provider == llm.Cohere {
+		client := llm.NewCohereLLM(apiKey)
+		return &Swarm{
+			client:   client,
+			provider: provider,
 		}
 	}
 	return nil
@@ -61,12 +126,76 @@ func NewSwarmWithHost(apiKey, host string, provider llm.LLMProvider) *Swarm {
 	if provider == llm.OpenAI {
 		client := llm.NewOpenAILLMWithHost(apiKey, host)
 		return &Swarm{
-			client: client,
+			client:   client,
+			provider: provider,
+			endpoint: host,
 		}
 	}
 	return nil
 }
 
+// NewVertexSwarm creates a swarm using Google Vertex AI in the configured project and region,
+// authorized with a service account or Application Default Credentials
+func NewVertexSwarm(ctx context.Context, cfg llm.VertexConfig) (*Swarm, error) {
+	client, err := llm.NewVertexLLM(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Swarm{
+		client:   client,
+		provider: llm.Vertex,
+		endpoint: cfg.BaseURL(),
+	}, nil
+}
+
+// NewOpenAICompatibleSwarm creates a swarm using an OpenAI-compatible server, such as a
+// self-hosted vLLM, llama.cpp or LM Studio server, working around its quirks with the profile
+func NewOpenAICompatibleSwarm(apiKey, host string, profile llm.CompatProfile) *Swarm {
+	return &Swarm{
+		client:   llm.NewOpenAICompatibleLLM(apiKey, host, profile),
+		provider: llm.OpenAI,
+		endpoint: host,
+	}
+}
+
+// skipToolsKey marks the context of a run stopping at the first tool calls instead of
+// executing them
+type skipToolsKey struct{}
+
+// checkToolHistory validates that tool messages line up with their tool calls,
+// repairing the history first when the agent allows it. Runs skipping tools are not
+// validated, since their histories may hold the calls they stopped at unanswered.
+func checkToolHistory(ctx context.Context, agent *Agent, history []llm.Message) ([]llm.Message, error) {
+	if agent.RepairToolHistory {
+		history = llm.RepairToolMessages(history)
+	}
+	if skip, _ := ctx.Value(skipToolsKey{}).(bool); skip {
+		return history, nil
+	}
+	if err := llm.ValidateToolMessages(history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// requestMessages returns the messages to send the provider: sanitized for it, and without
+// the interrupted marks sessions keep for themselves
+func (s *Swarm) requestMessages(messages []llm.Message) []llm.Message {
+	messages = llm.SanitizeMessages(s.provider, messages)
+	copied := false
+	for i := range messages {
+		if !messages[i].Interrupted {
+			continue
+		}
+		if !copied {
+			messages = append([]llm.Message(nil), messages...)
+			copied = true
+		}
+		messages[i].Interrupted = false
+	}
+	return messages
+}
+
 // getChatCompletion requests a chat completion from the LLM
 func (s *Swarm) getChatCompletion(
 	ctx context.Context,
@@ -76,56 +205,77 @@ func (s *Swarm) getChatCompletion(
 	modelOverride string,
 	stream bool,
 	debug bool,
-) (llm.ChatCompletionResponse, error) {
-	// Prepare the initial system message with agent instructions
-	instructions := agent.Instructions
-	if agent.InstructionsFunc != nil {
-		instructions = agent.InstructionsFunc(contextVariables)
-	}
-	messages := append([]llm.Message{
-		{
-			Role:    llm.RoleSystem,
-			Content: instructions,
-		},
-	}, history...)
+) (llm.ChatCompletionRequest, llm.ChatCompletionResponse, error) {
+	history, err := checkToolHistory(ctx, agent, history)
+	if err != nil {
+		return llm.ChatCompletionRequest{}, llm.ChatCompletionResponse{}, err
+	}
 
-	// Build tool definitions from agent's functions
-	var tools []llm.Tool
-	for _, af := range agent.Functions {
-		def := FunctionToDefinition(af)
-		tools = append(tools, llm.Tool{
-			Type: "function",
-			Function: &llm.Function{
-				Name:        def.Name,
-				Description: def.Description,
-				Parameters:  def.Parameters,
-			},
-		})
+	if err := agent.checkSkills(contextVariables); err != nil {
+		return llm.ChatCompletionRequest{}, llm.ChatCompletionResponse{}, err
 	}
 
-	// Prepare the chat completion request
-	model := agent.Model
-	if modelOverride != "" {
-		model = modelOverride
+	model, err := agent.resolveModel(modelOverride)
+	if err != nil {
+		return llm.ChatCompletionRequest{}, llm.ChatCompletionResponse{}, err
 	}
 
+	// Only context variables allowed by the data policy are rendered into the instructions
+	exposed, err := s.enforcePolicy(agent, model, contextVariables)
+	if err != nil {
+		return llm.ChatCompletionRequest{}, llm.ChatCompletionResponse{}, err
+	}
+
+	// Prepare the initial system message with agent instructions, copying the history once
+	instructions := agent.runInstructions(ctx, exposed)
+	messages := make([]llm.Message, len(history)+1)
+	messages[0] = llm.Message{Role: llm.RoleSystem, Content: instructions}
+	copy(messages[1:], history)
+	messages, compressed := s.compressPrompt(messages)
+
+	// Build tool definitions from agent's functions
+	tools, err := agent.selectTools(ctx, agent.toolDefinitions(ctx, contextVariables), history)
+	if err != nil {
+		return llm.ChatCompletionRequest{}, llm.ChatCompletionResponse{}, err
+	}
+
+	// Prepare the chat completion request
 	req := llm.ChatCompletionRequest{
-		Model:    model,
-		Messages: messages,
-		Tools:    tools,
+		Model:       model,
+		Messages:    s.requestMessages(messages),
+		Tools:       tools,
+		KeepRaw:     agent.KeepRaw,
+		HostedTools: agent.HostedTools,
+		User:        requestUser(ctx),
 	}
+	applyRequestParams(ctx, &req)
 
 	if debug {
-		log.Printf("Getting chat completion for: %+v\n", messages)
+		log.Printf("%sGetting chat completion for: %s\n", logPrefix(ctx), s.redactDebug(agent, messages, contextVariables))
+	}
+
+	if err := s.inspectRequest(ctx, agent, &req, debug); err != nil {
+		return req, llm.ChatCompletionResponse{}, err
+	}
+	if err := s.auditRequest(ctx, agent, req); err != nil {
+		return req, llm.ChatCompletionResponse{}, err
 	}
 
 	// Call the LLM to get a chat completion
-	resp, err := s.client.CreateChatCompletion(ctx, req)
+	client, err := s.clientFor(agent)
+	if err != nil {
+		return req, llm.ChatCompletionResponse{}, err
+	}
+	resp, err := client.CreateChatCompletion(providerContext(ctx, agent), req)
 	if err != nil {
-		return llm.ChatCompletionResponse{}, err
+		return req, llm.ChatCompletionResponse{}, err
 	}
+	if resp, err = s.recoverFiltered(ctx, client, agent, req, resp); err != nil {
+		return req, llm.ChatCompletionResponse{}, err
+	}
+	resp.Usage = addUsage(resp.Usage, compressed)
 
-	return resp, nil
+	return req, resp, nil
 }
 
 // handleToolCall processes a tool call from the chat completion
@@ -134,60 +284,108 @@ func (s *Swarm) handleToolCall(
 	toolCall *llm.ToolCall,
 	agent *Agent,
 	contextVariables map[string]interface{},
+	prefetch *prefetcher,
 	debug bool,
 ) (Response, error) {
+	ctx = withToolCallID(ctx, toolCall.ID)
 	toolName := toolCall.Function.Name
 	argsJSON := toolCall.Function.Arguments
 
 	// First parse into a generic map
 	var argsMap map[string]interface{}
-	if err := json.Unmarshal([]byte(argsJSON), &argsMap); err != nil {
+	if err := decodeJSON(argsJSON, &argsMap); err != nil {
 		return Response{}, err
 	}
 
 	if debug {
-		log.Printf("Processing tool call: %s with arguments %v\n", toolName, argsMap)
+		log.Printf("%sProcessing tool call: %s with arguments %s\n", logPrefix(ctx), toolName, s.redactDebug(agent, argsMap, contextVariables))
 	}
 
 	// Find the corresponding function
 	var functionFound *AgentFunction[map[string]interface{}]
-	for _, af := range agent.Functions {
+	for _, af := range agent.allFunctions() {
 		if af.Name == toolName {
 			functionFound = &af
 			break
 		}
 	}
 
-	// Handle case where function is not found
-	if functionFound == nil {
-		errorMessage := fmt.Sprintf("Error: Tool %s not found.", toolName)
+	// Handle case where function is not found, or is behind a disabled feature flag
+	if functionFound == nil || !flagEnabled(ctx, functionFound.Flag) {
+		errorMessage := messagesFromContext(ctx).format(MessageToolNotFound, toolName)
+		if debug {
+			log.Println(errorMessage)
+		}
+		return s.unknownToolResponse(toolCall, errorMessage, argsMap)
+	}
+
+	// Block tools the caller may not use, even if the model names them
+	principal, _ := PrincipalFromContext(ctx)
+	if !authorized(principal, functionFound) {
+		errorMessage := messagesFromContext(ctx).format(MessageToolNotAuthorized, toolName)
 		if debug {
 			log.Println(errorMessage)
 		}
 		return Response{
-			Messages: []llm.Message{
-				{
-					Role:    llm.RoleAssistant,
-					Content: errorMessage,
-				},
-			},
+			Messages: []llm.Message{s.toolMessage(toolCall, errorMessage)},
 		}, nil
 	}
 
-	// Execute the function with the properly typed arguments
-	result := functionFound.executor(argsMap, contextVariables)
+	// Ask before running tools needing approval
+	if ok, err := approved(ctx, functionFound, *toolCall); err != nil {
+		return Response{}, err
+	} else if !ok {
+		errorMessage := messagesFromContext(ctx).format(MessageToolNotApproved, toolName)
+		if debug {
+			log.Println(errorMessage)
+		}
+		return Response{
+			Messages: []llm.Message{s.toolMessage(toolCall, errorMessage)},
+		}, nil
+	}
 
-	// Create a message with the tool result
-	toolResultMessage := llm.Message{
-		Role:    llm.RoleAssistant,
-		Content: fmt.Sprintf("%v", result.Data),
+	// Fill in context variables the model referred to by placeholder
+	argsMap, err := agent.expandArguments(argsMap, contextVariables)
+	if err != nil {
+		return Response{
+			Messages: []llm.Message{s.toolMessage(toolCall, messagesFromContext(ctx).format(MessageToolError, err))},
+		}, nil
+	}
+
+	if err := s.audit(ctx, AuditRecord{
+		Type:       AuditToolCall,
+		Agent:      agent.Name,
+		Tool:       toolName,
+		ToolCallID: toolCall.ID,
+		Arguments:  argsJSON,
+		Prefetched: prefetch.has(toolName, argsMap),
+	}); err != nil {
+		return Response{}, err
+	}
+
+	// Execute the function with the properly typed arguments, unless it was prefetched
+	result, prefetched := prefetch.take(ctx, toolName, argsMap)
+	if !prefetched {
+		result = s.executeIdempotent(ctx, functionFound, toolCall.ID, argsMap, contextVariables, debug)
+	} else if debug {
+		log.Printf("%sServing prefetched result for tool call: %s\n", logPrefix(ctx), toolName)
 	}
+	recordCompensation(ctx, functionFound, toolCall.ID, argsMap, result)
+
+	// Create a message with the tool result
+	toolResultMessage := s.toolMessage(toolCall, agent.guardToolOutput(toolName, resultContent(messagesFromContext(ctx), result), contextVariables))
 
 	// Return the partial response with the tool result and any agent transfer
 	partialResponse := Response{
 		Messages:         []llm.Message{toolResultMessage},
 		Agent:            result.Agent, // Use the agent from the result if provided
 		ContextVariables: contextVariables,
+		ToolResults: []ToolResult{{
+			ToolCallID: toolCall.ID,
+			ToolName:   toolName,
+			Args:       argsMap,
+			Result:     result,
+		}},
 	}
 
 	return partialResponse, nil
@@ -205,12 +403,101 @@ func (s *Swarm) Run(
 	maxTurns int,
 	executeTools bool,
 ) (Response, error) {
+	return s.RunWithOptions(ctx, agent, messages, RunOptions{
+		ContextVariables: contextVariables,
+		ModelOverride:    modelOverride,
+		Stream:           stream,
+		Debug:            debug,
+		MaxTurns:         maxTurns,
+		SkipTools:        !executeTools,
+	})
+}
+
+// RunWithOptions executes the chat interaction loop with the agent as configured by opts
+func (s *Swarm) RunWithOptions(ctx context.Context, agent *Agent, messages []llm.Message, opts RunOptions) (response Response, err error) {
+	contextVariables := opts.ContextVariables
+	modelOverride := opts.ModelOverride
+	stream := opts.Stream
+	debug := opts.Debug
+	maxTurns := opts.MaxTurns
+
+	ctx, done, err := s.beginRun(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+	defer done()
+	ctx, cancel := withRunTimeout(ctx, opts.Timeout)
+	defer cancel()
+	defer func() { err = runTimeoutError(ctx, opts.Timeout, err) }()
+	if opts.ServiceTier != "" {
+		ctx = withServiceTier(ctx, opts.ServiceTier)
+	}
+	if opts.Residency != "" {
+		ctx = WithResidency(ctx, opts.Residency)
+	}
+	if len(opts.Headers) > 0 || len(opts.QueryParams) > 0 {
+		ctx = withRequestExtras(ctx, opts.Headers, opts.QueryParams)
+	}
+	if len(opts.GatewayMetadata) > 0 {
+		ctx = llm.WithGatewayMetadata(ctx, opts.GatewayMetadata)
+	}
+	if opts.RunID != "" {
+		ctx = context.WithValue(ctx, runIDKey{}, opts.RunID)
+	}
+	monitored := s.monitorRun(ctx, agent, messages)
+	defer func() { monitored.end(response, err) }()
+	ctx = withProgressListener(ctx, opts.OnToolProgress)
+	ctx = s.withMessages(ctx)
+	if len(opts.Stop) > 0 || opts.Grammar != nil {
+		ctx = withRequestParams(ctx, requestParams{stop: opts.Stop, grammar: opts.Grammar})
+	}
+	if opts.InstructionsPrefix != "" || opts.InstructionsSuffix != "" {
+		ctx = context.WithValue(ctx, instructionOverridesKey{}, instructionOverrides{prefix: opts.InstructionsPrefix, suffix: opts.InstructionsSuffix})
+	}
+	if opts.Tenant != "" {
+		ctx = WithTenant(ctx, opts.Tenant)
+	}
+	if opts.SkipTools {
+		ctx = context.WithValue(ctx, skipToolsKey{}, true)
+	}
+	maxTokens, err := s.admitTenant(ctx)
+	if err != nil {
+		return Response{}, err
+	}
+	if opts.MaxTokens > 0 && (maxTokens <= 0 || opts.MaxTokens < maxTokens) {
+		maxTokens = opts.MaxTokens
+	}
+	ctx, budget, err := s.enterRun(ctx, maxTokens, opts.BudgetShare)
+	if err != nil {
+		return Response{}, err
+	}
+	ctx, variants := withVariantAssignments(ctx, opts.PromptVariant, opts.ExperimentKey)
+
+	if err := s.audit(ctx, AuditRecord{Type: AuditRunStart, Agent: agent.Name, ContextKeys: contextKeys(contextVariables)}); err != nil {
+		return Response{}, err
+	}
+	defer func() { s.auditRunEnd(ctx, agent, err) }()
+
+	// Undo the tool calls of a failed run, before the failure is audited
+	if opts.CompensateOnError {
+		if sagaFromContext(ctx) == nil {
+			ctx = WithSaga(ctx, NewSaga())
+		}
+		defer func() {
+			if err != nil {
+				err = compensateRun(ctx, err)
+			}
+		}()
+	}
+
 	activeAgent := agent
-	history := make([]llm.Message, len(messages))
-	copy(history, messages)
+	// The history shares the caller's messages and grows in place without recopying them
+	history := NewHistory(messages...)
 	if contextVariables == nil {
 		contextVariables = make(map[string]interface{})
 	}
+	defer delete(contextVariables, scratchpadKey)
+	ctx, flags := s.withFlags(ctx, contextVariables)
 
 	// Initialize memory if not already initialized
 	if activeAgent.Memory == nil {
@@ -218,104 +505,208 @@ func (s *Swarm) Run(
 	}
 
 	initLen := len(messages)
-	turns := 0
+	if maxTurns <= 0 {
+		maxTurns = DefaultMaxTurns
+	}
 
 	// Store initial user message as memory if it exists
 	if len(messages) > 0 && messages[len(messages)-1].Role == llm.RoleUser {
-		activeAgent.Memory.AddMemory(Memory{
+		memory := Memory{
 			Content:   messages[len(messages)-1].Content,
 			Timestamp: time.Now(),
-		})
+		}
+		// Tag the memory with the user it belongs to, so DeleteUserData can erase it
+		if principal, ok := PrincipalFromContext(ctx); ok && principal.ID != "" {
+			memory.Context = map[string]interface{}{UserIDKey: principal.ID}
+		}
+		activeAgent.Memory.AddMemory(memory)
 	}
 
-	// Get chat completion from LLM
-	resp, err := s.getChatCompletion(ctx, activeAgent, history, contextVariables, modelOverride, stream, debug)
-	if err != nil {
-		return Response{}, err
-	}
+	var turns []Turn
+	var toolResults []ToolResult
+	var plan []llm.ToolCall
+	var usage llm.Usage
+	var compaction *Compaction
+	// Set once the model answers without requesting tools, before the turns run out
+	var answered bool
+
+	// Turns taken by the active agent since it took over
+	agentTurns := 0
+
+	for len(turns) < maxTurns {
+		turn := Turn{
+			ID:        s.newID(),
+			Index:     len(turns),
+			AgentName: activeAgent.Name,
+			StartTime: time.Now(),
+		}
+		turnCtx := withTurnID(ctx, turn.ID)
+		requestCtx := turnCtx
+		if choice := turnToolChoice(opts, activeAgent, len(turns), agentTurns); choice != nil {
+			requestCtx = withToolChoice(turnCtx, choice)
+		}
+		agentTurns++
 
-	// Process the response
-	if len(resp.Choices) == 0 {
-		return Response{}, fmt.Errorf("no choices in response")
-	}
+		// Start predicted tool calls while the model generates
+		conversation := compactedView(history, compaction)
+		var prefetch *prefetcher
+		if !opts.DryRun {
+			prefetch = s.startPrefetch(ctx, activeAgent, conversation, contextVariables)
+		}
+
+		// Get chat completion from LLM
+		monitored.setStatus(RunAwaitingModel, activeAgent.Name)
+		var req llm.ChatCompletionRequest
+		var resp llm.ChatCompletionResponse
+		if opts.BestOfN > 1 {
+			req, resp, turn.Candidates, err = s.bestOfN(requestCtx, activeAgent, conversation, contextVariables, opts)
+		} else if opts.Choices > 1 {
+			req, resp, turn.Candidates, err = s.multiChoice(requestCtx, activeAgent, conversation, contextVariables, opts)
+		} else {
+			req, resp, err = s.getChatCompletion(requestCtx, activeAgent, conversation, contextVariables, modelOverride, stream, debug)
+		}
+		if err != nil {
+			return Response{}, err
+		}
+		turn.Latency = time.Since(turn.StartTime)
+		turn.Metrics.Provider = turn.Latency
+		turn.Metrics.CompletionTokens = resp.Usage.CompletionTokens
+		if len(turns) == 0 {
+			turn.Metrics.Queue = queueLatencyFromContext(ctx)
+		}
 
-	choice := resp.Choices[0]
+		// Process the response
+		if len(resp.Choices) == 0 {
+			return Response{}, fmt.Errorf("no choices in response")
+		}
+		if opts.MaxContinuations > 0 {
+			resp, turn.Continuations, err = s.continueTruncated(requestCtx, activeAgent, conversation, contextVariables, modelOverride, stream, debug, resp, opts.MaxContinuations)
+			if err != nil {
+				return Response{}, err
+			}
+		}
 
-	// Check for tool calls
-	if len(choice.Message.ToolCalls) > 0 && executeTools {
-		var toolResponses []Response
-		var toolResults []ToolResult
-		// Add the assistant's message with tool calls
-		history = append(history, choice.Message)
+		choice := resp.Choices[0]
+		turn.RequestHash = hashRequest(req)
+		turn.Model = req.Model
+		turn.PromptVariant = variants.name(activeAgent)
+		turn.Message = choice.Message
+		turn.FinishReason = choice.FinishReason
+		turn.Usage = resp.Usage
+		usage = addUsage(usage, resp.Usage)
+		s.recordQuota(ctx, req.Model, resp.Usage)
+		if err := budget.charge(resp.Usage.TotalTokens); err != nil {
+			return Response{}, err
+		}
+
+		if err := checkOutput(activeAgent.OutputFilters, choice.Message.Content); err != nil {
+			return Response{}, err
+		}
+
+		// Add the assistant's message to history
+		history = history.Append(choice.Message)
+
+		// Stop once the model answers without requesting tools
+		if len(choice.Message.ToolCalls) == 0 || opts.SkipTools {
+			answered = true
+			turn.EndTime = time.Now()
+			turns = append(turns, turn)
+			s.exportTurn(turnCtx, turn)
+			monitored.addTurn(turn)
+			break
+		}
+
+		monitored.setStatus(RunExecutingTools, activeAgent.Name)
+		toolStart := time.Now()
 
 		for _, toolCall := range choice.Message.ToolCalls {
-			toolResp, err := s.handleToolCall(ctx, &toolCall, activeAgent, contextVariables, debug)
+			var toolResp Response
+			if opts.DryRun {
+				plan = append(plan, toolCall)
+				toolResp, err = s.dryRunToolCall(turnCtx, &toolCall, activeAgent, opts)
+			} else {
+				toolResp, err = s.handleToolCall(turnCtx, &toolCall, activeAgent, contextVariables, prefetch, debug)
+			}
 			if err != nil {
 				return Response{}, err
 			}
-			toolResponses = append(toolResponses, toolResp)
 
 			// Create ToolResult entry
-			var args interface{}
-			_ = json.Unmarshal([]byte(toolCall.Function.Arguments), &args)
-			toolResults = append(toolResults, ToolResult{
-				ToolName: toolCall.Function.Name,
-				Args:     args,
-				Result: Result{
-					Success: true,
-					Data:    toolResp.Messages[0].Content,
-					Error:   nil,
-					Agent:   toolResp.Agent,
-				},
-			})
-
-			// Add the tool response as a function message
-			history = append(history, llm.Message{
-				Role:    llm.RoleFunction,
-				Content: toolResp.Messages[0].Content,
-				Name:    toolCall.Function.Name,
-			})
+			if len(toolResp.ToolResults) > 0 {
+				turn.ToolResults = append(turn.ToolResults, toolResp.ToolResults...)
+			} else {
+				var args interface{}
+				_ = decodeJSON(toolCall.Function.Arguments, &args)
+				turn.ToolResults = append(turn.ToolResults, ToolResult{
+					ToolCallID: toolCall.ID,
+					ToolName:   toolCall.Function.Name,
+					Args:       args,
+					Result: Result{
+						Success: false,
+						Data:    toolResp.Messages[0].Content,
+						Agent:   toolResp.Agent,
+					},
+				})
+			}
+
+			// Add the tool response as a function message, or as the tool message it already is
+			toolMessage := llm.Message{
+				Role:       llm.RoleFunction,
+				Content:    toolResp.Messages[0].Content,
+				Name:       toolCall.Function.Name,
+				ToolCallID: toolCall.ID,
+			}
+			if toolResp.Messages[0].Role == llm.RoleTool {
+				toolMessage.Role = llm.RoleTool
+			}
+			history = history.Append(toolMessage)
 			// Update the active agent if the tool result includes an agent transfer
 			if toolResp.Agent != nil {
 				activeAgent = toolResp.Agent
+				agentTurns = 0
 			}
 		}
-		turns++
-
-		// Get a follow-up response from the AI after tool execution
-		followUpResp, err := s.getChatCompletion(ctx, activeAgent, history, contextVariables, modelOverride, stream, debug)
-		if err != nil {
-			return Response{}, err
-		}
 
-		followUpChoice := followUpResp.Choices[0]
-		// Don't process tool calls in the follow-up response to avoid loops
-		if len(followUpChoice.Message.ToolCalls) > 0 {
-			// Create a new message without the tool calls
-			followUpChoice.Message.ToolCalls = nil
-		}
-		if followUpChoice.Message.Content != "" {
-			history = append(history, followUpChoice.Message)
+		// Compact the conversation if the model asked to
+		if request, ok := compactionRequested(turn.ToolResults); ok {
+			compacted, used, err := s.compactHistory(ctx, activeAgent, history, compaction, request)
+			if err != nil {
+				return Response{}, err
+			}
+			usage = addUsage(usage, used)
+			s.recordQuota(ctx, turn.Model, used)
+			if err := budget.charge(used.TotalTokens); err != nil {
+				return Response{}, err
+			}
+			compaction = compacted
 		}
 
-		// Return response with all messages including the follow-up
-		return Response{
-			Messages:         history[initLen:],
-			Agent:            activeAgent,
-			ContextVariables: contextVariables,
-			ToolResults:      toolResults,
-		}, nil
-	} else {
-		// Add the assistant's message to history
-		history = append(history, choice.Message)
+		turn.EndTime = time.Now()
+		turn.Metrics.Tools = turn.EndTime.Sub(toolStart)
+		toolResults = append(toolResults, turn.ToolResults...)
+		turns = append(turns, turn)
+		s.exportTurn(turnCtx, turn)
+		monitored.addTurn(turn)
+	}
 
-		// Return final response only if there are no tool calls
-		finalResponse := Response{
-			Messages:         history[initLen:],
-			Agent:            activeAgent,
-			ContextVariables: contextVariables,
-			ToolResults:      nil, // No tool calls were made
-		}
-		return finalResponse, nil
+	var metrics LatencyMetrics
+	for _, turn := range turns {
+		metrics = metrics.add(turn.Metrics)
 	}
+
+	return Response{
+		Messages:         stripScratchpad(history.Since(initLen)),
+		Agent:            activeAgent,
+		ContextVariables: contextVariables,
+		ToolResults:      stripScratchpadResults(toolResults),
+		Turns:            turns,
+		Usage:            usage,
+		Metrics:          metrics,
+		Compaction:       compaction,
+		Plan:             plan,
+		PromptVariants:   variants.byAgent(),
+		Flags:            flags.snapshot(),
+		Tasks:            TasksFromContext(contextVariables),
+		MaxTurnsExceeded: !answered,
+	}, nil
 }
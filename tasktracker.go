@@ -0,0 +1,96 @@
+package swarmgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TasksKey is the context variable holding the task list of the built-in task tracker
+const TasksKey = "tasks"
+
+// TrackedTask is an item on the task list the model keeps with the task tracker
+type TrackedTask struct {
+	ID          int        `json:"id"`
+	Description string     `json:"description"`
+	Status      TaskStatus `json:"status"`
+}
+
+// taskTrackerInstructions explains the task tracker tools to the model
+const taskTrackerInstructions = `For requests that take several steps, keep a task list: add each step with add_task before starting, and mark it done with complete_task as soon as it is finished.`
+
+// TaskTrackerSkill returns a skill that lets the model keep a task list with the add_task
+// and complete_task tools. The list is kept in the TasksKey context variable, so it is
+// returned on Response.Tasks and persisted with the session.
+func TaskTrackerSkill() *Skill {
+	addTask, _ := NewAgentFunction("add_task", "Add a task to the task list", func(args struct {
+		Description string `json:"description" jsonschema:"required,description=What needs to be done"`
+	}, contextVariables map[string]interface{}) Result {
+		if strings.TrimSpace(args.Description) == "" {
+			return Result{Success: false, Error: fmt.Errorf("description is required")}
+		}
+		tasks := TasksFromContext(contextVariables)
+		task := TrackedTask{ID: len(tasks) + 1, Description: args.Description, Status: TaskPending}
+		contextVariables[TasksKey] = append(tasks, task)
+		return Result{Success: true, Data: fmt.Sprintf("Added task %d.\n%s", task.ID, formatTrackedTasks(contextVariables))}
+	})
+
+	completeTask, _ := NewAgentFunction("complete_task", "Mark a task on the task list as completed", func(args struct {
+		ID int `json:"id" jsonschema:"required,description=ID of the completed task"`
+	}, contextVariables map[string]interface{}) Result {
+		tasks := TasksFromContext(contextVariables)
+		if args.ID < 1 || args.ID > len(tasks) {
+			return Result{Success: false, Error: fmt.Errorf("no task with ID %d", args.ID)}
+		}
+		tasks[args.ID-1].Status = TaskCompleted
+		contextVariables[TasksKey] = tasks
+		return Result{Success: true, Data: fmt.Sprintf("Completed task %d.\n%s", args.ID, formatTrackedTasks(contextVariables))}
+	})
+
+	return NewSkill("Task tracking", taskTrackerInstructions, addTask, completeTask).
+		WithDescription("Keeps a task list for multi-step requests")
+}
+
+// WithTaskTracking lets the agent keep a task list with the built-in task tracker
+func (a *Agent) WithTaskTracking() *Agent {
+	return a.WithSkills(TaskTrackerSkill())
+}
+
+// TasksFromContext returns a copy of the task list kept in the context variables, including
+// lists restored from JSON
+func TasksFromContext(contextVariables map[string]interface{}) []TrackedTask {
+	switch tasks := contextVariables[TasksKey].(type) {
+	case nil:
+		return nil
+	case []TrackedTask:
+		return append([]TrackedTask(nil), tasks...)
+	default:
+		// Restored sessions hold the list as generic JSON values
+		data, err := json.Marshal(tasks)
+		if err != nil {
+			return nil
+		}
+		var decoded []TrackedTask
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			return nil
+		}
+		return decoded
+	}
+}
+
+// Tasks returns the session's task list
+func (s *Session) Tasks() []TrackedTask {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return TasksFromContext(s.ContextVariables)
+}
+
+// formatTrackedTasks renders the task list for the model
+func formatTrackedTasks(contextVariables map[string]interface{}) string {
+	var b strings.Builder
+	b.WriteString("Task list:")
+	for _, task := range TasksFromContext(contextVariables) {
+		fmt.Fprintf(&b, "\n%d. [%s] %s", task.ID, task.Status, task.Description)
+	}
+	return b.String()
+}
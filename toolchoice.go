@@ -0,0 +1,42 @@
+package swarmgo
+
+import (
+	"context"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// WithToolChoice sets whether the agent may, must or must not call tools on its first turn of
+// a run, including when it takes over from another agent, e.g. llm.ForceTool("classify") to
+// always classify first. Later turns let the model decide.
+func (a *Agent) WithToolChoice(choice *llm.ToolChoice) *Agent {
+	a.ToolChoice = choice
+	return a
+}
+
+type toolChoiceKey struct{}
+
+// withToolChoice returns a context whose requests are sent with the tool choice
+func withToolChoice(ctx context.Context, choice *llm.ToolChoice) context.Context {
+	return context.WithValue(ctx, toolChoiceKey{}, choice)
+}
+
+// turnToolChoice returns the tool choice of a turn: RunOptions.ToolChoice on the run's first
+// turn, and otherwise the agent's tool choice on its first turn. It returns nil for turns
+// where the model decides.
+func turnToolChoice(opts RunOptions, agent *Agent, runTurn, agentTurn int) *llm.ToolChoice {
+	if runTurn == 0 && opts.ToolChoice != nil {
+		return opts.ToolChoice
+	}
+	if agentTurn == 0 {
+		return agent.ToolChoice
+	}
+	return nil
+}
+
+// applyToolChoice sets the tool choice of the context on a request that offers tools
+func applyToolChoice(ctx context.Context, req *llm.ChatCompletionRequest) {
+	if choice, ok := ctx.Value(toolChoiceKey{}).(*llm.ToolChoice); ok && choice != nil && len(req.Tools) > 0 {
+		req.ToolChoice = choice
+	}
+}
@@ -0,0 +1,53 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"runtime/debug"
+)
+
+// ToolExecutionError describes a tool that failed by panicking instead of returning a Result
+type ToolExecutionError struct {
+	ToolName string      // Name of the tool that panicked
+	Panic    interface{} // Value passed to panic
+	Stack    string      // Stack trace captured at the point of recovery
+}
+
+// Error implements the error interface
+func (e *ToolExecutionError) Error() string {
+	return fmt.Sprintf("tool %s panicked: %v", e.ToolName, e.Panic)
+}
+
+// executeFunction runs an agent function, converting a panic into a failed Result
+func executeFunction(ctx context.Context, fn *AgentFunction[map[string]interface{}], args map[string]interface{}, contextVariables map[string]interface{}, debugMode bool) (result Result) {
+	defer func() {
+		if r := recover(); r != nil {
+			execErr := &ToolExecutionError{
+				ToolName: fn.Name,
+				Panic:    r,
+				Stack:    string(debug.Stack()),
+			}
+			if debugMode {
+				log.Printf("Recovered from panic in tool %s: %v\n%s", fn.Name, r, execErr.Stack)
+			}
+			result = Result{
+				Success: false,
+				Error:   execErr,
+			}
+		}
+	}()
+
+	if fn.contextExecutor != nil {
+		return fn.contextExecutor(ctx, args, contextVariables)
+	}
+	return fn.executor(args, contextVariables)
+}
+
+// resultContent renders a tool Result as the content fed back to the model
+func resultContent(messages Messages, result Result) string {
+	if result.Error != nil {
+		return messages.format(MessageToolError, result.Error)
+	}
+	return fmt.Sprintf("%v", result.Data)
+}
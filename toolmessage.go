@@ -0,0 +1,37 @@
+package swarmgo
+
+import "github.com/prathyushnallamothu/swarmgo/llm"
+
+// WithToolRoleMessages makes the swarm answer tool calls with tool-role messages carrying the
+// ID of the call they answer, as the OpenAI API requires, in both the messages handleToolCall
+// returns and the run's history. Without it the swarm keeps its legacy behavior: tool results
+// and errors are assistant-role messages, recorded in the history as function-role messages.
+func (s *Swarm) WithToolRoleMessages(enabled bool) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.toolRoleMessages = enabled
+	return s
+}
+
+// toolRole returns the role of the messages recording tool results in the history
+func (s *Swarm) toolRole() llm.Role {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.toolRoleMessages {
+		return llm.RoleTool
+	}
+	return llm.RoleFunction
+}
+
+// toolMessage answers a tool call with the content
+func (s *Swarm) toolMessage(toolCall *llm.ToolCall, content string) llm.Message {
+	if s.toolRole() == llm.RoleFunction {
+		return llm.Message{Role: llm.RoleAssistant, Content: content}
+	}
+	return llm.Message{
+		Role:       llm.RoleTool,
+		Content:    content,
+		Name:       toolCall.Function.Name,
+		ToolCallID: toolCall.ID,
+	}
+}
@@ -0,0 +1,52 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrNoToolCall is returned by CallTools when the model answers without calling a tool
+var ErrNoToolCall = errors.New("model did not call a tool")
+
+// CallToolsOptions configures a CallTools request
+type CallToolsOptions struct {
+	Model            string                 // Model the request is sent to
+	Instructions     string                 // System instructions, e.g. how to route commands
+	ContextVariables map[string]interface{} // Variables passed to the instructions and tools
+	// ToolChoice controls the tool calls, llm.ToolChoiceRequired if nil so the model always
+	// calls a tool
+	ToolChoice *llm.ToolChoice
+}
+
+// CallTools sends the prompt with the tools for exactly one model turn, executes the tool
+// calls the model makes and returns their results, without continuing the conversation. It
+// suits routing commands to handlers. The run applies the swarm's policies, auditing and
+// quotas like any other. It fails with ErrNoToolCall if the model calls no tool.
+func CallTools(ctx context.Context, sw *Swarm, tools []AgentFunction[map[string]interface{}], prompt string, opts CallToolsOptions) ([]ToolResult, error) {
+	agent := NewAgent("ToolRouter", opts.Model, sw.provider).WithFunctions(tools...)
+	agent.Instructions = opts.Instructions
+
+	toolChoice := opts.ToolChoice
+	if toolChoice == nil {
+		toolChoice = llm.NewToolChoice(llm.ToolChoiceRequired)
+	}
+	resp, err := sw.RunWithOptions(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: prompt}}, RunOptions{
+		ContextVariables: opts.ContextVariables,
+		MaxTurns:         1,
+		ToolChoice:       toolChoice,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.ToolResults) == 0 {
+		var content string
+		if len(resp.Messages) > 0 {
+			content = resp.Messages[len(resp.Messages)-1].Content
+		}
+		return nil, fmt.Errorf("%w: %q", ErrNoToolCall, content)
+	}
+	return resp.ToolResults, nil
+}
@@ -0,0 +1,143 @@
+package swarmgo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// DefaultToolSelectionK is the number of tools a ToolSelector offers when it does not set one
+const DefaultToolSelectionK = 8
+
+// ToolSelector offers the model only the tools most relevant to the latest user message, by
+// embedding similarity, so agents with many tools send small requests and the model chooses
+// among fewer tools. Tool embeddings are computed once and cached. It is safe for concurrent use.
+type ToolSelector struct {
+	Embed  EmbedFunc // Embeds tool descriptions and user messages
+	K      int       // Tools offered per request, DefaultToolSelectionK if not positive
+	Always []string  // Tools offered on every request, in addition to the K selected
+
+	mu         sync.Mutex
+	embeddings map[string][]float64 // Tool embeddings by name and description
+}
+
+// NewToolSelector creates a selector offering the k tools most similar to the user's request
+func NewToolSelector(embed EmbedFunc, k int) *ToolSelector {
+	return &ToolSelector{Embed: embed, K: k}
+}
+
+// WithToolSelector offers the model only the tools the selector picks for each request. It
+// applies after the agent's tool filter.
+func (a *Agent) WithToolSelector(selector *ToolSelector) *Agent {
+	a.ToolSelector = selector
+	return a
+}
+
+// Select returns the tools most relevant to the query, in their original order. All tools are
+// returned if there are no more than K or the query is empty.
+func (t *ToolSelector) Select(ctx context.Context, tools []llm.Tool, query string) ([]llm.Tool, error) {
+	k := t.K
+	if k <= 0 {
+		k = DefaultToolSelectionK
+	}
+	if len(tools) <= k || query == "" {
+		return tools, nil
+	}
+
+	queryEmbedding, err := t.Embed(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed request: %w", err)
+	}
+
+	always := make(map[string]bool, len(t.Always))
+	for _, name := range t.Always {
+		always[name] = true
+	}
+	scores := make([]float64, len(tools))
+	var candidates []int
+	for i, tool := range tools {
+		if tool.Function == nil || always[tool.Function.Name] {
+			continue
+		}
+		embedding, err := t.toolEmbedding(ctx, tool.Function)
+		if err != nil {
+			return nil, err
+		}
+		scores[i] = cosineSimilarity(queryEmbedding, embedding)
+		candidates = append(candidates, i)
+	}
+	sort.SliceStable(candidates, func(a, b int) bool { return scores[candidates[a]] > scores[candidates[b]] })
+
+	selected := make(map[int]bool, k)
+	for _, i := range candidates[:min(k, len(candidates))] {
+		selected[i] = true
+	}
+	picked := make([]llm.Tool, 0, k+len(always))
+	for i, tool := range tools {
+		if selected[i] || tool.Function == nil || always[tool.Function.Name] {
+			picked = append(picked, tool)
+		}
+	}
+	return picked, nil
+}
+
+// toolEmbedding returns the embedding of the function's name and description
+func (t *ToolSelector) toolEmbedding(ctx context.Context, fn *llm.Function) ([]float64, error) {
+	text := fn.Name + ": " + fn.Description
+	t.mu.Lock()
+	embedding, ok := t.embeddings[text]
+	t.mu.Unlock()
+	if ok {
+		return embedding, nil
+	}
+
+	embedding, err := t.Embed(ctx, text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed tool %s: %w", fn.Name, err)
+	}
+	t.mu.Lock()
+	if t.embeddings == nil {
+		t.embeddings = make(map[string][]float64)
+	}
+	t.embeddings[text] = embedding
+	t.mu.Unlock()
+	return embedding, nil
+}
+
+// selectTools narrows the tools of a request to those the agent's selector picks for the
+// latest user message
+func (a *Agent) selectTools(ctx context.Context, tools []llm.Tool, messages []llm.Message) ([]llm.Tool, error) {
+	if a.ToolSelector == nil || len(tools) == 0 {
+		return tools, nil
+	}
+	var query string
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == llm.RoleUser {
+			query = messages[i].Content
+			break
+		}
+	}
+	return a.ToolSelector.Select(ctx, tools, query)
+}
+
+// cosineSimilarity returns the cosine similarity of two vectors, zero if either is zero or
+// their dimensions differ
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
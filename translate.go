@@ -0,0 +1,210 @@
+package swarmgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// detectLanguageInstructions tells the detection model how to answer
+const detectLanguageInstructions = `Identify the language the user's text is written in, ignoring code, URLs and names. Give its ISO 639-1 code, its English name, and your confidence as a number from 0 to 1.`
+
+// translateInstructions tells the translation model how to translate
+const translateInstructions = `Translate the user's text into %s. Preserve the markdown formatting exactly: headings, lists, tables, emphasis, links and line breaks. Leave placeholders of the form %s unchanged and in place. Do not translate URLs. Give the ISO 639-1 code of the source language.`
+
+// codePlaceholder is the placeholder a code span or block is replaced with while translating
+const codePlaceholder = "[[CODE_%d]]"
+
+// codePattern matches fenced code blocks and inline code spans, which are never translated
+var codePattern = regexp.MustCompile("(?s)```.*?```|~~~.*?~~~|`[^`\n]+`")
+
+// LanguageDetection is the language DetectLanguage identified
+type LanguageDetection struct {
+	Language   string    `json:"language"`   // ISO 639-1 code, e.g. "fr"
+	Name       string    `json:"name"`       // English name, e.g. "French"
+	Confidence float64   `json:"confidence"` // From 0 to 1
+	Usage      llm.Usage `json:"-"`
+}
+
+// Translation is the result of Translate
+type Translation struct {
+	Text           string
+	SourceLanguage string // ISO 639-1 code of the detected or given source language
+	TargetLanguage string
+	Usage          llm.Usage
+}
+
+// TranslateOptions configures a translation
+type TranslateOptions struct {
+	SourceLanguage string            // Language of the text, detected if empty
+	Glossary       map[string]string // Required translations of terms
+	Instructions   string            // Additional guidance, such as the register to use
+	ChunkSize      int               // Size in characters above which text is translated in chunks, DefaultChunkSize if zero
+	Concurrency    int               // Maximum number of chunks translated at once, unlimited if not positive
+	Retries        int               // Corrections of invalid replies per chunk, DefaultStructuredRetries if zero
+}
+
+// DetectLanguage identifies the language of the text
+func DetectLanguage(ctx context.Context, sw *Swarm, model, text string) (LanguageDetection, error) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"language":   map[string]interface{}{"type": "string"},
+			"name":       map[string]interface{}{"type": "string"},
+			"confidence": map[string]interface{}{"type": "number", "minimum": 0, "maximum": 1},
+		},
+		"required":             []string{"language", "name", "confidence"},
+		"additionalProperties": false,
+	}
+	// Code says nothing about the language of the prose around it
+	masked, _ := maskCode(text)
+	agent := NewAgent("LanguageDetector", model, sw.provider).WithInstructions(detectLanguageInstructions)
+	var result LanguageDetection
+	usage, err := sw.runStructured(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: masked}}, schema, &result, func() error {
+		result.Language = strings.ToLower(strings.TrimSpace(result.Language))
+		if result.Language == "" {
+			return errors.New("no language code")
+		}
+		result.Confidence = min(max(result.Confidence, 0), 1)
+		return nil
+	}, DefaultStructuredRetries)
+	result.Usage = usage
+	if err != nil {
+		return result, fmt.Errorf("failed to detect language: %w", err)
+	}
+	return result, nil
+}
+
+// Translate translates text into the target language, given as a name or ISO 639-1 code,
+// keeping its markdown formatting and leaving code blocks and inline code untouched
+func Translate(ctx context.Context, sw *Swarm, model, text, target string) (Translation, error) {
+	return TranslateWithOptions(ctx, sw, model, text, target, TranslateOptions{})
+}
+
+// TranslateWithOptions translates text into the target language as configured by opts
+func TranslateWithOptions(ctx context.Context, sw *Swarm, model, text, target string, opts TranslateOptions) (Translation, error) {
+	result := Translation{SourceLanguage: opts.SourceLanguage, TargetLanguage: target}
+	masked, code := maskCode(text)
+	chunks := ChunkText(masked, opts.ChunkSize, 0)
+	if len(chunks) == 0 {
+		return result, errors.New("no text to translate")
+	}
+
+	instructions := fmt.Sprintf(translateInstructions, target, fmt.Sprintf(codePlaceholder, 0))
+	if opts.SourceLanguage != "" {
+		instructions += "\nThe text is in " + opts.SourceLanguage + "."
+	}
+	if len(opts.Glossary) > 0 {
+		instructions += "\nAlways translate these terms as given:"
+		terms := make([]string, 0, len(opts.Glossary))
+		for term := range opts.Glossary {
+			terms = append(terms, term)
+		}
+		sort.Strings(terms)
+		for _, term := range terms {
+			instructions += fmt.Sprintf("\n- %s: %s", term, opts.Glossary[term])
+		}
+	}
+	if opts.Instructions != "" {
+		instructions += "\n" + opts.Instructions
+	}
+	retries := opts.Retries
+	if retries == 0 {
+		retries = DefaultStructuredRetries
+	}
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"translation":     map[string]interface{}{"type": "string"},
+			"source_language": map[string]interface{}{"type": "string"},
+		},
+		"required":             []string{"translation", "source_language"},
+		"additionalProperties": false,
+	}
+
+	type part struct {
+		Translation    string `json:"translation"`
+		SourceLanguage string `json:"source_language"`
+	}
+	parts := make([]part, len(chunks))
+	usages := make([]llm.Usage, len(chunks))
+	errs := make([]error, len(chunks))
+	limit := opts.Concurrency
+	if limit <= 0 {
+		limit = len(chunks)
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			placeholders := placeholdersIn(chunk, len(code))
+			agent := NewAgent("Translator", model, sw.provider).WithInstructions(instructions)
+			usages[i], errs[i] = sw.runStructured(ctx, agent, []llm.Message{{Role: llm.RoleUser, Content: chunk}}, schema, &parts[i], func() error {
+				for _, placeholder := range placeholders {
+					if strings.Count(parts[i].Translation, placeholder) != 1 {
+						return fmt.Errorf("placeholder %s must appear exactly once", placeholder)
+					}
+				}
+				return nil
+			}, retries)
+		}(i, chunk)
+	}
+	wg.Wait()
+
+	var b strings.Builder
+	for i, chunk := range chunks {
+		result.Usage = addUsage(result.Usage, usages[i])
+		if errs[i] != nil {
+			return result, fmt.Errorf("failed to translate chunk %d of %d: %w", i+1, len(chunks), errs[i])
+		}
+		// Models trim the whitespace chunks were split at, which joins them back together
+		b.WriteString(strings.TrimSpace(parts[i].Translation))
+		b.WriteString(chunk[len(strings.TrimRight(chunk, " \t\r\n")):])
+		if result.SourceLanguage == "" {
+			result.SourceLanguage = strings.ToLower(strings.TrimSpace(parts[i].SourceLanguage))
+		}
+	}
+	result.Text = unmaskCode(b.String(), code)
+	return result, nil
+}
+
+// maskCode replaces the code blocks and inline code of markdown text with numbered
+// placeholders, returning the masked text and the code by placeholder number
+func maskCode(text string) (string, []string) {
+	var code []string
+	masked := codePattern.ReplaceAllStringFunc(text, func(match string) string {
+		code = append(code, match)
+		return fmt.Sprintf(codePlaceholder, len(code)-1)
+	})
+	return masked, code
+}
+
+// unmaskCode puts the code maskCode took out back in place of its placeholders
+func unmaskCode(text string, code []string) string {
+	for i := len(code) - 1; i >= 0; i-- {
+		text = strings.Replace(text, fmt.Sprintf(codePlaceholder, i), code[i], 1)
+	}
+	return text
+}
+
+// placeholdersIn returns the code placeholders in a chunk of masked text
+func placeholdersIn(chunk string, n int) []string {
+	var placeholders []string
+	for i := 0; i < n; i++ {
+		if placeholder := fmt.Sprintf(codePlaceholder, i); strings.Contains(chunk, placeholder) {
+			placeholders = append(placeholders, placeholder)
+		}
+	}
+	return placeholders
+}
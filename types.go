@@ -1,6 +1,11 @@
 package swarmgo
 
 import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/prathyushnallamothu/swarmgo/llm"
 )
 
@@ -9,14 +14,47 @@ type Response struct {
 	Messages         []llm.Message
 	Agent            *Agent
 	ContextVariables map[string]interface{}
-	ToolResults      []ToolResult // Results from tool calls
+	ToolResults      []ToolResult      // Results from tool calls
+	Turns            []Turn            // Breakdown of each model turn in the run
+	Usage            llm.Usage         // Token usage summed over all turns
+	Metrics          LatencyMetrics    // Latency breakdown summed over all turns
+	Compaction       *Compaction       // Compaction of the conversation requested by the model, if any
+	Plan             []llm.ToolCall    // Tool calls a dry run would have executed
+	PromptVariants   map[string]string // Prompt variant served to each agent, by agent name
+	Flags            map[string]bool   // Feature flags evaluated during the run
+	Tasks            []TrackedTask     // Task list kept with the task tracker, if enabled
+	// MaxTurnsExceeded is set when the run used all its turns without the model answering:
+	// the last turn's tool calls were executed, but the model never saw their results, so
+	// Messages ends with them rather than with a final assistant message
+	MaxTurnsExceeded bool
+}
+
+// Turn represents a single model request and the tool calls it triggered
+type Turn struct {
+	ID            string         // Correlation ID of the turn, sent to the provider with its requests
+	Index         int            // Position of the turn within the run
+	AgentName     string         // Agent that was active for the turn
+	Model         string         // Model the request was sent to
+	PromptVariant string         // Prompt variant the agent served, if it has variants
+	RequestHash   string         // Hash of the request snapshot sent to the provider
+	Message       llm.Message    // Message returned by the model
+	FinishReason  string         // Finish reason reported by the provider
+	ToolResults   []ToolResult   // Results of the tool calls requested in Message
+	Candidates    []Candidate    // Completions sampled for the turn with best-of-N or multiple choices
+	Continuations int            // Requests made to continue the message after it was cut off
+	Usage         llm.Usage      // Token usage of the request
+	Latency       time.Duration  // Time spent waiting for the provider
+	Metrics       LatencyMetrics // Latency breakdown of the turn
+	StartTime     time.Time
+	EndTime       time.Time
 }
 
 // ToolResult represents the result of a tool call
 type ToolResult struct {
-	ToolName string      // Name of the tool that was called
-	Args     interface{} // Arguments passed to the tool
-	Result   Result      // Result returned by the tool
+	ToolCallID string      // ID of the tool call this result answers
+	ToolName   string      // Name of the tool that was called
+	Args       interface{} // Arguments passed to the tool
+	Result     Result      // Result returned by the tool
 }
 
 // Result represents the result of a function execution
@@ -26,3 +64,25 @@ type Result struct {
 	Error   error       // Any error that occurred during execution
 	Agent   *Agent      // Active agent
 }
+
+// hashRequest returns a stable hash of a request snapshot
+func hashRequest(req llm.ChatCompletionRequest) string {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return ""
+	}
+	hash := sha256.Sum256(data)
+	return fmt.Sprintf("%x", hash[:])
+}
+
+// addUsage sums two usage records
+func addUsage(a, b llm.Usage) llm.Usage {
+	return llm.Usage{
+		PromptTokens:     a.PromptTokens + b.PromptTokens,
+		CompletionTokens: a.CompletionTokens + b.CompletionTokens,
+		TotalTokens:      a.TotalTokens + b.TotalTokens,
+		OriginalTokens:   a.OriginalTokens + b.OriginalTokens,
+		CompressedTokens: a.CompressedTokens + b.CompressedTokens,
+		Cost:             a.Cost + b.Cost,
+	}
+}
@@ -0,0 +1,69 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrUnknownTool is returned when the model calls a tool the agent does not have and the
+// swarm is configured to fail the run
+var ErrUnknownTool = errors.New("unknown tool")
+
+// UnknownToolStrategy determines how the swarm handles calls to tools the agent does not have,
+// including tools behind a disabled feature flag
+type UnknownToolStrategy int
+
+const (
+	// UnknownToolReply answers the call with the MessageToolNotFound message like any tool result
+	UnknownToolReply UnknownToolStrategy = iota
+	// UnknownToolResult answers the call with an error result paired to its call ID, for
+	// providers that require every tool call to have a matching result
+	UnknownToolResult
+	// UnknownToolFail fails the run with ErrUnknownTool
+	UnknownToolFail
+)
+
+// WithUnknownToolStrategy sets how the swarm handles calls to unknown tools. The default is
+// UnknownToolReply.
+func (s *Swarm) WithUnknownToolStrategy(strategy UnknownToolStrategy) *Swarm {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.unknownTool = strategy
+	return s
+}
+
+// unknownToolStrategy returns how the swarm handles calls to unknown tools
+func (s *Swarm) unknownToolStrategy() UnknownToolStrategy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unknownTool
+}
+
+// unknownToolResponse answers a call to an unknown tool according to the swarm's strategy
+func (s *Swarm) unknownToolResponse(toolCall *llm.ToolCall, content string, args map[string]interface{}) (Response, error) {
+	switch s.unknownToolStrategy() {
+	case UnknownToolFail:
+		return Response{}, fmt.Errorf("%w: %s", ErrUnknownTool, toolCall.Function.Name)
+	case UnknownToolResult:
+		return Response{
+			Messages: []llm.Message{{
+				Role:       s.toolRole(),
+				Content:    content,
+				Name:       toolCall.Function.Name,
+				ToolCallID: toolCall.ID,
+			}},
+			ToolResults: []ToolResult{{
+				ToolCallID: toolCall.ID,
+				ToolName:   toolCall.Function.Name,
+				Args:       args,
+				Result:     Result{Success: false, Error: fmt.Errorf("%w: %s", ErrUnknownTool, toolCall.Function.Name)},
+			}},
+		}, nil
+	default:
+		return Response{
+			Messages: []llm.Message{s.toolMessage(toolCall, content)},
+		}, nil
+	}
+}
@@ -0,0 +1,209 @@
+package swarmgo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/prathyushnallamothu/swarmgo/llm"
+)
+
+// ErrUnknownProvider is returned for providers swarms cannot be created for
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// ErrInvalidSchemaTag is returned for tool argument types with malformed jsonschema tags
+var ErrInvalidSchemaTag = errors.New("invalid jsonschema tag")
+
+// swarmProviders are the providers NewSwarm creates clients for
+var swarmProviders = map[llm.LLMProvider]bool{
+	llm.OpenAI:   true,
+	llm.Gemini:   true,
+	llm.Claude:   true,
+	llm.Ollama:   true,
+	llm.DeepSeek: true,
+	llm.XAI:      true,
+	llm.Cohere:   true,
+}
+
+// knownProviders are the providers agents may name
+var knownProviders = map[llm.LLMProvider]bool{
+	llm.OpenAI:          true,
+	llm.Azure:           true,
+	llm.AzureAD:         true,
+	llm.CloudflareAzure: true,
+	llm.Gemini:          true,
+	llm.Claude:          true,
+	llm.Ollama:          true,
+	llm.DeepSeek:        true,
+	llm.XAI:             true,
+	llm.Cohere:          true,
+	llm.Vertex:          true,
+}
+
+// NewValidatedSwarm creates a swarm like NewSwarm, but reports an unknown provider, a missing
+// API key or a client that cannot be created as an error instead of returning nil or exiting
+func NewValidatedSwarm(apiKey string, provider llm.LLMProvider) (*Swarm, error) {
+	var errs []error
+	if !swarmProviders[provider] {
+		supported := make([]string, 0, len(swarmProviders))
+		for p := range swarmProviders {
+			supported = append(supported, string(p))
+		}
+		sort.Strings(supported)
+		errs = append(errs, fmt.Errorf("%w %q, use one of %s", ErrUnknownProvider, provider, strings.Join(supported, ", ")))
+	}
+	if apiKey == "" && provider != llm.Ollama {
+		errs = append(errs, fmt.Errorf("missing API key for %s", provider))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid swarm configuration: %w", errors.Join(errs...))
+	}
+
+	switch provider {
+	case llm.Gemini:
+		client, err := llm.NewGeminiLLM(apiKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		}
+		return &Swarm{client: client, provider: provider}, nil
+	case llm.Ollama:
+		client, err := llm.NewOllamaLLM()
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Ollama client: %w", err)
+		}
+		return &Swarm{client: client, provider: provider}, nil
+	default:
+		return NewSwarm(apiKey, provider), nil
+	}
+}
+
+// Validate checks the swarm and the agents it will run before any run starts, reporting every
+// problem found together
+func (s *Swarm) Validate(agents ...*Agent) error {
+	s.mu.Lock()
+	client := s.client
+	responses := s.responses
+	s.mu.Unlock()
+
+	var errs []error
+	if client == nil {
+		errs = append(errs, errors.New("swarm has no LLM client"))
+	}
+	names := make(map[string]bool)
+	for i, agent := range agents {
+		if agent == nil {
+			errs = append(errs, fmt.Errorf("agent %d is nil", i))
+			continue
+		}
+		if agent.Name != "" && names[agent.Name] {
+			errs = append(errs, fmt.Errorf("agent name %q is used more than once", agent.Name))
+		}
+		names[agent.Name] = true
+		if err := agent.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if len(agent.HostedTools) > 0 && responses == nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", agent.Name, ErrNoResponsesBackend))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+// schemaTagKeys are the keys jsonschema tags may use
+var schemaTagKeys = map[string]bool{
+	"required": true, "nullable": true, "omitempty": true, "title": true, "description": true,
+	"type": true, "anchor": true, "default": true, "example": true, "enum": true, "format": true,
+	"pattern": true, "minimum": true, "maximum": true, "exclusiveMinimum": true,
+	"exclusiveMaximum": true, "multipleOf": true, "minLength": true, "maxLength": true,
+	"minItems": true, "maxItems": true, "uniqueItems": true, "readOnly": true, "writeOnly": true,
+	"oneof_required": true, "anyof_required": true, "oneof_ref": true, "oneof_type": true,
+	"anyof_ref": true, "anyof_type": true, "dependencies": true, "patternProperties": true,
+}
+
+// numericSchemaTagKeys are the tag keys whose values must be numbers
+var numericSchemaTagKeys = map[string]bool{
+	"minimum": true, "maximum": true, "exclusiveMinimum": true, "exclusiveMaximum": true,
+	"multipleOf": true, "minLength": true, "maxLength": true, "minItems": true, "maxItems": true,
+}
+
+// checkSchemaTags reports the malformed jsonschema tags of a tool argument type and of the
+// types of its fields, which the schema generator would otherwise silently ignore
+func checkSchemaTags(typ reflect.Type) error {
+	var errs []error
+	checkFieldTags(typ, "", make(map[reflect.Type]bool), &errs)
+	if len(errs) > 0 {
+		return fmt.Errorf("%w in %s: %w", ErrInvalidSchemaTag, typ, errors.Join(errs...))
+	}
+	return nil
+}
+
+// checkFieldTags checks the tags of the fields of a struct type, recursively
+func checkFieldTags(typ reflect.Type, path string, seen map[reflect.Type]bool, errs *[]error) {
+	for typ.Kind() == reflect.Pointer || typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := path + field.Name
+		tag, ok := field.Tag.Lookup("jsonschema")
+		if ok && tag != "-" {
+			values := make(map[string]string)
+			for _, part := range splitSchemaTag(tag) {
+				key, value, _ := strings.Cut(part, "=")
+				switch {
+				case part == "":
+				case !schemaTagKeys[key]:
+					*errs = append(*errs, fmt.Errorf("field %s: unknown key %q", name, key))
+				case numericSchemaTagKeys[key]:
+					if _, err := strconv.ParseFloat(value, 64); err != nil {
+						*errs = append(*errs, fmt.Errorf("field %s: %s=%q is not a number", name, key, value))
+					}
+					values[key] = value
+				case key == "pattern":
+					if _, err := regexp.Compile(value); err != nil {
+						*errs = append(*errs, fmt.Errorf("field %s: pattern %q does not compile: %v", name, value, err))
+					}
+				case key == "enum" && value == "":
+					*errs = append(*errs, fmt.Errorf("field %s: enum needs a value", name))
+				}
+			}
+			for _, bounds := range [][2]string{{"minimum", "maximum"}, {"minLength", "maxLength"}, {"minItems", "maxItems"}} {
+				low, errLow := strconv.ParseFloat(values[bounds[0]], 64)
+				high, errHigh := strconv.ParseFloat(values[bounds[1]], 64)
+				if errLow == nil && errHigh == nil && low > high {
+					*errs = append(*errs, fmt.Errorf("field %s: %s %v is greater than %s %v", name, bounds[0], low, bounds[1], high))
+				}
+			}
+		}
+		checkFieldTags(field.Type, name+".", seen, errs)
+	}
+}
+
+// splitSchemaTag splits a jsonschema tag into its parts, which like for the schema generator
+// may contain commas escaped with a backslash
+func splitSchemaTag(tag string) []string {
+	var parts []string
+	for _, part := range strings.Split(tag, ",") {
+		if n := len(parts); n > 0 && strings.HasSuffix(parts[n-1], "\\") {
+			parts[n-1] = strings.TrimSuffix(parts[n-1], "\\") + "," + part
+			continue
+		}
+		parts = append(parts, part)
+	}
+	return parts
+}